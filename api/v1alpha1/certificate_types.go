@@ -18,6 +18,7 @@ package v1alpha1
 
 import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 // CertificateSpec defines the desired state of a Certificate.
@@ -26,8 +27,64 @@ type CertificateSpec struct {
 	CertificateData CertificateData `json:"certificateData,omitempty"`
 	// SecretName is the name of the Kubernetes Secret where the extracted certificate is stored.
 	SecretName string `json:"secretName,omitempty"`
+	// SecretNameTemplate is a Go template rendered against the Certificate to derive the name of the Secret,
+	// e.g. "{{.Spec.CertificateData.Subject.CommonName}}-tls". It takes precedence over SecretName.
+	SecretNameTemplate string `json:"secretNameTemplate,omitempty"`
 	// ConfigRef is the referance to the CertificateConfig associated with this Certificate.
+	// If it is omitted, the CertificateConfig named by the cert.dana.io/default-config annotation of the namespace
+	// is used, or else the CertificateConfig labeled cert.dana.io/default: "true".
 	ConfigRef ConfigReference `json:"configRef,omitempty"`
+	// AdditionalFormats specifies additional Secrets in which the certificate is stored in other formats.
+	AdditionalFormats []SecretFormat `json:"additionalFormats,omitempty"`
+	// CombinedPEM specifies whether the Secret also stores the certificate, CA certificates and private key
+	// concatenated under a single tls.pem key.
+	CombinedPEM bool `json:"combinedPEM,omitempty"`
+	// AdoptExisting specifies whether existing Secrets which are not owned by this Certificate are adopted.
+	// If false, the Certificate refuses to overwrite such Secrets.
+	AdoptExisting bool `json:"adoptExisting,omitempty"`
+	// StorePrivateKey specifies whether the private key is stored in the Secret. If false, only the certificate
+	// is stored, in a Secret of type Opaque.
+	// +kubebuilder:default:=true
+	StorePrivateKey *bool `json:"storePrivateKey,omitempty"`
+	// ImmutableSecret specifies whether the Secret is created immutable, to prevent external tampering.
+	// Since immutable Secrets cannot be updated, the Secret is deleted and recreated when the certificate is renewed.
+	ImmutableSecret bool `json:"immutableSecret,omitempty"`
+	// CAKey is the key of the Secret holding the PEM encoded CA certificates, for consumers which expect another
+	// key than ca.crt, e.g. chain.pem. It must not be tls.crt, tls.key or tls.pem.
+	// +kubebuilder:validation:Pattern=`^[-._a-zA-Z0-9]+$`
+	// +kubebuilder:validation:MaxLength=253
+	// +kubebuilder:default:="ca.crt"
+	CAKey string `json:"caKey,omitempty"`
+	// SetOwnerReference specifies whether the Secrets of the Certificate are owned by it, and are therefore
+	// garbage collected when it is deleted. If false, the Secrets are labeled with the name of the Certificate
+	// instead, and the DeletionPolicy is applied to them through a finalizer: with Delete, they are deleted by the
+	// operator before the Certificate is, and with Orphan, they are left in place.
+	// +kubebuilder:default:=true
+	SetOwnerReference *bool `json:"setOwnerReference,omitempty"`
+	// PublishToConfigMap is the name of a ConfigMap in the namespace of the Certificate in which the certificate and
+	// CA certificates are also published, for consumers which cannot read Secrets. The private key is never published.
+	PublishToConfigMap string `json:"publishToConfigMap,omitempty"`
+	// Encoding is the encoding of the certificate, private key and CA certificates in the Secret, one of pem or der.
+	// DER encoded data is stored under cert.der, key.der and ca.der in a Secret of type Opaque, and CAKey is ignored.
+	// +kubebuilder:validation:Enum=pem;der
+	// +kubebuilder:default:=pem
+	Encoding string `json:"encoding,omitempty"`
+	// DeletionPolicy is what happens to the Secrets and ConfigMap of the Certificate when it is deleted, one of
+	// Delete or Orphan. With Delete, they are garbage collected along with the Certificate, or deleted by the operator
+	// if SetOwnerReference is false. With Orphan, the owner references of the Certificate are removed from them before
+	// it is deleted, so that they survive it.
+	// +kubebuilder:validation:Enum=Delete;Orphan
+	// +kubebuilder:default:=Delete
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+// SecretFormat specifies an additional Secret in which the certificate is stored in a given format.
+type SecretFormat struct {
+	// Format is the format in which the certificate is stored in the Secret.
+	// +kubebuilder:validation:Enum=pem;pkcs12;jks
+	Format string `json:"format"`
+	// SecretName is the name of the Secret.
+	SecretName string `json:"secretName"`
 }
 
 // A ConfigReference is a reference to a CertificateConfig resource that will be used
@@ -51,8 +108,77 @@ type CertificateStatus struct {
 	Guid string `json:"guid,omitempty"`
 	// SignatureHashAlgorithm is the algorithm used to sign the certificate.
 	SignatureHashAlgorithm string `json:"signatureHashAlgorithm,omitempty"`
+	// SecretName is the name of the Secret where the certificate is stored.
+	SecretName string `json:"secretName,omitempty"`
+	// Fingerprint is the SHA-256 fingerprint of the certificate, formatted as colon-separated hex.
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// KeyUsages are the key usages of the certificate, e.g. "digital signature", to verify that the CA honored the
+	// requested usages.
+	KeyUsages []string `json:"keyUsages,omitempty"`
+	// ExtendedKeyUsages are the extended key usages of the certificate, e.g. "server auth". Extended key usages
+	// unknown to the operator are listed by their OID.
+	ExtendedKeyUsages []string `json:"extendedKeyUsages,omitempty"`
+	// CAMetadata holds the fields of the cert API responses listed in the CAMetadataFields of the CertificateConfig.
+	CAMetadata map[string]string `json:"caMetadata,omitempty"`
+	// DebugRawResponse is the last response of the Cert API to a get or download request, with its password and
+	// certificate data redacted and truncated. It is only set when the operator runs with the --debug-store-responses flag.
+	DebugRawResponse string `json:"debugRawResponse,omitempty"`
+	// ConfigName is the name of the CertificateConfig of the Certificate, as resolved from its ConfigRef or from the
+	// default CertificateConfig when it has no ConfigRef.
+	ConfigName string `json:"configName,omitempty"`
+	// ConfigUID is the UID of the CertificateConfig the Certificate was last reconciled with. A change means that the
+	// CertificateConfig was deleted and recreated.
+	ConfigUID types.UID `json:"configUID,omitempty"`
+	// ConfigSecretResourceVersion is the resourceVersion of the Secret referenced by the CertificateConfig, holding
+	// the credentials of the Cert client the Certificate was last reconciled with.
+	ConfigSecretResourceVersion string `json:"configSecretResourceVersion,omitempty"`
+	// LastReconcileTime is the time at which the Certificate was last reconciled successfully. Successful reconciles
+	// which change no other field of the status only advance it once it is older than a few minutes, so that
+	// reconciling a Certificate in its steady state does not write to it every time.
+	LastReconcileTime metav1.Time `json:"lastReconcileTime,omitempty"`
+	// LastError is the message of the most recent failure to reconcile the Certificate, kept until it is reconciled
+	// successfully, unlike the conditions which are overwritten by later steps.
+	LastError string `json:"lastError,omitempty"`
+	// LastErrorTime is the time at which the most recent failure to reconcile the Certificate first occurred. It is
+	// kept while the Certificate keeps failing with the same error.
+	LastErrorTime metav1.Time `json:"lastErrorTime,omitempty"`
+	// DownloadFailures is the number of consecutive failures to poll or download the certificate of the Guid.
+	DownloadFailures int32 `json:"downloadFailures,omitempty"`
+	// GUIDIssuedTime is the time at which the certificate of the Guid was created, from which the Guid is cleared
+	// if its certificate keeps failing to be polled or downloaded.
+	GUIDIssuedTime metav1.Time `json:"guidIssuedTime,omitempty"`
+	// GUIDResets is the number of times the Guid was cleared after repeated download failures, so that a new
+	// certificate is created, since a certificate was last downloaded.
+	GUIDResets int32 `json:"guidResets,omitempty"`
+	// RenewalFailures is the number of consecutive failed attempts to renew the certificate while it is still valid,
+	// reset once the Certificate is reconciled successfully. Failures within a renewal attempt interval of the last
+	// counted failure are part of the same attempt, and are not counted.
+	RenewalFailures int32 `json:"renewalFailures,omitempty"`
+	// LastRenewalFailureTime is the time of the last failure counted in RenewalFailures.
+	LastRenewalFailureTime metav1.Time `json:"lastRenewalFailureTime,omitempty"`
 }
 
+const (
+	// KeyAlgorithmRSA is the KeyAlgorithm of RSA private keys.
+	KeyAlgorithmRSA = "RSA"
+	// KeyAlgorithmECDSA is the KeyAlgorithm of ECDSA private keys.
+	KeyAlgorithmECDSA = "ECDSA"
+)
+
+const (
+	// EncodingPEM is the Encoding of PEM encoded Secret data.
+	EncodingPEM = "pem"
+	// EncodingDER is the Encoding of DER encoded Secret data.
+	EncodingDER = "der"
+)
+
+const (
+	// DeletionPolicyDelete is the DeletionPolicy garbage collecting the Secrets of a deleted Certificate.
+	DeletionPolicyDelete = "Delete"
+	// DeletionPolicyOrphan is the DeletionPolicy leaving the Secrets of a deleted Certificate in place.
+	DeletionPolicyOrphan = "Orphan"
+)
+
 // CertificateData contains data for generating a Certificate.
 type CertificateData struct {
 	// Subject represents the subject of the certificate.
@@ -61,10 +187,35 @@ type CertificateData struct {
 	San San `json:"san,omitempty"`
 	// Template is an optional field specifying the template for the certificate.
 	Template string `json:"template,omitempty"`
+	// Issuer is an optional field specifying the issuing authority from which the certificate is requested,
+	// for Cert APIs hosting multiple authorities. It is distinct from the Issuer reported in the status.
+	Issuer string `json:"issuer,omitempty"`
+	// KeyAlgorithm is an optional field specifying the algorithm of the private key generated by the CA.
+	// The CA default is used if it is empty.
+	// +kubebuilder:validation:Enum=RSA;ECDSA
+	KeyAlgorithm string `json:"keyAlgorithm,omitempty"`
+	// KeySize is an optional field specifying the size in bits of RSA private keys, one of 2048, 3072 or 4096.
+	KeySize int `json:"keySize,omitempty"`
+	// KeyCurve is an optional field specifying the curve of ECDSA private keys, one of P-256 or P-384.
+	// +kubebuilder:validation:Enum=P-256;P-384
+	KeyCurve string `json:"keyCurve,omitempty"`
 	// Form is an optional field specifying the format of the certificate.
 	// +kubebuilder:default:="pfx"
 	// +kubebuilder:validation:Enum=pfx;
 	Form string `json:"form,omitempty"`
+	// Extensions are custom X.509 extensions requested for the certificate. The CA must support passing them through.
+	Extensions []CustomExtension `json:"extensions,omitempty"`
+}
+
+// CustomExtension is a custom X.509 extension of a certificate.
+type CustomExtension struct {
+	// OID is the object identifier of the extension in dotted notation, e.g. 1.3.6.1.4.1.311.20.2.
+	// +kubebuilder:validation:Pattern=`^[0-2](\.[0-9]+)+$`
+	OID string `json:"oid"`
+	// Value is the base64-encoded DER value of the extension.
+	Value string `json:"value"`
+	// Critical specifies whether the extension is marked critical.
+	Critical bool `json:"critical,omitempty"`
 }
 
 // Subject represents the subject of a Certificate.
@@ -76,6 +227,8 @@ type Subject struct {
 	Locality           string `json:"locality,omitempty"`
 	Organization       string `json:"organization,omitempty"`
 	OrganizationalUnit string `json:"organizationUnit,omitempty"`
+	// Email is the email address of the subject, for client and email certificates.
+	Email string `json:"email,omitempty"`
 }
 
 // San represents Subject Alternative Names of a Certificate.
@@ -83,11 +236,16 @@ type San struct {
 	// DNS represents the DNS names included in the certificate.
 	DNS []string `json:"dns,omitempty"`
 	// IPs represents the IP addresses included in the certificate.
+	// Every entry must be a single IPv4 or IPv6 address, CIDRs are not accepted.
 	IPs []string `json:"ips,omitempty"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Synced",type=string,JSONPath=`.status.conditions[?(@.type=="Synced")].status`
+//+kubebuilder:printcolumn:name="Reason",type=string,JSONPath=`.status.conditions[?(@.type=="Synced")].reason`
+//+kubebuilder:printcolumn:name="Last Reconcile",type=date,JSONPath=`.status.lastReconcileTime`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Certificate is the Schema for the certificates API.
 type Certificate struct {
@@ -17,19 +17,154 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// PasswordEncodingPlain is the PasswordEncoding of plaintext passwords.
+	PasswordEncodingPlain = "plain"
+	// PasswordEncodingBase64 is the PasswordEncoding of base64-encoded passwords.
+	PasswordEncodingBase64 = "base64"
+	// PasswordEncodingHex is the PasswordEncoding of hex-encoded passwords.
+	PasswordEncodingHex = "hex"
+)
+
+const (
+	// TLSVersion12 is the MinTLSVersion of TLS 1.2.
+	TLSVersion12 = "1.2"
+	// TLSVersion13 is the MinTLSVersion of TLS 1.3.
+	TLSVersion13 = "1.3"
+)
+
+const (
+	// TLSRenegotiationNever is the TLSRenegotiation refusing renegotiation.
+	TLSRenegotiationNever = "Never"
+	// TLSRenegotiationOnceAsClient is the TLSRenegotiation allowing the server to renegotiate once per connection.
+	TLSRenegotiationOnceAsClient = "OnceAsClient"
+	// TLSRenegotiationFreelyAsClient is the TLSRenegotiation allowing the server to renegotiate repeatedly.
+	TLSRenegotiationFreelyAsClient = "FreelyAsClient"
+)
+
 // CertificateConfigSpec defines the desired state of CertificateConfig.
 type CertificateConfigSpec struct {
 	// SecretRef is a reference to the Kubernetes Secret containing credentials for authenticating with the cert API.
 	SecretRef SecretRef `json:"secretRef"`
+	// APIEndpoint is the endpoint of the cert API. When set, it takes precedence over the apiEndpoint in the
+	// credentials of the Secret, which then only needs to hold the token.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	APIEndpoint string `json:"apiEndpoint,omitempty"`
+	// DownloadEndpoint is the path of the download endpoint of the cert API. When set, it takes precedence over the
+	// downloadEndpoint in the credentials of the Secret.
+	DownloadEndpoint string `json:"downloadEndpoint,omitempty"`
 	// DaysBeforeRenewal represents the number of days to renew the certificate before expiration.
 	DaysBeforeRenewal int `json:"daysBeforeRenewal"`
+	// RenewBeforePercent is the percentage of the validity of the certificate remaining at which it is renewed,
+	// e.g. 33 to renew certificates once two thirds of their validity elapsed. When set, it takes precedence over
+	// DaysBeforeRenewal, so that the renewal adapts to the validity of every certificate.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=99
+	RenewBeforePercent *int `json:"renewBeforePercent,omitempty"`
 	// WaitTimeout specifies the maximum time duration for waiting for response from cert.
+	// It is clamped to the maximum wait timeout of the operator.
 	WaitTimeout *metav1.Duration `json:"waitTimeout,omitempty"`
 	// ForceExpirationUpdate indicates whether to force an update of the Certificate details even when it's valid.
 	ForceExpirationUpdate bool `json:"forceExpirationUpdate,omitempty"`
+	// ResponsePath is the dot-separated path of the JSON object wrapping the responses of the cert API, e.g. "data".
+	// Responses are read from the root of the JSON document if it is empty.
+	ResponsePath string `json:"responsePath,omitempty"`
+	// ErrorField is the dot-separated path of a field of the responses of the cert API, from the root of the JSON
+	// document, e.g. "error", which signals a failed request when it is set, for cert APIs which respond with a
+	// successful status code to failed requests. Values of null, false and the empty string are not failures.
+	ErrorField string `json:"errorField,omitempty"`
+	// SuccessField is the dot-separated path of a boolean field of the responses of the cert API, from the root of
+	// the JSON document, e.g. "success", which signals a failed request when it is false.
+	SuccessField string `json:"successField,omitempty"`
+	// MaxResponseSize is the maximum size of a response body from the cert API. Defaults to 10Mi.
+	MaxResponseSize *resource.Quantity `json:"maxResponseSize,omitempty"`
+	// TemplatePolicies restrict the templates which Certificates using this CertificateConfig may request,
+	// per namespace. Namespaces which are not matched by any policy may request any template.
+	TemplatePolicies []TemplatePolicy `json:"templatePolicies,omitempty"`
+	// NotificationURL is an optional URL to which a JSON event is POSTed whenever a Certificate using this
+	// CertificateConfig is issued, renewed or fails to be issued. Failing to notify does not fail the reconcile.
+	// +kubebuilder:validation:Pattern=`^https?://`
+	NotificationURL string `json:"notificationURL,omitempty"`
+	// IdempotencyKeyHeader is the name of the header carrying the idempotency key sent when creating certificates,
+	// which lets the cert API deduplicate retried requests. Defaults to "Idempotency-Key".
+	IdempotencyKeyHeader string `json:"idempotencyKeyHeader,omitempty"`
+	// CAMetadataFields lists the fields of the get and download responses of the cert API which are copied
+	// to the CAMetadata of the status of Certificates, such as a policy ID or a profile.
+	CAMetadataFields []string `json:"caMetadataFields,omitempty"`
+	// AcceptLanguage is the value of the Accept-Language header sent to the cert API, e.g. "en-US", for cert APIs
+	// which localize their responses. The header is not sent if it is empty.
+	AcceptLanguage string `json:"acceptLanguage,omitempty"`
+	// MaxSANEntries is the maximum number of SAN entries, DNS names and IPs combined, a Certificate may request.
+	// Certificates exceeding it are not sent to the cert API. Defaults to 250.
+	// +kubebuilder:validation:Minimum=1
+	MaxSANEntries *int32 `json:"maxSANEntries,omitempty"`
+	// PasswordSecretRef is a reference to a key of a Secret holding the password of the PKCS#12 data downloaded
+	// from the cert API, for cert APIs which expect the client to supply it. The password returned by the cert API
+	// is used if it is not set.
+	PasswordSecretRef *SecretKeyRef `json:"passwordSecretRef,omitempty"`
+	// PasswordEncoding is the encoding of the PKCS#12 password returned by the cert API, one of plain, base64 or hex.
+	// It does not apply to the password referenced by PasswordSecretRef.
+	// +kubebuilder:validation:Enum=plain;base64;hex
+	// +kubebuilder:default:="plain"
+	PasswordEncoding string `json:"passwordEncoding,omitempty"`
+	// RequireChain fails the download of certificates whose PKCS#12 data holds no CA certificates, instead of only
+	// setting the ChainMissing condition of the Certificate.
+	RequireChain bool `json:"requireChain,omitempty"`
+	// VerifyChain specifies whether the downloaded certificates are verified to chain to the CA certificates
+	// downloaded with them. Certificates failing verification are not written to their Secrets.
+	VerifyChain bool `json:"verifyChain,omitempty"`
+	// FollowRedirects specifies whether redirects of the cert API are followed. If false, redirect responses fail
+	// with an error holding their location, e.g. of the login page of an authenticating proxy.
+	// +kubebuilder:default:=true
+	FollowRedirects *bool `json:"followRedirects,omitempty"`
+	// DefaultSubject holds the subject fields, e.g. the country and organization, requested for the Certificates
+	// using the CertificateConfig which leave them empty.
+	DefaultSubject Subject `json:"defaultSubject,omitempty"`
+	// MinTLSVersion is the minimum TLS version of the connections to the cert API, one of 1.2 or 1.3.
+	// +kubebuilder:validation:Enum="1.2";"1.3"
+	// +kubebuilder:default:="1.2"
+	MinTLSVersion string `json:"minTLSVersion,omitempty"`
+	// DisableHTTP2 disables HTTP/2 for the connections to the cert API, which then use HTTP/1.1 only, for legacy
+	// gateways which break with HTTP/2.
+	DisableHTTP2 bool `json:"disableHTTP2,omitempty"`
+	// TLSRenegotiation is the TLS renegotiation support of the connections to the cert API, one of Never,
+	// OnceAsClient or FreelyAsClient, for gateways requesting client certificates after the handshake.
+	// +kubebuilder:validation:Enum=Never;OnceAsClient;FreelyAsClient
+	// +kubebuilder:default:="Never"
+	TLSRenegotiation string `json:"tlsRenegotiation,omitempty"`
+	// Methods overrides the HTTP methods of the requests sent to the cert API, for cert APIs which, for example,
+	// expect certificates to be retrieved with a POST request.
+	Methods HTTPMethods `json:"methods,omitempty"`
+}
+
+// HTTPMethods specifies the HTTP methods of the requests sent to the cert API, per operation.
+type HTTPMethods struct {
+	// Post is the method of the requests creating certificates. Defaults to POST.
+	// +kubebuilder:validation:Enum=POST;PUT
+	Post string `json:"post,omitempty"`
+	// Get is the method of the requests getting the data of certificates. Defaults to GET.
+	// When it is POST, the guid of the certificate is also sent in the request body.
+	// +kubebuilder:validation:Enum=GET;POST
+	Get string `json:"get,omitempty"`
+	// Download is the method of the requests downloading certificates. Defaults to GET.
+	// When it is POST, the guid and form of the certificate are also sent in the request body.
+	// +kubebuilder:validation:Enum=GET;POST
+	Download string `json:"download,omitempty"`
+}
+
+// TemplatePolicy specifies the templates allowed in the namespaces it matches.
+// A namespace is matched if it is listed in Namespaces or if its labels match the NamespaceSelector.
+type TemplatePolicy struct {
+	// Namespaces are the names of the namespaces the policy applies to.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector selects the namespaces the policy applies to by their labels.
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+	// AllowedTemplates are the templates which Certificates in the matched namespaces may request.
+	AllowedTemplates []string `json:"allowedTemplates"`
 }
 
 // SecretRef is a reference to the Kubernetes Secret containing credentials for authenticating with the cert API.
@@ -40,9 +175,30 @@ type SecretRef struct {
 	Namespace string `json:"namespace"`
 }
 
+// SecretKeyRef is a reference to a key of a Kubernetes Secret.
+type SecretKeyRef struct {
+	// Name is the name of the Secret.
+	Name string `json:"name"`
+	// Namespace is the namespace where the Secret is located.
+	Namespace string `json:"namespace"`
+	// Key is the key of the Secret data holding the value.
+	Key string `json:"key"`
+}
+
 // CertificateConfigStatus defines the observed state of CertificateConfig.
 type CertificateConfigStatus struct {
-	// This section is intentionally left blank.
+	// FailedAttempts is the number of consecutive failed reconciles of the CertificateConfig, from which the delay
+	// before the next attempt is computed. It is reset when a reconcile succeeds.
+	FailedAttempts int32 `json:"failedAttempts,omitempty"`
+	// NextRetryTime is the time at which the CertificateConfig is reconciled again after the last failed reconcile.
+	NextRetryTime *metav1.Time `json:"nextRetryTime,omitempty"`
+	// ObservedGeneration is the generation of the CertificateConfig when the last failed reconcile happened. A
+	// CertificateConfig whose generation changed since is reconciled before its next retry time.
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// ObservedSecretResourceVersion is the resourceVersion of the secret of the CertificateConfig when the last failed
+	// reconcile happened, or empty if the secret was missing. A CertificateConfig whose secret changed since is
+	// reconciled before its next retry time.
+	ObservedSecretResourceVersion string `json:"observedSecretResourceVersion,omitempty"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,81 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CertificateRequestSpec defines the request sent to the cert API to create a certificate.
+type CertificateRequestSpec struct {
+	// CertificateRef is the reference to the Certificate for which the request was sent.
+	CertificateRef CertificateReference `json:"certificateRef"`
+	// ConfigRef is the reference to the CertificateConfig used to send the request.
+	ConfigRef ConfigReference `json:"configRef,omitempty"`
+	// Request is the exact JSON body sent to the cert API.
+	Request string `json:"request"`
+}
+
+// A CertificateReference is a reference to a Certificate resource in the same namespace.
+type CertificateReference struct {
+	// Name of the Certificate.
+	Name string `json:"name"`
+}
+
+// CertificateRequestStatus defines the response of the cert API to the request.
+type CertificateRequestStatus struct {
+	// Conditions represent the current conditions of the CertificateRequest.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Guid is the unique identifier of the certificate returned by the cert API.
+	Guid string `json:"guid,omitempty"`
+	// Failure is the error returned when the request to the cert API failed.
+	Failure string `json:"failure,omitempty"`
+	// RawResponse is the body of the response of the cert API, with its password and data fields redacted and truncated to
+	// 4096 characters.
+	RawResponse string `json:"rawResponse,omitempty"`
+	// RespondedAt is the time when the response of the cert API was recorded.
+	RespondedAt *metav1.Time `json:"respondedAt,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Certificate",type=string,JSONPath=`.spec.certificateRef.name`
+//+kubebuilder:printcolumn:name="Guid",type=string,JSONPath=`.status.guid`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// CertificateRequest is the Schema for the certificaterequests API. It records a single request sent to
+// the cert API for a Certificate and its response, for debugging and audit.
+type CertificateRequest struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CertificateRequestSpec   `json:"spec,omitempty"`
+	Status CertificateRequestStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CertificateRequestList contains a list of CertificateRequest.
+type CertificateRequestList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CertificateRequest `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CertificateRequest{}, &CertificateRequestList{})
+}
@@ -0,0 +1,83 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// CertificateSetSpec defines the desired state of a CertificateSet.
+type CertificateSetSpec struct {
+	// Template is the spec shared by the Certificates of the set, such as the CertificateConfig and the template
+	// of the certificates. The subject, SANs and secret name of each Certificate are taken from its entry.
+	Template CertificateSpec `json:"template"`
+	// Entries lists the certificates of the set. A Certificate named "<set>-<entry>" is created for every entry,
+	// and deleted when the entry is removed.
+	// +listType=map
+	// +listMapKey=name
+	Entries []CertificateSetEntry `json:"entries,omitempty"`
+}
+
+// CertificateSetEntry is a certificate of a CertificateSet.
+type CertificateSetEntry struct {
+	// Name of the entry, used as the suffix of the name of its Certificate.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`
+	Name string `json:"name"`
+	// Subject represents the subject of the certificate.
+	Subject Subject `json:"subject,omitempty"`
+	// San represents Subject Alternative Names of the certificate.
+	San San `json:"san,omitempty"`
+	// SecretName is the name of the Secret where the certificate is stored. It defaults to the name of the
+	// Certificate, unless the template sets a SecretNameTemplate.
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// CertificateSetStatus defines the observed state of a CertificateSet.
+type CertificateSetStatus struct {
+	// Conditions represent the current conditions of the CertificateSet.
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Certificates are the names of the Certificates created for the entries of the set.
+	Certificates []string `json:"certificates,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Synced",type=string,JSONPath=`.status.conditions[?(@.type=="Synced")].status`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// CertificateSet is the Schema for the certificatesets API. It issues a Certificate for each of its entries,
+// sharing a single spec template.
+type CertificateSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CertificateSetSpec   `json:"spec,omitempty"`
+	Status CertificateSetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// CertificateSetList contains a list of CertificateSet.
+type CertificateSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []CertificateSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&CertificateSet{}, &CertificateSetList{})
+}
@@ -17,28 +17,42 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"flag"
+	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/go-logr/zapr"
 	"go.elastic.co/ecszap"
 	runtimezap "sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	"github.com/dana-team/certificate-operator/internal/circuitbreaker"
 	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+	"github.com/dana-team/certificate-operator/internal/clients/notification"
+	"github.com/dana-team/certificate-operator/internal/configcheck"
+	"github.com/dana-team/certificate-operator/internal/metrics"
+	"github.com/dana-team/certificate-operator/internal/tracing"
 	"go.uber.org/zap"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
 
 	certv1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
 	"github.com/dana-team/certificate-operator/internal/controller"
+	"github.com/dana-team/certificate-operator/internal/webhook"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -47,6 +61,13 @@ var (
 	setupLog = ctrl.Log.WithName("setup")
 )
 
+// version and gitCommit are the version and git commit of the operator, set at build time with
+// -ldflags "-X main.version=<version> -X main.gitCommit=<commit>".
+var (
+	version   = "unknown"
+	gitCommit = "unknown"
+)
+
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 	utilruntime.Must(certv1alpha1.AddToScheme(scheme))
@@ -61,26 +82,147 @@ func initEcsLogger() {
 }
 
 func main() {
+	os.Exit(run())
+}
+
+// run runs the operator, or validates a CertificateConfig if requested, and returns the exit code of the process.
+// The remaining traces are flushed before it returns, whether it succeeded or not.
+func run() int {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
 	var ecsLogging bool
+	var finalizerName string
+	var circuitBreakerFailureThreshold int
+	var circuitBreakerCooldown time.Duration
+	var maxConditionMessageLength int
+	var enableWebhooks bool
+	var recordCertificateRequests bool
+	var certificateRequestHistoryLimit int
+	var terminalErrorRequeueAfter time.Duration
+	var defaultWaitTimeout time.Duration
+	var maxWaitTimeout time.Duration
+	var tokenFileDir string
+	var reconcileTimeout time.Duration
+	var debugStoreResponses bool
+	var resyncOnStart bool
+	var degradedAfterRenewalFailures int
+	var validateConfig string
+	var conditionTypePrefix string
+	var maxInFlightRequests int64
+	var otlpEndpoint string
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&ecsLogging, "ecs-logging", true, "Display controller logs in ecs format.")
+	flag.StringVar(&finalizerName, "finalizer-name", controller.DefaultDependenciesFinalizer,
+		"The finalizer set on CertificateConfigs. "+
+			"Use a distinct name for each operator instance running against the same cluster.")
+	flag.IntVar(&circuitBreakerFailureThreshold, "circuit-breaker-failure-threshold", 5,
+		"The number of consecutive requests to the Cert API of a CertificateConfig failing in transport or with a 5xx or 429 "+
+			"response after which requests are paused. Set to 0 to disable the circuit breaker.")
+	flag.DurationVar(&circuitBreakerCooldown, "circuit-breaker-cooldown", 5*time.Minute,
+		"The duration for which requests to the Cert API of a CertificateConfig are paused once the circuit breaker opens.")
+	flag.IntVar(&maxConditionMessageLength, "max-condition-message-length", controller.DefaultMaxConditionMessageLength,
+		"The maximum length of condition messages. Longer messages are truncated. Set to a negative value to disable truncation.")
+
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Enable the Certificate validating webhook, which enforces the template policies of CertificateConfigs. "+
+			"Requires the webhook serving certificate to be mounted.")
+	flag.BoolVar(&recordCertificateRequests, "record-certificate-requests", false,
+		"Record every request sent to the Cert API to create a certificate, and its response, in a CertificateRequest.")
+	flag.IntVar(&certificateRequestHistoryLimit, "certificate-request-history-limit", controller.DefaultRequestHistoryLimit,
+		"The number of recorded CertificateRequests kept per Certificate. The oldest CertificateRequests are deleted.")
+	flag.DurationVar(&terminalErrorRequeueAfter, "terminal-error-requeue-after", controller.DefaultTerminalErrorRequeueAfter,
+		"The interval at which Certificates failing with terminal errors, such as invalid credentials or rejected requests, are requeued. "+
+			"The Cert API is not requested again for them until the Certificate or its CertificateConfig change.")
+	flag.DurationVar(&defaultWaitTimeout, "default-wait-timeout", cert.DefaultWaitTimeout,
+		"The maximum time to wait for responses of the Cert API of CertificateConfigs which do not set a waitTimeout.")
+	flag.DurationVar(&maxWaitTimeout, "max-wait-timeout", cert.DefaultMaxWaitTimeout,
+		"The maximum waitTimeout of CertificateConfigs. Longer wait timeouts are clamped to it, "+
+			"so that a single issuance cannot hog a reconcile worker.")
+	flag.StringVar(&tokenFileDir, "token-file-dir", "",
+		"The directory of the files mounted into the operator pod which the tokenFile key of the credentials of "+
+			"CertificateConfigs may point to, such as the directory of projected ServiceAccount tokens. "+
+			"Token files are disabled if it is empty.")
+	flag.DurationVar(&reconcileTimeout, "reconcile-timeout", controller.DefaultReconcileTimeout,
+		"The maximum duration of the reconcile of a Certificate, after which it is abandoned and requeued. "+
+			"Must be larger than the max wait timeout.")
+	flag.BoolVar(&debugStoreResponses, "debug-store-responses", false,
+		"Store the last response of the Cert API to a get or download request in status.debugRawResponse of Certificates, "+
+			"with its password and certificate data redacted and truncated, to debug the support of a Cert API.")
+	flag.BoolVar(&resyncOnStart, "resync-on-start", false,
+		"Enqueue all Certificates once the operator starts or, with leader election, becomes the leader, "+
+			"so that they are re-evaluated promptly after a failover.")
+	flag.IntVar(&degradedAfterRenewalFailures, "degraded-after-renewal-failures", controller.DefaultDegradedAfterRenewalFailures,
+		"The number of consecutive failures to renew a certificate which is still valid after which the Certificate "+
+			"is marked Degraded, to warn before the certificate expires.")
+
+	flag.StringVar(&conditionTypePrefix, "condition-type-prefix", "",
+		"The prefix of the type of the Error condition of Certificates, e.g. \"cert.dana.io/\", "+
+			"to avoid collisions with the conditions of other controllers.")
+	flag.StringVar(&validateConfig, "validate-config", "",
+		"Validate the CertificateConfig with this name by sending an authenticated request to its Cert API, "+
+			"print whether it passed and exit without running the manager.")
+	flag.Int64Var(&maxInFlightRequests, "max-in-flight-requests", 0,
+		"The maximum number of requests sent concurrently to the Cert APIs, regardless of the number of concurrent "+
+			"reconciles. Requests are not limited if it is not positive.")
+	flag.StringVar(&otlpEndpoint, "otlp-endpoint", "",
+		"The URL of the OTLP/HTTP collector the traces of the reconciles and of the requests to the Cert APIs are "+
+			"exported to, such as http://otel-collector:4318. Traces are not exported if it is empty.")
 
 	flag.Parse()
 
+	httpClient.SetMaxInFlightRequests(maxInFlightRequests)
+
 	if ecsLogging {
 		initEcsLogger()
 	} else {
 		ctrl.SetLogger(runtimezap.New())
 	}
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	if errs := validation.IsQualifiedName(conditionTypePrefix + controller.ConditionError); len(errs) > 0 {
+		setupLog.Error(fmt.Errorf("%s", strings.Join(errs, "; ")), "invalid condition type prefix", "prefix", conditionTypePrefix)
+		return 1
+	}
+
+	if err := controller.ValidateReconcileTimeout(reconcileTimeout, maxWaitTimeout); err != nil {
+		setupLog.Error(err, "invalid reconcile timeout")
+		return 1
+	}
+
+	if err := metrics.RegisterBuildInfo(ctrlmetrics.Registry, version, gitCommit); err != nil {
+		setupLog.Error(err, "unable to register the build info metric")
+		return 1
+	}
+	setupLog.Info("operator build info", "version", version, "gitCommit", gitCommit)
+
+	if otlpEndpoint != "" {
+		shutdownTracerProvider, err := tracing.SetupTracerProvider(context.Background(), otlpEndpoint)
+		if err != nil {
+			setupLog.Error(err, "unable to set up tracing", "endpoint", otlpEndpoint)
+			return 1
+		}
+		defer func() {
+			if err := shutdownTracerProvider(context.Background()); err != nil {
+				setupLog.Error(err, "unable to flush the remaining traces")
+			}
+		}()
+	}
+
+	if validateConfig != "" {
+		return validateCertificateConfig(validateConfig, cert.NewClientBuilder(defaultWaitTimeout, maxWaitTimeout, tokenFileDir))
+	}
+
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		setupLog.Error(err, "unable to get kubeconfig")
+		return 1
+	}
+
+	mgr, err := ctrl.NewManager(config, ctrl.Options{
 		Scheme:                 scheme,
 		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
 		HealthProbeBindAddress: probeAddr,
@@ -90,43 +232,115 @@ func main() {
 
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
-		os.Exit(1)
+		return 1
+	}
+
+	var breaker *circuitbreaker.Breaker
+	if circuitBreakerFailureThreshold > 0 {
+		breaker = circuitbreaker.New(circuitBreakerFailureThreshold, circuitBreakerCooldown)
 	}
 
 	certificateLogger := log.Log.WithValues("controller", "Certificate")
 	if err = (&controller.CertificateReconciler{
-		Log:               certificateLogger,
-		Client:            mgr.GetClient(),
-		Scheme:            mgr.GetScheme(),
-		CertClientBuilder: cert.NewClientFromCertificateConfigAndSecretData,
+		Log:                          certificateLogger,
+		Client:                       mgr.GetClient(),
+		Scheme:                       mgr.GetScheme(),
+		CertClientBuilder:            cert.NewClientBuilder(defaultWaitTimeout, maxWaitTimeout, tokenFileDir),
+		CircuitBreaker:               breaker,
+		Notifier:                     notification.NewNotifier(),
+		RecordRequests:               recordCertificateRequests,
+		RequestHistoryLimit:          certificateRequestHistoryLimit,
+		TerminalErrorRequeueAfter:    terminalErrorRequeueAfter,
+		ReconcileTimeout:             reconcileTimeout,
+		DebugStoreResponses:          debugStoreResponses,
+		ResyncOnStart:                resyncOnStart,
+		DegradedAfterRenewalFailures: int32(degradedAfterRenewalFailures),
+		MaxConditionMessageLength:    maxConditionMessageLength,
+		ConditionTypePrefix:          conditionTypePrefix,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Certificate")
-		os.Exit(1)
+		return 1
 	}
 
 	certificateConfigLogger := log.Log.WithValues("controller", "CertificateConfig")
 	if err = (&controller.CertificateConfigReconciler{
-		Client: mgr.GetClient(),
-		Log:    certificateConfigLogger,
-		Scheme: mgr.GetScheme(),
+		Client:        mgr.GetClient(),
+		Log:           certificateConfigLogger,
+		Scheme:        mgr.GetScheme(),
+		FinalizerName: finalizerName,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "CertificateConfig")
-		os.Exit(1)
+		return 1
+	}
+	if enableWebhooks {
+		if err = (&webhook.CertificateValidator{
+			Client: mgr.GetClient(),
+		}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Certificate")
+			return 1
+		}
+	}
+	certificateRequestLogger := log.Log.WithValues("controller", "CertificateRequest")
+	if err = (&controller.CertificateRequestReconciler{
+		Client:                    mgr.GetClient(),
+		Log:                       certificateRequestLogger,
+		Scheme:                    mgr.GetScheme(),
+		MaxConditionMessageLength: maxConditionMessageLength,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CertificateRequest")
+		return 1
+	}
+	certificateSetLogger := log.Log.WithValues("controller", "CertificateSet")
+	if err = (&controller.CertificateSetReconciler{
+		Client:                    mgr.GetClient(),
+		Log:                       certificateSetLogger,
+		Scheme:                    mgr.GetScheme(),
+		MaxConditionMessageLength: maxConditionMessageLength,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "CertificateSet")
+		return 1
 	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
-		os.Exit(1)
+		return 1
 	}
 	if err := mgr.AddReadyzCheck("readyz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up ready check")
-		os.Exit(1)
+		return 1
 	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
 		setupLog.Error(err, "problem running manager")
-		os.Exit(1)
+		return 1
 	}
+
+	return 0
+}
+
+// validateCertificateConfig validates the CertificateConfig with the name against its Cert API, prints whether it
+// passed, and returns the exit code of the validation.
+func validateCertificateConfig(name string, certClientBuilder cert.ClientBuilder) int {
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		setupLog.Error(err, "unable to get kubeconfig")
+		return 1
+	}
+
+	kubeClient, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		setupLog.Error(err, "unable to create client")
+		return 1
+	}
+
+	validationLogger := log.Log.WithValues("certificateConfig", name)
+	if err := configcheck.Validate(context.Background(), kubeClient, validationLogger, certClientBuilder, name); err != nil {
+		fmt.Printf("FAIL: CertificateConfig %q: %v\n", name, err)
+		return 1
+	}
+
+	fmt.Printf("PASS: CertificateConfig %q can reach its Cert API\n", name)
+	return 0
 }
@@ -0,0 +1,81 @@
+package certhandler
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	errCreatingConfigMap = "cannot create ConfigMap %q in the namespace %q: %v"
+	errGettingConfigMap  = "cannot get ConfigMap %q in the namespace %q: %v"
+	errUpdatingConfigMap = "cannot update ConfigMap %q in the namespace %q: %v"
+)
+
+// CertConfigMap creates a ConfigMap publishing the public material of the TLS data, for consumers which cannot read
+// Secrets: the certificate under tls.crt and, if present, the CA certificates under ca.crt. The private key is never
+// included. The ConfigMap is annotated with the fingerprint of the certificate, if it was parsed.
+func CertConfigMap(tlsData TLSData, name, namespace string) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Data: map[string]string{
+			corev1.TLSCertKey: string(tlsData.CertificateBytes),
+		},
+	}
+
+	if len(tlsData.CACertificateBytes) > 0 {
+		configMap.Data[KeyCACert] = string(tlsData.CACertificateBytes)
+	}
+
+	if tlsData.Certificate != nil {
+		configMap.Annotations = map[string]string{AnnotationFingerprint: Fingerprint(tlsData.Certificate)}
+	}
+
+	return configMap
+}
+
+// CreateOrUpdateConfigMap creates or updates a ConfigMap in the Kubernetes cluster.
+// An existing ConfigMap is not updated if its data and metadata are already identical, to avoid needless writes.
+func CreateOrUpdateConfigMap(ctx context.Context, kubeClient client.Client, configMap *corev1.ConfigMap) error {
+	existingConfigMap := &corev1.ConfigMap{}
+
+	if err := kubeClient.Get(ctx, client.ObjectKeyFromObject(configMap), existingConfigMap); err != nil {
+		if !errors.IsNotFound(err) {
+			return fmt.Errorf(errGettingConfigMap, configMap.Name, configMap.Namespace, err)
+		}
+		if err := kubeClient.Create(ctx, configMap); err != nil {
+			return fmt.Errorf(errCreatingConfigMap, configMap.Name, configMap.Namespace, err)
+		}
+		return nil
+	}
+
+	originalConfigMap := existingConfigMap.DeepCopy()
+	existingConfigMap.Data = configMap.Data
+	for key, value := range configMap.Annotations {
+		metav1.SetMetaDataAnnotation(&existingConfigMap.ObjectMeta, key, value)
+	}
+	for key, value := range configMap.Labels {
+		metav1.SetMetaDataLabel(&existingConfigMap.ObjectMeta, key, value)
+	}
+	for _, ref := range configMap.OwnerReferences {
+		existingConfigMap.OwnerReferences = upsertReference(existingConfigMap.OwnerReferences, ref)
+	}
+
+	if equality.Semantic.DeepEqual(originalConfigMap, existingConfigMap) {
+		return nil
+	}
+
+	if err := kubeClient.Update(ctx, existingConfigMap); err != nil {
+		return fmt.Errorf(errUpdatingConfigMap, configMap.Name, configMap.Namespace, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package certhandler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const configMapName = "my-config-map"
+
+var errGetConfigMap = errors.New("cannot get ConfigMap")
+
+func Test_CertConfigMap(t *testing.T) {
+	type args struct {
+		tlsData TLSData
+	}
+	type want struct {
+		configMap *corev1.ConfigMap
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldPublishCertificateWithoutPrivateKey": {
+			args: args{
+				tlsData: TLSData{
+					CertificateBytes: validCertKey,
+					PrivateKeyBytes:  validPrivateKey,
+				},
+			},
+			want: want{
+				configMap: &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: configMapName, Namespace: namespace},
+					Data: map[string]string{
+						corev1.TLSCertKey: string(validCertKey),
+					},
+				},
+			},
+		},
+		"ShouldPublishCACertificates": {
+			args: args{
+				tlsData: TLSData{
+					CertificateBytes:   validCertKey,
+					PrivateKeyBytes:    validPrivateKey,
+					CACertificateBytes: validCACert,
+				},
+			},
+			want: want{
+				configMap: &corev1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: configMapName, Namespace: namespace},
+					Data: map[string]string{
+						corev1.TLSCertKey: string(validCertKey),
+						KeyCACert:         string(validCACert),
+					},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := CertConfigMap(tc.args.tlsData, configMapName, namespace)
+			if diff := cmp.Diff(tc.want.configMap, got); diff != "" {
+				t.Fatalf("CertConfigMap(...): -want ConfigMap, +got ConfigMap: %v", diff)
+			}
+
+			for key, value := range got.Data {
+				if strings.Contains(value, string(validPrivateKey)) {
+					t.Fatalf("CertConfigMap(...): key %q holds the private key", key)
+				}
+			}
+		})
+	}
+}
+
+func Test_CreateOrUpdateConfigMap(t *testing.T) {
+	configMap := CertConfigMap(TLSData{CertificateBytes: validCertKey, CACertificateBytes: validCACert}, configMapName, namespace)
+
+	type args struct {
+		localKube client.Client
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldCreateMissingConfigMap": {
+			args: args{
+				localKube: &test.MockClient{
+					MockGet:    test.NewMockGetFn(kerrors.NewNotFound(corev1.Resource("configmaps"), configMapName)),
+					MockCreate: test.NewMockCreateFn(nil),
+					MockUpdate: test.NewMockUpdateFn(errors.New("update should not be called")),
+				},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldUpdateChangedConfigMap": {
+			args: args{
+				localKube: &test.MockClient{
+					MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+						*obj.(*corev1.ConfigMap) = *configMap.DeepCopy()
+						obj.(*corev1.ConfigMap).Data[corev1.TLSCertKey] = "previous-certificate"
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+				},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldSkipUpdateOfIdenticalConfigMap": {
+			args: args{
+				localKube: &test.MockClient{
+					MockGet: func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+						*obj.(*corev1.ConfigMap) = *configMap.DeepCopy()
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(errors.New("update should not be called")),
+				},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldFailGettingConfigMap": {
+			args: args{
+				localKube: &test.MockClient{
+					MockGet: test.NewMockGetFn(errGetConfigMap),
+				},
+			},
+			want: want{
+				err: fmt.Errorf(errGettingConfigMap, configMapName, namespace, errGetConfigMap),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := CreateOrUpdateConfigMap(context.Background(), tc.args.localKube, configMap.DeepCopy())
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("CreateOrUpdateConfigMap(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
@@ -1,54 +1,391 @@
 package certhandler
 
 import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"strings"
 
-	"software.sslmate.com/src/go-pkcs12"
+	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
 )
 
 const (
 	errCannotDecodeData          = "cannot decode PKCS#12 data: %v"
 	errCannotDecodeB64Data       = "cannot decode base64-encoded PKCS#12 data: %v"
-	errCannotCastToRSAPrivateKey = "cannot cast to RSA Private Key"
+	errUnsupportedPrivateKey     = "unsupported private key type %T, expected an RSA or ECDSA private key"
+	errCannotMarshalECPrivateKey = "cannot marshal ECDSA private key: %v"
+	errMissingCertificate        = "PKCS#12 data contains no certificate"
+	errCannotDecodePassword      = "cannot decode %s-encoded PKCS#12 password: %v"
+	errUnknownPasswordEncoding   = "unknown PKCS#12 password encoding %q"
+	errMissingCertificatePEM     = "data contains no PEM-encoded certificate"
+	errMissingCACertificates     = "no CA certificates to verify the certificate against"
+	errChainVerificationFailed   = "certificate does not chain to the CA certificates: %v"
+	errPrivateKeyMismatch        = "private key does not match the public key of the certificate"
+	errDataAfterPadding          = "illegal base64 data after padding"
 
 	certificateBlockType = "CERTIFICATE"
 	rsaBlockType         = "PRIVATE KEY"
+	pkcs8BlockType       = "PRIVATE KEY"
 )
 
 // TLSData represents TLS data containing a private key and certificate bytes.
 type TLSData struct {
-	PrivateKeyBytes  []byte
-	CertificateBytes []byte
+	PrivateKeyBytes    []byte
+	CertificateBytes   []byte
+	CACertificateBytes []byte
+	// Certificate is the parsed leaf certificate.
+	Certificate *x509.Certificate
+	// CACertificates are the parsed CA certificates.
+	CACertificates []*x509.Certificate
+	// PrivateKey is the parsed private key, an *rsa.PrivateKey or an *ecdsa.PrivateKey.
+	PrivateKey crypto.Signer
+	// CertificateOnly indicates that the PKCS#12 data contained no private key, in which case PrivateKeyBytes is empty.
+	CertificateOnly bool
 }
 
-// Decoder decodes the PKCS#12 formatted TLS data.
+// Decoder decodes the PKCS#12 formatted TLS data. The data may be base64-encoded with the standard or URL-safe
+// alphabet, with or without padding, since CAs differ in the encoding they return.
 func Decoder(data, password string) (TLSData, error) {
-	decodedData, err := base64.StdEncoding.DecodeString(data)
+	return decode(pkcs12Decoder, data, password)
+}
+
+// DecodeDER decodes the PKCS#12 formatted TLS data which was already base64-decoded, e.g. by DecodeBase64Reader.
+func DecodeDER(decodedData []byte, password string) (TLSData, error) {
+	return decodeDER(pkcs12Decoder, decodedData, password)
+}
+
+// decode decodes the PKCS#12 formatted TLS data using the given PKCS#12 implementation.
+func decode(decoder PKCS12Decoder, data, password string) (TLSData, error) {
+	decodedData, err := decodeBase64String(data)
 	if err != nil {
 		return TLSData{}, fmt.Errorf(errCannotDecodeB64Data, err)
 	}
 
-	privateKey, certificate, _, err := pkcs12.DecodeChain(decodedData, password)
+	return decodeDER(decoder, decodedData, password)
+}
+
+// base64Encodings are the base64 encodings tried in order to decode PKCS#12 data: padded and unpadded, with the
+// standard and then the URL-safe alphabet.
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding,
+	base64.RawStdEncoding,
+	base64.URLEncoding,
+	base64.RawURLEncoding,
+}
+
+// decodeBase64String decodes the base64-encoded data with the first of the base64Encodings which can decode it.
+// The error of the standard encoding is returned if none can, so that invalid data fails as it would without the
+// fallbacks.
+func decodeBase64String(data string) ([]byte, error) {
+	var stdErr error
+	for _, encoding := range base64Encodings {
+		decodedData, err := encoding.DecodeString(data)
+		if err == nil {
+			return decodedData, nil
+		}
+		if stdErr == nil {
+			stdErr = err
+		}
+	}
+
+	return nil, stdErr
+}
+
+// decodeDER decodes the DER-encoded PKCS#12 TLS data using the given PKCS#12 implementation.
+func decodeDER(decoder PKCS12Decoder, decodedData []byte, password string) (TLSData, error) {
+
+	privateKey, certificate, caCerts, err := decoder.DecodeChain(decodedData, password)
 	if err != nil {
 		return TLSData{}, fmt.Errorf(errCannotDecodeData, err)
 	}
 
-	rsaPrivateKey, ok := privateKey.(*rsa.PrivateKey)
-	if !ok {
-		return TLSData{}, errors.New(errCannotCastToRSAPrivateKey)
+	if certificate == nil {
+		return TLSData{}, errors.New(errMissingCertificate)
 	}
 
-	// Encode certificate to PEM format
+	// Encode certificates to PEM format
 	certificateBytes := pem.EncodeToMemory(&pem.Block{Type: certificateBlockType, Bytes: certificate.Raw})
-	privateKeyBytes := pem.EncodeToMemory(&pem.Block{Type: rsaBlockType, Bytes: x509.MarshalPKCS1PrivateKey(rsaPrivateKey)})
+	caCertificateBytes := encodeCertificates(caCerts)
+
+	if privateKey == nil {
+		return TLSData{
+			CertificateBytes:   certificateBytes,
+			CACertificateBytes: caCertificateBytes,
+			Certificate:        certificate,
+			CACertificates:     caCerts,
+			CertificateOnly:    true,
+		}, nil
+	}
+
+	signer, privateKeyBytes, err := encodePrivateKey(privateKey)
+	if err != nil {
+		return TLSData{}, err
+	}
+
+	if err := verifyKeyPair(signer, certificate); err != nil {
+		return TLSData{}, err
+	}
 
 	return TLSData{
-		PrivateKeyBytes:  privateKeyBytes,
-		CertificateBytes: certificateBytes,
+		PrivateKeyBytes:    privateKeyBytes,
+		CertificateBytes:   certificateBytes,
+		CACertificateBytes: caCertificateBytes,
+		Certificate:        certificate,
+		CACertificates:     caCerts,
+		PrivateKey:         signer,
 	}, nil
 }
+
+// DecodeBase64Reader decodes the base64-encoded PKCS#12 data streamed from the reader, as it is read, so that large
+// bundles are never held in memory in both their encoded and decoded forms. Like Decoder, it accepts the standard and
+// URL-safe alphabets, with or without padding, and line breaks are ignored.
+func DecodeBase64Reader(reader io.Reader) ([]byte, error) {
+	var buffer bytes.Buffer
+	if _, err := buffer.ReadFrom(base64.NewDecoder(base64.RawStdEncoding, &base64Normalizer{reader: reader})); err != nil {
+		return nil, fmt.Errorf(errCannotDecodeB64Data, err)
+	}
+
+	return buffer.Bytes(), nil
+}
+
+// base64Normalizer reads base64-encoded data from a reader, with the URL-safe alphabet replaced by the standard one
+// and the padding removed, so that it is decoded with base64.RawStdEncoding whatever its encoding.
+type base64Normalizer struct {
+	reader io.Reader
+	padded bool
+}
+
+// Read reads the normalized base64-encoded data. It fails if data follows the padding.
+func (n *base64Normalizer) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for {
+		read, err := n.reader.Read(p)
+
+		written := 0
+		for _, b := range p[:read] {
+			switch {
+			case b == '=':
+				n.padded = true
+				continue
+			case b == '\r' || b == '\n':
+			case n.padded:
+				return 0, errors.New(errDataAfterPadding)
+			case b == '-':
+				b = '+'
+			case b == '_':
+				b = '/'
+			}
+			p[written] = b
+			written++
+		}
+
+		// Reads holding only padding are skipped, since a read of no data without an error is discouraged.
+		if written > 0 || err != nil {
+			return written, err
+		}
+	}
+}
+
+// encodePrivateKey encodes the RSA or ECDSA private key to PEM format.
+// RSA private keys are encoded in PKCS#1 form and ECDSA private keys in PKCS#8 form.
+func encodePrivateKey(privateKey interface{}) (crypto.Signer, []byte, error) {
+	switch key := privateKey.(type) {
+	case *rsa.PrivateKey:
+		return key, pem.EncodeToMemory(&pem.Block{Type: rsaBlockType, Bytes: x509.MarshalPKCS1PrivateKey(key)}), nil
+	case *ecdsa.PrivateKey:
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, nil, fmt.Errorf(errCannotMarshalECPrivateKey, err)
+		}
+		return key, pem.EncodeToMemory(&pem.Block{Type: pkcs8BlockType, Bytes: der}), nil
+	default:
+		return nil, nil, fmt.Errorf(errUnsupportedPrivateKey, privateKey)
+	}
+}
+
+// verifyKeyPair verifies that the public key derived from the private key is the public key of the certificate,
+// so that a malformed PKCS#12 bundle does not produce a Secret with a mismatched key pair.
+func verifyKeyPair(privateKey crypto.Signer, certificate *x509.Certificate) error {
+	publicKey, ok := privateKey.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !publicKey.Equal(certificate.PublicKey) {
+		return errors.New(errPrivateKeyMismatch)
+	}
+
+	return nil
+}
+
+// DecodePassword decodes a PKCS#12 password with the given encoding, one of v1alpha1.PasswordEncodingPlain,
+// v1alpha1.PasswordEncodingBase64 or v1alpha1.PasswordEncodingHex. An empty encoding is treated as plain.
+func DecodePassword(password, encoding string) (string, error) {
+	switch encoding {
+	case "", v1alpha1.PasswordEncodingPlain:
+		return password, nil
+	case v1alpha1.PasswordEncodingBase64:
+		decoded, err := base64.StdEncoding.DecodeString(password)
+		if err != nil {
+			return "", fmt.Errorf(errCannotDecodePassword, encoding, err)
+		}
+		return string(decoded), nil
+	case v1alpha1.PasswordEncodingHex:
+		decoded, err := hex.DecodeString(password)
+		if err != nil {
+			return "", fmt.Errorf(errCannotDecodePassword, encoding, err)
+		}
+		return string(decoded), nil
+	default:
+		return "", fmt.Errorf(errUnknownPasswordEncoding, encoding)
+	}
+}
+
+// SignatureHashAlgorithm returns the name of the hash algorithm used to sign the certificate, e.g. "sha256".
+// It returns the lowercase name of the signature algorithm if it does not use a distinct hash function.
+func SignatureHashAlgorithm(certificate *x509.Certificate) string {
+	switch certificate.SignatureAlgorithm {
+	case x509.MD5WithRSA:
+		return "md5"
+	case x509.SHA1WithRSA, x509.ECDSAWithSHA1, x509.DSAWithSHA1:
+		return "sha1"
+	case x509.SHA256WithRSA, x509.SHA256WithRSAPSS, x509.ECDSAWithSHA256, x509.DSAWithSHA256:
+		return "sha256"
+	case x509.SHA384WithRSA, x509.SHA384WithRSAPSS, x509.ECDSAWithSHA384:
+		return "sha384"
+	case x509.SHA512WithRSA, x509.SHA512WithRSAPSS, x509.ECDSAWithSHA512:
+		return "sha512"
+	default:
+		return strings.ToLower(certificate.SignatureAlgorithm.String())
+	}
+}
+
+// ParseCertificatePEM parses the first PEM-encoded certificate of the data, e.g. the leaf certificate of a TLS secret.
+func ParseCertificatePEM(data []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New(errMissingCertificatePEM)
+		}
+
+		if block.Type == certificateBlockType {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
+
+// keyUsages are the names of the key usages, in the order of their bits.
+var keyUsages = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digital signature"},
+	{x509.KeyUsageContentCommitment, "content commitment"},
+	{x509.KeyUsageKeyEncipherment, "key encipherment"},
+	{x509.KeyUsageDataEncipherment, "data encipherment"},
+	{x509.KeyUsageKeyAgreement, "key agreement"},
+	{x509.KeyUsageCertSign, "cert sign"},
+	{x509.KeyUsageCRLSign, "crl sign"},
+	{x509.KeyUsageEncipherOnly, "encipher only"},
+	{x509.KeyUsageDecipherOnly, "decipher only"},
+}
+
+// extKeyUsages are the names of the extended key usages.
+var extKeyUsages = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "any",
+	x509.ExtKeyUsageServerAuth:                     "server auth",
+	x509.ExtKeyUsageClientAuth:                     "client auth",
+	x509.ExtKeyUsageCodeSigning:                    "code signing",
+	x509.ExtKeyUsageEmailProtection:                "email protection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "ipsec end system",
+	x509.ExtKeyUsageIPSECTunnel:                    "ipsec tunnel",
+	x509.ExtKeyUsageIPSECUser:                      "ipsec user",
+	x509.ExtKeyUsageTimeStamping:                   "timestamping",
+	x509.ExtKeyUsageOCSPSigning:                    "ocsp signing",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "microsoft sgc",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "netscape sgc",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "microsoft commercial code signing",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "microsoft kernel code signing",
+}
+
+// KeyUsages returns the names of the key usages of the certificate, e.g. "digital signature", in the order of their bits.
+func KeyUsages(certificate *x509.Certificate) []string {
+	var names []string
+	for _, keyUsage := range keyUsages {
+		if certificate.KeyUsage&keyUsage.usage != 0 {
+			names = append(names, keyUsage.name)
+		}
+	}
+
+	return names
+}
+
+// ExtendedKeyUsages returns the names of the extended key usages of the certificate, e.g. "server auth", followed by
+// the OIDs of the extended key usages unknown to Go.
+func ExtendedKeyUsages(certificate *x509.Certificate) []string {
+	var names []string
+	for _, extKeyUsage := range certificate.ExtKeyUsage {
+		name, ok := extKeyUsages[extKeyUsage]
+		if !ok {
+			name = fmt.Sprintf("unknown (%d)", extKeyUsage)
+		}
+		names = append(names, name)
+	}
+
+	for _, oid := range certificate.UnknownExtKeyUsage {
+		names = append(names, oid.String())
+	}
+
+	return names
+}
+
+// Fingerprint returns the SHA-256 fingerprint of the certificate, formatted as colon-separated uppercase hex,
+// e.g. "B9:59:2B:...".
+func Fingerprint(certificate *x509.Certificate) string {
+	sum := sha256.Sum256(certificate.Raw)
+
+	hexBytes := make([]string, len(sum))
+	for i, b := range sum {
+		hexBytes[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return strings.Join(hexBytes, ":")
+}
+
+// VerifyChain verifies that the certificate of the TLS data chains to its CA certificates, all of which are trusted,
+// so that a chain without its root CA certificate is verified as well.
+func VerifyChain(tlsData TLSData) error {
+	if len(tlsData.CACertificates) == 0 {
+		return errors.New(errMissingCACertificates)
+	}
+
+	roots := x509.NewCertPool()
+	for _, caCertificate := range tlsData.CACertificates {
+		roots.AddCert(caCertificate)
+	}
+
+	if _, err := tlsData.Certificate.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+		return fmt.Errorf(errChainVerificationFailed, err)
+	}
+
+	return nil
+}
+
+// encodeCertificates encodes the certificates to concatenated PEM blocks.
+func encodeCertificates(certificates []*x509.Certificate) []byte {
+	var encoded []byte
+	for _, certificate := range certificates {
+		encoded = append(encoded, pem.EncodeToMemory(&pem.Block{Type: certificateBlockType, Bytes: certificate.Raw})...)
+	}
+
+	return encoded
+}
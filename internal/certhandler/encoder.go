@@ -0,0 +1,132 @@
+package certhandler
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// FormatPEM stores the certificate, private key and CA certificates as PEM in a TLS Secret.
+	FormatPEM = "pem"
+	// FormatPKCS12 stores the certificate, private key and CA certificates as a PKCS#12 keystore in an Opaque Secret.
+	FormatPKCS12 = "pkcs12"
+	// FormatJKS stores the certificate, private key and CA certificates as a JKS keystore in an Opaque Secret.
+	FormatJKS = "jks"
+
+	// KeyCACert is the Secret key of the PEM encoded CA certificates.
+	KeyCACert = "ca.crt"
+	// KeyPKCS12 is the Secret key of the PKCS#12 keystore.
+	KeyPKCS12 = "keystore.p12"
+	// KeyJKS is the Secret key of the JKS keystore.
+	KeyJKS = "keystore.jks"
+	// KeyKeystorePassword is the Secret key of the password protecting the keystore.
+	KeyKeystorePassword = "password"
+
+	keystorePasswordBytes = 16
+
+	errUnsupportedFormat        = "unsupported secret format %q"
+	errGeneratingPassword       = "cannot generate keystore password: %v"
+	errCannotEncodePKCS12Data   = "cannot encode PKCS#12 data: %v"
+	errCannotEncodeFormatSecret = "cannot encode %s secret %q: %v"
+)
+
+// FormatSecret creates a Secret with the TLS data encoded in the given format.
+func FormatSecret(tlsData TLSData, format, name, namespace string) (*corev1.Secret, error) {
+	secretType, data, err := encodeSecretData(tlsData, format)
+	if err != nil {
+		return nil, fmt.Errorf(errCannotEncodeFormatSecret, format, name, err)
+	}
+
+	secret := newSecret(name, namespace)
+	secret.Type = secretType
+	secret.Data = data
+
+	return secret, nil
+}
+
+// encodeSecretData encodes the TLS data into Secret data of the given format, and returns it with the matching Secret type.
+func encodeSecretData(tlsData TLSData, format string) (corev1.SecretType, map[string][]byte, error) {
+	switch format {
+	case FormatPEM:
+		return corev1.SecretTypeTLS, encodePEM(tlsData), nil
+	case FormatPKCS12:
+		data, err := encodePKCS12(pkcs12Encoder, tlsData)
+		return corev1.SecretTypeOpaque, data, err
+	case FormatJKS:
+		data, err := encodeJKS(tlsData)
+		return corev1.SecretTypeOpaque, data, err
+	default:
+		return "", nil, fmt.Errorf(errUnsupportedFormat, format)
+	}
+}
+
+// encodePEM encodes the TLS data into PEM Secret data.
+func encodePEM(tlsData TLSData) map[string][]byte {
+	data := map[string][]byte{
+		corev1.TLSCertKey:       tlsData.CertificateBytes,
+		corev1.TLSPrivateKeyKey: tlsData.PrivateKeyBytes,
+	}
+
+	if len(tlsData.CACertificateBytes) > 0 {
+		data[KeyCACert] = tlsData.CACertificateBytes
+	}
+
+	return data
+}
+
+// encodePKCS12 encodes the TLS data into a PKCS#12 keystore protected by a generated password.
+// Certificate-only TLS data is encoded as a trust store.
+func encodePKCS12(encoder PKCS12Encoder, tlsData TLSData) (map[string][]byte, error) {
+	password, err := generatePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	var keystore []byte
+	if tlsData.CertificateOnly {
+		keystore, err = encoder.EncodeTrustStore(append([]*x509.Certificate{tlsData.Certificate}, tlsData.CACertificates...), password)
+	} else {
+		keystore, err = encoder.Encode(tlsData.PrivateKey, tlsData.Certificate, tlsData.CACertificates, password)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf(errCannotEncodePKCS12Data, err)
+	}
+
+	return map[string][]byte{
+		KeyPKCS12:           keystore,
+		KeyKeystorePassword: []byte(password),
+	}, nil
+}
+
+// encodeJKS encodes the TLS data into a JKS keystore protected by a generated password.
+func encodeJKS(tlsData TLSData) (map[string][]byte, error) {
+	password, err := generatePassword()
+	if err != nil {
+		return nil, err
+	}
+
+	keystore, err := EncodeJKS(tlsData, password)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string][]byte{
+		KeyJKS:              keystore,
+		KeyKeystorePassword: []byte(password),
+	}, nil
+}
+
+// generatePassword returns a random hex-encoded password.
+func generatePassword() (string, error) {
+	buf := make([]byte, keystorePasswordBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf(errGeneratingPassword, err)
+	}
+
+	return hex.EncodeToString(buf), nil
+}
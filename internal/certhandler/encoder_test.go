@@ -0,0 +1,132 @@
+//go:build !fips
+
+package certhandler
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func Test_FormatSecret(t *testing.T) {
+	privateKey, certificate := newTestCertificate(t)
+	tlsData := TLSData{
+		CertificateBytes:   validCertKey,
+		PrivateKeyBytes:    validPrivateKey,
+		CACertificateBytes: validCertKey,
+		Certificate:        certificate,
+		CACertificates:     nil,
+		PrivateKey:         privateKey,
+	}
+
+	type args struct {
+		tlsData TLSData
+		format  string
+	}
+	type want struct {
+		secretType corev1.SecretType
+		keys       []string
+		err        error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldEncodePEMSecret": {
+			args: args{
+				tlsData: tlsData,
+				format:  FormatPEM,
+			},
+			want: want{
+				secretType: corev1.SecretTypeTLS,
+				keys:       []string{KeyCACert, corev1.TLSCertKey, corev1.TLSPrivateKeyKey},
+			},
+		},
+		"ShouldEncodePKCS12Secret": {
+			args: args{
+				tlsData: tlsData,
+				format:  FormatPKCS12,
+			},
+			want: want{
+				secretType: corev1.SecretTypeOpaque,
+				keys:       []string{KeyPKCS12, KeyKeystorePassword},
+			},
+		},
+		"ShouldEncodeJKSSecret": {
+			args: args{
+				tlsData: tlsData,
+				format:  FormatJKS,
+			},
+			want: want{
+				secretType: corev1.SecretTypeOpaque,
+				keys:       []string{KeyJKS, KeyKeystorePassword},
+			},
+		},
+		"ShouldFailWithUnsupportedFormat": {
+			args: args{
+				tlsData: tlsData,
+				format:  "der",
+			},
+			want: want{
+				err: fmt.Errorf(errCannotEncodeFormatSecret, "der", secretName, fmt.Errorf(errUnsupportedFormat, "der")),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			secret, err := FormatSecret(tc.args.tlsData, tc.args.format, secretName, namespace)
+			if tc.want.err != nil {
+				if err == nil {
+					t.Fatalf("FormatSecret(...): expected error %q, got nil", tc.want.err)
+				}
+				if diff := cmp.Diff(tc.want.err.Error(), err.Error()); diff != "" {
+					t.Fatalf("FormatSecret(...): -want error, +got error: %v", diff)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("FormatSecret(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.secretType, secret.Type); diff != "" {
+				t.Fatalf("FormatSecret(...): -want type, +got type: %v", diff)
+			}
+
+			for _, key := range tc.want.keys {
+				if len(secret.Data[key]) == 0 {
+					t.Fatalf("FormatSecret(...): expected key %q not found in secret data", key)
+				}
+			}
+
+			if diff := cmp.Diff(len(tc.want.keys), len(secret.Data)); diff != "" {
+				t.Fatalf("FormatSecret(...): -want number of keys, +got number of keys: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_encodePKCS12RoundTrip(t *testing.T) {
+	privateKey, certificate := newTestCertificate(t)
+
+	data, err := encodePKCS12(newDefaultPKCS12Encoder(), TLSData{Certificate: certificate, PrivateKey: privateKey})
+	if err != nil {
+		t.Fatalf("encodePKCS12(...): unexpected error: %v", err)
+	}
+
+	tlsData, err := Decoder(base64.StdEncoding.EncodeToString(data[KeyPKCS12]), string(data[KeyKeystorePassword]))
+	if err != nil {
+		t.Fatalf("Decoder(...): unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff(certificate.Raw, tlsData.Certificate.Raw); diff != "" {
+		t.Fatalf("Decoder(...): -want certificate, +got certificate: %v", diff)
+	}
+
+	if !privateKey.Equal(tlsData.PrivateKey) {
+		t.Fatalf("Decoder(...): decoded private key does not match the encoded private key")
+	}
+}
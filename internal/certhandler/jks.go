@@ -0,0 +1,98 @@
+package certhandler
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+)
+
+const (
+	// MinJKSPasswordLength is the minimal length of a password protecting a JKS keystore, as enforced by keytool.
+	MinJKSPasswordLength = 6
+
+	jksCertificateType = "X.509"
+	jksAlias           = "certificate"
+	jksCAAliasFormat   = "ca-%d"
+
+	errShortJKSPassword        = "JKS password must be at least %d characters long"
+	errMissingJKSCertificate   = "cannot encode JKS keystore without a certificate"
+	errCannotMarshalPrivateKey = "cannot marshal private key: %v"
+	errCannotSetJKSEntry       = "cannot set JKS entry %q: %v"
+	errCannotStoreJKS          = "cannot store JKS keystore: %v"
+)
+
+// EncodeJKS encodes the TLS data into a JKS keystore protected by the given password.
+// The private key and certificate chain are stored in a single private key entry;
+// certificate-only TLS data is stored as trusted certificate entries.
+func EncodeJKS(tlsData TLSData, password string) ([]byte, error) {
+	if len(password) < MinJKSPasswordLength {
+		return nil, fmt.Errorf(errShortJKSPassword, MinJKSPasswordLength)
+	}
+
+	if tlsData.Certificate == nil {
+		return nil, errors.New(errMissingJKSCertificate)
+	}
+
+	ks := keystore.New(keystore.WithOrderedAliases(), keystore.WithMinPasswordLen(MinJKSPasswordLength))
+	now := time.Now()
+
+	if tlsData.CertificateOnly {
+		for alias, cert := range jksTrustedCertificates(tlsData) {
+			entry := keystore.TrustedCertificateEntry{
+				CreationTime: now,
+				Certificate:  jksCertificate(cert),
+			}
+			if err := ks.SetTrustedCertificateEntry(alias, entry); err != nil {
+				return nil, fmt.Errorf(errCannotSetJKSEntry, alias, err)
+			}
+		}
+	} else {
+		key, err := x509.MarshalPKCS8PrivateKey(tlsData.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf(errCannotMarshalPrivateKey, err)
+		}
+
+		chain := []keystore.Certificate{jksCertificate(tlsData.Certificate)}
+		for _, ca := range tlsData.CACertificates {
+			chain = append(chain, jksCertificate(ca))
+		}
+
+		entry := keystore.PrivateKeyEntry{
+			CreationTime:     now,
+			PrivateKey:       key,
+			CertificateChain: chain,
+		}
+		if err := ks.SetPrivateKeyEntry(jksAlias, entry, []byte(password)); err != nil {
+			return nil, fmt.Errorf(errCannotSetJKSEntry, jksAlias, err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := ks.Store(&buf, []byte(password)); err != nil {
+		return nil, fmt.Errorf(errCannotStoreJKS, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// jksTrustedCertificates returns the certificate and CA certificates of the TLS data keyed by their alias.
+func jksTrustedCertificates(tlsData TLSData) map[string]*x509.Certificate {
+	certs := map[string]*x509.Certificate{jksAlias: tlsData.Certificate}
+	for i, ca := range tlsData.CACertificates {
+		certs[fmt.Sprintf(jksCAAliasFormat, i)] = ca
+	}
+
+	return certs
+}
+
+// jksCertificate converts an x509 certificate into a keystore certificate.
+func jksCertificate(cert *x509.Certificate) keystore.Certificate {
+	return keystore.Certificate{
+		Type:    jksCertificateType,
+		Content: cert.Raw,
+	}
+}
@@ -0,0 +1,137 @@
+package certhandler
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/pavlo-v-chernykh/keystore-go/v4"
+)
+
+const jksPassword = "changeit"
+
+func Test_EncodeJKS(t *testing.T) {
+	privateKey, certificate := newTestCertificate(t)
+	_, caCertificate := newTestCertificate(t)
+
+	type args struct {
+		tlsData  TLSData
+		password string
+	}
+	type want struct {
+		privateKeyEntry bool
+		certificates    [][]byte
+		err             error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldEncodePrivateKeyEntryWithChain": {
+			args: args{
+				tlsData: TLSData{
+					Certificate:    certificate,
+					CACertificates: []*x509.Certificate{caCertificate},
+					PrivateKey:     privateKey,
+				},
+				password: jksPassword,
+			},
+			want: want{
+				privateKeyEntry: true,
+				certificates:    [][]byte{certificate.Raw, caCertificate.Raw},
+			},
+		},
+		"ShouldEncodeTrustedCertificateEntries": {
+			args: args{
+				tlsData: TLSData{
+					Certificate:     certificate,
+					CACertificates:  []*x509.Certificate{caCertificate},
+					CertificateOnly: true,
+				},
+				password: jksPassword,
+			},
+			want: want{
+				certificates: [][]byte{caCertificate.Raw, certificate.Raw},
+			},
+		},
+		"ShouldFailWithShortPassword": {
+			args: args{
+				tlsData: TLSData{
+					Certificate: certificate,
+					PrivateKey:  privateKey,
+				},
+				password: "12345",
+			},
+			want: want{
+				err: fmt.Errorf(errShortJKSPassword, MinJKSPasswordLength),
+			},
+		},
+		"ShouldFailWithoutCertificate": {
+			args: args{
+				tlsData:  TLSData{PrivateKey: privateKey},
+				password: jksPassword,
+			},
+			want: want{
+				err: errors.New(errMissingJKSCertificate),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			data, err := EncodeJKS(tc.args.tlsData, tc.args.password)
+			if tc.want.err != nil {
+				if err == nil {
+					t.Fatalf("EncodeJKS(...): expected error %q, got nil", tc.want.err)
+				}
+				if diff := cmp.Diff(tc.want.err.Error(), err.Error()); diff != "" {
+					t.Fatalf("EncodeJKS(...): -want error, +got error: %v", diff)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("EncodeJKS(...): unexpected error: %v", err)
+			}
+
+			ks := keystore.New(keystore.WithOrderedAliases())
+			if err := ks.Load(bytes.NewReader(data), []byte(tc.args.password)); err != nil {
+				t.Fatalf("Load(...): unexpected error: %v", err)
+			}
+
+			var certificates [][]byte
+			if tc.want.privateKeyEntry {
+				entry, err := ks.GetPrivateKeyEntry(jksAlias, []byte(tc.args.password))
+				if err != nil {
+					t.Fatalf("GetPrivateKeyEntry(...): unexpected error: %v", err)
+				}
+
+				key, err := x509.ParsePKCS8PrivateKey(entry.PrivateKey)
+				if err != nil {
+					t.Fatalf("ParsePKCS8PrivateKey(...): unexpected error: %v", err)
+				}
+				if !privateKey.Equal(key) {
+					t.Fatalf("EncodeJKS(...): decoded private key does not match the encoded private key")
+				}
+
+				for _, cert := range entry.CertificateChain {
+					certificates = append(certificates, cert.Content)
+				}
+			} else {
+				for _, alias := range ks.Aliases() {
+					entry, err := ks.GetTrustedCertificateEntry(alias)
+					if err != nil {
+						t.Fatalf("GetTrustedCertificateEntry(%q): unexpected error: %v", alias, err)
+					}
+					certificates = append(certificates, entry.Certificate.Content)
+				}
+			}
+
+			if diff := cmp.Diff(tc.want.certificates, certificates); diff != "" {
+				t.Fatalf("EncodeJKS(...): -want certificates, +got certificates: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package certhandler
+
+import (
+	"crypto/x509"
+)
+
+// PKCS12Decoder is the interface to decode PKCS#12 formatted data.
+// It allows swapping the underlying PKCS#12 implementation, for example in FIPS builds.
+type PKCS12Decoder interface {
+	DecodeChain(pfxData []byte, password string) (privateKey interface{}, certificate *x509.Certificate, caCerts []*x509.Certificate, err error)
+}
+
+// PKCS12Encoder is the interface to encode certificates and private keys to PKCS#12 formatted data.
+// It allows swapping the underlying PKCS#12 implementation, for example in FIPS builds.
+type PKCS12Encoder interface {
+	Encode(privateKey interface{}, certificate *x509.Certificate, caCerts []*x509.Certificate, password string) ([]byte, error)
+	EncodeTrustStore(certs []*x509.Certificate, password string) ([]byte, error)
+}
+
+// pkcs12Decoder is the PKCS#12 implementation used by Decoder.
+var pkcs12Decoder = newDefaultPKCS12Decoder()
+
+// pkcs12Encoder is the PKCS#12 implementation used to encode Secret data.
+var pkcs12Encoder = newDefaultPKCS12Encoder()
+
+// SetPKCS12Decoder replaces the PKCS#12 implementation used by Decoder. It is exposed to builds of the operator by
+// pkcs12provider.SetDecoder.
+func SetPKCS12Decoder(decoder PKCS12Decoder) {
+	pkcs12Decoder = decoder
+}
+
+// SetPKCS12Encoder replaces the PKCS#12 implementation used to encode Secret data. It is exposed to builds of the
+// operator by pkcs12provider.SetEncoder.
+func SetPKCS12Encoder(encoder PKCS12Encoder) {
+	pkcs12Encoder = encoder
+}
@@ -0,0 +1,46 @@
+//go:build fips
+
+package certhandler
+
+import (
+	"crypto/x509"
+	"errors"
+)
+
+const (
+	errPKCS12DecoderNotConfigured = "no PKCS#12 decoder is configured for FIPS builds, use pkcs12provider.SetDecoder to register one"
+	errPKCS12EncoderNotConfigured = "no PKCS#12 encoder is configured for FIPS builds, use pkcs12provider.SetEncoder to register one"
+)
+
+// unconfiguredDecoder is the default PKCS#12 implementation in FIPS builds.
+// It fails every decode until a FIPS-approved implementation is registered with pkcs12provider.SetDecoder.
+type unconfiguredDecoder struct{}
+
+// DecodeChain always returns an error.
+func (unconfiguredDecoder) DecodeChain([]byte, string) (interface{}, *x509.Certificate, []*x509.Certificate, error) {
+	return nil, nil, nil, errors.New(errPKCS12DecoderNotConfigured)
+}
+
+// newDefaultPKCS12Decoder returns the default PKCS#12 implementation.
+func newDefaultPKCS12Decoder() PKCS12Decoder {
+	return unconfiguredDecoder{}
+}
+
+// unconfiguredEncoder is the default PKCS#12 encoder in FIPS builds.
+// It fails every encode until a FIPS-approved implementation is registered with pkcs12provider.SetEncoder.
+type unconfiguredEncoder struct{}
+
+// Encode always returns an error.
+func (unconfiguredEncoder) Encode(interface{}, *x509.Certificate, []*x509.Certificate, string) ([]byte, error) {
+	return nil, errors.New(errPKCS12EncoderNotConfigured)
+}
+
+// EncodeTrustStore always returns an error.
+func (unconfiguredEncoder) EncodeTrustStore([]*x509.Certificate, string) ([]byte, error) {
+	return nil, errors.New(errPKCS12EncoderNotConfigured)
+}
+
+// newDefaultPKCS12Encoder returns the default PKCS#12 encoder.
+func newDefaultPKCS12Encoder() PKCS12Encoder {
+	return unconfiguredEncoder{}
+}
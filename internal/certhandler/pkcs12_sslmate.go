@@ -0,0 +1,92 @@
+//go:build !fips
+
+package certhandler
+
+import (
+	"bytes"
+	"crypto/x509"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// sslmateDecoder decodes PKCS#12 data using the software.sslmate.com/src/go-pkcs12 library.
+// It supports both legacy data (RC2 or 3DES encryption with a SHA-1 MAC) and modern data
+// (PBES2 with PBKDF2 and AES encryption with a SHA-256 MAC), as emitted by newer CAs.
+type sslmateDecoder struct{}
+
+// DecodeChain decodes the PKCS#12 data into a private key, a leaf certificate and the CA certificates.
+// Data which is not a chain, such as a certificate-only bundle, is decoded as a trust store and returned with a nil
+// private key, and its leaf certificate is picked by leafCertificate. If it is not a trust store either, the error
+// of decoding it as a chain is returned.
+func (sslmateDecoder) DecodeChain(pfxData []byte, password string) (interface{}, *x509.Certificate, []*x509.Certificate, error) {
+	privateKey, certificate, caCerts, err := pkcs12.DecodeChain(pfxData, password)
+	if err == nil {
+		return privateKey, certificate, caCerts, nil
+	}
+
+	certs, trustStoreErr := pkcs12.DecodeTrustStore(pfxData, password)
+	if trustStoreErr != nil || len(certs) == 0 {
+		return nil, nil, nil, err
+	}
+
+	leaf, caCerts := leafCertificate(certs)
+	return nil, leaf, caCerts, nil
+}
+
+// leafCertificate returns the leaf certificate of a bundle, and the other certificates of the bundle in their order.
+// The leaf is a certificate which issued no other certificate of the bundle, preferably one which is not a CA, since
+// the certificates of a bundle are not ordered. The first certificate is returned if every certificate issued another.
+func leafCertificate(certs []*x509.Certificate) (*x509.Certificate, []*x509.Certificate) {
+	leafIndex := -1
+	for i, certificate := range certs {
+		if issuesAnother(certificate, certs) {
+			continue
+		}
+		if leafIndex == -1 || (certs[leafIndex].IsCA && !certificate.IsCA) {
+			leafIndex = i
+		}
+	}
+	if leafIndex == -1 {
+		leafIndex = 0
+	}
+
+	others := make([]*x509.Certificate, 0, len(certs)-1)
+	others = append(others, certs[:leafIndex]...)
+	others = append(others, certs[leafIndex+1:]...)
+
+	return certs[leafIndex], others
+}
+
+// issuesAnother checks if the certificate is the issuer of another certificate of the bundle.
+func issuesAnother(issuer *x509.Certificate, certs []*x509.Certificate) bool {
+	for _, certificate := range certs {
+		if certificate != issuer && bytes.Equal(certificate.RawIssuer, issuer.RawSubject) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newDefaultPKCS12Decoder returns the default PKCS#12 implementation.
+func newDefaultPKCS12Decoder() PKCS12Decoder {
+	return sslmateDecoder{}
+}
+
+// sslmateEncoder encodes PKCS#12 data using the software.sslmate.com/src/go-pkcs12 library.
+type sslmateEncoder struct{}
+
+// Encode encodes the private key, the leaf certificate and the CA certificates to PKCS#12 data.
+func (sslmateEncoder) Encode(privateKey interface{}, certificate *x509.Certificate, caCerts []*x509.Certificate, password string) ([]byte, error) {
+	return pkcs12.Modern2023.Encode(privateKey, certificate, caCerts, password)
+}
+
+// EncodeTrustStore encodes the certificates to PKCS#12 trust store data.
+func (sslmateEncoder) EncodeTrustStore(certs []*x509.Certificate, password string) ([]byte, error) {
+	return pkcs12.Modern2023.EncodeTrustStore(certs, password)
+}
+
+// newDefaultPKCS12Encoder returns the default PKCS#12 implementation.
+func newDefaultPKCS12Encoder() PKCS12Encoder {
+	return sslmateEncoder{}
+}
@@ -0,0 +1,259 @@
+//go:build !fips
+
+package certhandler
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func Test_sslmateDecoder(t *testing.T) {
+	validData, err := base64.StdEncoding.DecodeString(validPKCS12Data)
+	if err != nil {
+		t.Fatalf("failed to decode test data: %v", err)
+	}
+
+	modernData, err := base64.StdEncoding.DecodeString(modernPKCS12Data)
+	if err != nil {
+		t.Fatalf("failed to decode modern test data: %v", err)
+	}
+
+	legacyRC2Data, err := base64.StdEncoding.DecodeString(legacyRC2PKCS12Data)
+	if err != nil {
+		t.Fatalf("failed to decode legacy RC2 test data: %v", err)
+	}
+
+	_, certificate := newTestCertificate(t)
+	certificateOnlyData, err := pkcs12.Modern2023.EncodeTrustStore([]*x509.Certificate{certificate}, validPKCS12Password)
+	if err != nil {
+		t.Fatalf("failed to encode certificate-only test data: %v", err)
+	}
+
+	caKey, caCertificate := newTestCA(t)
+	leafCertificate := newTestLeaf(t, caKey, caCertificate)
+	certificateOnlyChainData, err := pkcs12.Modern2023.EncodeTrustStore([]*x509.Certificate{caCertificate, leafCertificate}, validPKCS12Password)
+	if err != nil {
+		t.Fatalf("failed to encode certificate-only chain test data: %v", err)
+	}
+
+	type args struct {
+		data     []byte
+		password string
+	}
+	type want struct {
+		hasCertificate bool
+		hasPrivateKey  bool
+		hasErr         bool
+		commonName     string
+		caCerts        int
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldDecodeChainSuccessfully": {
+			args: args{
+				data:     validData,
+				password: validPKCS12Password,
+			},
+			want: want{
+				hasCertificate: true,
+				hasPrivateKey:  true,
+				hasErr:         false,
+			},
+		},
+		"ShouldDecodeModernChain": {
+			args: args{
+				data:     modernData,
+				password: validPKCS12Password,
+			},
+			want: want{
+				hasCertificate: true,
+				hasPrivateKey:  true,
+				hasErr:         false,
+			},
+		},
+		"ShouldDecodeLegacyRC2Chain": {
+			args: args{
+				data:     legacyRC2Data,
+				password: validPKCS12Password,
+			},
+			want: want{
+				hasCertificate: true,
+				hasPrivateKey:  true,
+				hasErr:         false,
+			},
+		},
+		"ShouldFailModernChainWithWrongPassword": {
+			args: args{
+				data:     modernData,
+				password: "wrong-password",
+			},
+			want: want{
+				hasCertificate: false,
+				hasPrivateKey:  false,
+				hasErr:         true,
+			},
+		},
+		"ShouldDecodeCertificateOnlyBundle": {
+			args: args{
+				data:     certificateOnlyData,
+				password: validPKCS12Password,
+			},
+			want: want{
+				hasCertificate: true,
+				hasPrivateKey:  false,
+				hasErr:         false,
+			},
+		},
+		"ShouldDecodeLeafOfCertificateOnlyChain": {
+			args: args{
+				data:     certificateOnlyChainData,
+				password: validPKCS12Password,
+			},
+			want: want{
+				hasCertificate: true,
+				hasPrivateKey:  false,
+				hasErr:         false,
+				commonName:     "leaf",
+				caCerts:        1,
+			},
+		},
+		"ShouldFailWithWrongPassword": {
+			args: args{
+				data:     validData,
+				password: "wrong-password",
+			},
+			want: want{
+				hasCertificate: false,
+				hasPrivateKey:  false,
+				hasErr:         true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			privateKey, certificate, caCerts, err := newDefaultPKCS12Decoder().DecodeChain(tc.args.data, tc.args.password)
+			if diff := cmp.Diff(tc.want.hasPrivateKey, privateKey != nil); diff != "" {
+				t.Fatalf("DecodeChain(...): -want private key, +got private key: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.hasCertificate, certificate != nil); diff != "" {
+				t.Fatalf("DecodeChain(...): -want certificate, +got certificate: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.hasErr, err != nil); diff != "" {
+				t.Fatalf("DecodeChain(...): -want error, +got error: %v", diff)
+			}
+
+			if tc.want.commonName != "" {
+				if diff := cmp.Diff(tc.want.commonName, certificate.Subject.CommonName); diff != "" {
+					t.Errorf("DecodeChain(...): -want certificate common name, +got certificate common name: %v", diff)
+				}
+				if diff := cmp.Diff(tc.want.caCerts, len(caCerts)); diff != "" {
+					t.Errorf("DecodeChain(...): -want CA certificates, +got CA certificates: %v", diff)
+				}
+			}
+		})
+	}
+}
+
+func Test_leafCertificate(t *testing.T) {
+	caKey, caCertificate := newTestCA(t)
+	_, otherCACertificate := newTestCA(t)
+	leaf := newTestLeaf(t, caKey, caCertificate)
+	_, selfSigned := newTestCertificate(t)
+
+	type want struct {
+		leaf   *x509.Certificate
+		others []*x509.Certificate
+	}
+	cases := map[string]struct {
+		certs []*x509.Certificate
+		want  want
+	}{
+		"ShouldPickLeafAfterItsIssuer": {
+			certs: []*x509.Certificate{caCertificate, leaf},
+			want: want{
+				leaf:   leaf,
+				others: []*x509.Certificate{caCertificate},
+			},
+		},
+		"ShouldPickLeafBeforeItsIssuer": {
+			certs: []*x509.Certificate{leaf, caCertificate},
+			want: want{
+				leaf:   leaf,
+				others: []*x509.Certificate{caCertificate},
+			},
+		},
+		"ShouldPreferCertificateWhichIsNotCA": {
+			certs: []*x509.Certificate{otherCACertificate, selfSigned},
+			want: want{
+				leaf:   selfSigned,
+				others: []*x509.Certificate{otherCACertificate},
+			},
+		},
+		"ShouldPickSingleCertificate": {
+			certs: []*x509.Certificate{selfSigned},
+			want: want{
+				leaf:   selfSigned,
+				others: []*x509.Certificate{},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gotLeaf, gotOthers := leafCertificate(tc.certs)
+			if diff := cmp.Diff(tc.want.leaf.Raw, gotLeaf.Raw); diff != "" {
+				t.Errorf("leafCertificate(...): -want leaf, +got leaf: %v", diff)
+			}
+
+			if diff := cmp.Diff(rawCertificates(tc.want.others), rawCertificates(gotOthers)); diff != "" {
+				t.Errorf("leafCertificate(...): -want others, +got others: %v", diff)
+			}
+		})
+	}
+}
+
+// newTestLeaf returns a certificate issued by the CA.
+func newTestLeaf(t *testing.T, caKey any, caCertificate *x509.Certificate) *x509.Certificate {
+	t.Helper()
+
+	leafKey, _ := newTestCertificate(t)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "leaf"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, caCertificate, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %v", err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	return certificate
+}
+
+// rawCertificates returns the DER encodings of the certificates.
+func rawCertificates(certs []*x509.Certificate) [][]byte {
+	raw := make([][]byte, 0, len(certs))
+	for _, certificate := range certs {
+		raw = append(raw, certificate.Raw)
+	}
+
+	return raw
+}
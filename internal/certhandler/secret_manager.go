@@ -2,26 +2,71 @@ package certhandler
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"fmt"
+	"strings"
+	"time"
 
 	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/metrics"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 const (
+	// KeyCombinedPEM is the Secret key of the certificate, CA certificates and private key concatenated as PEM.
+	KeyCombinedPEM = "tls.pem"
+	// KeyCertificateDER is the Secret key of the DER encoded certificate.
+	KeyCertificateDER = "cert.der"
+	// KeyPrivateKeyDER is the Secret key of the DER encoded private key.
+	KeyPrivateKeyDER = "key.der"
+	// KeyCACertDER is the Secret key of the concatenated DER encoded CA certificates.
+	KeyCACertDER = "ca.der"
+	// AnnotationFingerprint is the annotation of the TLS secret holding the SHA-256 fingerprint of the certificate.
+	AnnotationFingerprint = "cert.dana.io/fingerprint-sha256"
+	// AnnotationValidFrom is the annotation of the TLS secret holding the start of the validity of the certificate.
+	AnnotationValidFrom = "cert.dana.io/valid-from"
+	// AnnotationValidTo is the annotation of the TLS secret holding the end of the validity of the certificate.
+	AnnotationValidTo = "cert.dana.io/valid-to"
+	// AnnotationCommonName is the annotation of the TLS secret holding the CommonName of the certificate.
+	AnnotationCommonName = "cert.dana.io/common-name"
+	// FieldManager is the field manager with which the operator applies the fields it sets on secrets.
+	FieldManager = "certificate-operator"
+
 	errCreatingSecret = "cannot create secret %q in the namespace %q: %v"
+	errDeletingSecret = "cannot delete immutable secret %q in the namespace %q: %v"
 	errGettingSecret  = "cannot get secret %q in the namespace %q: %v"
 	errUpdatingSecret = "cannot update secret %q in the namespace %q: %v"
 )
 
+// newSecret returns an empty Secret with the given name and namespace.
+func newSecret(name, namespace string) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+	}
+}
+
 // TlsSecret creates a TLS secret from the provided TLS data and Certificate object.
+// The CA certificates, if any, are stored under the CAKey of the Certificate, which defaults to KeyCACert.
+// When CombinedPEM is set on the Certificate, the secret also contains the full chain and key under KeyCombinedPEM.
+// When StorePrivateKey is false, the private key is omitted and the secret is of type Opaque.
+// When ImmutableSecret is set on the Certificate, the secret is immutable.
+// When the Encoding of the Certificate is der, the data is stored DER encoded instead, in a secret of type Opaque.
+// The secret is annotated with the fingerprint of the certificate, if it was parsed, and with its validity and
+// CommonName, formatted as RFC 3339, taken from the parsed certificate or else from the Certificate.
 func TlsSecret(tlsData TLSData, certificate *v1alpha1.Certificate, namespace string) *corev1.Secret {
-	return &corev1.Secret{
+	secret := &corev1.Secret{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:      certificate.Spec.SecretName,
+			Name:      tlsSecretName(certificate),
 			Namespace: namespace,
 		},
 		Type: corev1.SecretTypeTLS,
@@ -30,27 +75,315 @@ func TlsSecret(tlsData TLSData, certificate *v1alpha1.Certificate, namespace str
 			corev1.TLSPrivateKeyKey: tlsData.PrivateKeyBytes,
 		},
 	}
+
+	if !storePrivateKey(certificate) {
+		secret.Type = corev1.SecretTypeOpaque
+		delete(secret.Data, corev1.TLSPrivateKeyKey)
+		tlsData.PrivateKeyBytes = nil
+	}
+
+	if len(tlsData.CACertificateBytes) > 0 {
+		secret.Data[caKey(certificate)] = tlsData.CACertificateBytes
+	}
+
+	if certificate.Spec.CombinedPEM {
+		secret.Data[KeyCombinedPEM] = combinedPEM(tlsData)
+	}
+
+	if certificate.Spec.Encoding == v1alpha1.EncodingDER {
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = encodeDER(tlsData, secret.Data[KeyCombinedPEM])
+	}
+
+	if certificate.Spec.ImmutableSecret {
+		secret.Immutable = ptr.To(true)
+	}
+
+	secret.Annotations = secretAnnotations(tlsData, certificate)
+
+	return secret
+}
+
+// secretAnnotations returns the annotations of the TLS secret describing its certificate: the fingerprint of the
+// parsed certificate, and the validity and CommonName of the parsed certificate or else of the Certificate.
+// Empty values are omitted, and nil is returned if there are none.
+func secretAnnotations(tlsData TLSData, certificate *v1alpha1.Certificate) map[string]string {
+	annotations := map[string]string{}
+	validFrom, validTo := certificate.Status.ValidFrom.Time, certificate.Status.ValidTo.Time
+	commonName := certificate.Spec.CertificateData.Subject.CommonName
+
+	if tlsData.Certificate != nil {
+		annotations[AnnotationFingerprint] = Fingerprint(tlsData.Certificate)
+		validFrom, validTo = tlsData.Certificate.NotBefore, tlsData.Certificate.NotAfter
+		commonName = tlsData.Certificate.Subject.CommonName
+	}
+
+	if !validFrom.IsZero() {
+		annotations[AnnotationValidFrom] = validFrom.UTC().Format(time.RFC3339)
+	}
+	if !validTo.IsZero() {
+		annotations[AnnotationValidTo] = validTo.UTC().Format(time.RFC3339)
+	}
+	if commonName != "" {
+		annotations[AnnotationCommonName] = commonName
+	}
+
+	if len(annotations) == 0 {
+		return nil
+	}
+
+	return annotations
+}
+
+// isImmutable checks if the secret is immutable.
+func isImmutable(secret *corev1.Secret) bool {
+	return secret.Immutable != nil && *secret.Immutable
+}
+
+// secretType returns the type of the secret, which is Opaque unless set.
+func secretType(secret *corev1.Secret) corev1.SecretType {
+	if secret.Type == "" {
+		return corev1.SecretTypeOpaque
+	}
+
+	return secret.Type
+}
+
+// storePrivateKey checks if the private key should be stored in the secret, which it is unless explicitly disabled.
+func storePrivateKey(certificate *v1alpha1.Certificate) bool {
+	return certificate.Spec.StorePrivateKey == nil || *certificate.Spec.StorePrivateKey
+}
+
+// caKey returns the key of the CA certificates in the TLS secret of the Certificate, which defaults to KeyCACert.
+func caKey(certificate *v1alpha1.Certificate) string {
+	if certificate.Spec.CAKey != "" {
+		return certificate.Spec.CAKey
+	}
+
+	return KeyCACert
+}
+
+// tlsSecretName returns the name of the TLS secret of the Certificate.
+// The name resolved into the status, e.g. from a template, takes precedence over the literal name in the spec.
+func tlsSecretName(certificate *v1alpha1.Certificate) string {
+	if certificate.Status.SecretName != "" {
+		return certificate.Status.SecretName
+	}
+
+	return certificate.Spec.SecretName
+}
+
+// combinedPEM concatenates the certificate, CA certificates and private key, in this order.
+func combinedPEM(tlsData TLSData) []byte {
+	var combined []byte
+	for _, block := range [][]byte{tlsData.CertificateBytes, tlsData.CACertificateBytes, tlsData.PrivateKeyBytes} {
+		if len(block) == 0 {
+			continue
+		}
+
+		combined = append(combined, block...)
+		if block[len(block)-1] != '\n' {
+			combined = append(combined, '\n')
+		}
+	}
+
+	return combined
+}
+
+// encodeDER encodes the certificate, private key and CA certificates of the TLS data as DER secret data, along with
+// the combined PEM if any. The CA certificates are concatenated, and the private key is omitted if it is empty.
+func encodeDER(tlsData TLSData, combined []byte) map[string][]byte {
+	data := map[string][]byte{
+		KeyCertificateDER: pemToDER(tlsData.CertificateBytes),
+	}
+
+	if len(tlsData.PrivateKeyBytes) > 0 {
+		data[KeyPrivateKeyDER] = pemToDER(tlsData.PrivateKeyBytes)
+	}
+
+	if len(tlsData.CACertificateBytes) > 0 {
+		data[KeyCACertDER] = pemToDER(tlsData.CACertificateBytes)
+	}
+
+	if len(combined) > 0 {
+		data[KeyCombinedPEM] = combined
+	}
+
+	return data
 }
 
-// CreateOrUpdateTLSSecret creates or updates a TLS secret in the Kubernetes cluster.
+// pemToDER returns the concatenated DER bytes of the PEM blocks.
+func pemToDER(data []byte) []byte {
+	var der []byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return der
+		}
+
+		der = append(der, block.Bytes...)
+	}
+}
+
+// ParseSecretCertificate parses the certificate of a TLS secret, stored DER encoded under KeyCertificateDER or
+// PEM encoded under tls.crt.
+func ParseSecretCertificate(secret *corev1.Secret) (*x509.Certificate, error) {
+	if der, ok := secret.Data[KeyCertificateDER]; ok {
+		return x509.ParseCertificate(der)
+	}
+
+	return ParseCertificatePEM(secret.Data[corev1.TLSCertKey])
+}
+
+// CreateOrUpdateTLSSecret creates or updates a TLS secret in the Kubernetes cluster with server-side apply, as the
+// FieldManager, so that the operator only owns the fields it sets and keeps the fields other managers set, such as
+// foreign data keys, annotations or owner references.
+// An existing secret is not applied if the fields the operator sets are already identical and no data key it applied
+// before was dropped, to avoid needless writes.
+// An existing immutable secret whose data changed, or which should no longer be immutable, is deleted and created
+// again, since its data cannot be updated. So is an existing secret of another type, such as a kubernetes.io/tls
+// secret which no longer holds the private key, since the type of a secret cannot be updated.
 func CreateOrUpdateTLSSecret(ctx context.Context, kubeClient client.Client, secret *corev1.Secret) error {
 	existingSecret := &corev1.Secret{}
 
 	if err := kubeClient.Get(ctx, client.ObjectKey{Namespace: secret.Namespace, Name: secret.Name}, existingSecret); err != nil {
 		if errors.IsNotFound(err) {
-			if createErr := kubeClient.Create(ctx, secret); createErr != nil {
-				return fmt.Errorf(errCreatingSecret, secret.Name, secret.Namespace, createErr)
+			if applyErr := applySecret(ctx, kubeClient, secret); applyErr != nil {
+				return fmt.Errorf(errCreatingSecret, secret.Name, secret.Namespace, applyErr)
 			}
+			metrics.RecordSecretOperation(metrics.OperationCreate)
+			return nil
 		} else {
 			return fmt.Errorf(errGettingSecret, secret.Name, secret.Namespace, err)
 		}
 	}
 
-	existingSecret.Data = secret.Data
-	err := kubeClient.Update(ctx, existingSecret)
-	if err != nil {
+	originalSecret := existingSecret.DeepCopy()
+	for _, key := range appliedDataKeys(existingSecret) {
+		if _, ok := secret.Data[key]; !ok {
+			delete(existingSecret.Data, key)
+		}
+	}
+	for key, value := range secret.Data {
+		if existingSecret.Data == nil {
+			existingSecret.Data = map[string][]byte{}
+		}
+		existingSecret.Data[key] = value
+	}
+	for key, value := range secret.Annotations {
+		metav1.SetMetaDataAnnotation(&existingSecret.ObjectMeta, key, value)
+	}
+	for key, value := range secret.Labels {
+		metav1.SetMetaDataLabel(&existingSecret.ObjectMeta, key, value)
+	}
+	for _, ref := range secret.OwnerReferences {
+		existingSecret.OwnerReferences = upsertReference(existingSecret.OwnerReferences, ref)
+	}
+	if isImmutable(secret) != isImmutable(originalSecret) {
+		existingSecret.Immutable = secret.Immutable
+	}
+	if secret.Type != "" {
+		existingSecret.Type = secret.Type
+	}
+
+	if equality.Semantic.DeepEqual(originalSecret, existingSecret) {
+		return nil
+	}
+
+	if secretType(originalSecret) != secretType(existingSecret) {
+		return recreateSecret(ctx, kubeClient, existingSecret)
+	}
+
+	if isImmutable(originalSecret) && (!isImmutable(existingSecret) || !equality.Semantic.DeepEqual(originalSecret.Data, existingSecret.Data)) {
+		return recreateSecret(ctx, kubeClient, existingSecret)
+	}
+
+	if err := applySecret(ctx, kubeClient, secret); err != nil {
 		return fmt.Errorf(errUpdatingSecret, secret.Name, secret.Namespace, err)
 	}
+	metrics.RecordSecretOperation(metrics.OperationUpdate)
 
 	return nil
 }
+
+// applySecret applies the fields of the secret set by the operator with server-side apply, as the FieldManager.
+// Conflicting fields are forced, since the operator is the source of truth of the fields it sets.
+func applySecret(ctx context.Context, kubeClient client.Client, secret *corev1.Secret) error {
+	appliedSecret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: corev1.SchemeGroupVersion.String(), Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            secret.Name,
+			Namespace:       secret.Namespace,
+			Labels:          secret.Labels,
+			Annotations:     secret.Annotations,
+			OwnerReferences: secret.OwnerReferences,
+		},
+		Type:      secret.Type,
+		Data:      secret.Data,
+		Immutable: secret.Immutable,
+	}
+
+	return kubeClient.Patch(ctx, appliedSecret, client.Apply, client.FieldOwner(FieldManager), client.ForceOwnership)
+}
+
+// appliedDataKeys returns the data keys of the secret applied by the FieldManager, read from its managed fields.
+func appliedDataKeys(secret *corev1.Secret) []string {
+	var keys []string
+	for _, entry := range secret.ManagedFields {
+		if entry.Manager != FieldManager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+
+		fields := map[string]map[string]json.RawMessage{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		for field := range fields["f:data"] {
+			if key, ok := strings.CutPrefix(field, "f:"); ok {
+				keys = append(keys, key)
+			}
+		}
+	}
+
+	return keys
+}
+
+// recreateSecret deletes the existing secret and creates it again with the updated type, data and metadata.
+// The deletion is conditioned on the UID of the existing secret, so that a secret recreated meanwhile is not deleted.
+func recreateSecret(ctx context.Context, kubeClient client.Client, existingSecret *corev1.Secret) error {
+	if err := kubeClient.Delete(ctx, existingSecret, client.Preconditions{UID: &existingSecret.UID}); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf(errDeletingSecret, existingSecret.Name, existingSecret.Namespace, err)
+	}
+
+	recreatedSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            existingSecret.Name,
+			Namespace:       existingSecret.Namespace,
+			Labels:          existingSecret.Labels,
+			Annotations:     existingSecret.Annotations,
+			OwnerReferences: existingSecret.OwnerReferences,
+		},
+		Type:      existingSecret.Type,
+		Data:      existingSecret.Data,
+		Immutable: existingSecret.Immutable,
+	}
+	if err := kubeClient.Create(ctx, recreatedSecret, client.FieldOwner(FieldManager)); err != nil {
+		return fmt.Errorf(errCreatingSecret, recreatedSecret.Name, recreatedSecret.Namespace, err)
+	}
+	metrics.RecordSecretOperation(metrics.OperationRecreate)
+
+	return nil
+}
+
+// upsertReference adds the owner reference to the owner references, or replaces an existing reference to the same owner.
+func upsertReference(refs []metav1.OwnerReference, ref metav1.OwnerReference) []metav1.OwnerReference {
+	for i, existing := range refs {
+		if existing.UID == ref.UID && existing.Kind == ref.Kind && existing.Name == ref.Name {
+			refs[i] = ref
+			return refs
+		}
+	}
+
+	return append(refs, ref)
+}
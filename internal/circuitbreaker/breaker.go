@@ -0,0 +1,145 @@
+package circuitbreaker
+
+import (
+	"sync"
+	"time"
+)
+
+// State represents the state of a circuit.
+type State string
+
+const (
+	// StateClosed means requests are allowed and failures are being counted.
+	StateClosed State = "Closed"
+	// StateOpen means requests are rejected until the cooldown period elapses.
+	StateOpen State = "Open"
+	// StateHalfOpen means the cooldown period elapsed and a single probe request is allowed to test recovery.
+	// A success closes the circuit, a failure opens it again. Another probe is allowed if the result of the probe is
+	// not recorded within the cooldown period.
+	StateHalfOpen State = "HalfOpen"
+)
+
+// Breaker is an in-memory circuit breaker that tracks a separate circuit per key.
+type Breaker struct {
+	mu               sync.Mutex
+	failureThreshold int
+	cooldown         time.Duration
+	now              func() time.Time
+	circuits         map[string]*circuit
+}
+
+type circuit struct {
+	state    State
+	failures int
+	openedAt time.Time
+	// probedAt is the time at which the probe request of the half-open circuit was allowed, if any.
+	probedAt time.Time
+}
+
+// New returns a new Breaker which opens a circuit after failureThreshold consecutive failures,
+// and keeps it open for the cooldown period.
+func New(failureThreshold int, cooldown time.Duration) *Breaker {
+	return &Breaker{
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+		now:              time.Now,
+		circuits:         map[string]*circuit{},
+	}
+}
+
+// Allow reports whether a request for the given key is allowed. Once the circuit half-opens, only a single probe
+// request is allowed until its result is recorded.
+// If it is not, it also returns the remaining time until the circuit half-opens, or until another probe is allowed.
+func (b *Breaker) Allow(key string) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(key)
+	now := b.now()
+	switch c.state {
+	case StateClosed:
+		return true, 0
+	case StateOpen:
+		remaining := c.openedAt.Add(b.cooldown).Sub(now)
+		if remaining > 0 {
+			return false, remaining
+		}
+		c.state = StateHalfOpen
+	case StateHalfOpen:
+		remaining := c.probedAt.Add(b.cooldown).Sub(now)
+		if remaining > 0 {
+			return false, remaining
+		}
+	}
+
+	c.probedAt = now
+	return true, 0
+}
+
+// Check reports whether a request for the given key would be allowed, like Allow, but without taking the probe request
+// of a half-open circuit, so that callers which may not send a request do not hold it.
+// If it would not, it also returns the remaining time until the circuit half-opens, or until another probe is allowed.
+func (b *Breaker) Check(key string) (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(key)
+	now := b.now()
+	switch c.state {
+	case StateOpen:
+		if remaining := c.openedAt.Add(b.cooldown).Sub(now); remaining > 0 {
+			return false, remaining
+		}
+	case StateHalfOpen:
+		if remaining := c.probedAt.Add(b.cooldown).Sub(now); remaining > 0 {
+			return false, remaining
+		}
+	}
+
+	return true, 0
+}
+
+// RecordSuccess closes the circuit of the given key and resets its failure count.
+func (b *Breaker) RecordSuccess(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(key)
+	c.state = StateClosed
+	c.failures = 0
+	c.probedAt = time.Time{}
+}
+
+// RecordFailure counts a failure for the given key, and opens its circuit if the failure threshold is reached
+// or if the circuit is half-open.
+func (b *Breaker) RecordFailure(key string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.circuit(key)
+	c.failures++
+	if c.state == StateHalfOpen || c.failures >= b.failureThreshold {
+		c.state = StateOpen
+		c.openedAt = b.now()
+		c.probedAt = time.Time{}
+	}
+}
+
+// State returns the state of the circuit of the given key.
+func (b *Breaker) State(key string) State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.circuit(key).state
+}
+
+// circuit returns the circuit of the given key, creating a closed one if it does not exist.
+func (b *Breaker) circuit(key string) *circuit {
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{state: StateClosed}
+		b.circuits[key] = c
+	}
+
+	return c
+}
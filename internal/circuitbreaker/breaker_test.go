@@ -0,0 +1,167 @@
+package circuitbreaker
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	key       = "certificateconfig-sample"
+	threshold = 3
+	cooldown  = time.Minute
+)
+
+func Test_Breaker(t *testing.T) {
+	type step struct {
+		advance time.Duration
+		success bool
+		failure bool
+	}
+	type want struct {
+		state     State
+		allowed   bool
+		remaining time.Duration
+	}
+	cases := map[string]struct {
+		steps []step
+		want  want
+	}{
+		"ShouldStayClosedBelowThreshold": {
+			steps: []step{{failure: true}, {failure: true}},
+			want: want{
+				state:   StateClosed,
+				allowed: true,
+			},
+		},
+		"ShouldOpenAtThreshold": {
+			steps: []step{{failure: true}, {failure: true}, {failure: true}},
+			want: want{
+				state:     StateOpen,
+				allowed:   false,
+				remaining: cooldown,
+			},
+		},
+		"ShouldResetFailuresOnSuccess": {
+			steps: []step{{failure: true}, {failure: true}, {success: true}, {failure: true}},
+			want: want{
+				state:   StateClosed,
+				allowed: true,
+			},
+		},
+		"ShouldHalfOpenAfterCooldown": {
+			steps: []step{{failure: true}, {failure: true}, {failure: true}, {advance: cooldown - time.Second}, {advance: time.Second}},
+			want: want{
+				state:     StateHalfOpen,
+				allowed:   false,
+				remaining: cooldown,
+			},
+		},
+		"ShouldAllowSingleProbeWhenHalfOpen": {
+			steps: []step{{failure: true}, {failure: true}, {failure: true}, {advance: cooldown}, {advance: time.Second}},
+			want: want{
+				state:     StateHalfOpen,
+				allowed:   false,
+				remaining: cooldown - time.Second,
+			},
+		},
+		"ShouldAllowAnotherProbeIfProbeResultIsNotRecorded": {
+			steps: []step{{failure: true}, {failure: true}, {failure: true}, {advance: cooldown}, {advance: cooldown}},
+			want: want{
+				state:     StateHalfOpen,
+				allowed:   false,
+				remaining: cooldown,
+			},
+		},
+		"ShouldCloseWhenHalfOpenSucceeds": {
+			steps: []step{{failure: true}, {failure: true}, {failure: true}, {advance: cooldown}, {success: true}},
+			want: want{
+				state:   StateClosed,
+				allowed: true,
+			},
+		},
+		"ShouldReopenWhenHalfOpenFails": {
+			steps: []step{{failure: true}, {failure: true}, {failure: true}, {advance: cooldown}, {failure: true}},
+			want: want{
+				state:     StateOpen,
+				allowed:   false,
+				remaining: cooldown,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			b := New(threshold, cooldown)
+			b.now = func() time.Time { return now }
+
+			for _, s := range tc.steps {
+				now = now.Add(s.advance)
+				if s.advance > 0 {
+					b.Allow(key)
+				}
+				if s.success {
+					b.RecordSuccess(key)
+				}
+				if s.failure {
+					b.RecordFailure(key)
+				}
+			}
+
+			if diff := cmp.Diff(tc.want.state, b.State(key)); diff != "" {
+				t.Fatalf("State(...): -want state, +got state: %v", diff)
+			}
+
+			allowed, remaining := b.Allow(key)
+			if diff := cmp.Diff(tc.want.allowed, allowed); diff != "" {
+				t.Fatalf("Allow(...): -want allowed, +got allowed: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.remaining, remaining); diff != "" {
+				t.Fatalf("Allow(...): -want remaining, +got remaining: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_BreakerKeysAreIndependent(t *testing.T) {
+	b := New(1, cooldown)
+	b.RecordFailure(key)
+
+	if diff := cmp.Diff(StateOpen, b.State(key)); diff != "" {
+		t.Fatalf("State(...): -want state, +got state: %v", diff)
+	}
+
+	if diff := cmp.Diff(StateClosed, b.State("other-config")); diff != "" {
+		t.Fatalf("State(...): -want state, +got state: %v", diff)
+	}
+}
+
+func Test_BreakerCheckDoesNotTakeProbe(t *testing.T) {
+	now := time.Now()
+	b := New(1, cooldown)
+	b.now = func() time.Time { return now }
+
+	b.RecordFailure(key)
+	if allowed, remaining := b.Check(key); allowed || remaining != cooldown {
+		t.Fatalf("Check(...): expected the open circuit to reject requests for %v, got allowed %v and remaining %v", cooldown, allowed, remaining)
+	}
+
+	now = now.Add(cooldown)
+	for i := 0; i < 2; i++ {
+		if allowed, _ := b.Check(key); !allowed {
+			t.Fatalf("Check(...): expected the probe request to be allowed")
+		}
+	}
+	if diff := cmp.Diff(StateOpen, b.State(key)); diff != "" {
+		t.Fatalf("Check(...): -want state, +got state: %v", diff)
+	}
+
+	if allowed, _ := b.Allow(key); !allowed {
+		t.Fatalf("Allow(...): expected the probe request to be allowed after checks")
+	}
+	if allowed, remaining := b.Check(key); allowed || remaining != cooldown {
+		t.Fatalf("Check(...): expected the taken probe to reject requests for %v, got allowed %v and remaining %v", cooldown, allowed, remaining)
+	}
+}
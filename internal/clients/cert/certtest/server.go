@@ -0,0 +1,215 @@
+// Package certtest provides a fake Cert API for tests, serving the post, get and download endpoints
+// used by the Cert client over TLS.
+package certtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// APIPath is the path of the API endpoint of the fake Cert API.
+	APIPath = "/certificates/"
+	// DownloadPath is the path of the download endpoint of the fake Cert API, relative to a certificate.
+	DownloadPath = "/download/"
+
+	timeFormat = "2006-01-02T15:04:05"
+)
+
+// Operation is an operation of the fake Cert API.
+type Operation string
+
+const (
+	// OperationPost creates a certificate.
+	OperationPost Operation = "post"
+	// OperationGet gets the data of a certificate.
+	OperationGet Operation = "get"
+	// OperationDownload downloads a certificate.
+	OperationDownload Operation = "download"
+)
+
+// Server is a fake Cert API. Created certificates are all served with the same PKCS#12 data,
+// and are valid for a year from their creation.
+type Server struct {
+	*httptest.Server
+
+	token        string
+	pkcs12Data   string
+	password     string
+	mu           sync.Mutex
+	latency      time.Duration
+	failures     map[Operation]int
+	requests     map[Operation]int
+	certificates map[string]time.Time
+}
+
+// NewServer starts a fake Cert API which authenticates requests with the token and serves the base64-encoded
+// PKCS#12 data and its password on downloads. The Server should be closed when done.
+func NewServer(token, pkcs12Data, password string) *Server {
+	s := &Server{
+		token:        token,
+		pkcs12Data:   pkcs12Data,
+		password:     password,
+		failures:     map[Operation]int{},
+		requests:     map[Operation]int{},
+		certificates: map[string]time.Time{},
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// APIEndpoint returns the API endpoint of the fake Cert API.
+func (s *Server) APIEndpoint() string {
+	return s.URL + APIPath
+}
+
+// Credentials returns the data of a Secret holding the credentials of the fake Cert API, as expected by the Cert client.
+func (s *Server) Credentials() map[string][]byte {
+	credentials, _ := json.Marshal(map[string]string{
+		"apiEndpoint":      s.APIEndpoint(),
+		"downloadEndpoint": DownloadPath,
+		"token":            s.token,
+	})
+
+	return map[string][]byte{"credentials": credentials}
+}
+
+// SetLatency delays every response of the fake Cert API by the latency.
+func (s *Server) SetLatency(latency time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.latency = latency
+}
+
+// FailWith makes the operation fail with the HTTP status code. A status code of 0 makes it succeed again.
+func (s *Server) FailWith(operation Operation, statusCode int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if statusCode == 0 {
+		delete(s.failures, operation)
+		return
+	}
+	s.failures[operation] = statusCode
+}
+
+// Requests returns the number of requests received for the operation, including failed ones.
+func (s *Server) Requests(operation Operation) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[operation]
+}
+
+// handle serves a request to the fake Cert API.
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	operation, guid, ok := route(r)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests[operation]++
+	latency := s.latency
+	statusCode := s.failures[operation]
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if r.Header.Get("Authorization") != fmt.Sprintf("Bearer %s", s.token) {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if statusCode != 0 {
+		http.Error(w, http.StatusText(statusCode), statusCode)
+		return
+	}
+
+	switch operation {
+	case OperationPost:
+		writeJSON(w, map[string]string{"taskId": s.create()})
+	case OperationGet:
+		createdAt, found := s.created(guid)
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, map[string]string{
+			"validFrom":              createdAt.Format(timeFormat),
+			"validTo":                createdAt.AddDate(1, 0, 0).Format(timeFormat),
+			"signatureHashAlgorithm": "sha256",
+		})
+	case OperationDownload:
+		if _, found := s.created(guid); !found {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, map[string]string{
+			"form":     strings.TrimPrefix(r.URL.Path, APIPath+guid+DownloadPath),
+			"format":   "base64",
+			"data":     s.pkcs12Data,
+			"password": s.password,
+		})
+	}
+}
+
+// route returns the operation and the certificate guid of a request, if it matches an endpoint.
+func route(r *http.Request) (Operation, string, bool) {
+	path, ok := strings.CutPrefix(r.URL.Path, APIPath)
+	if !ok {
+		return "", "", false
+	}
+
+	switch {
+	case r.Method == http.MethodPost && path == "":
+		return OperationPost, "", true
+	case r.Method == http.MethodGet && path != "" && !strings.Contains(path, "/"):
+		return OperationGet, path, true
+	case r.Method == http.MethodGet && strings.Contains(path, DownloadPath):
+		guid, _, _ := strings.Cut(path, DownloadPath)
+		return OperationDownload, guid, true
+	default:
+		return "", "", false
+	}
+}
+
+// create creates a certificate and returns its guid.
+func (s *Server) create() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	guid := fmt.Sprintf("guid-%d", len(s.certificates)+1)
+	s.certificates[guid] = time.Now().UTC()
+
+	return guid
+}
+
+// created returns the creation time of the certificate with the guid, if it exists.
+func (s *Server) created(guid string) (time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	createdAt, found := s.certificates[guid]
+	return createdAt, found
+}
+
+// writeJSON writes the value as a JSON response.
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(value)
+}
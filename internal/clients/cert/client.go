@@ -2,9 +2,13 @@ package cert
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/dana-team/certificate-operator/api/v1alpha1"
@@ -13,43 +17,90 @@ import (
 )
 
 const (
-	defaultWaitTimeout  = time.Minute
+	defaultTokenFileTTL = 10 * time.Second
 	keyAPIEndpoint      = "apiEndpoint"
 	keyDownloadEndpoint = "downloadEndpoint"
 	keyToken            = "token"
+	keyTokenFile        = "tokenFile"
 	keyCredentials      = "credentials"
+	keyReplicas         = "replicas"
 
-	errMissingAPIEndpoint      = "missing API Endpoint in secret"
-	errMissingDownloadEndpoint = "missing Download API Endpoint in secret"
-	errMissingToken            = "missing token in secret"
+	errMissingAPIEndpoint      = `missing API Endpoint, expected the "apiEndpoint" field of the CertificateConfig or key in secret`
+	errMissingDownloadEndpoint = `missing Download API Endpoint, expected the "downloadEndpoint" field of the CertificateConfig or key in secret`
+	errMissingToken            = `missing token in secret, expected the "token" or "tokenFile" key`
+	errInvalidTokenFile        = "cannot use token file %q: %v"
+	errTokenFilesDisabled      = `cannot use token file %q: token files are disabled, since no token file directory is set`
+	errTokenFileOutsideDir     = "cannot use token file %q: it is not within the token file directory %q"
 	errUnmarshalCredentials    = "cannot unmarshal credentials as JSON: %v"
+	errUnmarshalReplicas       = "cannot unmarshal replicas as JSON: %v"
+	errMissingReplicaEndpoint  = `missing API Endpoint of replica %d, expected the "apiEndpoint" field`
 )
 
+// DefaultWaitTimeout is the default maximum time to wait for responses of the Cert API, used for the
+// CertificateConfigs which do not set a WaitTimeout.
+const DefaultWaitTimeout = time.Minute
+
+// DefaultMaxWaitTimeout is the default maximum wait timeout of CertificateConfigs. Longer wait timeouts are clamped
+// to it, so that a single issuance cannot hog a reconcile worker.
+const DefaultMaxWaitTimeout = 10 * time.Minute
+
 type ClientBuilder func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (Client, error)
 
 // Client is the interface to interact with Cert API service.
 type Client interface {
-	PostCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (string, error)
+	PostCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (PostCertificateResponse, error)
 	DownloadCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (DownloadCertificateResponse, error)
 	GetCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (GetCertificateResponse, error)
 }
 
 type client struct {
-	log              logr.Logger
-	localHttpClient  httpClient.Client
-	timeout          time.Duration
-	apiEndpoint      string
-	downloadEndpoint string
-	token            string
+	log                  logr.Logger
+	configName           string
+	localHttpClient      httpClient.Client
+	timeout              time.Duration
+	apiEndpoint          string
+	downloadEndpoint     string
+	token                string
+	tokenFile            string
+	tokenFileTTL         time.Duration
+	responsePath         string
+	errorField           string
+	successField         string
+	maxResponseSize      int64
+	idempotencyKeyHeader string
+	metadataFields       []string
+	acceptLanguage       string
+	methods              v1alpha1.HTTPMethods
+	followRedirects      bool
+	defaultSubject       v1alpha1.Subject
+	minTLSVersion        uint16
+	disableHTTP2         bool
+	renegotiation        tls.RenegotiationSupport
+	replicas             []Replica
+
+	tokenMu     sync.Mutex
+	cachedToken string
+	tokenReadAt time.Time
+
+	replicaMu      sync.Mutex
+	nextReplica    int
+	unhealthyUntil map[string]time.Time
 }
 
 // NewClient returns a new client.
 func NewClient(log logr.Logger, options ...func(*client)) Client {
-	cl := &client{}
-	cl.localHttpClient = httpClient.NewClient(log)
+	cl := &client{log: log, tokenFileTTL: defaultTokenFileTTL, followRedirects: true}
 	for _, o := range options {
 		o(cl)
 	}
+	cl.localHttpClient = httpClient.NewClient(
+		log,
+		httpClient.WithMaxResponseSize(cl.maxResponseSize),
+		httpClient.WithFollowRedirects(cl.followRedirects),
+		httpClient.WithMinTLSVersion(cl.minTLSVersion),
+		httpClient.WithDisableHTTP2(cl.disableHTTP2),
+		httpClient.WithRenegotiation(cl.renegotiation),
+	)
 
 	return cl
 }
@@ -75,6 +126,123 @@ func WithToken(token string) func(*client) {
 	}
 }
 
+// WithTokenFile returns a client with the Token File field populated.
+// The token is read from the file, so that rotated short-lived tokens, such as projected ServiceAccount tokens, are used.
+func WithTokenFile(tokenFile string) func(*client) {
+	return func(c *client) {
+		c.tokenFile = tokenFile
+	}
+}
+
+// WithResponsePath returns a client with the Response Path field populated.
+func WithResponsePath(responsePath string) func(*client) {
+	return func(c *client) {
+		c.responsePath = responsePath
+	}
+}
+
+// WithErrorField returns a client with the Error Field field populated.
+// Responses are not checked for an error field if it is empty.
+func WithErrorField(errorField string) func(*client) {
+	return func(c *client) {
+		c.errorField = errorField
+	}
+}
+
+// WithSuccessField returns a client with the Success Field field populated.
+// Responses are not checked for a success field if it is empty.
+func WithSuccessField(successField string) func(*client) {
+	return func(c *client) {
+		c.successField = successField
+	}
+}
+
+// WithMaxResponseSize returns a client with the Max Response Size field populated.
+func WithMaxResponseSize(maxResponseSize int64) func(*client) {
+	return func(c *client) {
+		c.maxResponseSize = maxResponseSize
+	}
+}
+
+// WithIdempotencyKeyHeader returns a client with the Idempotency Key Header field populated.
+// The DefaultIdempotencyKeyHeader is used if it is empty.
+func WithIdempotencyKeyHeader(idempotencyKeyHeader string) func(*client) {
+	return func(c *client) {
+		c.idempotencyKeyHeader = idempotencyKeyHeader
+	}
+}
+
+// WithMetadataFields returns a client with the Metadata Fields field populated.
+func WithMetadataFields(metadataFields []string) func(*client) {
+	return func(c *client) {
+		c.metadataFields = metadataFields
+	}
+}
+
+// WithAcceptLanguage returns a client with the Accept Language field populated.
+// The Accept-Language header is not sent if it is empty.
+func WithAcceptLanguage(acceptLanguage string) func(*client) {
+	return func(c *client) {
+		c.acceptLanguage = acceptLanguage
+	}
+}
+
+// WithMethods returns a client with the Methods field populated.
+// The default method of an operation is used if its method is empty.
+func WithMethods(methods v1alpha1.HTTPMethods) func(*client) {
+	return func(c *client) {
+		c.methods = methods
+	}
+}
+
+// WithFollowRedirects returns a client with the Follow Redirects field populated.
+// Redirects of the Cert API are followed by default.
+func WithFollowRedirects(followRedirects bool) func(*client) {
+	return func(c *client) {
+		c.followRedirects = followRedirects
+	}
+}
+
+// WithConfigName returns a client with the Config Name field populated.
+// It is the name of the CertificateConfig of the client, describing its requests in spans.
+func WithConfigName(configName string) func(*client) {
+	return func(c *client) {
+		c.configName = configName
+	}
+}
+
+// WithDefaultSubject returns a client with the Default Subject field populated.
+// Its fields are requested for the certificates which leave them empty.
+func WithDefaultSubject(defaultSubject v1alpha1.Subject) func(*client) {
+	return func(c *client) {
+		c.defaultSubject = defaultSubject
+	}
+}
+
+// WithMinTLSVersion returns a client with the Min TLS Version field populated.
+// The default minimum TLS version of the HTTP client is used if it is zero.
+func WithMinTLSVersion(minTLSVersion uint16) func(*client) {
+	return func(c *client) {
+		c.minTLSVersion = minTLSVersion
+	}
+}
+
+// WithDisableHTTP2 returns a client with the Disable HTTP2 field populated.
+// HTTP/2 is attempted by default.
+func WithDisableHTTP2(disableHTTP2 bool) func(*client) {
+	return func(c *client) {
+		c.disableHTTP2 = disableHTTP2
+	}
+}
+
+// WithRenegotiation returns a client with the Renegotiation field populated.
+// TLS renegotiation is refused by default.
+func WithRenegotiation(renegotiation tls.RenegotiationSupport) func(*client) {
+	return func(c *client) {
+		c.renegotiation = renegotiation
+	}
+}
+
 // WithTimeout returns a client with the Timeout field populated.
 func WithTimeout(timeout time.Duration) func(*client) {
 	return func(c *client) {
@@ -83,45 +251,222 @@ func WithTimeout(timeout time.Duration) func(*client) {
 }
 
 // NewClientFromCertificateConfigAndSecretData creates a new Client instance using the provided certificateConfig spec and secret data.
+// The endpoints set in the certificateConfig spec take precedence over the endpoints in the secret data.
+// The DefaultWaitTimeout is used if the certificateConfig does not set a WaitTimeout, and wait timeouts are clamped
+// to the DefaultMaxWaitTimeout. Token files are disabled.
 func NewClientFromCertificateConfigAndSecretData(log logr.Logger, certificateConfig *v1alpha1.CertificateConfig, secretData map[string][]byte) (Client, error) {
+	return NewClientBuilder(DefaultWaitTimeout, DefaultMaxWaitTimeout, "")(log, certificateConfig, secretData)
+}
+
+// NewClientBuilder returns a ClientBuilder creating clients like NewClientFromCertificateConfigAndSecretData, with
+// the given default wait timeout used for the CertificateConfigs which do not set a WaitTimeout, and the given
+// maximum wait timeout to which longer wait timeouts are clamped.
+// The DefaultWaitTimeout and DefaultMaxWaitTimeout are used if the default and maximum wait timeouts are not positive.
+// The token file of the secret data must be within the token file directory, such as the directory of the projected
+// ServiceAccount tokens of the operator pod, so that the editors of secrets cannot make the operator read and send
+// other files. Token files are disabled if the token file directory is empty.
+func NewClientBuilder(defaultWaitTimeout, maxWaitTimeout time.Duration, tokenFileDir string) ClientBuilder {
+	if defaultWaitTimeout <= 0 {
+		defaultWaitTimeout = DefaultWaitTimeout
+	}
+	if maxWaitTimeout <= 0 {
+		maxWaitTimeout = DefaultMaxWaitTimeout
+	}
+
+	return func(log logr.Logger, certificateConfig *v1alpha1.CertificateConfig, secretData map[string][]byte) (Client, error) {
+		return newClientFromCertificateConfigAndSecretData(log, certificateConfig, secretData, defaultWaitTimeout, maxWaitTimeout, tokenFileDir)
+	}
+}
+
+// newClientFromCertificateConfigAndSecretData creates a new Client instance using the provided certificateConfig spec
+// and secret data, with the default wait timeout used if the certificateConfig does not set a WaitTimeout, the
+// maximum wait timeout to which longer wait timeouts are clamped, and the directory the token file must be within.
+func newClientFromCertificateConfigAndSecretData(log logr.Logger, certificateConfig *v1alpha1.CertificateConfig, secretData map[string][]byte, defaultWaitTimeout, maxWaitTimeout time.Duration, tokenFileDir string) (Client, error) {
 	creds := map[string]string{}
 
 	if err := json.Unmarshal(secretData[keyCredentials], &creds); err != nil {
 		return nil, fmt.Errorf(errUnmarshalCredentials, err)
 	}
 
-	apiEndpoint := creds[keyAPIEndpoint]
+	apiEndpoint := endpoint(certificateConfig.Spec.APIEndpoint, creds[keyAPIEndpoint])
 	if apiEndpoint == "" {
 		return nil, errors.New(errMissingAPIEndpoint)
 	}
 
-	downloadEndpoint := creds[keyDownloadEndpoint]
+	downloadEndpoint := endpoint(certificateConfig.Spec.DownloadEndpoint, creds[keyDownloadEndpoint])
 	if downloadEndpoint == "" {
 		return nil, errors.New(errMissingDownloadEndpoint)
 	}
 
 	token := creds[keyToken]
-	if token == "" {
+	tokenFile := creds[keyTokenFile]
+	if token == "" && tokenFile == "" {
 		return nil, errors.New(errMissingToken)
 	}
 
-	timeout := getWaitTimeout(certificateConfig)
+	if tokenFile != "" {
+		if err := checkTokenFile(tokenFile, tokenFileDir); err != nil {
+			return nil, err
+		}
+	}
+
+	replicas, err := getReplicas(secretData)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := getWaitTimeout(log, certificateConfig, defaultWaitTimeout, maxWaitTimeout)
 
 	return NewClient(
 		log,
+		WithConfigName(certificateConfig.Name),
 		WithAPIEndpoint(apiEndpoint),
 		WithDownloadEndpoint(downloadEndpoint),
 		WithToken(token),
+		WithTokenFile(tokenFile),
 		WithTimeout(timeout),
+		WithResponsePath(certificateConfig.Spec.ResponsePath),
+		WithErrorField(certificateConfig.Spec.ErrorField),
+		WithSuccessField(certificateConfig.Spec.SuccessField),
+		WithMaxResponseSize(getMaxResponseSize(certificateConfig)),
+		WithIdempotencyKeyHeader(certificateConfig.Spec.IdempotencyKeyHeader),
+		WithMetadataFields(certificateConfig.Spec.CAMetadataFields),
+		WithAcceptLanguage(certificateConfig.Spec.AcceptLanguage),
+		WithMethods(certificateConfig.Spec.Methods),
+		WithFollowRedirects(followRedirects(certificateConfig)),
+		WithDefaultSubject(certificateConfig.Spec.DefaultSubject),
+		WithMinTLSVersion(minTLSVersion(certificateConfig)),
+		WithDisableHTTP2(certificateConfig.Spec.DisableHTTP2),
+		WithRenegotiation(renegotiation(certificateConfig)),
+		WithReplicas(replicas),
 	), nil
 
 }
 
+// checkTokenFile checks that the token file is an existing file within the token file directory. The path is checked
+// before the file is accessed, and again once its symbolic links are resolved, so that neither a relative path nor a
+// link can escape the directory.
+func checkTokenFile(tokenFile, tokenFileDir string) error {
+	if tokenFileDir == "" {
+		return fmt.Errorf(errTokenFilesDisabled, tokenFile)
+	}
+
+	if !filepath.IsAbs(tokenFile) || !withinDir(filepath.Clean(tokenFile), filepath.Clean(tokenFileDir)) {
+		return fmt.Errorf(errTokenFileOutsideDir, tokenFile, tokenFileDir)
+	}
+
+	resolvedDir, err := filepath.EvalSymlinks(tokenFileDir)
+	if err != nil {
+		return fmt.Errorf(errInvalidTokenFile, tokenFile, err)
+	}
+
+	resolvedFile, err := filepath.EvalSymlinks(tokenFile)
+	if err != nil {
+		return fmt.Errorf(errInvalidTokenFile, tokenFile, err)
+	}
+
+	if !withinDir(resolvedFile, resolvedDir) {
+		return fmt.Errorf(errTokenFileOutsideDir, tokenFile, tokenFileDir)
+	}
+
+	return nil
+}
+
+// withinDir checks if the clean path is within the clean directory.
+func withinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+
+	return rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// endpoint returns the endpoint set in the CertificateConfig, or the endpoint in the credentials if it is not set.
+func endpoint(configured, credentials string) string {
+	if configured != "" {
+		return configured
+	}
+
+	return credentials
+}
+
+// getReplicas returns the additional replicas of the Cert API held in the secret data, if any.
+// Every replica must have an API endpoint. Replicas without a token use the token of the credentials.
+func getReplicas(secretData map[string][]byte) ([]Replica, error) {
+	data, ok := secretData[keyReplicas]
+	if !ok {
+		return nil, nil
+	}
+
+	var replicas []Replica
+	if err := json.Unmarshal(data, &replicas); err != nil {
+		return nil, fmt.Errorf(errUnmarshalReplicas, err)
+	}
+
+	for i, replica := range replicas {
+		if replica.APIEndpoint == "" {
+			return nil, fmt.Errorf(errMissingReplicaEndpoint, i)
+		}
+	}
+
+	return replicas, nil
+}
+
+// followRedirects returns whether the redirects of the Cert API are followed, which they are unless explicitly disabled
+// in the CertificateConfig.
+func followRedirects(certificateConfig *v1alpha1.CertificateConfig) bool {
+	return certificateConfig.Spec.FollowRedirects == nil || *certificateConfig.Spec.FollowRedirects
+}
+
+// minTLSVersion returns the minimum TLS version of the connections to the Cert API set in the CertificateConfig, or zero
+// for the default minimum TLS version if it is not set.
+func minTLSVersion(certificateConfig *v1alpha1.CertificateConfig) uint16 {
+	switch certificateConfig.Spec.MinTLSVersion {
+	case v1alpha1.TLSVersion12:
+		return tls.VersionTLS12
+	case v1alpha1.TLSVersion13:
+		return tls.VersionTLS13
+	default:
+		return 0
+	}
+}
+
+// renegotiation returns the TLS renegotiation support of the connections to the Cert API set in the CertificateConfig,
+// which is never by default.
+func renegotiation(certificateConfig *v1alpha1.CertificateConfig) tls.RenegotiationSupport {
+	switch certificateConfig.Spec.TLSRenegotiation {
+	case v1alpha1.TLSRenegotiationOnceAsClient:
+		return tls.RenegotiateOnceAsClient
+	case v1alpha1.TLSRenegotiationFreelyAsClient:
+		return tls.RenegotiateFreelyAsClient
+	default:
+		return tls.RenegotiateNever
+	}
+}
+
 // getWaitTimeout returns the wait timeout duration specified in the CertificateConfig, or the default wait timeout if not specified.
-func getWaitTimeout(certificateConfig *v1alpha1.CertificateConfig) time.Duration {
+// Wait timeouts longer than the maximum wait timeout are clamped to it, with a logged warning.
+func getWaitTimeout(log logr.Logger, certificateConfig *v1alpha1.CertificateConfig, defaultWaitTimeout, maxWaitTimeout time.Duration) time.Duration {
+	waitTimeout := defaultWaitTimeout
 	if certificateConfig.Spec.WaitTimeout != nil {
-		return certificateConfig.Spec.WaitTimeout.Duration
+		waitTimeout = certificateConfig.Spec.WaitTimeout.Duration
+	}
+
+	if waitTimeout > maxWaitTimeout {
+		log.Info("wait timeout exceeds the maximum wait timeout, clamping it", "waitTimeout", waitTimeout.String(), "maxWaitTimeout", maxWaitTimeout.String())
+		return maxWaitTimeout
+	}
+
+	return waitTimeout
+}
+
+// getMaxResponseSize returns the maximum response size in bytes specified in the CertificateConfig,
+// or 0 if not specified, in which case the default maximum response size is used.
+func getMaxResponseSize(certificateConfig *v1alpha1.CertificateConfig) int64 {
+	if certificateConfig.Spec.MaxResponseSize != nil {
+		return certificateConfig.Spec.MaxResponseSize.Value()
 	}
 
-	return defaultWaitTimeout
+	return 0
 }
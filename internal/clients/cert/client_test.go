@@ -1,8 +1,12 @@
 package cert
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -11,6 +15,7 @@ import (
 	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -18,14 +23,18 @@ var (
 	testAPIEndpoint      = "https://api.endpoint"
 	testDownloadEndpoint = "https://download.endpoint"
 	testToken            = "dummy-token"
+	testTokenFile        = "/var/run/secrets/tokens/cert-token"
 	testTimeout          = 2 * time.Minute
+	testResponsePath     = "data"
 )
 
 const (
 	withAPIEndpoint      = "WithAPIEndpoint"
 	withDownloadEndpoint = "WithDownloadEndpoint"
 	withToken            = "WithToken"
+	withTokenFile        = "WithTokenFile"
 	withTimeout          = "WithTimeout"
+	withResponsePath     = "WithResponsePath"
 )
 
 func TestClientOptions(t *testing.T) {
@@ -68,6 +77,15 @@ func TestClientOptions(t *testing.T) {
 				value: testToken,
 			},
 		},
+		"ShouldCreateSuccessfullyWithTokenFile": {
+			args: args{
+				name:   withTokenFile,
+				option: WithTokenFile(testTokenFile),
+			},
+			want: want{
+				value: testTokenFile,
+			},
+		},
 		"ShouldCreateSuccessfullyWithTimeout": {
 			args: args{
 				name:   withTimeout,
@@ -77,6 +95,15 @@ func TestClientOptions(t *testing.T) {
 				value: testTimeout,
 			},
 		},
+		"ShouldCreateSuccessfullyWithResponsePath": {
+			args: args{
+				name:   withResponsePath,
+				option: WithResponsePath(testResponsePath),
+			},
+			want: want{
+				value: testResponsePath,
+			},
+		},
 	}
 
 	for name, tc := range cases {
@@ -95,12 +122,170 @@ func TestClientOptions(t *testing.T) {
 				if diff := cmp.Diff(tc.want.value, cl.(*client).token, test.EquateErrors()); diff != "" {
 					t.Fatalf("createClient(...): -want error, +got error: %v", diff)
 				}
+			case withTokenFile:
+				if diff := cmp.Diff(tc.want.value, cl.(*client).tokenFile, test.EquateErrors()); diff != "" {
+					t.Fatalf("createClient(...): -want error, +got error: %v", diff)
+				}
 			case withTimeout:
 				if diff := cmp.Diff(tc.want.value, cl.(*client).timeout, test.EquateErrors()); diff != "" {
 					t.Fatalf("createClient(...): -want error, +got error: %v", diff)
 				}
+			case withResponsePath:
+				if diff := cmp.Diff(tc.want.value, cl.(*client).responsePath, test.EquateErrors()); diff != "" {
+					t.Fatalf("createClient(...): -want error, +got error: %v", diff)
+				}
+			}
+
+		})
+	}
+}
+
+func Test_getMaxResponseSize(t *testing.T) {
+	maxResponseSize := resource.MustParse("1Mi")
+
+	type args struct {
+		certificateConfig *v1alpha1.CertificateConfig
+	}
+	type want struct {
+		value int64
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldSetCustomMaxResponseSize": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{
+					Spec: v1alpha1.CertificateConfigSpec{
+						MaxResponseSize: &maxResponseSize,
+					},
+				},
+			},
+			want: want{
+				value: 1 << 20,
+			},
+		},
+		"ShouldKeepDefaultMaxResponseSize": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{},
+			},
+			want: want{
+				value: 0,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := getMaxResponseSize(tc.args.certificateConfig)
+			if diff := cmp.Diff(tc.want.value, got); diff != "" {
+				t.Fatalf("getMaxResponseSize(...): -want value, +got value: %v", diff)
 			}
+		})
+	}
+}
+
+func Test_renegotiation(t *testing.T) {
+	type args struct {
+		certificateConfig *v1alpha1.CertificateConfig
+	}
+	type want struct {
+		value tls.RenegotiationSupport
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldRenegotiateOnce": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{
+					Spec: v1alpha1.CertificateConfigSpec{TLSRenegotiation: v1alpha1.TLSRenegotiationOnceAsClient},
+				},
+			},
+			want: want{
+				value: tls.RenegotiateOnceAsClient,
+			},
+		},
+		"ShouldRenegotiateFreely": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{
+					Spec: v1alpha1.CertificateConfigSpec{TLSRenegotiation: v1alpha1.TLSRenegotiationFreelyAsClient},
+				},
+			},
+			want: want{
+				value: tls.RenegotiateFreelyAsClient,
+			},
+		},
+		"ShouldNeverRenegotiateByDefault": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{},
+			},
+			want: want{
+				value: tls.RenegotiateNever,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := renegotiation(tc.args.certificateConfig)
+			if diff := cmp.Diff(tc.want.value, got); diff != "" {
+				t.Fatalf("renegotiation(...): -want value, +got value: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_minTLSVersion(t *testing.T) {
+	type args struct {
+		certificateConfig *v1alpha1.CertificateConfig
+	}
+	type want struct {
+		value uint16
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldSetTLS12": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{
+					Spec: v1alpha1.CertificateConfigSpec{MinTLSVersion: v1alpha1.TLSVersion12},
+				},
+			},
+			want: want{
+				value: tls.VersionTLS12,
+			},
+		},
+		"ShouldSetTLS13": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{
+					Spec: v1alpha1.CertificateConfigSpec{MinTLSVersion: v1alpha1.TLSVersion13},
+				},
+			},
+			want: want{
+				value: tls.VersionTLS13,
+			},
+		},
+		"ShouldKeepDefaultMinTLSVersion": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{},
+			},
+			want: want{
+				value: 0,
+			},
+		},
+	}
 
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := minTLSVersion(tc.args.certificateConfig)
+			if diff := cmp.Diff(tc.want.value, got); diff != "" {
+				t.Fatalf("minTLSVersion(...): -want value, +got value: %v", diff)
+			}
 		})
 	}
 }
@@ -155,19 +340,19 @@ func Test_getWaitTimeout(t *testing.T) {
 					WaitTimeout: nil,
 				},
 			},
-			expectedWaitTimeout: defaultWaitTimeout,
+			expectedWaitTimeout: DefaultWaitTimeout,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			assert.Equal(t, tt.expectedWaitTimeout, getWaitTimeout(tt.certificateConfig))
+			assert.Equal(t, tt.expectedWaitTimeout, getWaitTimeout(logr.Logger{}, tt.certificateConfig, DefaultWaitTimeout, DefaultMaxWaitTimeout))
 		})
 	}
 
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
-			gotValue := getWaitTimeout(tc.args.certificateConfig)
+			gotValue := getWaitTimeout(logr.Logger{}, tc.args.certificateConfig, DefaultWaitTimeout, DefaultMaxWaitTimeout)
 			if diff := cmp.Diff(tc.want.value, gotValue, test.EquateErrors()); diff != "" {
 				t.Fatalf("getWaitTimeout(...): -want value, +got value: %v", diff)
 			}
@@ -175,9 +360,182 @@ func Test_getWaitTimeout(t *testing.T) {
 	}
 }
 
+func Test_getWaitTimeoutMax(t *testing.T) {
+	type args struct {
+		waitTimeout    *metav1.Duration
+		maxWaitTimeout time.Duration
+	}
+	type want struct {
+		value time.Duration
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldKeepWaitTimeoutWithinMax": {
+			args: args{
+				waitTimeout:    &metav1.Duration{Duration: testTimeout},
+				maxWaitTimeout: 5 * time.Minute,
+			},
+			want: want{
+				value: testTimeout,
+			},
+		},
+		"ShouldKeepWaitTimeoutEqualToMax": {
+			args: args{
+				waitTimeout:    &metav1.Duration{Duration: 5 * time.Minute},
+				maxWaitTimeout: 5 * time.Minute,
+			},
+			want: want{
+				value: 5 * time.Minute,
+			},
+		},
+		"ShouldClampWaitTimeoutOverMax": {
+			args: args{
+				waitTimeout:    &metav1.Duration{Duration: time.Hour},
+				maxWaitTimeout: 5 * time.Minute,
+			},
+			want: want{
+				value: 5 * time.Minute,
+			},
+		},
+		"ShouldUseDefaultWaitTimeoutForNil": {
+			args: args{
+				waitTimeout:    nil,
+				maxWaitTimeout: 5 * time.Minute,
+			},
+			want: want{
+				value: DefaultWaitTimeout,
+			},
+		},
+		"ShouldClampDefaultWaitTimeoutOverMax": {
+			args: args{
+				waitTimeout:    nil,
+				maxWaitTimeout: 30 * time.Second,
+			},
+			want: want{
+				value: 30 * time.Second,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			certificateConfig := &v1alpha1.CertificateConfig{Spec: v1alpha1.CertificateConfigSpec{WaitTimeout: tc.args.waitTimeout}}
+
+			got := getWaitTimeout(logr.Logger{}, certificateConfig, DefaultWaitTimeout, tc.args.maxWaitTimeout)
+			if diff := cmp.Diff(tc.want.value, got); diff != "" {
+				t.Fatalf("getWaitTimeout(...): -want value, +got value: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_NewClientBuilder(t *testing.T) {
+	credentials, _ := json.Marshal(map[string]string{
+		keyAPIEndpoint:      testAPIEndpoint,
+		keyDownloadEndpoint: testDownloadEndpoint,
+		keyToken:            testToken,
+	})
+
+	type args struct {
+		defaultWaitTimeout time.Duration
+		maxWaitTimeout     time.Duration
+		waitTimeout        *metav1.Duration
+	}
+	type want struct {
+		timeout time.Duration
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldUseInjectedDefaultWaitTimeout": {
+			args: args{
+				defaultWaitTimeout: 5 * time.Minute,
+				waitTimeout:        nil,
+			},
+			want: want{
+				timeout: 5 * time.Minute,
+			},
+		},
+		"ShouldPreferWaitTimeoutOfConfig": {
+			args: args{
+				defaultWaitTimeout: 5 * time.Minute,
+				waitTimeout:        &metav1.Duration{Duration: testTimeout},
+			},
+			want: want{
+				timeout: testTimeout,
+			},
+		},
+		"ShouldUseDefaultWaitTimeoutForNonPositiveDefault": {
+			args: args{
+				defaultWaitTimeout: 0,
+				waitTimeout:        nil,
+			},
+			want: want{
+				timeout: DefaultWaitTimeout,
+			},
+		},
+		"ShouldClampWaitTimeoutOfConfigToMax": {
+			args: args{
+				maxWaitTimeout: time.Minute,
+				waitTimeout:    &metav1.Duration{Duration: testTimeout},
+			},
+			want: want{
+				timeout: time.Minute,
+			},
+		},
+		"ShouldUseDefaultMaxWaitTimeoutForNonPositiveMax": {
+			args: args{
+				maxWaitTimeout: 0,
+				waitTimeout:    &metav1.Duration{Duration: time.Hour},
+			},
+			want: want{
+				timeout: DefaultMaxWaitTimeout,
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			certConfig := &v1alpha1.CertificateConfig{Spec: v1alpha1.CertificateConfigSpec{WaitTimeout: tc.args.waitTimeout}}
+
+			got, err := NewClientBuilder(tc.args.defaultWaitTimeout, tc.args.maxWaitTimeout, "")(logr.Logger{}, certConfig, map[string][]byte{keyCredentials: credentials})
+			if err != nil {
+				t.Fatalf("NewClientBuilder(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want.timeout, got.(*client).timeout); diff != "" {
+				t.Fatalf("NewClientBuilder(...): -want timeout, +got timeout: %v", diff)
+			}
+		})
+	}
+}
+
 func Test_NewClientFromCertificateConfigAndSecretData(t *testing.T) {
+	tokenFileDir := t.TempDir()
+	tokenFile := filepath.Join(tokenFileDir, "token")
+	if err := os.WriteFile(tokenFile, []byte(testToken), 0600); err != nil {
+		t.Fatalf("Failed to write token file: %v", err)
+	}
+	missingTokenFile := filepath.Join(tokenFileDir, "missing")
+	_, errMissingFile := filepath.EvalSymlinks(missingTokenFile)
+
+	outsideFile := filepath.Join(t.TempDir(), "outside")
+	if err := os.WriteFile(outsideFile, []byte(testToken), 0600); err != nil {
+		t.Fatalf("Failed to write outside file: %v", err)
+	}
+	linkToOutsideFile := filepath.Join(tokenFileDir, "link")
+	if err := os.Symlink(outsideFile, linkToOutsideFile); err != nil {
+		t.Fatalf("Failed to link outside file: %v", err)
+	}
+	escapingTokenFile := tokenFileDir + "/../outside"
+
 	type args struct {
-		credentials map[string]string
+		credentials  map[string]string
+		tokenFileDir string
 	}
 	type want struct {
 		err error
@@ -221,6 +579,84 @@ func Test_NewClientFromCertificateConfigAndSecretData(t *testing.T) {
 				err: errors.New(errMissingDownloadEndpoint),
 			},
 		},
+		"ShouldCreateClientWithTokenFile": {
+			args: args{
+				credentials: map[string]string{
+					keyAPIEndpoint:      testAPIEndpoint,
+					keyDownloadEndpoint: testDownloadEndpoint,
+					keyTokenFile:        tokenFile,
+				},
+				tokenFileDir: tokenFileDir,
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldFailWithMissingTokenFile": {
+			args: args{
+				credentials: map[string]string{
+					keyAPIEndpoint:      testAPIEndpoint,
+					keyDownloadEndpoint: testDownloadEndpoint,
+					keyTokenFile:        missingTokenFile,
+				},
+				tokenFileDir: tokenFileDir,
+			},
+			want: want{
+				err: fmt.Errorf(errInvalidTokenFile, missingTokenFile, errMissingFile),
+			},
+		},
+		"ShouldFailWithTokenFileOutsideDir": {
+			args: args{
+				credentials: map[string]string{
+					keyAPIEndpoint:      testAPIEndpoint,
+					keyDownloadEndpoint: testDownloadEndpoint,
+					keyTokenFile:        outsideFile,
+				},
+				tokenFileDir: tokenFileDir,
+			},
+			want: want{
+				err: fmt.Errorf(errTokenFileOutsideDir, outsideFile, tokenFileDir),
+			},
+		},
+		"ShouldFailWithTokenFileEscapingDir": {
+			args: args{
+				credentials: map[string]string{
+					keyAPIEndpoint:      testAPIEndpoint,
+					keyDownloadEndpoint: testDownloadEndpoint,
+					keyTokenFile:        escapingTokenFile,
+				},
+				tokenFileDir: tokenFileDir,
+			},
+			want: want{
+				err: fmt.Errorf(errTokenFileOutsideDir, escapingTokenFile, tokenFileDir),
+			},
+		},
+		"ShouldFailWithTokenFileLinkingOutsideDir": {
+			args: args{
+				credentials: map[string]string{
+					keyAPIEndpoint:      testAPIEndpoint,
+					keyDownloadEndpoint: testDownloadEndpoint,
+					keyTokenFile:        linkToOutsideFile,
+				},
+				tokenFileDir: tokenFileDir,
+			},
+			want: want{
+				err: fmt.Errorf(errTokenFileOutsideDir, linkToOutsideFile, tokenFileDir),
+			},
+		},
+		"ShouldFailWhenTokenFilesAreDisabled": {
+			args: args{
+				credentials: map[string]string{
+					keyAPIEndpoint:      testAPIEndpoint,
+					keyDownloadEndpoint: testDownloadEndpoint,
+					keyTokenFile:        tokenFile,
+				},
+				tokenFileDir: "",
+			},
+			want: want{
+				err: fmt.Errorf(errTokenFilesDisabled, tokenFile),
+			},
+		},
 		"ShouldFailWithMissingToken": {
 			args: args{
 				credentials: map[string]string{
@@ -247,9 +683,187 @@ func Test_NewClientFromCertificateConfigAndSecretData(t *testing.T) {
 				keyCredentials: credentialsJSON,
 			}
 
-			_, gotErr := NewClientFromCertificateConfigAndSecretData(logr.Logger{}, certConfig, secretData)
+			_, gotErr := NewClientBuilder(DefaultWaitTimeout, DefaultMaxWaitTimeout, tc.args.tokenFileDir)(logr.Logger{}, certConfig, secretData)
 			if diff := cmp.Diff(tc.want.err, gotErr, test.EquateErrors()); diff != "" {
-				t.Fatalf("getSecret(...): -want error, +got error: %v", diff)
+				t.Fatalf("NewClientBuilder(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_NewClientEndpointsFromSpec(t *testing.T) {
+	const (
+		specAPIEndpoint      = "https://spec.example.com/cert/"
+		specDownloadEndpoint = "/spec-download/"
+	)
+
+	type args struct {
+		spec        v1alpha1.CertificateConfigSpec
+		credentials map[string]string
+	}
+	type want struct {
+		apiEndpoint      string
+		downloadEndpoint string
+		err              error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldUseSpecEndpointsWithTokenOnlySecret": {
+			args: args{
+				spec: v1alpha1.CertificateConfigSpec{APIEndpoint: specAPIEndpoint, DownloadEndpoint: specDownloadEndpoint},
+				credentials: map[string]string{
+					keyToken: testToken,
+				},
+			},
+			want: want{
+				apiEndpoint:      specAPIEndpoint,
+				downloadEndpoint: specDownloadEndpoint,
+			},
+		},
+		"ShouldPreferSpecEndpointsOverSecret": {
+			args: args{
+				spec: v1alpha1.CertificateConfigSpec{APIEndpoint: specAPIEndpoint, DownloadEndpoint: specDownloadEndpoint},
+				credentials: map[string]string{
+					keyAPIEndpoint:      testAPIEndpoint,
+					keyDownloadEndpoint: testDownloadEndpoint,
+					keyToken:            testToken,
+				},
+			},
+			want: want{
+				apiEndpoint:      specAPIEndpoint,
+				downloadEndpoint: specDownloadEndpoint,
+			},
+		},
+		"ShouldCombineSpecAndSecretEndpoints": {
+			args: args{
+				spec: v1alpha1.CertificateConfigSpec{APIEndpoint: specAPIEndpoint},
+				credentials: map[string]string{
+					keyDownloadEndpoint: testDownloadEndpoint,
+					keyToken:            testToken,
+				},
+			},
+			want: want{
+				apiEndpoint:      specAPIEndpoint,
+				downloadEndpoint: testDownloadEndpoint,
+			},
+		},
+		"ShouldFailWithoutDownloadEndpoint": {
+			args: args{
+				spec: v1alpha1.CertificateConfigSpec{APIEndpoint: specAPIEndpoint},
+				credentials: map[string]string{
+					keyToken: testToken,
+				},
+			},
+			want: want{
+				err: errors.New(errMissingDownloadEndpoint),
+			},
+		},
+		"ShouldStillRequireToken": {
+			args: args{
+				spec:        v1alpha1.CertificateConfigSpec{APIEndpoint: specAPIEndpoint, DownloadEndpoint: specDownloadEndpoint},
+				credentials: map[string]string{},
+			},
+			want: want{
+				err: errors.New(errMissingToken),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			credentialsJSON, err := json.Marshal(tc.args.credentials)
+			if err != nil {
+				t.Fatalf("Failed to marshal credentials: %v", err)
+			}
+
+			certConfig := &v1alpha1.CertificateConfig{Spec: tc.args.spec}
+			got, gotErr := NewClientFromCertificateConfigAndSecretData(logr.Logger{}, certConfig, map[string][]byte{keyCredentials: credentialsJSON})
+			if diff := cmp.Diff(tc.want.err, gotErr, test.EquateErrors()); diff != "" {
+				t.Fatalf("NewClientFromCertificateConfigAndSecretData(...): -want error, +got error: %v", diff)
+			}
+			if gotErr != nil {
+				return
+			}
+
+			if diff := cmp.Diff(tc.want.apiEndpoint, got.(*client).apiEndpoint); diff != "" {
+				t.Fatalf("NewClientFromCertificateConfigAndSecretData(...): -want API endpoint, +got API endpoint: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.downloadEndpoint, got.(*client).downloadEndpoint); diff != "" {
+				t.Fatalf("NewClientFromCertificateConfigAndSecretData(...): -want download endpoint, +got download endpoint: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_getReplicas(t *testing.T) {
+	type args struct {
+		secretData map[string][]byte
+	}
+	type want struct {
+		replicas []Replica
+		err      error
+	}
+
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldReturnReplicas": {
+			args: args{
+				secretData: map[string][]byte{
+					keyReplicas: []byte(`[{"apiEndpoint": "https://replica-a.endpoint", "token": "replica-token"}, {"apiEndpoint": "https://replica-b.endpoint"}]`),
+				},
+			},
+			want: want{
+				replicas: []Replica{
+					{APIEndpoint: "https://replica-a.endpoint", Token: "replica-token"},
+					{APIEndpoint: "https://replica-b.endpoint"},
+				},
+				err: nil,
+			},
+		},
+		"ShouldReturnNoReplicas": {
+			args: args{
+				secretData: map[string][]byte{},
+			},
+			want: want{
+				replicas: nil,
+				err:      nil,
+			},
+		},
+		"ShouldFailWithReplicaMissingAPIEndpoint": {
+			args: args{
+				secretData: map[string][]byte{
+					keyReplicas: []byte(`[{"apiEndpoint": "https://replica-a.endpoint"}, {"token": "replica-token"}]`),
+				},
+			},
+			want: want{
+				replicas: nil,
+				err:      fmt.Errorf(errMissingReplicaEndpoint, 1),
+			},
+		},
+		"ShouldFailWithInvalidReplicas": {
+			args: args{
+				secretData: map[string][]byte{
+					keyReplicas: []byte(`{}`),
+				},
+			},
+			want: want{
+				replicas: nil,
+				err:      fmt.Errorf(errUnmarshalReplicas, json.Unmarshal([]byte(`{}`), &[]Replica{})),
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			replicas, err := getReplicas(tc.args.secretData)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("getReplicas(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.replicas, replicas); diff != "" {
+				t.Fatalf("getReplicas(...): -want replicas, +got replicas: %v", diff)
 			}
 		})
 	}
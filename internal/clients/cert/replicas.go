@@ -0,0 +1,150 @@
+package cert
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+)
+
+// replicaCooldown is the duration for which a replica which failed a request is tried after the healthy replicas.
+const replicaCooldown = 30 * time.Second
+
+// Replica is an additional endpoint of a Cert API deployed with multiple replicas, with the token used to
+// authenticate with it. The replicas must share their state, since a certificate created on one replica may be
+// retrieved from another.
+type Replica struct {
+	APIEndpoint string `json:"apiEndpoint"`
+	Token       string `json:"token,omitempty"`
+}
+
+// WithReplicas returns a client with the Replicas field populated.
+// Requests are distributed in round-robin order across the API endpoint and the replicas.
+func WithReplicas(replicas []Replica) func(*client) {
+	return func(c *client) {
+		c.replicas = replicas
+	}
+}
+
+// sendRequest sends the request to the replicas of the Cert API, with the URL built from the API endpoint of each.
+// The replicas are tried as described in sendToReplicas.
+func (c *client) sendRequest(ctx context.Context, method string, url func(apiEndpoint string) string, body string, headers map[string][]string, failover func(error) bool) (httpClient.Response, error) {
+	var response httpClient.Response
+	err := c.sendToReplicas(ctx, headers, failover, func(apiEndpoint string, headers map[string][]string, timeout time.Duration) error {
+		var err error
+		response, err = c.localHttpClient.SendRequest(ctx, method, url(apiEndpoint), body, headers, true, timeout)
+		return err
+	})
+
+	return response, err
+}
+
+// sendToReplicas sends a request to the replicas of the Cert API with send, called with the API endpoint of each
+// replica, the headers authenticating with it and the timeout of the attempt.
+// The replicas are tried in round-robin order, healthy replicas first. A replica which cannot be reached or fails with
+// a server error is marked as unhealthy for the replica cooldown, and the request fails over to the next replica if
+// failover accepts the error. The attempts share the deadline of the context: each attempt is bounded by the time left
+// before it, and the request does not fail over once it passed, nor marks the replica unhealthy.
+func (c *client) sendToReplicas(ctx context.Context, headers map[string][]string, failover func(error) bool, send func(apiEndpoint string, headers map[string][]string, timeout time.Duration) error) error {
+	var err error
+
+	for _, replica := range c.replicaOrder() {
+		replicaHeaders := make(map[string][]string, len(headers))
+		for key, values := range headers {
+			replicaHeaders[key] = values
+		}
+		if replica.Token != "" {
+			replicaHeaders[authorizationHeaderKey] = []string{fmt.Sprintf(authorizationToken, replica.Token)}
+		}
+
+		err = send(replica.APIEndpoint, replicaHeaders, attemptTimeout(ctx, c.timeout))
+		if ctx.Err() != nil {
+			return err
+		}
+
+		healthy := err == nil || !isReplicaFailure(err)
+		c.setReplicaHealth(replica, healthy)
+		if healthy || !failover(err) {
+			return err
+		}
+
+		if len(c.replicas) > 0 {
+			c.log.Info("request to Cert API replica failed, failing over", "apiEndpoint", replica.APIEndpoint, "error", err.Error())
+		}
+	}
+
+	return err
+}
+
+// attemptTimeout returns the timeout of an attempt of a request: the timeout, bounded by the time left before the
+// deadline of the context, if any.
+func attemptTimeout(ctx context.Context, timeout time.Duration) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return timeout
+	}
+
+	if left := time.Until(deadline); timeout <= 0 || left < timeout {
+		return left
+	}
+	return timeout
+}
+
+// replicaOrder returns the replicas to try for the next request: the API endpoint of the client and the additional
+// replicas, rotated so that requests start at successive replicas, with the unhealthy replicas last.
+func (c *client) replicaOrder() []Replica {
+	replicas := append([]Replica{{APIEndpoint: c.apiEndpoint}}, c.replicas...)
+
+	c.replicaMu.Lock()
+	defer c.replicaMu.Unlock()
+
+	start := c.nextReplica % len(replicas)
+	c.nextReplica++
+
+	now := time.Now()
+	healthy := make([]Replica, 0, len(replicas))
+	var unhealthy []Replica
+	for i := range replicas {
+		replica := replicas[(start+i)%len(replicas)]
+		if now.Before(c.unhealthyUntil[replica.APIEndpoint]) {
+			unhealthy = append(unhealthy, replica)
+			continue
+		}
+		healthy = append(healthy, replica)
+	}
+
+	return append(healthy, unhealthy...)
+}
+
+// setReplicaHealth marks the replica as healthy, or as unhealthy for the replica cooldown.
+func (c *client) setReplicaHealth(replica Replica, healthy bool) {
+	c.replicaMu.Lock()
+	defer c.replicaMu.Unlock()
+
+	if healthy {
+		delete(c.unhealthyUntil, replica.APIEndpoint)
+		return
+	}
+
+	if c.unhealthyUntil == nil {
+		c.unhealthyUntil = map[string]time.Time{}
+	}
+	c.unhealthyUntil[replica.APIEndpoint] = time.Now().Add(replicaCooldown)
+}
+
+// isReplicaFailure checks if the error of a request means that the replica is failing, rather than the request:
+// the replica could not be reached, or responded with a server error. Requests retrieving certificates fail over on
+// any replica failure.
+func isReplicaFailure(err error) bool {
+	statusCode, ok := httpClient.StatusCode(err)
+	return !ok || statusCode >= http.StatusInternalServerError
+}
+
+// isConnectFailure checks if no connection could be established with the replica. Requests creating certificates
+// only fail over on connect failures, since a replica which received the request may have created the certificate
+// although it failed to respond.
+func isConnectFailure(err error) bool {
+	return httpClient.IsConnectError(err)
+}
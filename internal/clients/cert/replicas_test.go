@@ -0,0 +1,267 @@
+package cert
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+	"github.com/google/go-cmp/cmp"
+)
+
+const (
+	replicaEndpointA = "https://replica-a.example.com/cert/"
+	replicaEndpointB = "https://replica-b.example.com/cert/"
+	replicaToken     = "replica-token"
+)
+
+// replicaCertificate is the certificate requested in the tests of the replicas.
+var replicaCertificate = &v1alpha1.Certificate{Status: v1alpha1.CertificateStatus{Guid: "guid"}}
+
+// recordingHttpClient returns a MockHttpClient recording the URLs and authorization headers of its requests, which
+// fails the requests to the failing endpoints with the error.
+func recordingHttpClient(urls, authorizations *[]string, failing map[string]bool, err error) *MockHttpClient {
+	return &MockHttpClient{
+		MockSendRequest: func(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (httpClient.Response, error) {
+			*urls = append(*urls, url)
+			*authorizations = append(*authorizations, headers[authorizationHeaderKey][0])
+			for endpoint := range failing {
+				if url == endpoint+replicaCertificate.Status.Guid {
+					return httpClient.Response{}, err
+				}
+			}
+			return httpClient.Response{Body: `{"status": "ok"}`, StatusCode: http.StatusOK}, nil
+		},
+	}
+}
+
+func Test_sendRequestRoundRobin(t *testing.T) {
+	var urls, authorizations []string
+	cc := &client{
+		apiEndpoint:     apiEndpoint,
+		token:           token,
+		replicas:        []Replica{{APIEndpoint: replicaEndpointA, Token: replicaToken}, {APIEndpoint: replicaEndpointB}},
+		localHttpClient: recordingHttpClient(&urls, &authorizations, nil, nil),
+	}
+
+	for i := 0; i < 6; i++ {
+		if _, err := cc.GetCertificate(context.Background(), replicaCertificate); err != nil {
+			t.Fatalf("GetCertificate(...): unexpected error: %v", err)
+		}
+	}
+
+	wantURLs := []string{apiEndpoint, replicaEndpointA, replicaEndpointB, apiEndpoint, replicaEndpointA, replicaEndpointB}
+	for i := range wantURLs {
+		wantURLs[i] += replicaCertificate.Status.Guid
+	}
+	if diff := cmp.Diff(wantURLs, urls); diff != "" {
+		t.Fatalf("GetCertificate(...): -want URLs, +got URLs: %v", diff)
+	}
+
+	primaryToken, replicaAToken := fmt.Sprintf(authorizationToken, token), fmt.Sprintf(authorizationToken, replicaToken)
+	wantAuthorizations := []string{primaryToken, replicaAToken, primaryToken, primaryToken, replicaAToken, primaryToken}
+	if diff := cmp.Diff(wantAuthorizations, authorizations); diff != "" {
+		t.Fatalf("GetCertificate(...): -want authorizations, +got authorizations: %v", diff)
+	}
+}
+
+func Test_sendRequestFailover(t *testing.T) {
+	type args struct {
+		err error
+	}
+	type want struct {
+		urls []string
+		err  error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldFailOverUnreachableReplica": {
+			args: args{
+				err: errBoom,
+			},
+			want: want{
+				urls: []string{apiEndpoint, replicaEndpointA, replicaEndpointA, replicaEndpointA},
+				err:  nil,
+			},
+		},
+		"ShouldFailOverReplicaWithServerError": {
+			args: args{
+				err: &httpClient.APIError{StatusCode: http.StatusServiceUnavailable},
+			},
+			want: want{
+				urls: []string{apiEndpoint, replicaEndpointA, replicaEndpointA, replicaEndpointA},
+				err:  nil,
+			},
+		},
+		"ShouldNotFailOverClientError": {
+			args: args{
+				err: &httpClient.APIError{StatusCode: http.StatusNotFound},
+			},
+			want: want{
+				urls: []string{apiEndpoint, replicaEndpointA, apiEndpoint},
+				err:  fmt.Errorf(errGetDataToCertFailed, &httpClient.APIError{StatusCode: http.StatusNotFound}),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var urls, authorizations []string
+			cc := &client{
+				apiEndpoint:     apiEndpoint,
+				token:           token,
+				replicas:        []Replica{{APIEndpoint: replicaEndpointA}},
+				localHttpClient: recordingHttpClient(&urls, &authorizations, map[string]bool{apiEndpoint: true}, tc.args.err),
+			}
+
+			var err error
+			for i := 0; i < 3; i++ {
+				_, err = cc.GetCertificate(context.Background(), replicaCertificate)
+			}
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("GetCertificate(...): -want error, +got error: %v", diff)
+			}
+
+			wantURLs := make([]string, len(tc.want.urls))
+			for i, url := range tc.want.urls {
+				wantURLs[i] = url + replicaCertificate.Status.Guid
+			}
+			if diff := cmp.Diff(wantURLs, urls); diff != "" {
+				t.Fatalf("GetCertificate(...): -want URLs, +got URLs: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_sendRequestAllReplicasFailing(t *testing.T) {
+	var urls, authorizations []string
+	cc := &client{
+		apiEndpoint:     apiEndpoint,
+		token:           token,
+		replicas:        []Replica{{APIEndpoint: replicaEndpointA}},
+		localHttpClient: recordingHttpClient(&urls, &authorizations, map[string]bool{apiEndpoint: true, replicaEndpointA: true}, errBoom),
+	}
+
+	_, err := cc.GetCertificate(context.Background(), replicaCertificate)
+	if diff := cmp.Diff(fmt.Errorf(errGetDataToCertFailed, errBoom), err, test.EquateErrors()); diff != "" {
+		t.Fatalf("GetCertificate(...): -want error, +got error: %v", diff)
+	}
+
+	if diff := cmp.Diff([]string{apiEndpoint + replicaCertificate.Status.Guid, replicaEndpointA + replicaCertificate.Status.Guid}, urls); diff != "" {
+		t.Fatalf("GetCertificate(...): -want URLs, +got URLs: %v", diff)
+	}
+}
+
+func Test_sendRequestPostFailover(t *testing.T) {
+	dialError := &httpClient.TransportError{URL: apiEndpoint, Err: &net.OpError{Op: "dial", Net: "tcp", Err: errBoom}}
+	readError := &httpClient.TransportError{URL: apiEndpoint, Err: &net.OpError{Op: "read", Net: "tcp", Err: errBoom}}
+
+	type args struct {
+		err error
+	}
+	type want struct {
+		urls []string
+		err  error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldFailOverReplicaWhichCannotBeDialed": {
+			args: args{
+				err: dialError,
+			},
+			want: want{
+				urls: []string{apiEndpoint, replicaEndpointA},
+				err:  nil,
+			},
+		},
+		"ShouldNotFailOverReplicaWhichReceivedTheRequest": {
+			args: args{
+				err: readError,
+			},
+			want: want{
+				urls: []string{apiEndpoint},
+				err:  fmt.Errorf(errPostToCertFailed, readError),
+			},
+		},
+		"ShouldNotFailOverReplicaWithServerError": {
+			args: args{
+				err: &httpClient.APIError{StatusCode: http.StatusServiceUnavailable},
+			},
+			want: want{
+				urls: []string{apiEndpoint},
+				err:  fmt.Errorf(errPostToCertFailed, &httpClient.APIError{StatusCode: http.StatusServiceUnavailable}),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var urls []string
+			cc := &client{
+				apiEndpoint: apiEndpoint,
+				token:       token,
+				replicas:    []Replica{{APIEndpoint: replicaEndpointA}},
+				localHttpClient: &MockHttpClient{
+					MockSendRequest: func(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (httpClient.Response, error) {
+						urls = append(urls, url)
+						if url == apiEndpoint {
+							return httpClient.Response{}, tc.args.err
+						}
+						return httpClient.Response{Body: `{"taskId": "guid"}`, StatusCode: http.StatusOK}, nil
+					},
+				},
+			}
+
+			_, err := cc.PostCertificate(context.Background(), &certificate)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("PostCertificate(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.urls, urls); diff != "" {
+				t.Fatalf("PostCertificate(...): -want URLs, +got URLs: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_sendRequestContextDeadline(t *testing.T) {
+	var urls []string
+	var timeouts []time.Duration
+	cc := &client{
+		apiEndpoint: apiEndpoint,
+		token:       token,
+		timeout:     time.Hour,
+		replicas:    []Replica{{APIEndpoint: replicaEndpointA}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	cc.localHttpClient = &MockHttpClient{
+		MockSendRequest: func(_ context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (httpClient.Response, error) {
+			urls = append(urls, url)
+			timeouts = append(timeouts, timeout)
+			cancel()
+			return httpClient.Response{}, fmt.Errorf("http request to %q failed: %w", url, context.Canceled)
+		},
+	}
+
+	if _, err := cc.GetCertificate(ctx, replicaCertificate); err == nil {
+		t.Fatalf("GetCertificate(...): expected an error")
+	}
+
+	if diff := cmp.Diff([]string{apiEndpoint + replicaCertificate.Status.Guid}, urls); diff != "" {
+		t.Fatalf("GetCertificate(...): -want URLs, +got URLs: %v", diff)
+	}
+	if len(timeouts) != 1 || timeouts[0] > time.Minute {
+		t.Errorf("GetCertificate(...): expected the attempt to be bounded by the deadline of the context, got timeouts %v", timeouts)
+	}
+	if len(cc.unhealthyUntil) != 0 {
+		t.Errorf("GetCertificate(...): expected the replica not to be marked unhealthy, got %v", cc.unhealthyUntil)
+	}
+}
@@ -2,13 +2,23 @@ package cert
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"mime"
 	"net/http"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
 	jsonutil "github.com/dana-team/certificate-operator/internal/jsonutil"
+	"github.com/dana-team/certificate-operator/internal/tracing"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 const (
@@ -16,99 +26,508 @@ const (
 	authorizationHeaderKey = "Authorization"
 	acceptHeaderKey        = "accept"
 	acceptHeaderValue      = "application/json"
+	acceptLanguageHeader   = "Accept-Language"
+	contentTypeHeaderKey   = "Content-Type"
+	htmlContentType        = "text/html"
+
+	// htmlSnippetLength is the maximum length of the snippet of HTML response bodies included in errors.
+	htmlSnippetLength = 100
+
+	// DefaultIdempotencyKeyHeader is the default header carrying the idempotency key of POST requests.
+	DefaultIdempotencyKeyHeader = "Idempotency-Key"
 )
 
 const (
 	errBodyIsNotJson         = "response body is not JSON"
+	errBodyIsHTML            = "response body is an HTML page instead of JSON, the request may have reached a proxy or an error page (status %d): %q"
 	errFailedToUnmarshalBody = "failed to unmarshal response body: %v"
-	errPostToCertFailed      = "POST to cert failed: %v"
-	errDownloadToCertFailed  = "download request to Cert API failed: %v"
-	errGetDataToCertFailed   = "GET request to Cert API failed: %v"
+	errPostToCertFailed      = "POST to cert failed: %w"
+	errDownloadToCertFailed  = "download request to Cert API failed: %w"
+	errGetDataToCertFailed   = "GET request to Cert API failed: %w"
+	errReadingTokenFile      = "failed to read token file %q: %v"
+	errEmptyTokenFile        = "token file %q is empty"
+	errSANIPIsCIDR           = "SAN IP %q is a CIDR, specify a single IP address such as %q instead"
+	errInvalidSANIP          = "SAN IP %q is not a valid IPv4 or IPv6 address"
+	errResponsePathNotFound  = "response path %q not found in response body"
+	errInvalidExtensionOID   = "extension OID %q is not a valid object identifier"
+	errInvalidExtensionValue = "value of extension %q is not base64-encoded: %v"
+	errResponseBodyError     = "Cert API signaled a failure in the %q field of the response body: %s"
+	errResponseNotSuccessful = "Cert API signaled a failure with the %q field of the response body set to false"
+	errReadingResponseBody   = "failed reading response body: %v"
 )
 
-// PostCertificate sends a POST request to cert to create a new certificate and returns the GUID.
-func (c *client) PostCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (string, error) {
-	body := createPostBody(certificate)
+// PostCertificate sends a POST request to cert to create a new certificate and returns the response holding the GUID.
+// Once a response is received, the returned response holds its raw body, even if an error is returned.
+func (c *client) PostCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (_ PostCertificateResponse, err error) {
+	ctx, span := tracing.Start(ctx, "PostCertificate", c.certificateAttributes(certificate)...)
+	defer tracing.End(span, &err)
+
+	headers, err := c.getAuthorizationHeader()
+	if err != nil {
+		return PostCertificateResponse{}, fmt.Errorf(errPostToCertFailed, err)
+	}
+
+	body, err := createPostBody(certificate, c.defaultSubject)
+	if err != nil {
+		return PostCertificateResponse{}, fmt.Errorf(errPostToCertFailed, err)
+	}
 
-	response, err := c.localHttpClient.SendRequest(ctx, http.MethodPost, c.apiEndpoint, jsonutil.ToJSON(body), c.getAuthorizationHeader(), true, c.timeout)
+	headers[c.idempotencyKeyHeaderName()] = []string{idempotencyKey(certificate)}
+
+	response, err := c.sendRequest(ctx, method(c.methods.Post, http.MethodPost), func(apiEndpoint string) string {
+		return apiEndpoint
+	}, jsonutil.ToJSON(body), headers, isConnectFailure)
 	if err != nil {
-		return "", fmt.Errorf(errPostToCertFailed, err)
+		return PostCertificateResponse{}, fmt.Errorf(errPostToCertFailed, err)
+	}
+
+	if err = c.checkResponseStatus(response.Body); err != nil {
+		return PostCertificateResponse{Raw: response.Body}, fmt.Errorf(errPostToCertFailed, err)
 	}
 
 	var responseBody PostCertificateResponse
-	if err = parseResponseBody(response.Body, &responseBody); err != nil {
-		return "", fmt.Errorf(errFailedToUnmarshalBody, err)
+	if err = parseResponseBody(response, c.responsePath, &responseBody); err != nil {
+		return PostCertificateResponse{Raw: response.Body}, fmt.Errorf(errFailedToUnmarshalBody, err)
+	}
+	responseBody.Raw = response.Body
+
+	return responseBody, nil
+}
+
+// RequestBody returns the JSON body of the request sent to the Cert API to create the certificate, with the empty
+// subject fields of the certificate taken from the default subject.
+func RequestBody(certificate *v1alpha1.Certificate, defaultSubject v1alpha1.Subject) (string, error) {
+	body, err := createPostBody(certificate, defaultSubject)
+	if err != nil {
+		return "", err
+	}
+
+	return jsonutil.ToJSON(body), nil
+}
+
+// idempotencyKey returns the idempotency key of a request to create the certificate. It is derived from the UID and
+// generation of the Certificate, so that retried requests share a key, from the guid of the certificate being
+// renewed, so that renewals of an unchanged Certificate do not, and from the number of times a stuck guid was cleared,
+// so that the CA does not return the stuck guid again.
+func idempotencyKey(certificate *v1alpha1.Certificate) string {
+	key := fmt.Sprintf("%s-%d", certificate.UID, certificate.Generation)
+	if certificate.Status.Guid != "" {
+		key = fmt.Sprintf("%s-%s", key, certificate.Status.Guid)
+	}
+	if certificate.Status.GUIDResets > 0 {
+		key = fmt.Sprintf("%s-reset-%d", key, certificate.Status.GUIDResets)
+	}
+
+	return key
+}
+
+// idempotencyKeyHeaderName returns the name of the header carrying the idempotency key.
+func (c *client) idempotencyKeyHeaderName() string {
+	if c.idempotencyKeyHeader != "" {
+		return c.idempotencyKeyHeader
 	}
 
-	return responseBody.Guid, nil
+	return DefaultIdempotencyKeyHeader
 }
 
 // DownloadCertificate downloads a certificate from the Cert API.
-func (c *client) DownloadCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (DownloadCertificateResponse, error) {
-	url := fmt.Sprintf("%s%s%s%s", c.apiEndpoint, certificate.Status.Guid, c.downloadEndpoint, certificate.Spec.CertificateData.Form)
+// The response is streamed, and its PKCS#12 data is base64-decoded as it is received into the DecodedData of the
+// returned response, so that large bundles are never held in memory in their encoded form.
+func (c *client) DownloadCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (_ DownloadCertificateResponse, err error) {
+	ctx, span := tracing.Start(ctx, "DownloadCertificate", c.certificateAttributes(certificate)...)
+	defer tracing.End(span, &err)
 
-	response, err := c.localHttpClient.SendRequest(ctx, http.MethodGet, url, "", c.getAuthorizationHeader(), true, c.timeout)
+	headers, err := c.getAuthorizationHeader()
 	if err != nil {
 		return DownloadCertificateResponse{}, fmt.Errorf(errDownloadToCertFailed, err)
 	}
 
+	url := func(apiEndpoint string) string {
+		return fmt.Sprintf("%s%s%s%s", apiEndpoint, certificate.Status.Guid, c.downloadEndpoint, certificate.Spec.CertificateData.Form)
+	}
+	downloadMethod := method(c.methods.Download, http.MethodGet)
+	body := retrievalRequestBody(downloadMethod, retrievalBody{Guid: certificate.Status.Guid, Form: certificate.Spec.CertificateData.Form})
+
+	streamResponse, err := c.sendStreamRequest(ctx, downloadMethod, url, body, headers, isReplicaFailure)
+	if err != nil {
+		return DownloadCertificateResponse{}, fmt.Errorf(errDownloadToCertFailed, err)
+	}
+	defer streamResponse.Body.Close()
+
+	scanned, err := scanDownloadResponse(streamResponse.Body, c.responsePath)
+	if err != nil {
+		return DownloadCertificateResponse{}, fmt.Errorf(errDownloadToCertFailed, fmt.Errorf(errReadingResponseBody, err))
+	}
+	response := httpClient.Response{Body: scanned.skeleton, Headers: streamResponse.Headers, StatusCode: streamResponse.StatusCode}
+
+	if err = c.checkResponseStatus(response.Body); err != nil {
+		return DownloadCertificateResponse{}, fmt.Errorf(errDownloadToCertFailed, err)
+	}
+
 	var responseBody DownloadCertificateResponse
-	if err = parseResponseBody(response.Body, &responseBody); err != nil {
+	if err = parseResponseBody(response, c.responsePath, &responseBody); err != nil {
 		return DownloadCertificateResponse{}, fmt.Errorf(errFailedToUnmarshalBody, err)
 	}
 
+	if responseBody.Metadata, err = parseMetadata(response.Body, c.responsePath, c.metadataFields); err != nil {
+		return DownloadCertificateResponse{}, fmt.Errorf(errFailedToUnmarshalBody, err)
+	}
+	responseBody.DecodedData = scanned.decodedData
+	responseBody.DataErr = scanned.dataErr
+	responseBody.Raw = response.Body
+
 	return responseBody, nil
 }
 
 // GetCertificate gets certificate data from the Cert API.
-func (c *client) GetCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (GetCertificateResponse, error) {
-	url := fmt.Sprintf("%s%s", c.apiEndpoint, certificate.Status.Guid)
+func (c *client) GetCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (_ GetCertificateResponse, err error) {
+	ctx, span := tracing.Start(ctx, "GetCertificate", c.certificateAttributes(certificate)...)
+	defer tracing.End(span, &err)
+
+	headers, err := c.getAuthorizationHeader()
+	if err != nil {
+		return GetCertificateResponse{}, fmt.Errorf(errGetDataToCertFailed, err)
+	}
 
-	response, err := c.localHttpClient.SendRequest(ctx, http.MethodGet, url, "", c.getAuthorizationHeader(), true, c.timeout)
+	url := func(apiEndpoint string) string {
+		return fmt.Sprintf("%s%s", apiEndpoint, certificate.Status.Guid)
+	}
+	getMethod := method(c.methods.Get, http.MethodGet)
+	body := retrievalRequestBody(getMethod, retrievalBody{Guid: certificate.Status.Guid})
+
+	response, err := c.sendRequest(ctx, getMethod, url, body, headers, isReplicaFailure)
 	if err != nil {
 		return GetCertificateResponse{}, fmt.Errorf(errGetDataToCertFailed, err)
 	}
 
+	if err = c.checkResponseStatus(response.Body); err != nil {
+		return GetCertificateResponse{}, fmt.Errorf(errGetDataToCertFailed, err)
+	}
+
 	var responseBody GetCertificateResponse
-	if err = parseResponseBody(response.Body, &responseBody); err != nil {
+	if err = parseResponseBody(response, c.responsePath, &responseBody); err != nil {
+		return GetCertificateResponse{}, fmt.Errorf(errFailedToUnmarshalBody, err)
+	}
+
+	if responseBody.Metadata, err = parseMetadata(response.Body, c.responsePath, c.metadataFields); err != nil {
 		return GetCertificateResponse{}, fmt.Errorf(errFailedToUnmarshalBody, err)
 	}
+	responseBody.Raw = response.Body
 
 	return responseBody, nil
 }
 
-// getAuthorizationHeader retrieves the authorization header for communicating with the Cert API.
-func (c *client) getAuthorizationHeader() map[string][]string {
-	return map[string][]string{
-		authorizationHeaderKey: {fmt.Sprintf(authorizationToken, c.token)},
+// method returns the configured HTTP method of an operation, or its default method if none is configured.
+func method(configured, defaultMethod string) string {
+	if configured != "" {
+		return configured
+	}
+
+	return defaultMethod
+}
+
+// retrievalRequestBody returns the JSON body of a request getting or downloading a certificate with the method.
+// GET requests have no body.
+func retrievalRequestBody(method string, body retrievalBody) string {
+	if method == http.MethodGet {
+		return ""
+	}
+
+	return jsonutil.ToJSON(body)
+}
+
+// getAuthorizationHeader retrieves the headers for communicating with the Cert API: the authorization header, the accept
+// header and, if configured, the Accept-Language header.
+func (c *client) getAuthorizationHeader() (map[string][]string, error) {
+	token, err := c.getToken()
+	if err != nil {
+		return nil, err
+	}
+
+	headers := map[string][]string{
+		authorizationHeaderKey: {fmt.Sprintf(authorizationToken, token)},
 		acceptHeaderKey:        {acceptHeaderValue},
 	}
+	if c.acceptLanguage != "" {
+		headers[acceptLanguageHeader] = []string{c.acceptLanguage}
+	}
+
+	return headers, nil
+}
+
+// getToken returns the token used to authenticate with the Cert API.
+// If a token file is configured, the token is read from it and cached for the token file TTL.
+func (c *client) getToken() (string, error) {
+	if c.tokenFile == "" {
+		return c.token, nil
+	}
+
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.cachedToken != "" && time.Since(c.tokenReadAt) < c.tokenFileTTL {
+		return c.cachedToken, nil
+	}
+
+	data, err := os.ReadFile(c.tokenFile)
+	if err != nil {
+		return "", fmt.Errorf(errReadingTokenFile, c.tokenFile, err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf(errEmptyTokenFile, c.tokenFile)
+	}
+
+	c.cachedToken = token
+	c.tokenReadAt = time.Now()
+
+	return token, nil
+}
+
+// certificateAttributes returns the attributes describing the Certificate and the CertificateConfig of the client in
+// the spans of requests to the Cert API.
+func (c *client) certificateAttributes(certificate *v1alpha1.Certificate) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		tracing.String(tracing.AttributeCertificate, certificate.Namespace+"/"+certificate.Name),
+		tracing.String(tracing.AttributeConfig, c.configName),
+	}
 }
 
 // createPostBody creates the post request body for obtaining a certificate.
-func createPostBody(certificate *v1alpha1.Certificate) postCertificateBody {
+// The subject fields the certificate leaves empty are taken from the default subject.
+// It returns an error if the SAN IPs are not valid single IP addresses.
+func createPostBody(certificate *v1alpha1.Certificate, defaultSubject v1alpha1.Subject) (postCertificateBody, error) {
+	if err := validateSANIPs(certificate.Spec.CertificateData.San.IPs); err != nil {
+		return postCertificateBody{}, err
+	}
+
+	extensions, err := createExtensions(certificate.Spec.CertificateData.Extensions)
+	if err != nil {
+		return postCertificateBody{}, err
+	}
+
+	subject := certificate.Spec.CertificateData.Subject
 	return postCertificateBody{
 		Subject: Subject{
-			CommonName:         certificate.Spec.CertificateData.Subject.CommonName,
-			Country:            certificate.Spec.CertificateData.Subject.Country,
-			State:              certificate.Spec.CertificateData.Subject.State,
-			Locality:           certificate.Spec.CertificateData.Subject.Locality,
-			Organization:       certificate.Spec.CertificateData.Subject.Organization,
-			OrganizationalUnit: certificate.Spec.CertificateData.Subject.OrganizationalUnit,
+			CommonName:         withDefault(subject.CommonName, defaultSubject.CommonName),
+			Country:            withDefault(subject.Country, defaultSubject.Country),
+			State:              withDefault(subject.State, defaultSubject.State),
+			Locality:           withDefault(subject.Locality, defaultSubject.Locality),
+			Organization:       withDefault(subject.Organization, defaultSubject.Organization),
+			OrganizationalUnit: withDefault(subject.OrganizationalUnit, defaultSubject.OrganizationalUnit),
+			Email:              withDefault(subject.Email, defaultSubject.Email),
 		},
 		San: San{
 			DNS: certificate.Spec.CertificateData.San.DNS,
 			IPs: certificate.Spec.CertificateData.San.IPs,
 		},
-		Template: certificate.Spec.CertificateData.Template,
+		Template:     certificate.Spec.CertificateData.Template,
+		Issuer:       certificate.Spec.CertificateData.Issuer,
+		KeyAlgorithm: certificate.Spec.CertificateData.KeyAlgorithm,
+		KeySize:      certificate.Spec.CertificateData.KeySize,
+		KeyCurve:     certificate.Spec.CertificateData.KeyCurve,
+		Extensions:   extensions,
+	}, nil
+}
+
+// createExtensions converts the custom extensions of a certificate to the extensions of the request body.
+// It returns an error if an OID is not a valid object identifier, or a value is not base64-encoded.
+func createExtensions(customExtensions []v1alpha1.CustomExtension) ([]Extension, error) {
+	var extensions []Extension
+	for _, extension := range customExtensions {
+		if !isValidOID(extension.OID) {
+			return nil, fmt.Errorf(errInvalidExtensionOID, extension.OID)
+		}
+
+		if _, err := base64.StdEncoding.DecodeString(extension.Value); err != nil {
+			return nil, fmt.Errorf(errInvalidExtensionValue, extension.OID, err)
+		}
+
+		extensions = append(extensions, Extension{OID: extension.OID, Value: extension.Value, Critical: extension.Critical})
+	}
+
+	return extensions, nil
+}
+
+// isValidOID checks if the OID is an object identifier in dotted notation, with at least two arcs, a first arc of
+// 0, 1 or 2, and a second arc below 40 under the first two.
+func isValidOID(oid string) bool {
+	arcs := strings.Split(oid, ".")
+	if len(arcs) < 2 {
+		return false
 	}
+
+	values := make([]uint64, len(arcs))
+	for i, arc := range arcs {
+		if arc == "" || (len(arc) > 1 && arc[0] == '0') {
+			return false
+		}
+
+		value, err := strconv.ParseUint(arc, 10, 64)
+		if err != nil {
+			return false
+		}
+		values[i] = value
+	}
+
+	return values[0] <= 2 && (values[0] == 2 || values[1] < 40)
+}
+
+// withDefault returns the value, or the default value if it is empty.
+func withDefault(value, defaultValue string) string {
+	if value != "" {
+		return value
+	}
+
+	return defaultValue
+}
+
+// validateSANIPs checks that every SAN IP is a single IPv4 or IPv6 address.
+// CIDRs are explicitly rejected, since the Cert API does not accept them.
+func validateSANIPs(ips []string) error {
+	for _, ip := range ips {
+		if _, err := netip.ParseAddr(ip); err == nil {
+			continue
+		}
+
+		if prefix, err := netip.ParsePrefix(ip); err == nil {
+			return fmt.Errorf(errSANIPIsCIDR, ip, prefix.Addr().String())
+		}
+
+		return fmt.Errorf(errInvalidSANIP, ip)
+	}
+
+	return nil
 }
 
 // parseResponseBody parses the response body received from the Cert API.
-func parseResponseBody(body string, response interface{}) error {
+// HTML bodies, e.g. of the error page of a gateway or the login page of a proxy, fail with an error holding a snippet.
+// If a response path is given, the body is parsed from the JSON object found at that path.
+func parseResponseBody(response httpClient.Response, responsePath string, out interface{}) error {
+	if isHTML(response) {
+		return fmt.Errorf(errBodyIsHTML, response.StatusCode, snippet(response.Body, htmlSnippetLength))
+	}
+
+	data, err := responseData(response.Body, responsePath)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, out)
+}
+
+// checkResponseStatus checks the error and success fields of the response body, for Cert APIs which respond with a
+// successful status code to failed requests. It returns an error if the error field is set to a value other than
+// null, false or the empty string, or if the success field is false. Missing fields and bodies which are not JSON
+// do not signal a failure.
+func (c *client) checkResponseStatus(body string) error {
+	if c.errorField != "" {
+		if value, err := responseData(body, c.errorField); err == nil && !isUnsetValue(value) {
+			return fmt.Errorf(errResponseBodyError, c.errorField, jsonValueString(value))
+		}
+	}
+
+	if c.successField != "" {
+		if value, err := responseData(body, c.successField); err == nil && string(value) == "false" {
+			return fmt.Errorf(errResponseNotSuccessful, c.successField)
+		}
+	}
+
+	return nil
+}
+
+// isUnsetValue checks if the JSON value is null, false or the empty string.
+func isUnsetValue(value json.RawMessage) bool {
+	switch string(value) {
+	case "null", "false", `""`:
+		return true
+	default:
+		return false
+	}
+}
+
+// jsonValueString returns the JSON value as a string: strings are unquoted, and other values are kept as JSON.
+func jsonValueString(value json.RawMessage) string {
+	var str string
+	if err := json.Unmarshal(value, &str); err != nil {
+		return string(value)
+	}
+
+	return str
+}
+
+// isHTML checks if the response is an HTML page, by its content type or its body starting with a tag.
+func isHTML(response httpClient.Response) bool {
+	if mediaType, _, err := mime.ParseMediaType(http.Header(response.Headers).Get(contentTypeHeaderKey)); err == nil && mediaType == htmlContentType {
+		return true
+	}
+
+	return strings.HasPrefix(strings.TrimSpace(response.Body), "<")
+}
+
+// snippet returns the body with its whitespace collapsed, truncated to the maximum length.
+func snippet(body string, maxLength int) string {
+	collapsed := []rune(strings.Join(strings.Fields(body), " "))
+	if len(collapsed) <= maxLength {
+		return string(collapsed)
+	}
+
+	return string(collapsed[:maxLength]) + "..."
+}
+
+// parseMetadata returns the values of the given fields of the response body received from the Cert API.
+// Fields missing from the response or set to null are skipped, and values which are not strings are kept as JSON.
+func parseMetadata(body, responsePath string, fields []string) (map[string]string, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	data, err := responseData(body, responsePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+
+	metadata := map[string]string{}
+	for _, field := range fields {
+		value, ok := object[field]
+		if !ok || string(value) == "null" {
+			continue
+		}
+
+		metadata[field] = jsonValueString(value)
+	}
+
+	return metadata, nil
+}
+
+// responseData returns the JSON data of the response body received from the Cert API,
+// which is the JSON object found at the response path if one is given.
+func responseData(body, responsePath string) (json.RawMessage, error) {
 	if !jsonutil.IsJSONString(body) {
-		return errors.New(errBodyIsNotJson)
+		return nil, errors.New(errBodyIsNotJson)
+	}
+
+	data := json.RawMessage(body)
+	if responsePath != "" {
+		for _, key := range strings.Split(responsePath, ".") {
+			var object map[string]json.RawMessage
+			if err := json.Unmarshal(data, &object); err != nil {
+				return nil, fmt.Errorf(errResponsePathNotFound, responsePath)
+			}
+
+			value, ok := object[key]
+			if !ok {
+				return nil, fmt.Errorf(errResponsePathNotFound, responsePath)
+			}
+			data = value
+		}
 	}
 
-	return json.Unmarshal([]byte(body), response)
+	return data, nil
 }
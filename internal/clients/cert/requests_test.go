@@ -2,16 +2,27 @@ package cert
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/crossplane/crossplane-runtime/pkg/test"
 	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert/certtest"
 	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+	jsonutil "github.com/dana-team/certificate-operator/internal/jsonutil"
+	"github.com/dana-team/certificate-operator/internal/tracing"
+	"github.com/dana-team/certificate-operator/internal/tracing/tracingtest"
 	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -81,13 +92,16 @@ var (
 )
 
 func Test_PostCertificate(t *testing.T) {
+	cidrCertificate := certificate.DeepCopy()
+	cidrCertificate.Spec.CertificateData.San.IPs = []string{"10.0.0.0/24"}
+
 	type args struct {
 		http              httpClient.Client
 		certificate       *v1alpha1.Certificate
 		certificateConfig *v1alpha1.CertificateConfig
 	}
 	type want struct {
-		result string
+		result PostCertificateResponse
 		err    error
 	}
 	cases := map[string]struct {
@@ -109,8 +123,11 @@ func Test_PostCertificate(t *testing.T) {
 				},
 			},
 			want: want{
-				result: `83729jsdjd92819w1yhdsduy288yhduwdbd`,
-				err:    nil,
+				result: PostCertificateResponse{
+					Guid: "83729jsdjd92819w1yhdsduy288yhduwdbd",
+					Raw:  `{"taskId": "83729jsdjd92819w1yhdsduy288yhduwdbd"}`,
+				},
+				err: nil,
 			},
 		},
 		"ShouldFailSendingRequest": {
@@ -124,7 +141,7 @@ func Test_PostCertificate(t *testing.T) {
 				},
 			},
 			want: want{
-				result: "",
+				result: PostCertificateResponse{},
 				err:    fmt.Errorf(errPostToCertFailed, errBoom),
 			},
 		},
@@ -143,10 +160,25 @@ func Test_PostCertificate(t *testing.T) {
 				},
 			},
 			want: want{
-				result: "",
+				result: PostCertificateResponse{Raw: `{ "83729jsdjd92819w1yhdsduy288yhduwdbd"}`},
 				err:    fmt.Errorf(errFailedToUnmarshalBody, errBodyNotJson),
 			},
 		},
+		"ShouldFailWithCIDRSANIP": {
+			args: args{
+				certificateConfig: &certificateConfig,
+				certificate:       cidrCertificate,
+				http: &MockHttpClient{
+					MockSendRequest: func(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (resp httpClient.Response, err error) {
+						return httpClient.Response{}, errors.New("request should not be sent")
+					},
+				},
+			},
+			want: want{
+				result: PostCertificateResponse{},
+				err:    fmt.Errorf(errPostToCertFailed, fmt.Errorf(errSANIPIsCIDR, "10.0.0.0/24", "10.0.0.0")),
+			},
+		},
 	}
 	for name, tc := range cases {
 		t.Run(name, func(t *testing.T) {
@@ -170,6 +202,259 @@ func Test_PostCertificate(t *testing.T) {
 	}
 }
 
+func Test_PostCertificateIdempotencyKey(t *testing.T) {
+	issued := certificate.DeepCopy()
+	issued.UID = "uid"
+	issued.Generation = 2
+
+	renewed := issued.DeepCopy()
+	renewed.Status.Guid = "previous-guid"
+
+	reset := issued.DeepCopy()
+	reset.Status.GUIDResets = 1
+
+	type args struct {
+		certificate          *v1alpha1.Certificate
+		idempotencyKeyHeader string
+	}
+	type want struct {
+		header string
+		key    string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldSendDefaultHeader": {
+			args: args{
+				certificate: issued,
+			},
+			want: want{
+				header: DefaultIdempotencyKeyHeader,
+				key:    "uid-2",
+			},
+		},
+		"ShouldSendConfiguredHeader": {
+			args: args{
+				certificate:          issued,
+				idempotencyKeyHeader: "X-Request-Id",
+			},
+			want: want{
+				header: "X-Request-Id",
+				key:    "uid-2",
+			},
+		},
+		"ShouldIncludeRenewedGuid": {
+			args: args{
+				certificate: renewed,
+			},
+			want: want{
+				header: DefaultIdempotencyKeyHeader,
+				key:    "uid-2-previous-guid",
+			},
+		},
+		"ShouldIncludeGuidResets": {
+			args: args{
+				certificate: reset,
+			},
+			want: want{
+				header: DefaultIdempotencyKeyHeader,
+				key:    "uid-2-reset-1",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var gotKeys []string
+			cc := &client{
+				log: logr.Logger{},
+				localHttpClient: &MockHttpClient{
+					MockSendRequest: func(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (resp httpClient.Response, err error) {
+						gotKeys = append(gotKeys, headers[tc.want.header]...)
+						return httpClient.Response{}, errBoom
+					},
+				},
+				apiEndpoint:          apiEndpoint,
+				token:                token,
+				idempotencyKeyHeader: tc.args.idempotencyKeyHeader,
+			}
+
+			for i := 0; i < 2; i++ {
+				if _, err := cc.PostCertificate(context.Background(), tc.args.certificate); err == nil {
+					t.Fatalf("PostCertificate(...): expected an error")
+				}
+			}
+
+			if diff := cmp.Diff([]string{tc.want.key, tc.want.key}, gotKeys); diff != "" {
+				t.Errorf("PostCertificate(...): -want idempotency keys, +got idempotency keys: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_validateSANIPs(t *testing.T) {
+	type args struct {
+		ips []string
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldAcceptIPv4Address": {
+			args: args{
+				ips: []string{"192.168.1.1"},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldAcceptIPv6Address": {
+			args: args{
+				ips: []string{"2001:db8::1", "::1"},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldRejectIPv4CIDR": {
+			args: args{
+				ips: []string{"192.168.1.1", "10.0.0.0/24"},
+			},
+			want: want{
+				err: fmt.Errorf(errSANIPIsCIDR, "10.0.0.0/24", "10.0.0.0"),
+			},
+		},
+		"ShouldRejectIPv6CIDR": {
+			args: args{
+				ips: []string{"2001:db8::/32"},
+			},
+			want: want{
+				err: fmt.Errorf(errSANIPIsCIDR, "2001:db8::/32", "2001:db8::"),
+			},
+		},
+		"ShouldRejectInvalidAddress": {
+			args: args{
+				ips: []string{"www.example.com"},
+			},
+			want: want{
+				err: fmt.Errorf(errInvalidSANIP, "www.example.com"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gotErr := validateSANIPs(tc.args.ips)
+			if diff := cmp.Diff(tc.want.err, gotErr, test.EquateErrors()); diff != "" {
+				t.Fatalf("validateSANIPs(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_parseResponseBody(t *testing.T) {
+	htmlPage := "<html>\n  <head><title>502 Bad Gateway</title></head>\n  <body><h1>502 Bad Gateway</h1></body>\n</html>"
+
+	type args struct {
+		body         string
+		headers      map[string][]string
+		responsePath string
+	}
+	type want struct {
+		response GetCertificateResponse
+		err      error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldParseRootLevelResponse": {
+			args: args{
+				body: `{"validFrom": "2024-01-01T00:00:00", "validTo": "2025-01-01T00:00:00"}`,
+			},
+			want: want{
+				response: GetCertificateResponse{ValidFrom: "2024-01-01T00:00:00", ValidTo: "2025-01-01T00:00:00"},
+			},
+		},
+		"ShouldParseEnvelopedResponse": {
+			args: args{
+				body:         `{"data": {"validFrom": "2024-01-01T00:00:00", "validTo": "2025-01-01T00:00:00"}}`,
+				responsePath: "data",
+			},
+			want: want{
+				response: GetCertificateResponse{ValidFrom: "2024-01-01T00:00:00", ValidTo: "2025-01-01T00:00:00"},
+			},
+		},
+		"ShouldParseNestedEnvelopedResponse": {
+			args: args{
+				body:         `{"result": {"data": {"validTo": "2025-01-01T00:00:00"}}}`,
+				responsePath: "result.data",
+			},
+			want: want{
+				response: GetCertificateResponse{ValidTo: "2025-01-01T00:00:00"},
+			},
+		},
+		"ShouldFailWithMissingResponsePath": {
+			args: args{
+				body:         `{"validTo": "2025-01-01T00:00:00"}`,
+				responsePath: "data",
+			},
+			want: want{
+				err: fmt.Errorf(errResponsePathNotFound, "data"),
+			},
+		},
+		"ShouldFailWithNonObjectResponsePath": {
+			args: args{
+				body:         `{"data": "2025-01-01T00:00:00"}`,
+				responsePath: "data.validTo",
+			},
+			want: want{
+				err: fmt.Errorf(errResponsePathNotFound, "data.validTo"),
+			},
+		},
+		"ShouldFailWithHTMLContentType": {
+			args: args{
+				body:    "Service Unavailable",
+				headers: map[string][]string{"Content-Type": {"text/html; charset=utf-8"}},
+			},
+			want: want{
+				err: fmt.Errorf(errBodyIsHTML, http.StatusOK, "Service Unavailable"),
+			},
+		},
+		"ShouldFailWithHTMLBody": {
+			args: args{
+				body: htmlPage,
+			},
+			want: want{
+				err: fmt.Errorf(errBodyIsHTML, http.StatusOK, "<html> <head><title>502 Bad Gateway</title></head> <body><h1>502 Bad Gateway</h1></body> </html>"),
+			},
+		},
+		"ShouldTruncateLongHTMLBody": {
+			args: args{
+				body: "<html>" + strings.Repeat("a", 200) + "</html>",
+			},
+			want: want{
+				err: fmt.Errorf(errBodyIsHTML, http.StatusOK, "<html>"+strings.Repeat("a", htmlSnippetLength-len("<html>"))+"..."),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got GetCertificateResponse
+			response := httpClient.Response{Body: tc.args.body, Headers: tc.args.headers, StatusCode: http.StatusOK}
+			gotErr := parseResponseBody(response, tc.args.responsePath, &got)
+			if diff := cmp.Diff(tc.want.err, gotErr, test.EquateErrors()); diff != "" {
+				t.Fatalf("parseResponseBody(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.response, got); diff != "" {
+				t.Errorf("parseResponseBody(...): -want result, +got result: %v", diff)
+			}
+		})
+	}
+}
+
 func Test_DownloadCertificate(t *testing.T) {
 	type args struct {
 		http              httpClient.Client
@@ -191,7 +476,7 @@ func Test_DownloadCertificate(t *testing.T) {
 				http: &MockHttpClient{
 					MockSendRequest: func(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (resp httpClient.Response, err error) {
 						return httpClient.Response{
-							Body:       `{"form":"pfx","format":"PEM","data":"string","password":"string"}`,
+							Body:       `{"form":"pfx","format":"PEM","data":"cGtjczEyLWRhdGE=","password":"string"}`,
 							Headers:    nil,
 							StatusCode: 200,
 						}, nil
@@ -199,7 +484,7 @@ func Test_DownloadCertificate(t *testing.T) {
 				},
 			},
 			want: want{
-				result: DownloadCertificateResponse{Form: "pfx", Format: "PEM", Data: "string", Password: "string"},
+				result: DownloadCertificateResponse{Form: "pfx", Format: "PEM", DecodedData: []byte("pkcs12-data"), Password: "string", Raw: `{"form":"pfx","format":"PEM","data":"","password":"string"}`},
 				err:    nil,
 			},
 		},
@@ -289,7 +574,7 @@ func Test_GetCertificate(t *testing.T) {
 				},
 			},
 			want: want{
-				result: GetCertificateResponse{ValidTo: "2024-10-18T09:05:22", ValidFrom: "2024-04-18T09:05:22", SignatureHashAlgorithm: "sha384"},
+				result: GetCertificateResponse{ValidTo: "2024-10-18T09:05:22", ValidFrom: "2024-04-18T09:05:22", SignatureHashAlgorithm: "sha384", Raw: `{"validTo":"2024-10-18T09:05:22","validFrom":"2024-04-18T09:05:22","signatureHashAlgorithm":"sha384"}`},
 				err:    nil,
 			},
 		},
@@ -349,3 +634,995 @@ func Test_GetCertificate(t *testing.T) {
 		})
 	}
 }
+
+func Test_getTokenFromFile(t *testing.T) {
+	type args struct {
+		tokenFileTTL time.Duration
+	}
+	type want struct {
+		headers []string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldReadRotatedToken": {
+			args: args{
+				tokenFileTTL: 0,
+			},
+			want: want{
+				headers: []string{"Bearer token-1", "Bearer token-2"},
+			},
+		},
+		"ShouldUseCachedTokenWithinTTL": {
+			args: args{
+				tokenFileTTL: time.Hour,
+			},
+			want: want{
+				headers: []string{"Bearer token-1", "Bearer token-1"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			tokenFile := filepath.Join(t.TempDir(), "token")
+			if err := os.WriteFile(tokenFile, []byte("token-1\n"), 0600); err != nil {
+				t.Fatalf("Failed to write token file: %v", err)
+			}
+
+			var gotHeaders []string
+			cc := &client{
+				log: logr.Logger{},
+				localHttpClient: &MockHttpClient{
+					MockSendRequest: func(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (resp httpClient.Response, err error) {
+						gotHeaders = append(gotHeaders, headers[authorizationHeaderKey]...)
+						return httpClient.Response{Body: `{"taskId": "guid"}`, StatusCode: 200}, nil
+					},
+				},
+				timeout:      timeout,
+				apiEndpoint:  apiEndpoint,
+				tokenFile:    tokenFile,
+				tokenFileTTL: tc.args.tokenFileTTL,
+			}
+
+			if _, err := cc.PostCertificate(context.Background(), &certificate); err != nil {
+				t.Fatalf("PostCertificate(...): unexpected error: %v", err)
+			}
+
+			if err := os.WriteFile(tokenFile, []byte("token-2\n"), 0600); err != nil {
+				t.Fatalf("Failed to write token file: %v", err)
+			}
+
+			if _, err := cc.PostCertificate(context.Background(), &certificate); err != nil {
+				t.Fatalf("PostCertificate(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.headers, gotHeaders); diff != "" {
+				t.Fatalf("PostCertificate(...): -want headers, +got headers: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_getTokenFromMissingFile(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	_, errMissingFile := os.ReadFile(tokenFile)
+
+	cc := &client{
+		log:             logr.Logger{},
+		localHttpClient: &MockHttpClient{},
+		tokenFile:       tokenFile,
+	}
+
+	_, gotErr := cc.PostCertificate(context.Background(), &certificate)
+	wantErr := fmt.Errorf(errPostToCertFailed, fmt.Errorf(errReadingTokenFile, tokenFile, errMissingFile))
+	if diff := cmp.Diff(wantErr, gotErr, test.EquateErrors()); diff != "" {
+		t.Fatalf("PostCertificate(...): -want error, +got error: %v", diff)
+	}
+}
+
+func Test_createPostBodyIssuer(t *testing.T) {
+	withIssuer := certificate.DeepCopy()
+	withIssuer.Spec.CertificateData.Issuer = "issuing-ca-2"
+
+	type args struct {
+		certificate *v1alpha1.Certificate
+	}
+	type want struct {
+		issuer  interface{}
+		present bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldSerializeIssuer": {
+			args: args{
+				certificate: withIssuer,
+			},
+			want: want{
+				issuer:  "issuing-ca-2",
+				present: true,
+			},
+		},
+		"ShouldOmitEmptyIssuer": {
+			args: args{
+				certificate: &certificate,
+			},
+			want: want{
+				issuer:  nil,
+				present: false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			body, err := createPostBody(tc.args.certificate, v1alpha1.Subject{})
+			if err != nil {
+				t.Fatalf("createPostBody(...): unexpected error: %v", err)
+			}
+
+			var serialized map[string]interface{}
+			if err := json.Unmarshal([]byte(jsonutil.ToJSON(body)), &serialized); err != nil {
+				t.Fatalf("Unmarshal(...): unexpected error: %v", err)
+			}
+
+			issuer, present := serialized["issuer"]
+			if diff := cmp.Diff(tc.want.present, present); diff != "" {
+				t.Fatalf("createPostBody(...): -want issuer present, +got issuer present: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.issuer, issuer); diff != "" {
+				t.Fatalf("createPostBody(...): -want issuer, +got issuer: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_createPostBodyEmail(t *testing.T) {
+	withEmail := certificate.DeepCopy()
+	withEmail.Spec.CertificateData.Subject.Email = "admin@example.com"
+
+	type args struct {
+		certificate *v1alpha1.Certificate
+	}
+	type want struct {
+		subject map[string]interface{}
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldSerializeEmail": {
+			args: args{
+				certificate: withEmail,
+			},
+			want: want{
+				subject: map[string]interface{}{"commonName": "example", "email": "admin@example.com"},
+			},
+		},
+		"ShouldOmitEmptyEmail": {
+			args: args{
+				certificate: &certificate,
+			},
+			want: want{
+				subject: map[string]interface{}{"commonName": "example"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			body, err := createPostBody(tc.args.certificate, v1alpha1.Subject{})
+			if err != nil {
+				t.Fatalf("createPostBody(...): unexpected error: %v", err)
+			}
+
+			var serialized map[string]interface{}
+			if err := json.Unmarshal([]byte(jsonutil.ToJSON(body)), &serialized); err != nil {
+				t.Fatalf("Unmarshal(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.subject, serialized["subject"]); diff != "" {
+				t.Fatalf("createPostBody(...): -want subject, +got subject: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_createPostBodyDefaultSubject(t *testing.T) {
+	withOrganization := certificate.DeepCopy()
+	withOrganization.Spec.CertificateData.Subject.Organization = "team-a"
+
+	defaultSubject := v1alpha1.Subject{
+		Country:            "IL",
+		Organization:       "dana",
+		OrganizationalUnit: "platform",
+	}
+
+	type args struct {
+		certificate    *v1alpha1.Certificate
+		defaultSubject v1alpha1.Subject
+	}
+	type want struct {
+		subject Subject
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldApplyDefaultsToEmptyFields": {
+			args: args{
+				certificate:    &certificate,
+				defaultSubject: defaultSubject,
+			},
+			want: want{
+				subject: Subject{CommonName: "example", Country: "IL", Organization: "dana", OrganizationalUnit: "platform"},
+			},
+		},
+		"ShouldPreferCertificateFields": {
+			args: args{
+				certificate:    withOrganization,
+				defaultSubject: defaultSubject,
+			},
+			want: want{
+				subject: Subject{CommonName: "example", Country: "IL", Organization: "team-a", OrganizationalUnit: "platform"},
+			},
+		},
+		"ShouldKeepSubjectWithoutDefaults": {
+			args: args{
+				certificate:    withOrganization,
+				defaultSubject: v1alpha1.Subject{},
+			},
+			want: want{
+				subject: Subject{CommonName: "example", Organization: "team-a"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			body, err := createPostBody(tc.args.certificate, tc.args.defaultSubject)
+			if err != nil {
+				t.Fatalf("createPostBody(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.subject, body.Subject); diff != "" {
+				t.Fatalf("createPostBody(...): -want subject, +got subject: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_createPostBodyKey(t *testing.T) {
+	withRSAKey := certificate.DeepCopy()
+	withRSAKey.Spec.CertificateData.KeyAlgorithm = v1alpha1.KeyAlgorithmRSA
+	withRSAKey.Spec.CertificateData.KeySize = 4096
+
+	withECDSAKey := certificate.DeepCopy()
+	withECDSAKey.Spec.CertificateData.KeyAlgorithm = v1alpha1.KeyAlgorithmECDSA
+	withECDSAKey.Spec.CertificateData.KeyCurve = "P-256"
+
+	type args struct {
+		certificate *v1alpha1.Certificate
+	}
+	type want struct {
+		key map[string]interface{}
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldSerializeRSAKey": {
+			args: args{
+				certificate: withRSAKey,
+			},
+			want: want{
+				key: map[string]interface{}{"keyAlgorithm": "RSA", "keySize": float64(4096)},
+			},
+		},
+		"ShouldSerializeECDSAKey": {
+			args: args{
+				certificate: withECDSAKey,
+			},
+			want: want{
+				key: map[string]interface{}{"keyAlgorithm": "ECDSA", "keyCurve": "P-256"},
+			},
+		},
+		"ShouldOmitEmptyKey": {
+			args: args{
+				certificate: &certificate,
+			},
+			want: want{
+				key: map[string]interface{}{},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			body, err := createPostBody(tc.args.certificate, v1alpha1.Subject{})
+			if err != nil {
+				t.Fatalf("createPostBody(...): unexpected error: %v", err)
+			}
+
+			var serialized map[string]interface{}
+			if err := json.Unmarshal([]byte(jsonutil.ToJSON(body)), &serialized); err != nil {
+				t.Fatalf("Unmarshal(...): unexpected error: %v", err)
+			}
+
+			key := map[string]interface{}{}
+			for _, field := range []string{"keyAlgorithm", "keySize", "keyCurve"} {
+				if value, ok := serialized[field]; ok {
+					key[field] = value
+				}
+			}
+			if diff := cmp.Diff(tc.want.key, key); diff != "" {
+				t.Fatalf("createPostBody(...): -want key, +got key: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_createPostBodyExtensions(t *testing.T) {
+	withExtensions := certificate.DeepCopy()
+	withExtensions.Spec.CertificateData.Extensions = []v1alpha1.CustomExtension{
+		{OID: "1.3.6.1.4.1.311.20.2", Value: "HgQAVQBzAGUAcg==", Critical: true},
+		{OID: "2.5.29.9", Value: "MAA="},
+	}
+
+	withInvalidOID := certificate.DeepCopy()
+	withInvalidOID.Spec.CertificateData.Extensions = []v1alpha1.CustomExtension{{OID: "3.1.2", Value: "MAA="}}
+
+	withInvalidValue := certificate.DeepCopy()
+	withInvalidValue.Spec.CertificateData.Extensions = []v1alpha1.CustomExtension{{OID: "2.5.29.9", Value: "not base64"}}
+
+	type args struct {
+		certificate *v1alpha1.Certificate
+	}
+	type want struct {
+		extensions interface{}
+		err        error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldSerializeCriticalAndNonCriticalExtensions": {
+			args: args{
+				certificate: withExtensions,
+			},
+			want: want{
+				extensions: []interface{}{
+					map[string]interface{}{"oid": "1.3.6.1.4.1.311.20.2", "value": "HgQAVQBzAGUAcg==", "critical": true},
+					map[string]interface{}{"oid": "2.5.29.9", "value": "MAA=", "critical": false},
+				},
+			},
+		},
+		"ShouldOmitEmptyExtensions": {
+			args: args{
+				certificate: &certificate,
+			},
+			want: want{
+				extensions: nil,
+			},
+		},
+		"ShouldFailWithInvalidOID": {
+			args: args{
+				certificate: withInvalidOID,
+			},
+			want: want{
+				err: fmt.Errorf(errInvalidExtensionOID, "3.1.2"),
+			},
+		},
+		"ShouldFailWithInvalidValue": {
+			args: args{
+				certificate: withInvalidValue,
+			},
+			want: want{
+				err: fmt.Errorf(errInvalidExtensionValue, "2.5.29.9", base64.CorruptInputError(3)),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			body, err := createPostBody(tc.args.certificate, v1alpha1.Subject{})
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("createPostBody(...): -want error, +got error: %v", diff)
+			}
+			if err != nil {
+				return
+			}
+
+			var serialized map[string]interface{}
+			if err := json.Unmarshal([]byte(jsonutil.ToJSON(body)), &serialized); err != nil {
+				t.Fatalf("Unmarshal(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.extensions, serialized["extensions"]); diff != "" {
+				t.Fatalf("createPostBody(...): -want extensions, +got extensions: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_isValidOID(t *testing.T) {
+	type args struct {
+		oid string
+	}
+	type want struct {
+		valid bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldAcceptOID": {
+			args: args{
+				oid: "1.3.6.1.4.1.311.20.2",
+			},
+			want: want{
+				valid: true,
+			},
+		},
+		"ShouldAcceptLargeArcUnderJoint": {
+			args: args{
+				oid: "2.999.1",
+			},
+			want: want{
+				valid: true,
+			},
+		},
+		"ShouldRejectSingleArc": {
+			args: args{
+				oid: "1",
+			},
+			want: want{
+				valid: false,
+			},
+		},
+		"ShouldRejectFirstArcAboveTwo": {
+			args: args{
+				oid: "3.1",
+			},
+			want: want{
+				valid: false,
+			},
+		},
+		"ShouldRejectSecondArcAbove39": {
+			args: args{
+				oid: "1.40",
+			},
+			want: want{
+				valid: false,
+			},
+		},
+		"ShouldRejectLeadingZero": {
+			args: args{
+				oid: "1.03",
+			},
+			want: want{
+				valid: false,
+			},
+		},
+		"ShouldRejectEmptyArc": {
+			args: args{
+				oid: "1..3",
+			},
+			want: want{
+				valid: false,
+			},
+		},
+		"ShouldRejectNonNumericArc": {
+			args: args{
+				oid: "1.a",
+			},
+			want: want{
+				valid: false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want.valid, isValidOID(tc.args.oid)); diff != "" {
+				t.Fatalf("isValidOID(...): -want result, +got result: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_GetCertificateMetadata(t *testing.T) {
+	richResponse := `{"data":{"validTo":"2024-10-18T09:05:22","validFrom":"2024-04-18T09:05:22","signatureHashAlgorithm":"sha384",` +
+		`"policyId":"policy-7","profile":"web-server","serial":42,"revoked":null,"internal":"ignored"}}`
+
+	type args struct {
+		metadataFields []string
+	}
+	type want struct {
+		metadata map[string]string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldCaptureSelectedFields": {
+			args: args{
+				metadataFields: []string{"policyId", "profile", "serial"},
+			},
+			want: want{
+				metadata: map[string]string{"policyId": "policy-7", "profile": "web-server", "serial": "42"},
+			},
+		},
+		"ShouldSkipMissingAndNullFields": {
+			args: args{
+				metadataFields: []string{"profile", "fingerprint", "revoked"},
+			},
+			want: want{
+				metadata: map[string]string{"profile": "web-server"},
+			},
+		},
+		"ShouldNotCaptureWithoutFields": {
+			args: args{
+				metadataFields: nil,
+			},
+			want: want{
+				metadata: nil,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cc := &client{
+				log: logr.Logger{},
+				localHttpClient: &MockHttpClient{
+					MockSendRequest: func(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (resp httpClient.Response, err error) {
+						return httpClient.Response{Body: richResponse, StatusCode: 200}, nil
+					},
+				},
+				timeout:        timeout,
+				apiEndpoint:    apiEndpoint,
+				token:          token,
+				responsePath:   "data",
+				metadataFields: tc.args.metadataFields,
+			}
+
+			got, err := cc.GetCertificate(context.Background(), &certificate)
+			if err != nil {
+				t.Fatalf("GetCertificate(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff("sha384", got.SignatureHashAlgorithm); diff != "" {
+				t.Fatalf("GetCertificate(...): -want signatureHashAlgorithm, +got signatureHashAlgorithm: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.metadata, got.Metadata); diff != "" {
+				t.Fatalf("GetCertificate(...): -want metadata, +got metadata: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_clientAgainstFakeCertAPI(t *testing.T) {
+	type args struct {
+		operation  certtest.Operation
+		statusCode int
+		latency    time.Duration
+	}
+	type want struct {
+		postErr     error
+		getErr      error
+		downloadErr error
+		timedOut    bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldIssueCertificate": {
+			args: args{},
+			want: want{},
+		},
+		"ShouldFailPost": {
+			args: args{
+				operation:  certtest.OperationPost,
+				statusCode: http.StatusInternalServerError,
+			},
+			want: want{
+				postErr: fmt.Errorf(errPostToCertFailed, errors.New(http.StatusText(http.StatusInternalServerError))),
+			},
+		},
+		"ShouldFailGet": {
+			args: args{
+				operation:  certtest.OperationGet,
+				statusCode: http.StatusServiceUnavailable,
+			},
+			want: want{
+				getErr: fmt.Errorf(errGetDataToCertFailed, errors.New(http.StatusText(http.StatusServiceUnavailable))),
+			},
+		},
+		"ShouldFailDownload": {
+			args: args{
+				operation:  certtest.OperationDownload,
+				statusCode: http.StatusNotFound,
+			},
+			want: want{
+				downloadErr: fmt.Errorf(errDownloadToCertFailed, errors.New(http.StatusText(http.StatusNotFound))),
+			},
+		},
+		"ShouldTimeOutOnLatency": {
+			args: args{
+				latency: time.Second,
+			},
+			want: want{
+				timedOut: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := certtest.NewServer(token, base64.StdEncoding.EncodeToString([]byte("pkcs12-data")), "pkcs12-password")
+			defer server.Close()
+
+			server.SetLatency(tc.args.latency)
+			if tc.args.operation != "" {
+				server.FailWith(tc.args.operation, tc.args.statusCode)
+			}
+
+			config := certificateConfig.DeepCopy()
+			config.Spec.WaitTimeout = &metav1.Duration{Duration: 100 * time.Millisecond}
+
+			cc, err := NewClientFromCertificateConfigAndSecretData(logr.Discard(), config, server.Credentials())
+			if err != nil {
+				t.Fatalf("NewClientFromCertificateConfigAndSecretData(...): unexpected error: %v", err)
+			}
+
+			c := certificate.DeepCopy()
+			response, err := cc.PostCertificate(context.Background(), c)
+			if tc.want.timedOut {
+				if err == nil {
+					t.Fatalf("PostCertificate(...): expected a timeout error")
+				}
+				return
+			}
+			if diff := cmp.Diff(tc.want.postErr, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("PostCertificate(...): -want error, +got error: %v", diff)
+			}
+			if err != nil {
+				return
+			}
+			c.Status.Guid = response.Guid
+
+			getResponse, err := cc.GetCertificate(context.Background(), c)
+			if diff := cmp.Diff(tc.want.getErr, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("GetCertificate(...): -want error, +got error: %v", diff)
+			}
+			if err == nil && getResponse.ValidTo == "" {
+				t.Fatalf("GetCertificate(...): expected validTo to be set")
+			}
+
+			downloadResponse, err := cc.DownloadCertificate(context.Background(), c)
+			if diff := cmp.Diff(tc.want.downloadErr, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("DownloadCertificate(...): -want error, +got error: %v", diff)
+			}
+			if err == nil {
+				want := DownloadCertificateResponse{Form: "pfx", Format: "base64", DecodedData: []byte("pkcs12-data"), Password: "pkcs12-password"}
+				if diff := cmp.Diff(want, downloadResponse, cmpopts.IgnoreFields(DownloadCertificateResponse{}, "Raw")); diff != "" {
+					t.Fatalf("DownloadCertificate(...): -want response, +got response: %v", diff)
+				}
+			}
+		})
+	}
+}
+
+func Test_getAuthorizationHeaderAcceptLanguage(t *testing.T) {
+	type args struct {
+		acceptLanguage string
+	}
+	type want struct {
+		headers map[string][]string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldSendAcceptLanguage": {
+			args: args{
+				acceptLanguage: "en-US",
+			},
+			want: want{
+				headers: map[string][]string{
+					authorizationHeaderKey: {fmt.Sprintf(authorizationToken, token)},
+					acceptHeaderKey:        {acceptHeaderValue},
+					acceptLanguageHeader:   {"en-US"},
+				},
+			},
+		},
+		"ShouldNotSendEmptyAcceptLanguage": {
+			args: args{
+				acceptLanguage: "",
+			},
+			want: want{
+				headers: map[string][]string{
+					authorizationHeaderKey: {fmt.Sprintf(authorizationToken, token)},
+					acceptHeaderKey:        {acceptHeaderValue},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cc := &client{token: token, acceptLanguage: tc.args.acceptLanguage}
+
+			headers, err := cc.getAuthorizationHeader()
+			if err != nil {
+				t.Fatalf("getAuthorizationHeader(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want.headers, headers); diff != "" {
+				t.Fatalf("getAuthorizationHeader(...): -want headers, +got headers: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_requestMethods(t *testing.T) {
+	const guid = "83729jsdjd92819w1yhdsduy288yhduwdbd"
+
+	type request struct {
+		method string
+		body   string
+	}
+	type args struct {
+		methods v1alpha1.HTTPMethods
+	}
+	type want struct {
+		requests []request
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldUseDefaultMethods": {
+			args: args{
+				methods: v1alpha1.HTTPMethods{},
+			},
+			want: want{
+				requests: []request{
+					{method: http.MethodPost},
+					{method: http.MethodGet, body: ""},
+					{method: http.MethodGet, body: ""},
+				},
+			},
+		},
+		"ShouldUseConfiguredMethodsWithRetrievalBody": {
+			args: args{
+				methods: v1alpha1.HTTPMethods{Post: http.MethodPut, Get: http.MethodPost, Download: http.MethodPost},
+			},
+			want: want{
+				requests: []request{
+					{method: http.MethodPut},
+					{method: http.MethodPost, body: fmt.Sprintf(`{"taskId":%q}`, guid)},
+					{method: http.MethodPost, body: fmt.Sprintf(`{"taskId":%q,"form":"pfx"}`, guid)},
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got []request
+			cc := &client{
+				log: logr.Logger{},
+				localHttpClient: &MockHttpClient{
+					MockSendRequest: func(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (resp httpClient.Response, err error) {
+						got = append(got, request{method: method, body: body})
+						return httpClient.Response{Body: `{}`, StatusCode: http.StatusOK}, nil
+					},
+				},
+				apiEndpoint:      apiEndpoint,
+				downloadEndpoint: downloadEndpoint,
+				token:            token,
+				methods:          tc.args.methods,
+			}
+
+			issued := certificate.DeepCopy()
+			issued.Status.Guid = guid
+
+			_, _ = cc.PostCertificate(context.Background(), issued)
+			_, _ = cc.GetCertificate(context.Background(), issued)
+			_, _ = cc.DownloadCertificate(context.Background(), issued)
+
+			// The body of the request creating the certificate is covered by the createPostBody tests.
+			got[0].body = ""
+			if diff := cmp.Diff(tc.want.requests, got, cmp.AllowUnexported(request{})); diff != "" {
+				t.Fatalf("SendRequest(...): -want requests, +got requests: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_requestSpans(t *testing.T) {
+	recorder, reset := tracingtest.NewRecorder()
+	defer reset()
+
+	server := certtest.NewServer(token, "pkcs12-data", "pkcs12-password")
+	defer server.Close()
+
+	cc, err := NewClientFromCertificateConfigAndSecretData(logr.Discard(), &certificateConfig, server.Credentials())
+	if err != nil {
+		t.Fatalf("NewClientFromCertificateConfigAndSecretData(...): unexpected error: %v", err)
+	}
+
+	ctx, span := tracing.Start(context.Background(), "Reconcile")
+	c := certificate.DeepCopy()
+	response, err := cc.PostCertificate(ctx, c)
+	if err != nil {
+		t.Fatalf("PostCertificate(...): unexpected error: %v", err)
+	}
+	c.Status.Guid = response.Guid
+	if _, err := cc.GetCertificate(ctx, c); err != nil {
+		t.Fatalf("GetCertificate(...): unexpected error: %v", err)
+	}
+	server.FailWith(certtest.OperationDownload, http.StatusNotFound)
+	_, downloadErr := cc.DownloadCertificate(ctx, c)
+	span.End()
+
+	certificateAttributes := map[string]any{
+		tracing.AttributeCertificate: "default/test-cert",
+		tracing.AttributeConfig:      certificateConfig.Name,
+	}
+	want := []tracingtest.RecordedSpan{
+		{Name: "Reconcile", Attributes: map[string]any{}, Ended: true},
+		{Name: "PostCertificate", Parent: "Reconcile", Attributes: certificateAttributes, Ended: true},
+		{Name: "SendRequest", Parent: "PostCertificate", Attributes: map[string]any{tracing.AttributeMethod: http.MethodPost, tracing.AttributeStatusCode: int64(http.StatusOK)}, Ended: true},
+		{Name: "GetCertificate", Parent: "Reconcile", Attributes: certificateAttributes, Ended: true},
+		{Name: "SendRequest", Parent: "GetCertificate", Attributes: map[string]any{tracing.AttributeMethod: http.MethodGet, tracing.AttributeStatusCode: int64(http.StatusOK)}, Ended: true},
+		{Name: "DownloadCertificate", Parent: "Reconcile", Attributes: certificateAttributes, Err: downloadErr.Error(), Ended: true},
+		{Name: "SendRequest", Parent: "DownloadCertificate", Attributes: map[string]any{tracing.AttributeMethod: http.MethodGet, tracing.AttributeStatusCode: int64(http.StatusNotFound)}, Err: (&httpClient.APIError{StatusCode: http.StatusNotFound}).Error(), Ended: true},
+	}
+	if diff := cmp.Diff(want, recorder.Spans()); diff != "" {
+		t.Fatalf("Spans(): -want spans, +got spans: %v", diff)
+	}
+}
+
+func Test_checkResponseStatus(t *testing.T) {
+	type args struct {
+		errorField   string
+		successField string
+		body         string
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldPassSuccessfulResponse": {
+			args: args{
+				errorField:   "error",
+				successField: "success",
+				body:         `{"taskId": "guid", "error": null, "success": true}`,
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldPassResponseWithoutFields": {
+			args: args{
+				errorField:   "error",
+				successField: "success",
+				body:         `{"taskId": "guid"}`,
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldPassEmptyErrorField": {
+			args: args{
+				errorField: "error",
+				body:       `{"taskId": "guid", "error": ""}`,
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldPassWithoutConfiguredFields": {
+			args: args{
+				body: `{"error": "quota exceeded", "success": false}`,
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldFailWithErrorField": {
+			args: args{
+				errorField:   "error",
+				successField: "success",
+				body:         `{"error": "quota exceeded", "success": false}`,
+			},
+			want: want{
+				err: fmt.Errorf(errResponseBodyError, "error", "quota exceeded"),
+			},
+		},
+		"ShouldFailWithNestedErrorObject": {
+			args: args{
+				errorField: "result.error",
+				body:       `{"result": {"error": {"code": 42}}}`,
+			},
+			want: want{
+				err: fmt.Errorf(errResponseBodyError, "result.error", `{"code": 42}`),
+			},
+		},
+		"ShouldFailWithFalseSuccessField": {
+			args: args{
+				successField: "success",
+				body:         `{"success": false}`,
+			},
+			want: want{
+				err: fmt.Errorf(errResponseNotSuccessful, "success"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cc := &client{errorField: tc.args.errorField, successField: tc.args.successField}
+
+			err := cc.checkResponseStatus(tc.args.body)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("checkResponseStatus(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_PostCertificateErrorInBody(t *testing.T) {
+	type args struct {
+		body string
+	}
+	type want struct {
+		result PostCertificateResponse
+		err    error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldReturnGuidOfSuccessfulResponse": {
+			args: args{
+				body: `{"taskId": "83729jsdjd92819w1yhdsduy288yhduwdbd", "success": true}`,
+			},
+			want: want{
+				result: PostCertificateResponse{
+					Guid: "83729jsdjd92819w1yhdsduy288yhduwdbd",
+					Raw:  `{"taskId": "83729jsdjd92819w1yhdsduy288yhduwdbd", "success": true}`,
+				},
+				err: nil,
+			},
+		},
+		"ShouldFailWithErrorInBody": {
+			args: args{
+				body: `{"error": "template not allowed", "success": false}`,
+			},
+			want: want{
+				result: PostCertificateResponse{Raw: `{"error": "template not allowed", "success": false}`},
+				err:    fmt.Errorf(errPostToCertFailed, fmt.Errorf(errResponseBodyError, "error", "template not allowed")),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cc := &client{
+				localHttpClient: &MockHttpClient{
+					MockSendRequest: func(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (httpClient.Response, error) {
+						return httpClient.Response{Body: tc.args.body, StatusCode: http.StatusOK}, nil
+					},
+				},
+				apiEndpoint:  apiEndpoint,
+				token:        token,
+				errorField:   "error",
+				successField: "success",
+			}
+
+			got, err := cc.PostCertificate(context.Background(), &certificate)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("PostCertificate(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.result, got); diff != "" {
+				t.Fatalf("PostCertificate(...): -want result, +got result: %v", diff)
+			}
+		})
+	}
+}
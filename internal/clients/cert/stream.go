@@ -0,0 +1,476 @@
+package cert
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dana-team/certificate-operator/internal/certhandler"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+)
+
+const (
+	// dataField is the field of download responses holding the base64-encoded PKCS#12 data, matched
+	// case-insensitively, as it is when unmarshalling the response.
+	dataField = "data"
+
+	// maxResponseDepth is the maximum nesting depth of the JSON values of scanned responses.
+	maxResponseDepth = 64
+
+	errUnexpectedEnd       = "unexpected end of JSON input"
+	errUnexpectedCharacter = "unexpected character %q in JSON input"
+	errResponseTooDeep     = "JSON input is nested too deeply"
+	errInvalidEscape       = "invalid escape sequence in JSON string"
+	errNonASCIIEscape      = "non-ASCII escaped character in the data field"
+)
+
+// scannedResponse is the result of scanning a download response.
+type scannedResponse struct {
+	// skeleton is the body of the response as received, with the content of the data field left empty.
+	skeleton string
+	// decodedData is the PKCS#12 data, base64-decoded as it was read. It is nil if the response held no data field at
+	// the response path.
+	decodedData []byte
+	// dataErr is the error of decoding the data field, if any.
+	dataErr error
+}
+
+// sendStreamRequest sends the request to the replicas of the Cert API like sendRequest, and returns the response with
+// its body as a reader if the HTTP client can stream responses. The responses of other HTTP clients are read whole,
+// and returned with a reader of their body.
+func (c *client) sendStreamRequest(ctx context.Context, method string, url func(apiEndpoint string) string, body string, headers map[string][]string, failover func(error) bool) (httpClient.StreamResponse, error) {
+	streamClient, ok := c.localHttpClient.(httpClient.StreamClient)
+	if !ok {
+		response, err := c.sendRequest(ctx, method, url, body, headers, failover)
+		if err != nil {
+			return httpClient.StreamResponse{}, err
+		}
+
+		return httpClient.StreamResponse{
+			Body:       io.NopCloser(strings.NewReader(response.Body)),
+			Headers:    response.Headers,
+			StatusCode: response.StatusCode,
+		}, nil
+	}
+
+	var response httpClient.StreamResponse
+	err := c.sendToReplicas(ctx, headers, failover, func(apiEndpoint string, headers map[string][]string, timeout time.Duration) error {
+		var err error
+		response, err = streamClient.SendRequestStream(ctx, method, url(apiEndpoint), body, headers, true, timeout)
+		return err
+	})
+
+	return response, err
+}
+
+// scanDownloadResponse reads the JSON body of a download response from the reader. The content of the data field of
+// the object at the response path is base64-decoded as it is read, and the rest of the body is copied to the skeleton
+// of the returned response, so that the encoded PKCS#12 data is never held in memory. Bodies which are not valid JSON
+// are copied to the skeleton from the first invalid character on, so that parsing the skeleton reports them. It only
+// returns an error if the body cannot be read.
+func scanDownloadResponse(reader io.Reader, responsePath string) (scannedResponse, error) {
+	dataPath := []string{dataField}
+	if responsePath != "" {
+		dataPath = append(strings.Split(responsePath, "."), dataField)
+	}
+
+	scanner := &responseScanner{reader: bufio.NewReader(reader), dataPath: dataPath}
+	_ = scanner.scanValue(0, true)
+	if scanner.readErr != nil {
+		return scannedResponse{}, scanner.readErr
+	}
+
+	if _, err := scanner.skeleton.ReadFrom(scanner.reader); err != nil {
+		return scannedResponse{}, err
+	}
+
+	return scannedResponse{
+		skeleton:    scanner.skeleton.String(),
+		decodedData: scanner.decodedData,
+		dataErr:     scanner.dataErr,
+	}, nil
+}
+
+// responseScanner scans a JSON response body, copying the bytes it reads to the skeleton, except the content of the
+// data string, which is decoded instead.
+type responseScanner struct {
+	reader   *bufio.Reader
+	skeleton bytes.Buffer
+	dataPath []string
+	// readErr is the error of reading the body, other than its end.
+	readErr error
+
+	decodedData []byte
+	dataErr     error
+}
+
+// readByte reads the next byte of the body, without copying it to the skeleton.
+func (s *responseScanner) readByte() (byte, error) {
+	b, err := s.reader.ReadByte()
+	if err != nil {
+		if err != io.EOF {
+			s.readErr = err
+			return 0, err
+		}
+		return 0, errors.New(errUnexpectedEnd)
+	}
+
+	return b, nil
+}
+
+// copyByte reads the next byte of the body and copies it to the skeleton.
+func (s *responseScanner) copyByte() (byte, error) {
+	b, err := s.readByte()
+	if err != nil {
+		return 0, err
+	}
+	s.skeleton.WriteByte(b)
+
+	return b, nil
+}
+
+// peekToken copies the whitespace before the next token to the skeleton, and returns the first byte of the token
+// without reading it.
+func (s *responseScanner) peekToken() (byte, error) {
+	for {
+		next, err := s.reader.Peek(1)
+		if err != nil {
+			if err != io.EOF {
+				s.readErr = err
+				return 0, err
+			}
+			return 0, errors.New(errUnexpectedEnd)
+		}
+
+		switch next[0] {
+		case ' ', '\t', '\r', '\n':
+			_, _ = s.copyByte()
+		default:
+			return next[0], nil
+		}
+	}
+}
+
+// expect copies the next token to the skeleton, and fails if it is not the given character.
+func (s *responseScanner) expect(c byte) error {
+	if _, err := s.peekToken(); err != nil {
+		return err
+	}
+
+	b, err := s.copyByte()
+	if err != nil {
+		return err
+	}
+	if b != c {
+		return fmt.Errorf(errUnexpectedCharacter, b)
+	}
+
+	return nil
+}
+
+// scanValue scans the next JSON value, at the given nesting level. onPath is set if the keys of the value are the
+// first keys of the data path, in which case the value is the data field if it is at the level of the data path.
+func (s *responseScanner) scanValue(level int, onPath bool) error {
+	if level > maxResponseDepth {
+		return errors.New(errResponseTooDeep)
+	}
+
+	b, err := s.peekToken()
+	if err != nil {
+		return err
+	}
+
+	switch b {
+	case '{':
+		return s.scanObject(level, onPath)
+	case '[':
+		return s.scanArray(level)
+	case '"':
+		if onPath && level == len(s.dataPath) {
+			return s.scanData()
+		}
+		_, err := s.scanString(false)
+		return err
+	default:
+		return s.scanLiteral()
+	}
+}
+
+// scanObject scans a JSON object, whose fields are at the next nesting level.
+func (s *responseScanner) scanObject(level int, onPath bool) error {
+	if err := s.expect('{'); err != nil {
+		return err
+	}
+
+	b, err := s.peekToken()
+	if err != nil {
+		return err
+	}
+	if b == '}' {
+		return s.expect('}')
+	}
+
+	for {
+		if b, err = s.peekToken(); err != nil {
+			return err
+		}
+		if b != '"' {
+			return fmt.Errorf(errUnexpectedCharacter, b)
+		}
+
+		key, err := s.scanString(true)
+		if err != nil {
+			return err
+		}
+
+		if err := s.expect(':'); err != nil {
+			return err
+		}
+
+		if err := s.scanValue(level+1, onPath && s.isOnPath(level, key)); err != nil {
+			return err
+		}
+
+		if _, err := s.peekToken(); err != nil {
+			return err
+		}
+		if b, err = s.copyByte(); err != nil {
+			return err
+		}
+		switch b {
+		case ',':
+			continue
+		case '}':
+			return nil
+		default:
+			return fmt.Errorf(errUnexpectedCharacter, b)
+		}
+	}
+}
+
+// isOnPath checks if the key of a field at the given nesting level is the key of the data path at that level. The
+// data field is matched case-insensitively, and the keys of the response path exactly, as they are when parsing the
+// response.
+func (s *responseScanner) isOnPath(level int, key string) bool {
+	if level >= len(s.dataPath) {
+		return false
+	}
+
+	if level == len(s.dataPath)-1 {
+		return strings.EqualFold(key, s.dataPath[level])
+	}
+
+	return key == s.dataPath[level]
+}
+
+// scanArray scans a JSON array, whose elements are at the next nesting level and never on the data path.
+func (s *responseScanner) scanArray(level int) error {
+	if err := s.expect('['); err != nil {
+		return err
+	}
+
+	b, err := s.peekToken()
+	if err != nil {
+		return err
+	}
+	if b == ']' {
+		return s.expect(']')
+	}
+
+	for {
+		if err := s.scanValue(level+1, false); err != nil {
+			return err
+		}
+
+		if _, err := s.peekToken(); err != nil {
+			return err
+		}
+		if b, err = s.copyByte(); err != nil {
+			return err
+		}
+		switch b {
+		case ',':
+			continue
+		case ']':
+			return nil
+		default:
+			return fmt.Errorf(errUnexpectedCharacter, b)
+		}
+	}
+}
+
+// scanString scans a JSON string. If unquote is set, the string is returned unquoted.
+func (s *responseScanner) scanString(unquote bool) (string, error) {
+	start := s.skeleton.Len()
+	if err := s.expect('"'); err != nil {
+		return "", err
+	}
+
+	for {
+		b, err := s.copyByte()
+		if err != nil {
+			return "", err
+		}
+
+		if b == '\\' {
+			if _, err := s.copyByte(); err != nil {
+				return "", err
+			}
+			continue
+		}
+
+		if b == '"' {
+			break
+		}
+	}
+
+	if !unquote {
+		return "", nil
+	}
+
+	var str string
+	if err := json.Unmarshal(s.skeleton.Bytes()[start:], &str); err != nil {
+		return "", err
+	}
+
+	return str, nil
+}
+
+// scanData scans the JSON string of the data field, whose content is base64-decoded as it is read instead of being
+// copied to the skeleton, where the data field is left empty.
+func (s *responseScanner) scanData() error {
+	if err := s.expect('"'); err != nil {
+		return err
+	}
+
+	dataReader := &jsonStringReader{scanner: s}
+	decodedData, dataErr := certhandler.DecodeBase64Reader(dataReader)
+	if _, err := io.Copy(io.Discard, dataReader); err != nil {
+		return err
+	}
+	if dataReader.err != nil {
+		return dataReader.err
+	}
+
+	s.decodedData, s.dataErr = decodedData, dataErr
+	s.skeleton.WriteByte('"')
+
+	return nil
+}
+
+// jsonStringReader reads the unescaped content of a JSON string from the body scanned by the scanner, up to its
+// closing quote, which it consumes.
+type jsonStringReader struct {
+	scanner *responseScanner
+	done    bool
+	// err is the error of reading the string, which is either malformed or could not be read.
+	err error
+}
+
+// Read reads the unescaped content of the string.
+func (r *jsonStringReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	n := 0
+	for n < len(p) && !r.done {
+		b, err := r.scanner.readByte()
+		if err != nil {
+			r.err = err
+			return n, err
+		}
+
+		switch {
+		case b == '"':
+			r.done = true
+		case b == '\\':
+			if b, err = r.readEscape(); err != nil {
+				r.err = err
+				return n, err
+			}
+			p[n] = b
+			n++
+		case b < 0x20:
+			r.err = fmt.Errorf(errUnexpectedCharacter, b)
+			return n, r.err
+		default:
+			p[n] = b
+			n++
+		}
+	}
+
+	if n == 0 && r.done {
+		return 0, io.EOF
+	}
+
+	return n, nil
+}
+
+// readEscape reads the escape sequence following a backslash, and returns the character it escapes. Only ASCII
+// characters are returned, since base64-encoded data holds no other characters.
+func (r *jsonStringReader) readEscape() (byte, error) {
+	b, err := r.scanner.readByte()
+	if err != nil {
+		return 0, err
+	}
+
+	switch b {
+	case '"', '\\', '/':
+		return b, nil
+	case 'b':
+		return '\b', nil
+	case 'f':
+		return '\f', nil
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case 'u':
+		var hex [4]byte
+		for i := range hex {
+			if hex[i], err = r.scanner.readByte(); err != nil {
+				return 0, err
+			}
+		}
+		code, err := strconv.ParseUint(string(hex[:]), 16, 16)
+		if err != nil {
+			return 0, errors.New(errInvalidEscape)
+		}
+		if code >= 0x80 {
+			return 0, errors.New(errNonASCIIEscape)
+		}
+		return byte(code), nil
+	default:
+		return 0, errors.New(errInvalidEscape)
+	}
+}
+
+// scanLiteral scans a JSON number, boolean or null, or, in bodies which are not JSON, the characters up to the next
+// delimiter.
+func (s *responseScanner) scanLiteral() error {
+	for read := 0; ; read++ {
+		next, err := s.reader.Peek(1)
+		if err != nil && err != io.EOF {
+			s.readErr = err
+			return err
+		}
+
+		if err == io.EOF || strings.IndexByte(",:]} \t\r\n", next[0]) >= 0 {
+			if read == 0 {
+				return errors.New(errUnexpectedEnd)
+			}
+			return nil
+		}
+		_, _ = s.copyByte()
+	}
+}
@@ -0,0 +1,227 @@
+package cert
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+	"github.com/google/go-cmp/cmp"
+)
+
+const streamedData = "pkcs12-data"
+
+var encodedStreamedData = base64.StdEncoding.EncodeToString([]byte(streamedData))
+
+func Test_scanDownloadResponse(t *testing.T) {
+	type args struct {
+		body         string
+		responsePath string
+	}
+	type want struct {
+		skeleton    string
+		decodedData []byte
+		dataErr     bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldStreamData": {
+			args: args{
+				body: `{"form":"pfx","data":"` + encodedStreamedData + `","password":"pkcs12-password"}`,
+			},
+			want: want{
+				skeleton:    `{"form":"pfx","data":"","password":"pkcs12-password"}`,
+				decodedData: []byte(streamedData),
+			},
+		},
+		"ShouldStreamDataAtResponsePath": {
+			args: args{
+				body:         `{"result": {"certificate": {"data": "` + encodedStreamedData + `"}}, "data": "other"}`,
+				responsePath: "result.certificate",
+			},
+			want: want{
+				skeleton:    `{"result": {"certificate": {"data": ""}}, "data": "other"}`,
+				decodedData: []byte(streamedData),
+			},
+		},
+		"ShouldStreamDataMatchedCaseInsensitively": {
+			args: args{
+				body: `{"Data":"` + encodedStreamedData + `"}`,
+			},
+			want: want{
+				skeleton:    `{"Data":""}`,
+				decodedData: []byte(streamedData),
+			},
+		},
+		"ShouldStreamEscapedData": {
+			args: args{
+				body: `{"data":"` + base64.StdEncoding.EncodeToString([]byte{0xff, 0xff, 0xff}) + `\/+\r\n"}`,
+			},
+			want: want{
+				skeleton:    `{"data":""}`,
+				decodedData: []byte{0xff, 0xff, 0xff, 0xff},
+			},
+		},
+		"ShouldNotStreamDataOutsideOfResponsePath": {
+			args: args{
+				body: `{"items":[{"data":"a"}],"meta":{"data":"b"},"serial":12,"valid":true,"revoked":null}`,
+			},
+			want: want{
+				skeleton:    `{"items":[{"data":"a"}],"meta":{"data":"b"},"serial":12,"valid":true,"revoked":null}`,
+				decodedData: nil,
+			},
+		},
+		"ShouldKeepStringsWithEscapedQuotes": {
+			args: args{
+				body: `{"message":"a \"quoted\" \\ value","data":"` + encodedStreamedData + `"}`,
+			},
+			want: want{
+				skeleton:    `{"message":"a \"quoted\" \\ value","data":""}`,
+				decodedData: []byte(streamedData),
+			},
+		},
+		"ShouldReportDataWhichCannotBeDecoded": {
+			args: args{
+				body: `{"data":"not base64!","password":"pkcs12-password"}`,
+			},
+			want: want{
+				skeleton: `{"data":"","password":"pkcs12-password"}`,
+				dataErr:  true,
+			},
+		},
+		"ShouldCopyHTMLBody": {
+			args: args{
+				body: "<html>\n<body>Gateway Timeout</body>\n</html>",
+			},
+			want: want{
+				skeleton: "<html>\n<body>Gateway Timeout</body>\n</html>",
+			},
+		},
+		"ShouldCopyInvalidJSON": {
+			args: args{
+				body: `{ "83729jsdjd92819w1yhdsduy288yhduwdbd"}`,
+			},
+			want: want{
+				skeleton: `{ "83729jsdjd92819w1yhdsduy288yhduwdbd"}`,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := scanDownloadResponse(strings.NewReader(tc.args.body), tc.args.responsePath)
+			if err != nil {
+				t.Fatalf("scanDownloadResponse(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want.skeleton, got.skeleton); diff != "" {
+				t.Errorf("scanDownloadResponse(...): -want skeleton, +got skeleton: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.decodedData, got.decodedData); diff != "" {
+				t.Errorf("scanDownloadResponse(...): -want decoded data, +got decoded data: %v", diff)
+			}
+			if (got.dataErr != nil) != tc.want.dataErr {
+				t.Errorf("scanDownloadResponse(...): want data error %v, got data error %v", tc.want.dataErr, got.dataErr)
+			}
+		})
+	}
+}
+
+// failingReader returns the data and then fails with the error.
+type failingReader struct {
+	data *strings.Reader
+	err  error
+}
+
+// Read reads the data, and fails with the error once it was read.
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.data.Len() == 0 {
+		return 0, r.err
+	}
+
+	return r.data.Read(p)
+}
+
+func Test_scanDownloadResponseReadError(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	cases := map[string]struct {
+		body string
+	}{
+		"ShouldFailReadingData":     {body: `{"data":"` + encodedStreamedData},
+		"ShouldFailReadingSkeleton": {body: `{"form":"pfx"`},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := scanDownloadResponse(&failingReader{data: strings.NewReader(tc.body), err: errBoom}, "")
+			if diff := cmp.Diff(errBoom, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("scanDownloadResponse(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
+
+// newDownloadBody returns the body of a download response holding the given amount of random data.
+func newDownloadBody(t testing.TB, size int) ([]byte, string) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatalf("failed to generate data: %v", err)
+	}
+
+	return data, fmt.Sprintf(`{"form":"pfx","format":"base64","data":%q,"password":"pkcs12-password"}`, base64.StdEncoding.EncodeToString(data))
+}
+
+func Test_scanDownloadResponseMatchesBufferedParse(t *testing.T) {
+	data, body := newDownloadBody(t, 4<<20)
+
+	scanned, err := scanDownloadResponse(strings.NewReader(body), "")
+	if err != nil {
+		t.Fatalf("scanDownloadResponse(...): unexpected error: %v", err)
+	}
+
+	var buffered DownloadCertificateResponse
+	if err := parseResponseBody(httpClient.Response{Body: body}, "", &buffered); err != nil {
+		t.Fatalf("parseResponseBody(...): unexpected error: %v", err)
+	}
+	bufferedData, err := base64.StdEncoding.DecodeString(buffered.Data)
+	if err != nil {
+		t.Fatalf("DecodeString(...): unexpected error: %v", err)
+	}
+
+	if !bytes.Equal(bufferedData, scanned.decodedData) || !bytes.Equal(data, scanned.decodedData) {
+		t.Fatalf("scanDownloadResponse(...): streamed data differs from the buffered data")
+	}
+}
+
+// Benchmark_scanDownloadResponse compares streaming a large download response with parsing it whole and decoding its
+// data, as download responses were parsed before they were streamed.
+func Benchmark_scanDownloadResponse(b *testing.B) {
+	_, body := newDownloadBody(b, 4<<20)
+
+	b.Run("Streamed", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := scanDownloadResponse(strings.NewReader(body), ""); err != nil {
+				b.Fatalf("scanDownloadResponse(...): unexpected error: %v", err)
+			}
+		}
+	})
+
+	b.Run("Buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var response DownloadCertificateResponse
+			if err := parseResponseBody(httpClient.Response{Body: string([]byte(body))}, "", &response); err != nil {
+				b.Fatalf("parseResponseBody(...): unexpected error: %v", err)
+			}
+			if _, err := base64.StdEncoding.DecodeString(response.Data); err != nil {
+				b.Fatalf("DecodeString(...): unexpected error: %v", err)
+			}
+		}
+	})
+}
@@ -2,13 +2,25 @@ package cert
 
 // postCertificateBody represents the request body structure for sending a POST request to the Cert service.
 type postCertificateBody struct {
-	Subject  Subject `json:"subject,omitempty"`
-	San      San     `json:"san,omitempty"`
-	Template string  `json:"template,omitempty"`
+	Subject      Subject     `json:"subject,omitempty"`
+	San          San         `json:"san,omitempty"`
+	Template     string      `json:"template,omitempty"`
+	Issuer       string      `json:"issuer,omitempty"`
+	KeyAlgorithm string      `json:"keyAlgorithm,omitempty"`
+	KeySize      int         `json:"keySize,omitempty"`
+	KeyCurve     string      `json:"keyCurve,omitempty"`
+	Extensions   []Extension `json:"extensions,omitempty"`
+}
+
+// Extension represents a custom X.509 extension of a certificate, with its base64-encoded DER value.
+type Extension struct {
+	OID      string `json:"oid"`
+	Value    string `json:"value"`
+	Critical bool   `json:"critical"`
 }
 
 // Subject represents the subject of a certificate, including common name, country, state, locality,
-// organization, and organizational unit.
+// organization, organizational unit, and email.
 type Subject struct {
 	CommonName         string `json:"commonName,omitempty"`
 	Country            string `json:"country,omitempty"`
@@ -16,6 +28,7 @@ type Subject struct {
 	Locality           string `json:"locality,omitempty"`
 	Organization       string `json:"organization,omitempty"`
 	OrganizationalUnit string `json:"organizationalUnit,omitempty"`
+	Email              string `json:"email,omitempty"`
 }
 
 // San represents the subject alternative name (SAN) of a certificate, including DNS names and IP addresses.
@@ -24,17 +37,39 @@ type San struct {
 	IPs []string `json:"ips,omitempty"`
 }
 
+// retrievalBody represents the request body sent to the Cert service to get or download a certificate,
+// when the method of the request is not GET.
+type retrievalBody struct {
+	Guid string `json:"taskId"`
+	Form string `json:"form,omitempty"`
+}
+
 // PostCertificateResponse represents the structure of the JSON response body for obtaining a certificate.
 type PostCertificateResponse struct {
 	Guid string `json:"taskId"`
+	// Raw is the body of the response as received.
+	Raw string `json:"-"`
 }
 
 // DownloadCertificateResponse represents the response received when downloading a certificate.
 type DownloadCertificateResponse struct {
-	Form     string `json:"form"`
-	Format   string `json:"format"`
+	Form   string `json:"form"`
+	Format string `json:"format"`
+	// Data is the base64-encoded PKCS#12 data. It is empty when the data was streamed, in which case DecodedData
+	// holds it.
 	Data     string `json:"data"`
 	Password string `json:"password"`
+	// DecodedData is the PKCS#12 data, base64-decoded as it was streamed from the response. It is nil if the response
+	// held no data, or if it could not be decoded, in which case DataErr is set.
+	DecodedData []byte `json:"-"`
+	// DataErr is the error of decoding the streamed data. It is not returned by the download, so that data which cannot
+	// be decoded does not fail the request.
+	DataErr error `json:"-"`
+	// Metadata holds the metadata fields of the response.
+	Metadata map[string]string `json:"-"`
+	// Raw is the body of the response as received, including the password, with the data field left empty when the
+	// data was streamed.
+	Raw string `json:"-"`
 }
 
 // GetCertificateResponse represents the response received when getting certificate data.
@@ -42,4 +77,19 @@ type GetCertificateResponse struct {
 	ValidTo                string `json:"validTo"`
 	ValidFrom              string `json:"validFrom"`
 	SignatureHashAlgorithm string `json:"signatureHashAlgorithm"`
+	// Status is the status of the certificate at the CA, one of the CAStatus values, if the Cert API reports it.
+	Status string `json:"status,omitempty"`
+	// Metadata holds the metadata fields of the response.
+	Metadata map[string]string `json:"-"`
+	// Raw is the body of the response as received.
+	Raw string `json:"-"`
 }
+
+// Statuses of a certificate at the CA, reported in the status field of the get response. They are matched
+// case-insensitively.
+const (
+	CAStatusIssued  = "issued"
+	CAStatusPending = "pending"
+	CAStatusRevoked = "revoked"
+	CAStatusExpired = "expired"
+)
@@ -2,17 +2,40 @@ package http
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	jsonutil "github.com/dana-team/certificate-operator/internal/jsonutil"
+	"github.com/dana-team/certificate-operator/internal/tracing"
 
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultMaxResponseSize is the default maximum size, in bytes, of a response body.
+	DefaultMaxResponseSize int64 = 10 << 20
+	// DefaultMinTLSVersion is the default minimum TLS version of the connections.
+	DefaultMinTLSVersion uint16 = tls.VersionTLS12
+
+	contentEncodingHeaderKey = "Content-Encoding"
+	gzipEncoding             = "gzip"
+	retryAfterHeaderKey      = "Retry-After"
+	locationHeaderKey        = "Location"
+
+	errResponseTooLarge    = "response body exceeds the maximum size of %d bytes"
+	errRedirectNotFollowed = "request was redirected to %q, which is not followed: %w"
 )
 
 // Client is the interface to interact with HTTP
@@ -21,7 +44,26 @@ type Client interface {
 }
 
 type client struct {
-	log logr.Logger
+	log             logr.Logger
+	maxResponseSize int64
+	followRedirects bool
+	minTLSVersion   uint16
+	disableHTTP2    bool
+	renegotiation   tls.RenegotiationSupport
+	roundTripper    http.RoundTripper
+}
+
+// StreamClient is implemented by Clients which can return the body of a response as a reader, so that large bodies
+// are processed as they are received instead of being read whole.
+type StreamClient interface {
+	SendRequestStream(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (resp StreamResponse, err error)
+}
+
+// StreamResponse represents an HTTP response whose body is read as it is received. The body must be closed.
+type StreamResponse struct {
+	Body       io.ReadCloser
+	Headers    map[string][]string
+	StatusCode int
 }
 
 // Response represents an HTTP response.
@@ -31,6 +73,105 @@ type Response struct {
 	StatusCode int
 }
 
+// APIError is the error returned for responses with a status code other than 200.
+// Its message is the status text of the status code, and not the response body, which may be localized.
+type APIError struct {
+	StatusCode int
+	// RetryAfter is the delay requested by the Retry-After header of 429 and 503 responses, if any.
+	RetryAfter time.Duration
+}
+
+// Error returns the status text of the status code.
+func (e *APIError) Error() string {
+	return http.StatusText(e.StatusCode)
+}
+
+// StatusCode returns the status code of the APIError wrapped by err, if any.
+func StatusCode(err error) (int, bool) {
+	var apiError *APIError
+	if !errors.As(err, &apiError) {
+		return 0, false
+	}
+
+	return apiError.StatusCode, true
+}
+
+// RetryAfter returns the delay requested by the Retry-After header of the response of the APIError wrapped by err, if any.
+func RetryAfter(err error) (time.Duration, bool) {
+	var apiError *APIError
+	if !errors.As(err, &apiError) || apiError.RetryAfter <= 0 {
+		return 0, false
+	}
+
+	return apiError.RetryAfter, true
+}
+
+// parseRetryAfter parses the value of a Retry-After header, either a number of seconds or an HTTP date, into the
+// delay from now. It returns false if the value is invalid or is not in the future.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds <= 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	date, err := http.ParseTime(value)
+	if err != nil || !date.After(now) {
+		return 0, false
+	}
+
+	return date.Sub(now), true
+}
+
+// TransportError is the error returned when a request could not be sent to the server, or its response could not be
+// received, e.g. because the connection failed or timed out. It is not returned when the context of the request is done.
+type TransportError struct {
+	URL string
+	Err error
+}
+
+// Error returns the URL of the request and the error of the transport.
+func (e *TransportError) Error() string {
+	return fmt.Sprintf("http request to %q failed: %v", e.URL, e.Err)
+}
+
+// Unwrap returns the error of the transport.
+func (e *TransportError) Unwrap() error {
+	return e.Err
+}
+
+// IsTransportError checks if err wraps a TransportError.
+func IsTransportError(err error) bool {
+	var transportError *TransportError
+	return errors.As(err, &transportError)
+}
+
+// DNSError returns the DNS error wrapped by err, if any, which means that the host of the request could not be resolved.
+func DNSError(err error) (*net.DNSError, bool) {
+	var dnsError *net.DNSError
+	if !errors.As(err, &dnsError) {
+		return nil, false
+	}
+
+	return dnsError, true
+}
+
+// IsConnectError checks if err means that no connection could be established with the server, because its host could
+// not be resolved or dialing it or the proxy failed, so that the server received none of the request.
+func IsConnectError(err error) bool {
+	if _, ok := DNSError(err); ok {
+		return true
+	}
+
+	var opError *net.OpError
+	return errors.As(err, &opError) && (opError.Op == "dial" || opError.Op == "proxyconnect")
+}
+
 // Request represents an HTTP request.
 type Request struct {
 	Method  string              `json:"method"`
@@ -40,62 +181,314 @@ type Request struct {
 }
 
 // SendRequest sends an HTTP request and returns the response.
-func (c *client) SendRequest(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (Response, error) {
-	requestBody := []byte(body)
-	request, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBuffer(requestBody))
+// Redirects are followed unless disabled with WithFollowRedirects. Requests are sent with the round tripper set with
+// WithRoundTripper, if any, or with the transport returned by newTransport, whose connections use at least the
+// minimum TLS version.
+// The request waits until it does not exceed the maximum number of in-flight requests, if set.
+// The request is traced in a span with its method and the status code of the response. The trace context of the span
+// is propagated to the Cert API in the headers of the request.
+func (c *client) SendRequest(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (resp Response, err error) {
+	ctx, span := tracing.Start(ctx, "SendRequest", tracing.String(tracing.AttributeMethod, method))
+	defer tracing.End(span, &err)
 
+	response, closeResponse, err := c.send(ctx, span, method, url, body, headers, skipTLSVerify, timeout)
 	if err != nil {
 		return Response{}, err
 	}
+	defer closeResponse()
+
+	responseBody, err := readResponseBody(response, c.maxResponseSize)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed reading response body: %v", err)
+	}
+
+	return Response{
+		Body:       string(responseBody),
+		Headers:    response.Header,
+		StatusCode: response.StatusCode,
+	}, nil
+}
+
+// SendRequestStream sends an HTTP request like SendRequest, and returns the response with its body as a reader, so
+// that it is processed as it is received instead of being read whole. The body is decompressed if it is gzip-encoded,
+// and fails with an error once it exceeds the maximum response size. The body must be closed, which releases the
+// in-flight request.
+func (c *client) SendRequestStream(ctx context.Context, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (resp StreamResponse, err error) {
+	ctx, span := tracing.Start(ctx, "SendRequest", tracing.String(tracing.AttributeMethod, method))
+	defer tracing.End(span, &err)
+
+	response, closeResponse, err := c.send(ctx, span, method, url, body, headers, skipTLSVerify, timeout)
+	if err != nil {
+		return StreamResponse{}, err
+	}
+
+	reader, closeReader, err := responseBodyReader(response, c.maxResponseSize)
+	if err != nil {
+		closeResponse()
+		return StreamResponse{}, fmt.Errorf("failed reading response body: %v", err)
+	}
+
+	return StreamResponse{
+		Body: &responseStream{Reader: reader, close: func() {
+			closeReader()
+			closeResponse()
+		}},
+		Headers:    response.Header,
+		StatusCode: response.StatusCode,
+	}, nil
+}
+
+// send sends an HTTP request and returns the response, if its status code is 200, with the function closing it.
+// Responses with another status code are closed, and fail with an APIError. The request holds an in-flight request
+// until the response is closed.
+func (c *client) send(ctx context.Context, span trace.Span, method string, url string, body string, headers map[string][]string, skipTLSVerify bool, timeout time.Duration) (*http.Response, func(), error) {
+	release, err := acquireInFlightRequest(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, url, bytes.NewBufferString(body))
+	if err != nil {
+		release()
+		return nil, nil, err
+	}
 
 	for key, values := range headers {
 		for _, value := range values {
 			request.Header.Add(key, value)
 		}
 	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(request.Header))
 
 	hclient := &http.Client{
-		Transport: &http.Transport{
-			// #nosec G402
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: skipTLSVerify},
-		},
-		Timeout: timeout,
+		Transport: c.transport(skipTLSVerify),
+		Timeout:   timeout,
+	}
+	if !c.followRedirects {
+		hclient.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
 	}
 
 	response, err := hclient.Do(request)
 	c.log.Info(fmt.Sprint("http request sent: ", jsonutil.ToJSON(Request{URL: url, Body: body, Method: method})))
 
-	if err != nil {
-		return Response{}, fmt.Errorf("http request to %q failed: %v", url, err)
+	closeResponse := func() {
+		if response != nil {
+			response.Body.Close()
+		}
+		if c.roundTripper == nil {
+			// The transport is built for this request only, so its idle connections would never be reused.
+			hclient.CloseIdleConnections()
+		}
+		release()
 	}
 
-	responseBody, err := io.ReadAll(response.Body)
 	if err != nil {
-		return Response{}, fmt.Errorf("failed reading response body: %v", err)
+		closeResponse()
+		if ctx.Err() != nil {
+			return nil, nil, fmt.Errorf("http request to %q failed: %w", url, err)
+		}
+		return nil, nil, &TransportError{URL: url, Err: err}
 	}
+	span.SetAttributes(tracing.Int(tracing.AttributeStatusCode, response.StatusCode))
 
 	if response.StatusCode != http.StatusOK {
+		defer closeResponse()
+
+		responseBody, err := readResponseBody(response, c.maxResponseSize)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed reading response body: %v", err)
+		}
+
 		c.log.Info(fmt.Sprintf("request failed, method: %v, status code: %v, body: %v", method, response.StatusCode, responseBody))
-		return Response{}, errors.New(http.StatusText(response.StatusCode))
+		apiError := &APIError{StatusCode: response.StatusCode}
+		if response.StatusCode == http.StatusTooManyRequests || response.StatusCode == http.StatusServiceUnavailable {
+			apiError.RetryAfter, _ = parseRetryAfter(response.Header.Get(retryAfterHeaderKey), time.Now())
+		}
+		if isRedirect(response.StatusCode) {
+			return nil, nil, fmt.Errorf(errRedirectNotFollowed, response.Header.Get(locationHeaderKey), apiError)
+		}
+		return nil, nil, apiError
 	}
 
-	beautifiedResponse := Response{
-		Body:       string(responseBody),
-		Headers:    response.Header,
-		StatusCode: response.StatusCode,
+	return response, closeResponse, nil
+}
+
+// transport returns the round tripper set with WithRoundTripper, if any, or a new transport.
+func (c *client) transport(skipTLSVerify bool) http.RoundTripper {
+	if c.roundTripper != nil {
+		return c.roundTripper
 	}
 
-	err = response.Body.Close()
+	return c.newTransport(skipTLSVerify)
+}
+
+// newTransport returns the transport of the requests, with the TLS settings of the client.
+// HTTP/2 is attempted unless disabled with WithDisableHTTP2, in which case the transport only speaks HTTP/1.1, for
+// legacy gateways which break with HTTP/2. TLS renegotiation is refused unless enabled with WithRenegotiation.
+func (c *client) newTransport(skipTLSVerify bool) *http.Transport {
+	transport := &http.Transport{
+		// #nosec G402
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: skipTLSVerify,
+			MinVersion:         c.minTLSVersion,
+			Renegotiation:      c.renegotiation,
+		},
+		ForceAttemptHTTP2: !c.disableHTTP2,
+	}
+
+	if c.disableHTTP2 {
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	return transport
+}
+
+// isRedirect checks if the status code is a redirection, which is returned when redirects are not followed.
+func isRedirect(statusCode int) bool {
+	return statusCode >= http.StatusMultipleChoices && statusCode < http.StatusBadRequest
+}
+
+// readResponseBody reads the body of the response, decompressing it if it is gzip-encoded.
+// It returns an error if the (decompressed) body is larger than maxSize bytes.
+func readResponseBody(response *http.Response, maxSize int64) ([]byte, error) {
+	reader, closeReader, err := responseBodyReader(response, maxSize)
+	if err != nil {
+		return nil, err
+	}
+	defer closeReader()
+
+	body, err := io.ReadAll(reader)
 	if err != nil {
-		return beautifiedResponse, err
+		return nil, err
 	}
 
-	return beautifiedResponse, nil
+	return body, nil
+}
+
+// responseBodyReader returns a reader of the body of the response, decompressing it if it is gzip-encoded, with the
+// function closing the decompressor. The reader fails with an error once the (decompressed) body exceeds maxSize bytes.
+func responseBodyReader(response *http.Response, maxSize int64) (io.Reader, func(), error) {
+	var reader io.Reader = response.Body
+	closeReader := func() {}
+	if !response.Uncompressed && strings.EqualFold(response.Header.Get(contentEncodingHeaderKey), gzipEncoding) {
+		gzipReader, err := gzip.NewReader(response.Body)
+		if err != nil {
+			return nil, nil, err
+		}
+		reader = gzipReader
+		closeReader = func() { gzipReader.Close() }
+	}
+
+	return &sizeLimitedReader{reader: reader, remaining: maxSize, maxSize: maxSize}, closeReader, nil
+}
+
+// sizeLimitedReader reads from a reader until it read more than maxSize bytes, and then fails with an error.
+type sizeLimitedReader struct {
+	reader    io.Reader
+	remaining int64
+	maxSize   int64
+}
+
+// Read reads from the reader, and fails with an error if more than maxSize bytes were read.
+func (r *sizeLimitedReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		// A single byte is read past the maximum size, to tell a body of exactly the maximum size from a larger one.
+		var extra [1]byte
+		n, err := r.reader.Read(extra[:])
+		if n > 0 {
+			return 0, fmt.Errorf(errResponseTooLarge, r.maxSize)
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+
+	return n, err
+}
+
+// responseStream is the body of a StreamResponse, which closes the response when it is closed.
+type responseStream struct {
+	io.Reader
+	close func()
+}
+
+// Close closes the response.
+func (s *responseStream) Close() error {
+	s.close()
+	return nil
 }
 
 // NewClient returns a new Http Client
-func NewClient(log logr.Logger) Client {
-	return &client{
-		log: log,
+func NewClient(log logr.Logger, options ...func(*client)) Client {
+	cl := &client{
+		log:             log,
+		maxResponseSize: DefaultMaxResponseSize,
+		followRedirects: true,
+		minTLSVersion:   DefaultMinTLSVersion,
+	}
+	for _, o := range options {
+		o(cl)
+	}
+
+	return cl
+}
+
+// WithMaxResponseSize returns a client with the Max Response Size field populated.
+// The default maximum response size is kept if maxResponseSize is not positive.
+func WithMaxResponseSize(maxResponseSize int64) func(*client) {
+	return func(c *client) {
+		if maxResponseSize > 0 {
+			c.maxResponseSize = maxResponseSize
+		}
+	}
+}
+
+// WithFollowRedirects returns a client with the Follow Redirects field populated.
+// Redirects are followed by default. When they are not, redirect responses fail with an error holding their location,
+// instead of, for example, a login page the Cert API redirects unauthenticated requests to being parsed.
+func WithFollowRedirects(followRedirects bool) func(*client) {
+	return func(c *client) {
+		c.followRedirects = followRedirects
+	}
+}
+
+// WithMinTLSVersion returns a client with the Min TLS Version field populated, e.g. tls.VersionTLS13.
+// The DefaultMinTLSVersion is kept if minTLSVersion is zero.
+func WithMinTLSVersion(minTLSVersion uint16) func(*client) {
+	return func(c *client) {
+		if minTLSVersion != 0 {
+			c.minTLSVersion = minTLSVersion
+		}
+	}
+}
+
+// WithDisableHTTP2 returns a client with the Disable HTTP2 field populated.
+// HTTP/2 is attempted by default. When it is disabled, requests are sent over HTTP/1.1 only.
+func WithDisableHTTP2(disableHTTP2 bool) func(*client) {
+	return func(c *client) {
+		c.disableHTTP2 = disableHTTP2
+	}
+}
+
+// WithRenegotiation returns a client with the Renegotiation field populated, e.g. tls.RenegotiateOnceAsClient, for
+// servers requesting client certificates after the handshake. Renegotiation is refused by default.
+func WithRenegotiation(renegotiation tls.RenegotiationSupport) func(*client) {
+	return func(c *client) {
+		c.renegotiation = renegotiation
+	}
+}
+
+// WithRoundTripper returns a client with the Round Tripper field populated, which sends the requests instead of the
+// transport built by the client, e.g. to fake the responses of a Cert API in tests. The TLS settings of the client,
+// including skipTLSVerify, do not apply to it.
+func WithRoundTripper(roundTripper http.RoundTripper) func(*client) {
+	return func(c *client) {
+		c.roundTripper = roundTripper
 	}
 }
@@ -0,0 +1,927 @@
+package http
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/internal/tracing"
+	"github.com/dana-team/certificate-operator/internal/tracing/tracingtest"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+const responseBody = `{"validTo": "2025-01-01T00:00:00"}`
+
+func gzipBody(t *testing.T, body string) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(body)); err != nil {
+		t.Fatalf("Write(...): unexpected error: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close(...): unexpected error: %v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func Test_readResponseBody(t *testing.T) {
+	type args struct {
+		body    []byte
+		headers http.Header
+	}
+	type want struct {
+		body string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldReadPlainBody": {
+			args: args{
+				body:    []byte(responseBody),
+				headers: http.Header{},
+			},
+			want: want{
+				body: responseBody,
+			},
+		},
+		"ShouldDecompressGzipBody": {
+			args: args{
+				body:    gzipBody(t, responseBody),
+				headers: http.Header{contentEncodingHeaderKey: {gzipEncoding}},
+			},
+			want: want{
+				body: responseBody,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			response := &http.Response{
+				Header: tc.args.headers,
+				Body:   io.NopCloser(bytes.NewReader(tc.args.body)),
+			}
+
+			got, err := readResponseBody(response, DefaultMaxResponseSize)
+			if err != nil {
+				t.Fatalf("readResponseBody(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want.body, string(got)); diff != "" {
+				t.Fatalf("readResponseBody(...): -want body, +got body: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_readResponseBodySizeLimit(t *testing.T) {
+	const maxSize = 16
+
+	type args struct {
+		body    []byte
+		headers http.Header
+	}
+	type want struct {
+		body string
+		err  error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldReadBodyAtLimit": {
+			args: args{
+				body:    bytes.Repeat([]byte("a"), maxSize),
+				headers: http.Header{},
+			},
+			want: want{
+				body: strings.Repeat("a", maxSize),
+			},
+		},
+		"ShouldFailWithBodyOverLimit": {
+			args: args{
+				body:    bytes.Repeat([]byte("a"), maxSize+1),
+				headers: http.Header{},
+			},
+			want: want{
+				err: fmt.Errorf(errResponseTooLarge, maxSize),
+			},
+		},
+		"ShouldFailWithDecompressedBodyOverLimit": {
+			args: args{
+				body:    gzipBody(t, strings.Repeat("a", maxSize+1)),
+				headers: http.Header{contentEncodingHeaderKey: {gzipEncoding}},
+			},
+			want: want{
+				err: fmt.Errorf(errResponseTooLarge, maxSize),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			response := &http.Response{
+				Header: tc.args.headers,
+				Body:   io.NopCloser(bytes.NewReader(tc.args.body)),
+			}
+
+			got, err := readResponseBody(response, maxSize)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("readResponseBody(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.body, string(got)); diff != "" {
+				t.Fatalf("readResponseBody(...): -want body, +got body: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_SendRequestGzipResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(contentEncodingHeaderKey, gzipEncoding)
+		_, _ = w.Write(gzipBody(t, responseBody))
+	}))
+	defer server.Close()
+
+	headers := map[string][]string{"Accept-Encoding": {gzipEncoding}}
+	response, err := NewClient(logr.Logger{}).SendRequest(context.Background(), http.MethodGet, server.URL, "", headers, false, time.Minute)
+	if err != nil {
+		t.Fatalf("SendRequest(...): unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff(responseBody, response.Body); diff != "" {
+		t.Fatalf("SendRequest(...): -want body, +got body: %v", diff)
+	}
+}
+
+func Test_SendRequestErrorStatusCode(t *testing.T) {
+	type args struct {
+		statusCode int
+		body       string
+	}
+	type want struct {
+		statusCode int
+		err        error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldReturnNotFoundWithEnglishBody": {
+			args: args{
+				statusCode: http.StatusNotFound,
+				body:       "certificate not found",
+			},
+			want: want{
+				statusCode: http.StatusNotFound,
+				err:        &APIError{StatusCode: http.StatusNotFound},
+			},
+		},
+		"ShouldReturnNotFoundWithLocalizedBody": {
+			args: args{
+				statusCode: http.StatusNotFound,
+				body:       "Zertifikat wurde nicht gefunden",
+			},
+			want: want{
+				statusCode: http.StatusNotFound,
+				err:        &APIError{StatusCode: http.StatusNotFound},
+			},
+		},
+		"ShouldReturnServerErrorWithLocalizedBody": {
+			args: args{
+				statusCode: http.StatusInternalServerError,
+				body:       "Erreur interne du serveur",
+			},
+			want: want{
+				statusCode: http.StatusInternalServerError,
+				err:        &APIError{StatusCode: http.StatusInternalServerError},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, tc.args.body, tc.args.statusCode)
+			}))
+			defer server.Close()
+
+			_, err := NewClient(logr.Logger{}).SendRequest(context.Background(), http.MethodGet, server.URL, "", nil, false, time.Minute)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("SendRequest(...): -want error, +got error: %v", diff)
+			}
+
+			statusCode, ok := StatusCode(fmt.Errorf("request failed: %w", err))
+			if !ok {
+				t.Fatalf("StatusCode(...): expected a wrapped APIError")
+			}
+			if diff := cmp.Diff(tc.want.statusCode, statusCode); diff != "" {
+				t.Fatalf("StatusCode(...): -want status code, +got status code: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_DNSError(t *testing.T) {
+	dnsError := &net.DNSError{Err: "no such host", Name: "cert.example.com", IsNotFound: true}
+
+	cases := map[string]struct {
+		err  error
+		want *net.DNSError
+	}{
+		"ShouldDetectWrappedDNSError": {
+			err:  fmt.Errorf("POST to cert failed: %w", fmt.Errorf("http request to %q failed: %w", "https://cert.example.com", dnsError)),
+			want: dnsError,
+		},
+		"ShouldNotDetectStatusCodeError": {
+			err:  &APIError{StatusCode: http.StatusBadGateway},
+			want: nil,
+		},
+		"ShouldNotDetectDNSMessageWithoutDNSError": {
+			err:  fmt.Errorf("http request to %q failed: %v", "https://cert.example.com", dnsError),
+			want: nil,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, ok := DNSError(tc.err)
+			if diff := cmp.Diff(tc.want != nil, ok); diff != "" {
+				t.Fatalf("DNSError(...): -want ok, +got ok: %v", diff)
+			}
+			if got != tc.want {
+				t.Fatalf("DNSError(...): want %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func Test_IsConnectError(t *testing.T) {
+	dialError := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	readError := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+
+	cases := map[string]struct {
+		err  error
+		want bool
+	}{
+		"ShouldDetectDialError": {
+			err:  fmt.Errorf("POST to cert failed: %w", &TransportError{URL: "https://cert.example.com", Err: dialError}),
+			want: true,
+		},
+		"ShouldDetectProxyConnectError": {
+			err:  &TransportError{URL: "https://cert.example.com", Err: &net.OpError{Op: "proxyconnect", Net: "tcp", Err: errors.New("connection refused")}},
+			want: true,
+		},
+		"ShouldDetectDNSError": {
+			err:  &TransportError{URL: "https://cert.example.com", Err: &net.DNSError{Err: "no such host", Name: "cert.example.com", IsNotFound: true}},
+			want: true,
+		},
+		"ShouldNotDetectReadError": {
+			err:  &TransportError{URL: "https://cert.example.com", Err: readError},
+			want: false,
+		},
+		"ShouldNotDetectStatusCodeError": {
+			err:  &APIError{StatusCode: http.StatusServiceUnavailable},
+			want: false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, IsConnectError(tc.err)); diff != "" {
+				t.Fatalf("IsConnectError(...): -want connect error, +got connect error: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_parseRetryAfter(t *testing.T) {
+	now := time.Date(2024, time.May, 1, 12, 0, 0, 0, time.UTC)
+
+	type want struct {
+		retryAfter time.Duration
+		ok         bool
+	}
+	cases := map[string]struct {
+		value string
+		want  want
+	}{
+		"ShouldParseSeconds": {
+			value: "120",
+			want:  want{retryAfter: 2 * time.Minute, ok: true},
+		},
+		"ShouldParseHTTPDate": {
+			value: now.Add(90 * time.Second).Format(http.TimeFormat),
+			want:  want{retryAfter: 90 * time.Second, ok: true},
+		},
+		"ShouldIgnorePastHTTPDate": {
+			value: now.Add(-time.Minute).Format(http.TimeFormat),
+			want:  want{retryAfter: 0, ok: false},
+		},
+		"ShouldIgnoreNonPositiveSeconds": {
+			value: "0",
+			want:  want{retryAfter: 0, ok: false},
+		},
+		"ShouldIgnoreInvalidValue": {
+			value: "soon",
+			want:  want{retryAfter: 0, ok: false},
+		},
+		"ShouldIgnoreMissingValue": {
+			value: "",
+			want:  want{retryAfter: 0, ok: false},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			retryAfter, ok := parseRetryAfter(tc.value, now)
+			if diff := cmp.Diff(tc.want, want{retryAfter: retryAfter, ok: ok}, cmp.AllowUnexported(want{})); diff != "" {
+				t.Fatalf("parseRetryAfter(...): -want, +got: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_SendRequestRetryAfter(t *testing.T) {
+	type args struct {
+		statusCode int
+		retryAfter string
+	}
+	type want struct {
+		retryAfter time.Duration
+		ok         bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldSurfaceRetryAfterSecondsOfTooManyRequests": {
+			args: args{
+				statusCode: http.StatusTooManyRequests,
+				retryAfter: "30",
+			},
+			want: want{retryAfter: 30 * time.Second, ok: true},
+		},
+		"ShouldSurfaceRetryAfterDateOfServiceUnavailable": {
+			args: args{
+				statusCode: http.StatusServiceUnavailable,
+				retryAfter: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat),
+			},
+			want: want{retryAfter: time.Hour, ok: true},
+		},
+		"ShouldIgnoreRetryAfterOfOtherStatusCodes": {
+			args: args{
+				statusCode: http.StatusInternalServerError,
+				retryAfter: "30",
+			},
+			want: want{retryAfter: 0, ok: false},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Retry-After", tc.args.retryAfter)
+				w.WriteHeader(tc.args.statusCode)
+			}))
+			defer server.Close()
+
+			_, err := NewClient(logr.Logger{}).SendRequest(context.Background(), http.MethodGet, server.URL, "", nil, false, time.Minute)
+
+			retryAfter, ok := RetryAfter(fmt.Errorf("request failed: %w", err))
+			if diff := cmp.Diff(tc.want.ok, ok); diff != "" {
+				t.Fatalf("RetryAfter(...): -want ok, +got ok: %v", diff)
+			}
+			// HTTP dates have a resolution of a second.
+			if diff := cmp.Diff(tc.want.retryAfter, retryAfter, cmp.Comparer(func(x, y time.Duration) bool {
+				return (x - y).Abs() <= time.Second
+			})); diff != "" {
+				t.Fatalf("RetryAfter(...): -want retry after, +got retry after: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_SendRequestRedirect(t *testing.T) {
+	type args struct {
+		followRedirects bool
+	}
+	type want struct {
+		body string
+		err  error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldFollowRedirect": {
+			args: args{
+				followRedirects: true,
+			},
+			want: want{
+				body: responseBody,
+				err:  nil,
+			},
+		},
+		"ShouldRejectRedirect": {
+			args: args{
+				followRedirects: false,
+			},
+			want: want{
+				body: "",
+				err:  fmt.Errorf(errRedirectNotFollowed, "/login", &APIError{StatusCode: http.StatusFound}),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/certificate", func(w http.ResponseWriter, r *http.Request) {
+				http.Redirect(w, r, "/login", http.StatusFound)
+			})
+			mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(responseBody))
+			})
+			server := httptest.NewServer(mux)
+			defer server.Close()
+
+			response, err := NewClient(logr.Logger{}, WithFollowRedirects(tc.args.followRedirects)).SendRequest(context.Background(), http.MethodGet, server.URL+"/certificate", "", nil, false, time.Minute)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("SendRequest(...): -want error, +got error: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.body, response.Body); diff != "" {
+				t.Fatalf("SendRequest(...): -want body, +got body: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_SendRequestMinTLSVersion(t *testing.T) {
+	type args struct {
+		minTLSVersion uint16
+	}
+	type want struct {
+		body string
+		err  bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldConnectWithDefaultMinTLSVersion": {
+			args: args{
+				minTLSVersion: 0,
+			},
+			want: want{
+				body: responseBody,
+				err:  false,
+			},
+		},
+		"ShouldConnectWithSupportedMinTLSVersion": {
+			args: args{
+				minTLSVersion: tls.VersionTLS12,
+			},
+			want: want{
+				body: responseBody,
+				err:  false,
+			},
+		},
+		"ShouldRejectServerBelowMinTLSVersion": {
+			args: args{
+				minTLSVersion: tls.VersionTLS13,
+			},
+			want: want{
+				body: "",
+				err:  true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(responseBody))
+			}))
+			server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+			server.StartTLS()
+			defer server.Close()
+
+			response, err := NewClient(logr.Logger{}, WithMinTLSVersion(tc.args.minTLSVersion)).SendRequest(context.Background(), http.MethodGet, server.URL, "", nil, true, time.Minute)
+			if (err != nil) != tc.want.err {
+				t.Fatalf("SendRequest(...): want error %v, got error %v", tc.want.err, err)
+			}
+
+			if diff := cmp.Diff(tc.want.body, response.Body); diff != "" {
+				t.Fatalf("SendRequest(...): -want body, +got body: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_WithMinTLSVersion(t *testing.T) {
+	cases := map[string]struct {
+		minTLSVersion uint16
+		want          uint16
+	}{
+		"ShouldKeepDefaultMinTLSVersion": {
+			minTLSVersion: 0,
+			want:          DefaultMinTLSVersion,
+		},
+		"ShouldSetMinTLSVersion": {
+			minTLSVersion: tls.VersionTLS13,
+			want:          tls.VersionTLS13,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := NewClient(logr.Logger{}, WithMinTLSVersion(tc.minTLSVersion)).(*client).minTLSVersion
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Fatalf("WithMinTLSVersion(...): -want version, +got version: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_newTransport(t *testing.T) {
+	type args struct {
+		options       []func(*client)
+		skipTLSVerify bool
+	}
+	type want struct {
+		forceAttemptHTTP2 bool
+		http2Disabled     bool
+		renegotiation     tls.RenegotiationSupport
+		minTLSVersion     uint16
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldAttemptHTTP2ByDefault": {
+			args: args{},
+			want: want{
+				forceAttemptHTTP2: true,
+				http2Disabled:     false,
+				renegotiation:     tls.RenegotiateNever,
+				minTLSVersion:     DefaultMinTLSVersion,
+			},
+		},
+		"ShouldDisableHTTP2": {
+			args: args{
+				options: []func(*client){WithDisableHTTP2(true)},
+			},
+			want: want{
+				forceAttemptHTTP2: false,
+				http2Disabled:     true,
+				renegotiation:     tls.RenegotiateNever,
+				minTLSVersion:     DefaultMinTLSVersion,
+			},
+		},
+		"ShouldSetRenegotiation": {
+			args: args{
+				options: []func(*client){WithRenegotiation(tls.RenegotiateOnceAsClient), WithMinTLSVersion(tls.VersionTLS13)},
+			},
+			want: want{
+				forceAttemptHTTP2: true,
+				http2Disabled:     false,
+				renegotiation:     tls.RenegotiateOnceAsClient,
+				minTLSVersion:     tls.VersionTLS13,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			transport := NewClient(logr.Logger{}, tc.args.options...).(*client).newTransport(tc.args.skipTLSVerify)
+
+			if diff := cmp.Diff(tc.want.forceAttemptHTTP2, transport.ForceAttemptHTTP2); diff != "" {
+				t.Fatalf("newTransport(...): -want ForceAttemptHTTP2, +got ForceAttemptHTTP2: %v", diff)
+			}
+
+			http2Disabled := transport.TLSNextProto != nil && len(transport.TLSNextProto) == 0
+			if diff := cmp.Diff(tc.want.http2Disabled, http2Disabled); diff != "" {
+				t.Fatalf("newTransport(...): -want HTTP/2 disabled, +got HTTP/2 disabled: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.renegotiation, transport.TLSClientConfig.Renegotiation); diff != "" {
+				t.Fatalf("newTransport(...): -want renegotiation, +got renegotiation: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.minTLSVersion, transport.TLSClientConfig.MinVersion); diff != "" {
+				t.Fatalf("newTransport(...): -want min TLS version, +got min TLS version: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_SendRequestHTTP2(t *testing.T) {
+	type args struct {
+		disableHTTP2 bool
+	}
+	type want struct {
+		proto string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldUseHTTP2ByDefault": {
+			args: args{
+				disableHTTP2: false,
+			},
+			want: want{
+				proto: "HTTP/2.0",
+			},
+		},
+		"ShouldUseHTTP11WhenHTTP2IsDisabled": {
+			args: args{
+				disableHTTP2: true,
+			},
+			want: want{
+				proto: "HTTP/1.1",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(r.Proto))
+			}))
+			server.EnableHTTP2 = true
+			server.StartTLS()
+			defer server.Close()
+
+			response, err := NewClient(logr.Logger{}, WithDisableHTTP2(tc.args.disableHTTP2)).SendRequest(context.Background(), http.MethodGet, server.URL, "", nil, true, time.Minute)
+			if err != nil {
+				t.Fatalf("SendRequest(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.proto, response.Body); diff != "" {
+				t.Fatalf("SendRequest(...): -want protocol, +got protocol: %v", diff)
+			}
+		})
+	}
+}
+
+// roundTripperFunc is a RoundTripper sending requests with the function.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(request *http.Request) (*http.Response, error) {
+	return f(request)
+}
+
+// fakeResponse returns a response to the request with the status code, headers and body.
+func fakeResponse(request *http.Request, statusCode int, headers map[string]string, body []byte) *http.Response {
+	header := http.Header{}
+	for key, value := range headers {
+		header.Set(key, value)
+	}
+
+	return &http.Response{
+		StatusCode: statusCode,
+		Status:     http.StatusText(statusCode),
+		Header:     header,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    request,
+	}
+}
+
+func Test_SendRequestRoundTripper(t *testing.T) {
+	errBoom := errors.New("boom")
+
+	type args struct {
+		followRedirects bool
+		roundTrip       func(t *testing.T, request *http.Request) (*http.Response, error)
+	}
+	type want struct {
+		body string
+		err  error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldReturnOKResponse": {
+			args: args{
+				roundTrip: func(t *testing.T, request *http.Request) (*http.Response, error) {
+					if diff := cmp.Diff([]string{"Bearer token"}, request.Header.Values("Authorization")); diff != "" {
+						t.Errorf("RoundTrip(...): -want authorization, +got authorization: %v", diff)
+					}
+					return fakeResponse(request, http.StatusOK, nil, []byte(responseBody)), nil
+				},
+			},
+			want: want{
+				body: responseBody,
+				err:  nil,
+			},
+		},
+		"ShouldReturnGzipResponse": {
+			args: args{
+				roundTrip: func(t *testing.T, request *http.Request) (*http.Response, error) {
+					return fakeResponse(request, http.StatusOK, map[string]string{contentEncodingHeaderKey: gzipEncoding}, gzipBody(t, responseBody)), nil
+				},
+			},
+			want: want{
+				body: responseBody,
+				err:  nil,
+			},
+		},
+		"ShouldFailClientError": {
+			args: args{
+				roundTrip: func(t *testing.T, request *http.Request) (*http.Response, error) {
+					return fakeResponse(request, http.StatusNotFound, nil, []byte("certificate not found")), nil
+				},
+			},
+			want: want{
+				body: "",
+				err:  &APIError{StatusCode: http.StatusNotFound},
+			},
+		},
+		"ShouldFailServerErrorWithRetryAfter": {
+			args: args{
+				roundTrip: func(t *testing.T, request *http.Request) (*http.Response, error) {
+					return fakeResponse(request, http.StatusServiceUnavailable, map[string]string{retryAfterHeaderKey: "30"}, nil), nil
+				},
+			},
+			want: want{
+				body: "",
+				err:  &APIError{StatusCode: http.StatusServiceUnavailable, RetryAfter: 30 * time.Second},
+			},
+		},
+		"ShouldFailServerError": {
+			args: args{
+				roundTrip: func(t *testing.T, request *http.Request) (*http.Response, error) {
+					return fakeResponse(request, http.StatusInternalServerError, nil, []byte("internal error")), nil
+				},
+			},
+			want: want{
+				body: "",
+				err:  &APIError{StatusCode: http.StatusInternalServerError},
+			},
+		},
+		"ShouldFollowRedirect": {
+			args: args{
+				followRedirects: true,
+				roundTrip: func(t *testing.T, request *http.Request) (*http.Response, error) {
+					if request.URL.Path == "/certificate" {
+						return fakeResponse(request, http.StatusFound, map[string]string{locationHeaderKey: "/login"}, nil), nil
+					}
+					return fakeResponse(request, http.StatusOK, nil, []byte(responseBody)), nil
+				},
+			},
+			want: want{
+				body: responseBody,
+				err:  nil,
+			},
+		},
+		"ShouldRejectRedirect": {
+			args: args{
+				followRedirects: false,
+				roundTrip: func(t *testing.T, request *http.Request) (*http.Response, error) {
+					return fakeResponse(request, http.StatusFound, map[string]string{locationHeaderKey: "/login"}, nil), nil
+				},
+			},
+			want: want{
+				body: "",
+				err:  fmt.Errorf(errRedirectNotFollowed, "/login", &APIError{StatusCode: http.StatusFound}),
+			},
+		},
+		"ShouldFailRoundTrip": {
+			args: args{
+				roundTrip: func(t *testing.T, request *http.Request) (*http.Response, error) {
+					return nil, errBoom
+				},
+			},
+			want: want{
+				body: "",
+				err:  &TransportError{URL: "https://cert.example.com/certificate", Err: &url.Error{Op: "Get", URL: "https://cert.example.com/certificate", Err: errBoom}},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			roundTripper := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+				return tc.args.roundTrip(t, request)
+			})
+			c := NewClient(logr.Logger{}, WithRoundTripper(roundTripper), WithFollowRedirects(tc.args.followRedirects))
+
+			headers := map[string][]string{"Authorization": {"Bearer token"}}
+			response, err := c.SendRequest(context.Background(), http.MethodGet, "https://cert.example.com/certificate", "", headers, false, time.Minute)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("SendRequest(...): -want error, +got error: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.body, response.Body); diff != "" {
+				t.Fatalf("SendRequest(...): -want body, +got body: %v", diff)
+			}
+		})
+	}
+}
+
+// closeRecorder is a response body which records whether it was closed.
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+// Close records that the body was closed.
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func Test_SendRequestClosesBody(t *testing.T) {
+	type args struct {
+		statusCode int
+		body       string
+	}
+	cases := map[string]struct {
+		args args
+	}{
+		"ShouldCloseBodyOfOKResponse": {
+			args: args{
+				statusCode: http.StatusOK,
+				body:       responseBody,
+			},
+		},
+		"ShouldCloseBodyOfErrorResponse": {
+			args: args{
+				statusCode: http.StatusInternalServerError,
+				body:       "internal error",
+			},
+		},
+		"ShouldCloseBodyOfTooLargeResponse": {
+			args: args{
+				statusCode: http.StatusOK,
+				body:       strings.Repeat("a", 32),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			body := &closeRecorder{Reader: strings.NewReader(tc.args.body)}
+			roundTripper := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+				response := fakeResponse(request, tc.args.statusCode, nil, nil)
+				response.Body = body
+				return response, nil
+			})
+			c := NewClient(logr.Logger{}, WithRoundTripper(roundTripper), WithMaxResponseSize(int64(len(responseBody))))
+
+			_, _ = c.SendRequest(context.Background(), http.MethodGet, "https://cert.example.com/certificate", "", nil, false, time.Minute)
+			if !body.closed {
+				t.Fatalf("SendRequest(...): response body was not closed")
+			}
+		})
+	}
+}
+
+func Test_SendRequestCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	roundTripper := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		cancel()
+		return nil, request.Context().Err()
+	})
+	c := NewClient(logr.Logger{}, WithRoundTripper(roundTripper))
+
+	_, err := c.SendRequest(ctx, http.MethodGet, "https://cert.example.com/certificate", "", nil, false, time.Minute)
+	if err == nil {
+		t.Fatalf("SendRequest(...): expected an error")
+	}
+	if IsTransportError(err) {
+		t.Fatalf("SendRequest(...): the request of a cancelled context is not a transport error: %v", err)
+	}
+}
+
+func Test_SendRequestPropagatesTraceContext(t *testing.T) {
+	_, reset := tracingtest.NewRecorder()
+	defer reset()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator())
+
+	var traceparent string
+	roundTripper := roundTripperFunc(func(request *http.Request) (*http.Response, error) {
+		traceparent = request.Header.Get("traceparent")
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(responseBody))}, nil
+	})
+	c := NewClient(logr.Logger{}, WithRoundTripper(roundTripper))
+
+	ctx, span := tracing.Start(context.Background(), "Reconcile")
+	defer span.End()
+	if _, err := c.SendRequest(ctx, http.MethodGet, "https://cert.example.com/certificate", "", nil, false, time.Minute); err != nil {
+		t.Fatalf("SendRequest(...): unexpected error: %v", err)
+	}
+
+	if !strings.Contains(traceparent, span.SpanContext().TraceID().String()) {
+		t.Fatalf("SendRequest(...): expected the traceparent header to hold the trace %s, got %q", span.SpanContext().TraceID(), traceparent)
+	}
+}
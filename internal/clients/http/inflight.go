@@ -0,0 +1,41 @@
+package http
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/semaphore"
+)
+
+const errAcquireInFlightRequest = "failed waiting for a request to the Cert API to complete: %w"
+
+// inFlightRequests limits the number of requests sent concurrently by all clients.
+// Requests are not limited if it is nil.
+var inFlightRequests *semaphore.Weighted
+
+// SetMaxInFlightRequests limits the number of requests sent concurrently by all clients, regardless of the number of
+// concurrent reconciles, to protect the Cert API from bursts. Requests are not limited if maxInFlightRequests is not
+// positive. It must be called before any request is sent.
+func SetMaxInFlightRequests(maxInFlightRequests int64) {
+	if maxInFlightRequests <= 0 {
+		inFlightRequests = nil
+		return
+	}
+
+	inFlightRequests = semaphore.NewWeighted(maxInFlightRequests)
+}
+
+// acquireInFlightRequest waits until a request may be sent without exceeding the maximum number of in-flight requests,
+// or until the context is done, and returns the function releasing the request.
+func acquireInFlightRequest(ctx context.Context) (func(), error) {
+	limit := inFlightRequests
+	if limit == nil {
+		return func() {}, nil
+	}
+
+	if err := limit.Acquire(ctx, 1); err != nil {
+		return nil, fmt.Errorf(errAcquireInFlightRequest, err)
+	}
+
+	return func() { limit.Release(1) }, nil
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+func Test_SendRequestMaxInFlightRequests(t *testing.T) {
+	const maxInFlightRequests = 2
+
+	var inFlight, maxInFlight int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			observed := atomic.LoadInt32(&maxInFlight)
+			if current <= observed || atomic.CompareAndSwapInt32(&maxInFlight, observed, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte(responseBody))
+	}))
+	defer server.Close()
+
+	SetMaxInFlightRequests(maxInFlightRequests)
+	defer SetMaxInFlightRequests(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := NewClient(logr.Logger{})
+			if _, err := c.SendRequest(context.Background(), http.MethodGet, server.URL, "", nil, false, 5*time.Second); err != nil {
+				t.Errorf("SendRequest(...): unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxInFlight > maxInFlightRequests {
+		t.Fatalf("SendRequest(...): want at most %d in-flight requests, got %d", maxInFlightRequests, maxInFlight)
+	}
+}
+
+func Test_SendRequestMaxInFlightRequestsContextCanceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(responseBody))
+	}))
+	defer server.Close()
+
+	SetMaxInFlightRequests(1)
+	defer SetMaxInFlightRequests(0)
+
+	release, err := acquireInFlightRequest(context.Background())
+	if err != nil {
+		t.Fatalf("acquireInFlightRequest(...): unexpected error: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = NewClient(logr.Logger{}).SendRequest(ctx, http.MethodGet, server.URL, "", nil, false, 5*time.Second)
+	if diff := cmp.Diff(context.DeadlineExceeded, errors.Unwrap(err), cmpopts.EquateErrors()); diff != "" {
+		t.Fatalf("SendRequest(...): -want error, +got error: %v", diff)
+	}
+}
+
+func Test_SetMaxInFlightRequests(t *testing.T) {
+	defer SetMaxInFlightRequests(0)
+
+	SetMaxInFlightRequests(0)
+	if inFlightRequests != nil {
+		t.Fatalf("SetMaxInFlightRequests(...): want requests not to be limited")
+	}
+
+	SetMaxInFlightRequests(3)
+	if inFlightRequests == nil {
+		t.Fatalf("SetMaxInFlightRequests(...): want requests to be limited")
+	}
+}
@@ -0,0 +1,144 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// EventIssued is the type of the event sent when a certificate is issued for the first time.
+	EventIssued = "Issued"
+	// EventRenewed is the type of the event sent when a certificate is renewed.
+	EventRenewed = "Renewed"
+	// EventFailed is the type of the event sent when issuing or renewing a certificate fails.
+	EventFailed = "Failed"
+
+	defaultTimeout = 5 * time.Second
+
+	// maxDrainedResponseSize is the maximum size of the response body read to reuse the connection.
+	maxDrainedResponseSize = 64 << 10
+
+	contentTypeHeaderKey = "Content-Type"
+	contentTypeJSON      = "application/json"
+
+	errMarshalEvent        = "failed to marshal notification event: %v"
+	errSendNotification    = "failed to send notification to %q: %v"
+	errNotificationRefused = "notification endpoint responded with status %d"
+)
+
+// Notifier sends Certificate events to a notification URL.
+type Notifier interface {
+	Notify(ctx context.Context, url string, event Event) error
+}
+
+// Event is the JSON payload sent to the notification URL.
+type Event struct {
+	// Type is the type of the event, one of Issued, Renewed or Failed.
+	Type string `json:"type"`
+	// Certificate identifies the Certificate the event is about.
+	Certificate CertificateReference `json:"certificate"`
+	// CommonName is the common name of the certificate.
+	CommonName string `json:"commonName,omitempty"`
+	// Guid is the unique identifier of the certificate in the Cert API.
+	Guid string `json:"guid,omitempty"`
+	// Condition is the condition which caused a Failed event.
+	Condition *metav1.Condition `json:"condition,omitempty"`
+	// ValidFrom is the time when the certificate becomes valid.
+	ValidFrom *metav1.Time `json:"validFrom,omitempty"`
+	// ValidTo is the time when the certificate expires.
+	ValidTo *metav1.Time `json:"validTo,omitempty"`
+	// Timestamp is the time when the event occurred.
+	Timestamp metav1.Time `json:"timestamp"`
+}
+
+// CertificateReference identifies a Certificate.
+type CertificateReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// NewEvent returns an event of the given type about the Certificate. The condition is only included if it is not nil,
+// and the validity of the certificate is only included if it is known.
+func NewEvent(eventType string, certificate *v1alpha1.Certificate, condition *metav1.Condition, now time.Time) Event {
+	event := Event{
+		Type: eventType,
+		Certificate: CertificateReference{
+			Name:      certificate.Name,
+			Namespace: certificate.Namespace,
+		},
+		CommonName: certificate.Spec.CertificateData.Subject.CommonName,
+		Guid:       certificate.Status.Guid,
+		Condition:  condition,
+		Timestamp:  metav1.NewTime(now),
+	}
+
+	if !certificate.Status.ValidFrom.IsZero() {
+		event.ValidFrom = certificate.Status.ValidFrom.DeepCopy()
+	}
+	if !certificate.Status.ValidTo.IsZero() {
+		event.ValidTo = certificate.Status.ValidTo.DeepCopy()
+	}
+
+	return event
+}
+
+type notifier struct {
+	httpClient *http.Client
+	timeout    time.Duration
+}
+
+// NewNotifier returns a new Notifier. It sends the events with its own HTTP client, so that notifications neither
+// wait for the limit of in-flight requests to the Cert APIs nor have their responses logged.
+func NewNotifier(options ...func(*notifier)) Notifier {
+	n := &notifier{timeout: defaultTimeout}
+	for _, o := range options {
+		o(n)
+	}
+	if n.httpClient == nil {
+		n.httpClient = &http.Client{Timeout: n.timeout}
+	}
+
+	return n
+}
+
+// WithTimeout returns a notifier with the Timeout field populated.
+func WithTimeout(timeout time.Duration) func(*notifier) {
+	return func(n *notifier) {
+		n.timeout = timeout
+	}
+}
+
+// Notify POSTs the event as JSON to the url. Any 2xx response is accepted.
+func (n *notifier) Notify(ctx context.Context, url string, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf(errMarshalEvent, err)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf(errSendNotification, url, err)
+	}
+	request.Header.Set(contentTypeHeaderKey, contentTypeJSON)
+
+	response, err := n.httpClient.Do(request)
+	if err != nil {
+		return fmt.Errorf(errSendNotification, url, err)
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, maxDrainedResponseSize))
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf(errSendNotification, url, fmt.Errorf(errNotificationRefused, response.StatusCode))
+	}
+
+	return nil
+}
@@ -0,0 +1,171 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var now = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func Test_NewEvent(t *testing.T) {
+	validFrom := metav1.NewTime(now)
+	validTo := metav1.NewTime(now.AddDate(1, 0, 0))
+	condition := &metav1.Condition{Type: "Error", Status: metav1.ConditionTrue, Reason: "PostToCertAPIFailed", Message: "boom"}
+
+	type args struct {
+		eventType   string
+		certificate *v1alpha1.Certificate
+		condition   *metav1.Condition
+	}
+	cases := map[string]struct {
+		args args
+		want Event
+	}{
+		"ShouldIncludeValidity": {
+			args: args{
+				eventType: EventIssued,
+				certificate: &v1alpha1.Certificate{
+					ObjectMeta: metav1.ObjectMeta{Name: "certificate", Namespace: "default"},
+					Spec: v1alpha1.CertificateSpec{
+						CertificateData: v1alpha1.CertificateData{Subject: v1alpha1.Subject{CommonName: "example.com"}},
+					},
+					Status: v1alpha1.CertificateStatus{Guid: "guid", ValidFrom: validFrom, ValidTo: validTo},
+				},
+			},
+			want: Event{
+				Type:        EventIssued,
+				Certificate: CertificateReference{Name: "certificate", Namespace: "default"},
+				CommonName:  "example.com",
+				Guid:        "guid",
+				ValidFrom:   &validFrom,
+				ValidTo:     &validTo,
+				Timestamp:   metav1.NewTime(now),
+			},
+		},
+		"ShouldIncludeConditionWithoutValidity": {
+			args: args{
+				eventType: EventFailed,
+				certificate: &v1alpha1.Certificate{
+					ObjectMeta: metav1.ObjectMeta{Name: "certificate", Namespace: "default"},
+				},
+				condition: condition,
+			},
+			want: Event{
+				Type:        EventFailed,
+				Certificate: CertificateReference{Name: "certificate", Namespace: "default"},
+				Condition:   condition,
+				Timestamp:   metav1.NewTime(now),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got := NewEvent(tc.args.eventType, tc.args.certificate, tc.args.condition, now)
+			if diff := cmp.Diff(tc.want, got); diff != "" {
+				t.Errorf("NewEvent(...): -want, +got: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_Notify(t *testing.T) {
+	validTo := metav1.NewTime(now.AddDate(1, 0, 0))
+	event := Event{
+		Type:        EventRenewed,
+		Certificate: CertificateReference{Name: "certificate", Namespace: "default"},
+		CommonName:  "example.com",
+		Guid:        "guid",
+		ValidTo:     &validTo,
+		Timestamp:   metav1.NewTime(now),
+	}
+
+	type want struct {
+		payload map[string]interface{}
+		err     bool
+	}
+	cases := map[string]struct {
+		statusCode int
+		delay      time.Duration
+		want       want
+	}{
+		"ShouldPostEventPayload": {
+			statusCode: http.StatusOK,
+			want: want{
+				payload: map[string]interface{}{
+					"type":        EventRenewed,
+					"certificate": map[string]interface{}{"name": "certificate", "namespace": "default"},
+					"commonName":  "example.com",
+					"guid":        "guid",
+					"validTo":     "2025-01-01T00:00:00Z",
+					"timestamp":   "2024-01-01T00:00:00Z",
+				},
+			},
+		},
+		"ShouldAcceptNoContentResponse": {
+			statusCode: http.StatusNoContent,
+			want: want{
+				payload: map[string]interface{}{
+					"type":        EventRenewed,
+					"certificate": map[string]interface{}{"name": "certificate", "namespace": "default"},
+					"commonName":  "example.com",
+					"guid":        "guid",
+					"validTo":     "2025-01-01T00:00:00Z",
+					"timestamp":   "2024-01-01T00:00:00Z",
+				},
+			},
+		},
+		"ShouldFailAfterTimeout": {
+			statusCode: http.StatusOK,
+			delay:      500 * time.Millisecond,
+			want: want{
+				err: true,
+			},
+		},
+		"ShouldFailOnErrorResponse": {
+			statusCode: http.StatusInternalServerError,
+			want: want{
+				err: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var payload map[string]interface{}
+			var contentType string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get(contentTypeHeaderKey)
+				body, _ := io.ReadAll(r.Body)
+				if err := json.Unmarshal(body, &payload); err != nil {
+					t.Errorf("Unmarshal(...): unexpected error: %v", err)
+				}
+				time.Sleep(tc.delay)
+				w.WriteHeader(tc.statusCode)
+			}))
+			defer server.Close()
+
+			err := NewNotifier(WithTimeout(100*time.Millisecond)).Notify(context.Background(), server.URL, event)
+			if gotErr := err != nil; gotErr != tc.want.err {
+				t.Fatalf("Notify(...): want error %v, got %v", tc.want.err, err)
+			}
+			if tc.want.err {
+				return
+			}
+
+			if contentType != contentTypeJSON {
+				t.Errorf("Notify(...): want content type %q, got %q", contentTypeJSON, contentType)
+			}
+			if diff := cmp.Diff(tc.want.payload, payload); diff != "" {
+				t.Errorf("Notify(...): -want payload, +got payload: %v", diff)
+			}
+		})
+	}
+}
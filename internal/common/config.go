@@ -0,0 +1,66 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationDefaultConfig is the annotation of a namespace naming the CertificateConfig of its Certificates which
+	// do not reference one.
+	AnnotationDefaultConfig = "cert.dana.io/default-config"
+	// LabelDefaultConfig is the label which, when set to "true" on a CertificateConfig, makes it the cluster default,
+	// used by Certificates which do not reference one in namespaces without a default.
+	LabelDefaultConfig = "cert.dana.io/default"
+)
+
+const (
+	errGetNamespace           = "failed to get namespace %q: %w"
+	errListDefaultConfigs     = "failed to list the CertificateConfigs labeled %q: %w"
+	errMultipleDefaultConfigs = "CertificateConfigs %v are all labeled %q=\"true\", only one may be the cluster default"
+)
+
+// ErrNoDefaultConfig is returned when a Certificate does not reference a CertificateConfig and no default is set.
+var ErrNoDefaultConfig = errors.New("no CertificateConfig is referenced, and no default is set by the " +
+	AnnotationDefaultConfig + " annotation of the namespace or the " + LabelDefaultConfig + " label of a CertificateConfig")
+
+// ConfigName returns the name of the CertificateConfig of the Certificate: the one it references, or else the one
+// named by the AnnotationDefaultConfig annotation of its namespace, or else the single CertificateConfig labeled
+// with LabelDefaultConfig.
+func ConfigName(ctx context.Context, cl client.Reader, certificate *v1alpha1.Certificate) (string, error) {
+	if certificate.Spec.ConfigRef.Name != "" {
+		return certificate.Spec.ConfigRef.Name, nil
+	}
+
+	namespace := &corev1.Namespace{}
+	if err := cl.Get(ctx, client.ObjectKey{Name: certificate.Namespace}, namespace); err != nil {
+		return "", fmt.Errorf(errGetNamespace, certificate.Namespace, err)
+	}
+	if name := namespace.Annotations[AnnotationDefaultConfig]; name != "" {
+		return name, nil
+	}
+
+	certificateConfigList := &v1alpha1.CertificateConfigList{}
+	if err := cl.List(ctx, certificateConfigList, client.MatchingLabels{LabelDefaultConfig: "true"}); err != nil {
+		return "", fmt.Errorf(errListDefaultConfigs, LabelDefaultConfig, err)
+	}
+
+	switch len(certificateConfigList.Items) {
+	case 0:
+		return "", ErrNoDefaultConfig
+	case 1:
+		return certificateConfigList.Items[0].Name, nil
+	}
+
+	names := make([]string, 0, len(certificateConfigList.Items))
+	for _, certificateConfig := range certificateConfigList.Items {
+		names = append(names, certificateConfig.Name)
+	}
+
+	return "", fmt.Errorf(errMultipleDefaultConfigs, names, LabelDefaultConfig)
+}
@@ -0,0 +1,141 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_ConfigName(t *testing.T) {
+	type args struct {
+		configRef        string
+		namespaceDefault string
+		clusterDefaults  []string
+		namespaceGetErr  error
+		defaultsListErr  error
+	}
+	type want struct {
+		name string
+		err  error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldUseReferencedConfig": {
+			args: args{
+				configRef:        "explicit",
+				namespaceDefault: "namespace-default",
+				clusterDefaults:  []string{"cluster-default"},
+			},
+			want: want{
+				name: "explicit",
+				err:  nil,
+			},
+		},
+		"ShouldUseNamespaceDefault": {
+			args: args{
+				namespaceDefault: "namespace-default",
+				clusterDefaults:  []string{"cluster-default"},
+			},
+			want: want{
+				name: "namespace-default",
+				err:  nil,
+			},
+		},
+		"ShouldUseClusterDefault": {
+			args: args{
+				clusterDefaults: []string{"cluster-default"},
+			},
+			want: want{
+				name: "cluster-default",
+				err:  nil,
+			},
+		},
+		"ShouldFailWithoutDefault": {
+			args: args{},
+			want: want{
+				name: "",
+				err:  ErrNoDefaultConfig,
+			},
+		},
+		"ShouldFailWithMultipleClusterDefaults": {
+			args: args{
+				clusterDefaults: []string{"cluster-default-a", "cluster-default-b"},
+			},
+			want: want{
+				name: "",
+				err:  fmt.Errorf(errMultipleDefaultConfigs, []string{"cluster-default-a", "cluster-default-b"}, LabelDefaultConfig),
+			},
+		},
+		"ShouldFailGettingNamespace": {
+			args: args{
+				namespaceGetErr: errBoom,
+			},
+			want: want{
+				name: "",
+				err:  fmt.Errorf(errGetNamespace, secretNamespace, errBoom),
+			},
+		},
+		"ShouldFailListingClusterDefaults": {
+			args: args{
+				defaultsListErr: errBoom,
+			},
+			want: want{
+				name: "",
+				err:  fmt.Errorf(errListDefaultConfigs, LabelDefaultConfig, errBoom),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cl := &test.MockClient{
+				MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+					if tc.args.namespaceGetErr != nil {
+						return tc.args.namespaceGetErr
+					}
+					namespace := obj.(*corev1.Namespace)
+					namespace.Name = key.Name
+					if tc.args.namespaceDefault != "" {
+						namespace.Annotations = map[string]string{AnnotationDefaultConfig: tc.args.namespaceDefault}
+					}
+					return nil
+				},
+				MockList: func(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+					if tc.args.defaultsListErr != nil {
+						return tc.args.defaultsListErr
+					}
+					listOptions := &client.ListOptions{}
+					listOptions.ApplyOptions(opts)
+					if listOptions.LabelSelector.String() != LabelDefaultConfig+"=true" {
+						return fmt.Errorf("unexpected label selector %q", listOptions.LabelSelector.String())
+					}
+					certificateConfigList := list.(*v1alpha1.CertificateConfigList)
+					for _, configName := range tc.args.clusterDefaults {
+						certificateConfigList.Items = append(certificateConfigList.Items, v1alpha1.CertificateConfig{ObjectMeta: metav1.ObjectMeta{Name: configName}})
+					}
+					return nil
+				},
+			}
+			certificate := &v1alpha1.Certificate{
+				ObjectMeta: metav1.ObjectMeta{Name: "certificate", Namespace: secretNamespace},
+				Spec:       v1alpha1.CertificateSpec{ConfigRef: v1alpha1.ConfigReference{Name: tc.args.configRef}},
+			}
+
+			got, err := ConfigName(context.Background(), cl, certificate)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("ConfigName(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.name, got); diff != "" {
+				t.Fatalf("ConfigName(...): -want name, +got name: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const errInvalidSelector = "invalid namespace selector in the template policies of CertificateConfig %q: %v"
+
+// AllowedTemplates returns the templates allowed in the namespace by the template policies of the CertificateConfig,
+// and whether any policy applies to the namespace at all.
+func AllowedTemplates(ctx context.Context, cl client.Reader, certificateConfig *v1alpha1.CertificateConfig, namespace string) ([]string, bool, error) {
+	var allowedTemplates []string
+	var restricted bool
+	var ns *corev1.Namespace
+
+	for _, policy := range certificateConfig.Spec.TemplatePolicies {
+		matches := slices.Contains(policy.Namespaces, namespace)
+
+		if !matches && policy.NamespaceSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(policy.NamespaceSelector)
+			if err != nil {
+				return nil, false, fmt.Errorf(errInvalidSelector, certificateConfig.Name, err)
+			}
+
+			if ns == nil {
+				ns = &corev1.Namespace{}
+				if err := cl.Get(ctx, client.ObjectKey{Name: namespace}, ns); err != nil {
+					return nil, false, fmt.Errorf(errGetNamespace, namespace, err)
+				}
+			}
+
+			matches = selector.Matches(labels.Set(ns.Labels))
+		}
+
+		if matches {
+			restricted = true
+			allowedTemplates = append(allowedTemplates, policy.AllowedTemplates...)
+		}
+	}
+
+	return allowedTemplates, restricted, nil
+}
@@ -0,0 +1,117 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_AllowedTemplates(t *testing.T) {
+	const namespace = "team-a"
+	selector := &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "production"}}
+
+	type args struct {
+		policies        []v1alpha1.TemplatePolicy
+		namespaceLabels map[string]string
+		namespaceGetErr error
+	}
+	type want struct {
+		allowedTemplates []string
+		restricted       bool
+		err              error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldNotRestrictWithoutPolicies": {
+			args: args{},
+			want: want{
+				allowedTemplates: nil,
+				restricted:       false,
+			},
+		},
+		"ShouldCombineTemplatesOfMatchingPolicies": {
+			args: args{
+				policies: []v1alpha1.TemplatePolicy{
+					{Namespaces: []string{namespace}, AllowedTemplates: []string{"default"}},
+					{NamespaceSelector: selector, AllowedTemplates: []string{"web"}},
+					{Namespaces: []string{"team-b"}, AllowedTemplates: []string{"admin"}},
+				},
+				namespaceLabels: map[string]string{"tier": "production"},
+			},
+			want: want{
+				allowedTemplates: []string{"default", "web"},
+				restricted:       true,
+			},
+		},
+		"ShouldNotRestrictNamespaceMatchedByNoPolicy": {
+			args: args{
+				policies: []v1alpha1.TemplatePolicy{
+					{NamespaceSelector: selector, AllowedTemplates: []string{"web"}},
+				},
+				namespaceLabels: map[string]string{"tier": "staging"},
+			},
+			want: want{
+				allowedTemplates: nil,
+				restricted:       false,
+			},
+		},
+		"ShouldFailWithInvalidSelector": {
+			args: args{
+				policies: []v1alpha1.TemplatePolicy{
+					{NamespaceSelector: &metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{Key: "tier", Operator: "Bogus"}}}},
+				},
+			},
+			want: want{
+				err: fmt.Errorf(errInvalidSelector, "test-conf", `"Bogus" is not a valid label selector operator`),
+			},
+		},
+		"ShouldFailGettingNamespace": {
+			args: args{
+				policies: []v1alpha1.TemplatePolicy{
+					{NamespaceSelector: selector, AllowedTemplates: []string{"web"}},
+				},
+				namespaceGetErr: errBoom,
+			},
+			want: want{
+				err: fmt.Errorf(errGetNamespace, namespace, errBoom),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			cl := &test.MockClient{
+				MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+					if tc.args.namespaceGetErr != nil {
+						return tc.args.namespaceGetErr
+					}
+					obj.(*corev1.Namespace).Labels = tc.args.namespaceLabels
+					return nil
+				},
+			}
+			certificateConfig := &v1alpha1.CertificateConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-conf"},
+				Spec:       v1alpha1.CertificateConfigSpec{TemplatePolicies: tc.args.policies},
+			}
+
+			gotTemplates, gotRestricted, gotErr := AllowedTemplates(context.Background(), cl, certificateConfig, namespace)
+			if diff := cmp.Diff(tc.want.err, gotErr, test.EquateErrors()); diff != "" {
+				t.Fatalf("AllowedTemplates(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.allowedTemplates, gotTemplates); diff != "" {
+				t.Errorf("AllowedTemplates(...): -want templates, +got templates: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.restricted, gotRestricted); diff != "" {
+				t.Errorf("AllowedTemplates(...): -want restricted, +got restricted: %v", diff)
+			}
+		})
+	}
+}
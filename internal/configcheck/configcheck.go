@@ -0,0 +1,65 @@
+// Package configcheck validates that a CertificateConfig can be used to reach the Cert API, without running the manager.
+package configcheck
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+	"github.com/dana-team/certificate-operator/internal/common"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// probeGuid is the guid of the certificate requested from the Cert API to validate a CertificateConfig.
+// It is not expected to exist, so the request has no effect on the CA.
+const probeGuid = "certificate-operator-validate-config"
+
+const (
+	errGetCertificateConfig = "failed to get CertificateConfig %q: %v"
+	errGetSecret            = "failed to get secret %q in namespace %q: %v"
+	errBuildCertClient      = "failed to build Cert client: %v"
+	errUnauthorized         = "the Cert API rejected the credentials: %w"
+	errRequestFailed        = "request to the Cert API failed: %w"
+)
+
+// Validate loads the CertificateConfig with the name and its secret, builds a Cert client from them, and sends an
+// authenticated request for a certificate which does not exist to the Cert API.
+// It returns an error if the Cert API cannot be reached, or rejects the credentials. A response that the certificate
+// is not found means that the Cert API accepted the credentials.
+func Validate(ctx context.Context, kubeClient client.Client, log logr.Logger, clientBuilder cert.ClientBuilder, name string) error {
+	certificateConfig := &v1alpha1.CertificateConfig{}
+	if err := kubeClient.Get(ctx, client.ObjectKey{Name: name}, certificateConfig); err != nil {
+		return fmt.Errorf(errGetCertificateConfig, name, err)
+	}
+
+	secretRef := certificateConfig.Spec.SecretRef
+	secret, err := common.GetSecret(kubeClient, ctx, secretRef.Name, secretRef.Namespace)
+	if err != nil {
+		return fmt.Errorf(errGetSecret, secretRef.Name, secretRef.Namespace, err)
+	}
+
+	certClient, err := clientBuilder(log, certificateConfig, secret.Data)
+	if err != nil {
+		return fmt.Errorf(errBuildCertClient, err)
+	}
+
+	probe := &v1alpha1.Certificate{Status: v1alpha1.CertificateStatus{Guid: probeGuid}}
+	_, err = certClient.GetCertificate(ctx, probe)
+	if err == nil {
+		return nil
+	}
+
+	statusCode, ok := httpClient.StatusCode(err)
+	switch {
+	case ok && statusCode == http.StatusNotFound:
+		return nil
+	case ok && (statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden):
+		return fmt.Errorf(errUnauthorized, err)
+	default:
+		return fmt.Errorf(errRequestFailed, err)
+	}
+}
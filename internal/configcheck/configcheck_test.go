@@ -0,0 +1,146 @@
+package configcheck
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	"github.com/dana-team/certificate-operator/internal/clients/cert/certtest"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	configName = "certificateconfig-sample"
+	secretName = "cert-secret"
+	token      = "token"
+)
+
+var errBoom = errors.New("boom")
+
+// mockGet returns a MockGetFn serving the CertificateConfig and a secret holding the credentials,
+// or the error for the kind of object which should fail.
+func mockGet(credentials map[string][]byte, failing client.Object, err error) test.MockGetFn {
+	return func(ctx context.Context, key client.ObjectKey, obj client.Object) error {
+		switch o := obj.(type) {
+		case *v1alpha1.CertificateConfig:
+			if _, ok := failing.(*v1alpha1.CertificateConfig); ok {
+				return err
+			}
+			*o = v1alpha1.CertificateConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: configName},
+				Spec:       v1alpha1.CertificateConfigSpec{SecretRef: v1alpha1.SecretRef{Name: secretName, Namespace: "default"}},
+			}
+		case *corev1.Secret:
+			if _, ok := failing.(*corev1.Secret); ok {
+				return err
+			}
+			*o = corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: "default"}, Data: credentials}
+		}
+		return nil
+	}
+}
+
+// credentialsWithToken returns the data of a Secret holding the credentials of the fake Cert API, with another token.
+func credentialsWithToken(server *certtest.Server, token string) map[string][]byte {
+	credentials, _ := json.Marshal(map[string]string{
+		"apiEndpoint":      server.APIEndpoint(),
+		"downloadEndpoint": certtest.DownloadPath,
+		"token":            token,
+	})
+
+	return map[string][]byte{"credentials": credentials}
+}
+
+func Test_Validate(t *testing.T) {
+	server := certtest.NewServer(token, "", "")
+	defer server.Close()
+
+	failingServer := certtest.NewServer(token, "", "")
+	defer failingServer.Close()
+	failingServer.FailWith(certtest.OperationGet, http.StatusInternalServerError)
+
+	type args struct {
+		localKube client.Client
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldPassWithValidCredentials": {
+			args: args{
+				localKube: &test.MockClient{MockGet: mockGet(server.Credentials(), nil, nil)},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldFailWithRejectedCredentials": {
+			args: args{
+				localKube: &test.MockClient{MockGet: mockGet(credentialsWithToken(server, "another-token"), nil, nil)},
+			},
+			want: want{
+				err: fmt.Errorf(errUnauthorized, fmt.Errorf("GET request to Cert API failed: %w", &httpClient.APIError{StatusCode: http.StatusUnauthorized})),
+			},
+		},
+		"ShouldFailWithFailingCertAPI": {
+			args: args{
+				localKube: &test.MockClient{MockGet: mockGet(failingServer.Credentials(), nil, nil)},
+			},
+			want: want{
+				err: fmt.Errorf(errRequestFailed, fmt.Errorf("GET request to Cert API failed: %w", &httpClient.APIError{StatusCode: http.StatusInternalServerError})),
+			},
+		},
+		"ShouldFailWithMissingCertificateConfig": {
+			args: args{
+				localKube: &test.MockClient{MockGet: mockGet(nil, &v1alpha1.CertificateConfig{}, errBoom)},
+			},
+			want: want{
+				err: fmt.Errorf(errGetCertificateConfig, configName, errBoom),
+			},
+		},
+		"ShouldFailWithMissingSecret": {
+			args: args{
+				localKube: &test.MockClient{MockGet: mockGet(nil, &corev1.Secret{}, kerrors.NewNotFound(corev1.Resource("secrets"), secretName))},
+			},
+			want: want{
+				err: fmt.Errorf(errGetSecret, secretName, "default", kerrors.NewNotFound(corev1.Resource("secrets"), secretName)),
+			},
+		},
+		"ShouldFailWithInvalidCredentials": {
+			args: args{
+				localKube: &test.MockClient{MockGet: mockGet(map[string][]byte{}, nil, nil)},
+			},
+			want: want{
+				err: fmt.Errorf(errBuildCertClient, errors.New("cannot unmarshal credentials as JSON: unexpected end of JSON input")),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := Validate(context.Background(), tc.args.localKube, logr.Discard(), cert.NewClientFromCertificateConfigAndSecretData, configName)
+			if (err != nil) != (tc.want.err != nil) {
+				t.Fatalf("Validate(...): want error %v, got error %v", tc.want.err, err)
+			}
+			if err != nil {
+				if diff := cmp.Diff(tc.want.err.Error(), err.Error()); diff != "" {
+					t.Fatalf("Validate(...): -want error, +got error: %v", diff)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	errCertificatePending     = "certificate %q is pending at the CA"
+	errCertificateRevoked     = "certificate %q was revoked by the CA"
+	errCertificateExpiredAtCA = "certificate %q expired according to the CA"
+)
+
+const (
+	reasonIssuancePending        = "IssuancePending"
+	reasonCertificateExpiredAtCA = "CertificateExpiredAtCA"
+)
+
+// caStatusCondition returns the condition and error of a certificate whose status at the CA prevents it from being
+// used: a pending certificate gets the CertificatePending condition, and a revoked or expired certificate gets the
+// Revoked or ExpiredAtCA error condition. Certificates whose status is issued, unknown or not reported get no error.
+func caStatusCondition(certificate *v1alpha1.Certificate, caStatus string) (metav1.Condition, error) {
+	switch strings.ToLower(caStatus) {
+	case cert.CAStatusPending:
+		err := fmt.Errorf(errCertificatePending, certificate.Status.Guid)
+		return metav1.Condition{
+			Type:    ConditionCertificatePending,
+			Status:  metav1.ConditionTrue,
+			Reason:  reasonIssuancePending,
+			Message: err.Error(),
+		}, err
+	case cert.CAStatusRevoked:
+		err := fmt.Errorf(errCertificateRevoked, certificate.Status.Guid)
+		return errorCondition(ConditionRevoked, err), err
+	case cert.CAStatusExpired:
+		err := fmt.Errorf(errCertificateExpiredAtCA, certificate.Status.Guid)
+		return errorCondition(ConditionExpiredAtCA, err), err
+	}
+
+	return metav1.Condition{}, nil
+}
+
+// waitForPendingCertificate sets the CertificatePending condition of the Certificate, and requeues it until the CA
+// issues the certificate. No certificate is created meanwhile.
+func (r *CertificateReconciler) waitForPendingCertificate(ctx context.Context, certificate *v1alpha1.Certificate, condition metav1.Condition) (ctrl.Result, error) {
+	meta.SetStatusCondition(&certificate.Status.Conditions, condition)
+	if err := r.patchStatus(ctx, certificate); err != nil {
+		return ctrl.Result{}, fmt.Errorf(errUpdateStatus, err)
+	}
+
+	r.logger(ctx).Info("certificate is pending at the CA, waiting for it to be issued", "guid", certificate.Status.Guid)
+	return ctrl.Result{RequeueAfter: requeueAfterPendingCertificate}, nil
+}
+
+// handleRevokedCertificate sets the Revoked condition of the Certificate. A revoked certificate is a terminal error:
+// the Cert API is not requested again until the Certificate or its CertificateConfig change.
+func (r *CertificateReconciler) handleRevokedCertificate(ctx context.Context, certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig, version string, condition metav1.Condition, err error) (ctrl.Result, error) {
+	if updateErr := r.failIssuance(ctx, certificate, certificateConfig, condition); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+
+	r.terminalErrors.record(client.ObjectKeyFromObject(certificate), version)
+	r.logger(ctx).Error(err, "certificate was revoked, not retrying until the Certificate or its CertificateConfig change")
+	return ctrl.Result{RequeueAfter: r.terminalErrorRequeueAfter()}, nil
+}
+
+// expireCertificate expires the Certificate now, with the ExpiredAtCA condition, so that it is reissued although its
+// validity has not ended.
+func (r *CertificateReconciler) expireCertificate(ctx context.Context, certificate *v1alpha1.Certificate, condition metav1.Condition) error {
+	now := time.Now()
+	certificate.Status.ValidTo = metav1.NewTime(now)
+	meta.SetStatusCondition(&certificate.Status.Conditions, metav1.Condition{
+		Type:    ConditionExpired,
+		Status:  metav1.ConditionTrue,
+		Reason:  reasonCertificateExpiredAtCA,
+		Message: fmt.Sprintf("certificate expired according to the CA at %s", now.Format(time.RFC3339)),
+	})
+
+	r.logger(ctx).Info("certificate expired according to the CA, reissuing", "guid", certificate.Status.Guid)
+	return r.updateCertificateConditions(ctx, certificate, condition)
+}
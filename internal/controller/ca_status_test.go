@@ -0,0 +1,289 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_caStatusCondition(t *testing.T) {
+	type args struct {
+		caStatus string
+	}
+	type want struct {
+		condition metav1.Condition
+		err       error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldNotFailIssuedCertificate": {
+			args: args{
+				caStatus: cert.CAStatusIssued,
+			},
+			want: want{
+				condition: metav1.Condition{},
+				err:       nil,
+			},
+		},
+		"ShouldNotFailUnreportedStatus": {
+			args: args{
+				caStatus: "",
+			},
+			want: want{
+				condition: metav1.Condition{},
+				err:       nil,
+			},
+		},
+		"ShouldNotFailUnknownStatus": {
+			args: args{
+				caStatus: "suspended",
+			},
+			want: want{
+				condition: metav1.Condition{},
+				err:       nil,
+			},
+		},
+		"ShouldReportPendingCertificate": {
+			args: args{
+				caStatus: "Pending",
+			},
+			want: want{
+				condition: metav1.Condition{
+					Type:    ConditionCertificatePending,
+					Status:  metav1.ConditionTrue,
+					Reason:  reasonIssuancePending,
+					Message: fmt.Sprintf(errCertificatePending, guid),
+				},
+				err: fmt.Errorf(errCertificatePending, guid),
+			},
+		},
+		"ShouldReportRevokedCertificate": {
+			args: args{
+				caStatus: cert.CAStatusRevoked,
+			},
+			want: want{
+				condition: condition(ConditionRevoked, fmt.Errorf(errCertificateRevoked, guid)),
+				err:       fmt.Errorf(errCertificateRevoked, guid),
+			},
+		},
+		"ShouldReportExpiredCertificate": {
+			args: args{
+				caStatus: "EXPIRED",
+			},
+			want: want{
+				condition: condition(ConditionExpiredAtCA, fmt.Errorf(errCertificateExpiredAtCA, guid)),
+				err:       fmt.Errorf(errCertificateExpiredAtCA, guid),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			certificate := &v1alpha1.Certificate{Status: v1alpha1.CertificateStatus{Guid: guid}}
+
+			gotCondition, err := caStatusCondition(certificate, tc.args.caStatus)
+			if diff := cmp.Diff(tc.want.condition, gotCondition); diff != "" {
+				t.Fatalf("caStatusCondition(...): -want condition, +got condition: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("caStatusCondition(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileCAStatus(t *testing.T) {
+	validTo := time.Now().AddDate(1, 0, 0)
+
+	type args struct {
+		caStatus string
+		pending  bool
+	}
+	type want struct {
+		result     ctrl.Result
+		posted     bool
+		downloaded bool
+		pending    bool
+		reason     string
+		expired    bool
+		blocked    bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldDownloadIssuedCertificate": {
+			args: args{
+				caStatus: cert.CAStatusIssued,
+			},
+			want: want{
+				result:     ctrl.Result{},
+				posted:     true,
+				downloaded: true,
+			},
+		},
+		"ShouldRequeuePendingCertificate": {
+			args: args{
+				caStatus: cert.CAStatusPending,
+			},
+			want: want{
+				result:  ctrl.Result{RequeueAfter: requeueAfterPendingCertificate},
+				posted:  true,
+				pending: true,
+			},
+		},
+		"ShouldNotPostWhilePending": {
+			args: args{
+				caStatus: cert.CAStatusPending,
+				pending:  true,
+			},
+			want: want{
+				result:  ctrl.Result{RequeueAfter: requeueAfterPendingCertificate},
+				posted:  false,
+				pending: true,
+			},
+		},
+		"ShouldDownloadPendingCertificateOnceIssued": {
+			args: args{
+				caStatus: cert.CAStatusIssued,
+				pending:  true,
+			},
+			want: want{
+				result:     ctrl.Result{},
+				posted:     false,
+				downloaded: true,
+			},
+		},
+		"ShouldStopOnRevokedCertificate": {
+			args: args{
+				caStatus: cert.CAStatusRevoked,
+			},
+			want: want{
+				result:  ctrl.Result{RequeueAfter: DefaultTerminalErrorRequeueAfter},
+				posted:  true,
+				reason:  ConditionRevoked,
+				blocked: true,
+			},
+		},
+		"ShouldReissueExpiredCertificate": {
+			args: args{
+				caStatus: cert.CAStatusExpired,
+			},
+			want: want{
+				result:  ctrl.Result{Requeue: true},
+				posted:  true,
+				reason:  ConditionExpiredAtCA,
+				expired: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.Certificate
+			var posted, downloaded bool
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							certificate.DeepCopyInto(o)
+							o.Status.Guid = guid
+							if tc.args.pending {
+								o.Status.Conditions = []metav1.Condition{{Type: ConditionCertificatePending, Status: metav1.ConditionTrue, Reason: reasonIssuancePending}}
+							}
+							got = o
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							if key.Name != certificateConfig.Spec.SecretRef.Name {
+								return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+							}
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockUpdate:      test.NewMockUpdateFn(nil),
+					MockPatch:       test.NewMockPatchFn(nil),
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: newScheme(),
+				Log:    logr.Discard(),
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							posted = true
+							return cert.PostCertificateResponse{Guid: guid}, nil
+						},
+						MockGetCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.GetCertificateResponse, error) {
+							return cert.GetCertificateResponse{
+								ValidTo:   validTo.Format(timeFormat),
+								ValidFrom: time.Now().Format(timeFormat),
+								Status:    tc.args.caStatus,
+							}, nil
+						},
+						MockDownloadCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.DownloadCertificateResponse, error) {
+							downloaded = true
+							return cert.DownloadCertificateResponse{Data: validPKCS12Data, Password: validPKCS12Password}, nil
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			result, _ := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Fatalf("Reconcile(...): -want result, +got result: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.posted, posted); diff != "" {
+				t.Fatalf("Reconcile(...): -want posted, +got posted: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.downloaded, downloaded); diff != "" {
+				t.Fatalf("Reconcile(...): -want downloaded, +got downloaded: %v", diff)
+			}
+
+			pending := meta.IsStatusConditionTrue(got.Status.Conditions, ConditionCertificatePending)
+			if diff := cmp.Diff(tc.want.pending, pending); diff != "" {
+				t.Fatalf("Reconcile(...): -want pending, +got pending: %v", diff)
+			}
+
+			var reason string
+			if errorCondition := meta.FindStatusCondition(got.Status.Conditions, ConditionError); errorCondition != nil {
+				reason = errorCondition.Reason
+			}
+			if diff := cmp.Diff(tc.want.reason, reason); diff != "" {
+				t.Fatalf("Reconcile(...): -want Error reason, +got Error reason: %v", diff)
+			}
+
+			expired := meta.IsStatusConditionTrue(got.Status.Conditions, ConditionExpired)
+			if diff := cmp.Diff(tc.want.expired, expired); diff != "" {
+				t.Fatalf("Reconcile(...): -want expired, +got expired: %v", diff)
+			}
+			if tc.want.expired && got.Status.ValidTo.After(time.Now()) {
+				t.Fatalf("Reconcile(...): expected the certificate to be valid until now, got %v", got.Status.ValidTo)
+			}
+
+			blocked := r.terminalErrors.blocked(req.NamespacedName, terminalErrorVersion(got, &certificateConfig, &corev1.Secret{}))
+			if diff := cmp.Diff(tc.want.blocked, blocked); diff != "" {
+				t.Fatalf("Reconcile(...): -want blocked, +got blocked: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	"github.com/dana-team/certificate-operator/internal/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+)
+
+// certClientCache caches the Cert clients built for CertificateConfigs, so that credentials are not parsed on every reconcile.
+// An entry is valid as long as neither the CertificateConfig spec nor its referenced Secret change, and the
+// CertificateConfig is not recreated.
+// The zero value is an empty cache ready to use.
+type certClientCache struct {
+	mu      sync.Mutex
+	entries map[string]certClientCacheEntry
+}
+
+type certClientCacheEntry struct {
+	version string
+	client  cert.Client
+}
+
+// certClientVersion returns the version under which a Cert client built from the CertificateConfig and Secret is cached.
+func certClientVersion(certificateConfig *v1alpha1.CertificateConfig, secret *corev1.Secret) string {
+	return fmt.Sprintf("%s/%d/%s", certificateConfig.UID, certificateConfig.Generation, secret.ResourceVersion)
+}
+
+// get returns the cached client of the CertificateConfig if it was built from the given version.
+func (c *certClientCache) get(configName, version string) (cert.Client, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[configName]
+	if !ok || entry.version != version {
+		return nil, false
+	}
+
+	return entry.client, true
+}
+
+// add caches the client of the CertificateConfig built from the given version, replacing any older client.
+func (c *certClientCache) add(configName, version string, client cert.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = map[string]certClientCacheEntry{}
+	}
+	c.entries[configName] = certClientCacheEntry{version: version, client: client}
+}
+
+// evict removes the cached client of the CertificateConfig.
+func (c *certClientCache) evict(configName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, configName)
+}
+
+// certClient returns the Cert client of the CertificateConfig, building it only if no client was cached
+// for the current versions of the CertificateConfig and its Secret.
+func (r *CertificateReconciler) certClient(certificateConfig *v1alpha1.CertificateConfig, secret *corev1.Secret) (cert.Client, error) {
+	version := certClientVersion(certificateConfig, secret)
+	if certClient, ok := r.certClients.get(certificateConfig.Name, version); ok {
+		return certClient, nil
+	}
+
+	certClient, err := r.CertClientBuilder(r.Log, certificateConfig, secret.Data)
+	if err != nil {
+		r.certClients.evict(certificateConfig.Name)
+		return nil, err
+	}
+
+	r.certClients.add(certificateConfig.Name, version, certClient)
+	return certClient, nil
+}
+
+// isConfigSecretStale checks if the Secret referenced by the CertificateConfig changed or was deleted since it was
+// read, by reading it again and comparing its resourceVersion, in which case the Cert client built from it holds
+// stale credentials.
+func (r *CertificateReconciler) isConfigSecretStale(ctx context.Context, certificateConfig *v1alpha1.CertificateConfig, secret *corev1.Secret) (bool, error) {
+	secretRef := certificateConfig.Spec.SecretRef
+	current, err := common.GetSecret(r.Client, ctx, secretRef.Name, secretRef.Namespace)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	return current.ResourceVersion != secret.ResourceVersion, nil
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_certClient(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "secret", Namespace: "default", ResourceVersion: "1"}}
+
+	rotatedSecret := secret.DeepCopy()
+	rotatedSecret.ResourceVersion = "2"
+
+	updatedConfig := certificateConfig.DeepCopy()
+	updatedConfig.Generation = 2
+
+	recreatedConfig := certificateConfig.DeepCopy()
+	recreatedConfig.UID = "recreated-uid"
+
+	type args struct {
+		certificateConfig *v1alpha1.CertificateConfig
+		secret            *corev1.Secret
+		evict             bool
+	}
+	type want struct {
+		builds int
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldHitCacheWithSameVersion": {
+			args: args{
+				certificateConfig: &certificateConfig,
+				secret:            secret,
+			},
+			want: want{
+				builds: 1,
+			},
+		},
+		"ShouldMissCacheWithNewSecretVersion": {
+			args: args{
+				certificateConfig: &certificateConfig,
+				secret:            rotatedSecret,
+			},
+			want: want{
+				builds: 2,
+			},
+		},
+		"ShouldMissCacheWithNewConfigGeneration": {
+			args: args{
+				certificateConfig: updatedConfig,
+				secret:            secret,
+			},
+			want: want{
+				builds: 2,
+			},
+		},
+		"ShouldMissCacheWithRecreatedConfig": {
+			args: args{
+				certificateConfig: recreatedConfig,
+				secret:            secret,
+			},
+			want: want{
+				builds: 2,
+			},
+		},
+		"ShouldMissCacheAfterEviction": {
+			args: args{
+				certificateConfig: &certificateConfig,
+				secret:            secret,
+				evict:             true,
+			},
+			want: want{
+				builds: 2,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var builds int
+			r := &CertificateReconciler{
+				Log: logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					builds++
+					return &MockCertClient{}, nil
+				},
+			}
+
+			if _, err := r.certClient(&certificateConfig, secret); err != nil {
+				t.Fatalf("certClient(...): unexpected error: %v", err)
+			}
+
+			if tc.args.evict {
+				r.certClients.evict(certificateConfig.Name)
+			}
+
+			if _, err := r.certClient(tc.args.certificateConfig, tc.args.secret); err != nil {
+				t.Fatalf("certClient(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.builds, builds); diff != "" {
+				t.Fatalf("certClient(...): -want builds, +got builds: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_isConfigSecretStale(t *testing.T) {
+	type args struct {
+		current *corev1.Secret
+		getErr  error
+	}
+	type want struct {
+		stale bool
+		err   error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldNotBeStaleWithSameResourceVersion": {
+			args: args{
+				current: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}},
+			},
+			want: want{
+				stale: false,
+				err:   nil,
+			},
+		},
+		"ShouldBeStaleWhenSecretChanged": {
+			args: args{
+				current: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "2"}},
+			},
+			want: want{
+				stale: true,
+				err:   nil,
+			},
+		},
+		"ShouldBeStaleWhenSecretDeleted": {
+			args: args{
+				getErr: kerrors.NewNotFound(corev1.Resource("secrets"), "secret"),
+			},
+			want: want{
+				stale: true,
+				err:   nil,
+			},
+		},
+		"ShouldFailGettingSecret": {
+			args: args{
+				getErr: errBoom,
+			},
+			want: want{
+				stale: false,
+				err:   errBoom,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+						if key.Name != certificateConfig.Spec.SecretRef.Name {
+							return fmt.Errorf("unexpected secret %q", key.Name)
+						}
+						if tc.args.getErr != nil {
+							return tc.args.getErr
+						}
+						tc.args.current.DeepCopyInto(obj.(*corev1.Secret))
+						return nil
+					},
+				},
+				Log: logr.Discard(),
+			}
+
+			secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{ResourceVersion: "1"}}
+			stale, err := r.isConfigSecretStale(context.Background(), &certificateConfig, secret)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("isConfigSecretStale(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.stale, stale); diff != "" {
+				t.Fatalf("isConfigSecretStale(...): -want stale, +got stale: %v", diff)
+			}
+		})
+	}
+}
@@ -19,32 +19,52 @@ package controller
 import (
 	"context"
 	"fmt"
+	"maps"
 	"net/http"
-	"strings"
 	"time"
 
+	"github.com/dana-team/certificate-operator/internal/circuitbreaker"
 	"github.com/dana-team/certificate-operator/internal/common"
+	"github.com/dana-team/certificate-operator/internal/metrics"
+	"github.com/dana-team/certificate-operator/internal/tracing"
 
 	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+	"github.com/dana-team/certificate-operator/internal/clients/notification"
 	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
 )
 
 const (
-	errCreationFailed               = "failed to create Certificate: %v"
+	errCreationFailed               = "failed to create Certificate: %w"
 	errGetFailed                    = "failed to get Certificate: %v"
 	errFailedToSetOwnerRefForSecret = "failed to set owner reference for secret %v"
 	errUpdateStatus                 = "failed to update Certificate status: %v"
 	errFailedBuildingCertClient     = "failed to build Cert client: %v"
+	errCircuitOpen                  = "requests to the Cert API are paused for %v after repeated failures"
+	errCAEndpointUnreachable        = "cannot resolve the host %q of the Cert API"
+	errRateLimited                  = "the Cert API rate-limited the request: %v"
+	errRateLimitedRetryAfter        = "the Cert API rate-limited the request, retrying after %v: %v"
+	errUpdateFinalizers             = "failed to update the finalizers of the Certificate: %v"
+	errCertNotFoundAtCA             = "certificate %q was not found by the Cert API"
+	errWaitingForConfig             = "waiting for the CertificateConfig %q to be created"
+	errRenewalFailing               = "renewal failed %d consecutive times, the certificate expires at %s"
 )
 
 const (
@@ -54,6 +74,43 @@ const (
 	ConditionGetCertDataFromCertAPIFailed  = "GetCertDataFromCertAPIFailed"
 	ConditionUpdateStatusFailed            = "StatusUpdateFailed"
 	ConditionDecodeCertFailed              = "DecodeCertFailed"
+	ConditionExpired                       = "Expired"
+	ConditionChainMissing                  = "ChainMissing"
+	ConditionChainVerificationFailed       = "ChainVerificationFailed"
+	ConditionRenewalMisconfigured          = "RenewalMisconfigured"
+	ConditionCircuitOpen                   = "CircuitOpen"
+	ConditionPaused                        = "Paused"
+	ConditionCredentialsInvalid            = "CredentialsInvalid"
+	ConditionCAEndpointUnreachable         = "CAEndpointUnreachable"
+	ConditionRateLimited                   = "RateLimited"
+	ConditionCertificatePending            = "CertificatePending"
+	ConditionRevoked                       = "Revoked"
+	ConditionExpiredAtCA                   = "ExpiredAtCA"
+	ConditionCertNotFoundAtCA              = "CertNotFoundAtCA"
+	ConditionWaitingForConfig              = "WaitingForConfig"
+	ConditionDefaultConfigNotResolved      = "DefaultConfigNotResolved"
+	ConditionDegraded                      = "Degraded"
+)
+
+const (
+	// AnnotationPaused is the annotation which, when set to "true" on a Certificate, stops it from being reconciled.
+	AnnotationPaused = "cert.dana.io/paused"
+	// AnnotationPendingGUID is the annotation holding the guid of a created certificate until it is persisted in the status.
+	AnnotationPendingGUID = "cert.dana.io/pending-guid"
+	// AnnotationPendingGUIDGeneration is the annotation holding the generation of the Certificate for which the
+	// certificate of the pending guid was created.
+	AnnotationPendingGUIDGeneration = "cert.dana.io/pending-guid-generation"
+	// FinalizerOrphanSecrets is the finalizer set on Certificates with the Orphan DeletionPolicy, removed once the
+	// owner references of the Certificate are removed from its Secrets and ConfigMap.
+	FinalizerOrphanSecrets = "cert.dana.io/orphan-secrets"
+	// FinalizerCleanupSecrets is the finalizer set on Certificates with the Delete DeletionPolicy which do not own their
+	// Secrets and ConfigMap, removed once the Secrets and ConfigMap labeled with the name of the Certificate are deleted.
+	FinalizerCleanupSecrets = "cert.dana.io/cleanup-secrets"
+
+	reasonReconcilePaused     = "ReconcilePaused"
+	reasonCertificateNotFound = "CertificateNotFound"
+	reasonConfigNotFound      = "ConfigNotFound"
+	reasonRenewalFailing      = "RenewalFailing"
 )
 
 const (
@@ -62,155 +119,668 @@ const (
 
 const requeueAfterNotFoundError = time.Second * 5
 
+// requeueAfterPendingCertificate is the interval at which certificates which are pending at the CA are polled.
+const requeueAfterPendingCertificate = time.Second * 30
+
+const (
+	// stuckGUIDTimeout is the time since the certificate of a guid was created after which the guid is cleared if its
+	// certificate still fails to be polled or downloaded, so that a new certificate is created. It is a duration rather
+	// than a number of failures, so that a short outage of the CA does not cause a new certificate to be created.
+	stuckGUIDTimeout = time.Hour
+	// maxGUIDResets is the number of times the guid of a Certificate is cleared until a certificate is downloaded,
+	// so that a CA which never serves the certificates it creates does not cause a creation loop.
+	maxGUIDResets = 3
+)
+
 // CertificateReconciler reconciles a Certificate object
 type CertificateReconciler struct {
 	client.Client
 	Scheme            *runtime.Scheme
 	Log               logr.Logger
 	CertClientBuilder cert.ClientBuilder
+	// CircuitBreaker pauses requests to the Cert API per CertificateConfig after repeated failures.
+	// It is disabled if nil.
+	CircuitBreaker *circuitbreaker.Breaker
+	// Notifier sends events to the NotificationURL of CertificateConfigs.
+	// It is disabled if nil.
+	Notifier notification.Notifier
+	// RecordRequests specifies whether every request sent to the Cert API to create a certificate is recorded
+	// in a CertificateRequest, along with its response.
+	RecordRequests bool
+	// RequestHistoryLimit is the number of CertificateRequests recorded per Certificate which are kept, the oldest
+	// being deleted. It defaults to DefaultRequestHistoryLimit.
+	RequestHistoryLimit int
+	// TerminalErrorRequeueAfter is the interval at which Certificates failing with terminal errors are requeued.
+	// It defaults to DefaultTerminalErrorRequeueAfter.
+	TerminalErrorRequeueAfter time.Duration
+	// ReconcileTimeout is the maximum duration of the reconcile of a Certificate, after which it is abandoned and
+	// requeued. It defaults to DefaultReconcileTimeout.
+	ReconcileTimeout time.Duration
+	// DebugStoreResponses specifies whether the last response of the Cert API to a get or download request is stored
+	// in the status of Certificates, with its password redacted, to debug the support of the fields of a Cert API.
+	DebugStoreResponses bool
+	// ResyncOnStart specifies whether all Certificates are enqueued once the operator starts or becomes the leader.
+	ResyncOnStart bool
+	// DegradedAfterRenewalFailures is the number of consecutive failures to renew a certificate which is still valid
+	// after which the Certificate is marked Degraded. It defaults to DefaultDegradedAfterRenewalFailures.
+	DegradedAfterRenewalFailures int32
+	// MaxConditionMessageLength is the maximum length of the condition messages of Certificates, longer messages being
+	// truncated. It defaults to DefaultMaxConditionMessageLength, and messages are not truncated if it is negative.
+	MaxConditionMessageLength int
+	// ConditionTypePrefix is prepended to the type of the Error condition, e.g. "cert.dana.io/", so that it does not
+	// collide with the conditions of other controllers. It must be empty or a DNS subdomain followed by a slash.
+	ConditionTypePrefix string
+
+	terminalErrors terminalErrors
+
+	reconcileLocks reconcileLocks
+
+	certClients certClientCache
 }
 
 //+kubebuilder:rbac:groups=cert.dana.io,resources=certificates,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=cert.dana.io,resources=certificates/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=cert.dana.io,resources=certificates/finalizers,verbs=update
-//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update;create
+//+kubebuilder:rbac:groups=cert.dana.io,resources=certificaterequests,verbs=list;create;delete
+//+kubebuilder:rbac:groups=cert.dana.io,resources=certificaterequests/status,verbs=update
+//+kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update;create;patch;delete
+//+kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;update;create;delete
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
 
 // SetupWithManager sets up the controller with the Manager.
+// Certificates are reconciled when the secrets they own change, so that a deleted secret is recreated. Secrets are
+// watched through any owner reference, since Certificates do not set themselves as their controller.
+// Updates of Certificates only enqueue them when their spec, annotations or labels change, so that the status
+// patches of a reconcile do not enqueue the Certificate again and bypass the backoff of the workqueue. Neither do the
+// patches of the pending guid annotations, which are only written by the reconcile itself.
+// If ResyncOnStart is set, all Certificates are enqueued once the operator starts or becomes the leader.
 func (r *CertificateReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.Certificate{}).
-		Complete(r)
+	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.Certificate{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			annotationChangedPredicate(),
+			predicate.LabelChangedPredicate{},
+		))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestForOwner(mgr.GetScheme(), mgr.GetRESTMapper(), &v1alpha1.Certificate{})).
+		Watches(&v1alpha1.CertificateConfig{}, handler.EnqueueRequestsFromMapFunc(r.certificatesForRecreatedConfig)).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.certificatesUsingNamespaceDefault), builder.WithPredicates(predicate.AnnotationChangedPredicate{}))
+
+	if r.ResyncOnStart {
+		events := make(chan event.GenericEvent)
+		if err := mgr.Add(&certificateResync{client: mgr.GetClient(), cache: mgr.GetCache(), events: events, log: r.Log}); err != nil {
+			return err
+		}
+		controllerBuilder = controllerBuilder.WatchesRawSource(&source.Channel{Source: events}, &handler.EnqueueRequestForObject{})
+	}
+
+	return controllerBuilder.Complete(r)
+}
+
+// certificatesForRecreatedConfig returns reconcile requests for the Certificates referencing the given CertificateConfig
+// which were last reconciled with another CertificateConfig of the same name, so that they are refreshed when it is
+// recreated, or were never reconciled with it, so that Certificates waiting for it are reconciled once it is created.
+// Certificates are not requeued when the CertificateConfig is updated in place.
+func (r *CertificateReconciler) certificatesForRecreatedConfig(ctx context.Context, certificateConfig client.Object) []reconcile.Request {
+	certificateList := &v1alpha1.CertificateList{}
+	if err := r.Client.List(ctx, certificateList, client.MatchingFields{configNameIndexField: certificateConfig.GetName()}); err != nil {
+		r.Log.Error(err, "failed to list Certificates referencing CertificateConfig", "certificateConfig", certificateConfig.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, certificate := range certificateList.Items {
+		if certificate.Status.ConfigUID == certificateConfig.GetUID() {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&certificate)})
+	}
+
+	return requests
+}
+
+// certificatesUsingNamespaceDefault returns reconcile requests for the Certificates of the given namespace which do not
+// reference a CertificateConfig, so that they are reconciled with the CertificateConfig named by the default config
+// annotation of the namespace when it changes.
+func (r *CertificateReconciler) certificatesUsingNamespaceDefault(ctx context.Context, namespace client.Object) []reconcile.Request {
+	certificateList := &v1alpha1.CertificateList{}
+	if err := r.Client.List(ctx, certificateList, client.InNamespace(namespace.GetName())); err != nil {
+		r.Log.Error(err, "failed to list Certificates of namespace", "namespace", namespace.GetName())
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, certificate := range certificateList.Items {
+		if certificate.Spec.ConfigRef.Name != "" {
+			continue
+		}
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&certificate)})
+	}
+
+	return requests
+}
+
+// waitForConfig sets the WaitingForConfig condition of a Certificate whose CertificateConfig does not exist yet, and
+// requeues it after the delay returned by waitForConfigDelay. The Certificate is also enqueued once the
+// CertificateConfig is created, by certificatesForRecreatedConfig, if the Certificate references it.
+func (r *CertificateReconciler) waitForConfig(ctx context.Context, certificate *v1alpha1.Certificate, configName string) (ctrl.Result, error) {
+	delay := waitForConfigDelay(meta.FindStatusCondition(certificate.Status.Conditions, ConditionWaitingForConfig), time.Now())
+
+	meta.SetStatusCondition(&certificate.Status.Conditions, metav1.Condition{
+		Type:    ConditionWaitingForConfig,
+		Status:  metav1.ConditionTrue,
+		Reason:  reasonConfigNotFound,
+		Message: fmt.Sprintf(errWaitingForConfig, configName),
+	})
+	if err := r.patchStatus(ctx, certificate); err != nil {
+		return ctrl.Result{}, fmt.Errorf(errUpdateStatus, err)
+	}
+
+	r.logger(ctx).Info("CertificateConfig does not exist, waiting for it to be created", "certificateConfig", configName, "retryAfter", delay)
+	return ctrl.Result{RequeueAfter: delay}, nil
+}
+
+// waitForConfigDelay returns the delay before reconciling a Certificate waiting for its CertificateConfig again. It is
+// the time the Certificate has already waited since the WaitingForConfig condition was set, so that it roughly
+// doubles with every reconcile, from configBackoffBase up to configBackoffMax.
+func waitForConfigDelay(waitingCondition *metav1.Condition, now time.Time) time.Duration {
+	if waitingCondition == nil || waitingCondition.Status != metav1.ConditionTrue {
+		return configBackoffBase
+	}
+
+	delay := now.Sub(waitingCondition.LastTransitionTime.Time)
+	if delay < configBackoffBase {
+		return configBackoffBase
+	}
+	if delay > configBackoffMax {
+		return configBackoffMax
+	}
+
+	return delay
 }
 
 // Reconcile handles reconciliation of Certificate objects.
-func (r *CertificateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	r.Log = r.Log.WithValues("certificate", req.NamespacedName)
-	r.Log.Info("Starting Reconcile")
+// Every reconcile is traced in a span, parent to the spans of the requests sent to the Cert API.
+// Reconciles of the same Certificate are serialized, so that they do not race on the writes of its Secrets.
+// The logger of the reconcile, holding the key of the Certificate, is passed in the context rather than set on the
+// reconciler, which is shared by concurrent reconciles of different Certificates.
+// The status is patched with the fields changed by the reconcile, so that concurrent writes do not conflict with it.
+func (r *CertificateReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
+	ctx, span := tracing.Start(ctx, "Reconcile", tracing.String(tracing.AttributeCertificate, req.NamespacedName.String()))
+	defer tracing.End(span, &err)
+
+	defer r.reconcileLocks.lock(req.NamespacedName)()
+
+	ctx = logr.NewContext(ctx, r.Log.WithValues("certificate", req.NamespacedName))
+
+	ctx, cancel := context.WithTimeout(ctx, r.reconcileTimeout())
+	defer cancel()
+	defer r.abandonTimedOutReconcile(ctx, &result, &err)
+
+	r.logger(ctx).Info("Starting Reconcile")
 
 	certificate := &v1alpha1.Certificate{}
 	if err := r.Client.Get(ctx, req.NamespacedName, certificate); err != nil {
 		if errors.IsNotFound(err) {
+			metrics.SetCertificateError(req.NamespacedName.String(), false)
+			metrics.DeleteCertificateExpiry(req.Namespace, req.Name)
 			return ctrl.Result{}, nil
 		}
 		return ctrl.Result{}, fmt.Errorf(errGetFailed, err)
 	}
+	ctx = withStatusBase(ctx, certificate)
+
+	if deleting, err := r.handleDeletionPolicy(ctx, certificate); deleting || err != nil {
+		return ctrl.Result{}, err
+	}
+
+	paused, err := r.updatePausedCondition(ctx, certificate)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if paused {
+		r.logger(ctx).Info("Reconcile is paused, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.updateExpiredCondition(ctx, certificate); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if condition, err := resolveSecretName(certificate); err != nil {
+		r.logger(ctx).Error(err, "invalid secret name")
+		return ctrl.Result{}, r.updateCertificateConditions(ctx, certificate, condition)
+	}
+
+	configName, err := common.ConfigName(ctx, r.Client, certificate)
+	if err != nil {
+		if updateErr := r.updateCertificateConditions(ctx, certificate, errorCondition(ConditionDefaultConfigNotResolved, err)); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		return ctrl.Result{}, err
+	}
+	certificate.Status.ConfigName = configName
 
 	certificateConfig := &v1alpha1.CertificateConfig{}
-	if err := r.Client.Get(ctx, types.NamespacedName{Name: certificate.Spec.ConfigRef.Name}, certificateConfig); err != nil {
+	if err := r.Client.Get(ctx, types.NamespacedName{Name: configName}, certificateConfig); err != nil {
+		r.certClients.evict(configName)
+		if errors.IsNotFound(err) {
+			return r.waitForConfig(ctx, certificate, configName)
+		}
 		err = r.updateCertificateConditions(ctx, certificate, errorCondition("ConfigRetrievalFailed", err))
 		if err != nil {
 			return ctrl.Result{}, fmt.Errorf(errCreationFailed, err)
 		}
 		return ctrl.Result{}, fmt.Errorf(errCreationFailed, err)
 	}
+	span.SetAttributes(tracing.String(tracing.AttributeConfig, certificateConfig.Name))
+	meta.RemoveStatusCondition(&certificate.Status.Conditions, ConditionWaitingForConfig)
+
+	if certificate.Status.ConfigUID != certificateConfig.UID {
+		if certificate.Status.ConfigUID != "" {
+			r.logger(ctx).Info("CertificateConfig was recreated, refreshing", "certificateConfig", certificateConfig.Name)
+			r.certClients.evict(certificateConfig.Name)
+			r.terminalErrors.forget(req.NamespacedName)
+		}
+		certificate.Status.ConfigUID = certificateConfig.UID
+	}
 
 	secret, err := common.GetSecret(r.Client, ctx, certificateConfig.Spec.SecretRef.Name, certificateConfig.Spec.SecretRef.Namespace)
 	if err != nil {
 		return ctrl.Result{}, fmt.Errorf(errFailedToGetSecret, err)
 	}
 
-	certClient, err := r.CertClientBuilder(r.Log, certificateConfig, secret.Data)
+	certClient, err := r.certClient(certificateConfig, secret)
 	if err != nil {
-		return ctrl.Result{}, fmt.Errorf(errFailedBuildingCertClient, err)
+		err = fmt.Errorf(errFailedBuildingCertClient, err)
+		if updateErr := r.updateCertificateConditions(ctx, certificate, errorCondition(ConditionCredentialsInvalid, err)); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
+		r.logger(ctx).Error(err, "invalid Cert API credentials", "secret", certificateConfig.Spec.SecretRef.Name)
+		return ctrl.Result{RequeueAfter: r.terminalErrorRequeueAfter()}, nil
 	}
 
-	if isCertificateValid(certificate, certificateConfig) {
-		if err := r.removeErrorConditions(ctx, certificate); err != nil {
+	stale, err := r.isConfigSecretStale(ctx, certificateConfig, secret)
+	if err != nil {
+		return ctrl.Result{}, fmt.Errorf(errFailedToGetSecret, err)
+	}
+	if stale {
+		r.logger(ctx).Info("secret of the CertificateConfig changed while building the Cert client, requeueing", "secret", certificateConfig.Spec.SecretRef.Name)
+		return ctrl.Result{Requeue: true}, nil
+	}
+	certificate.Status.ConfigSecretResourceVersion = secret.ResourceVersion
+
+	if r.CircuitBreaker != nil {
+		certClient = newBreakerCertClient(certClient, r.CircuitBreaker, certificateConfig.Name)
+	}
+
+	redownload := false
+	if isCertificateValid(certificate, certificateConfig, time.Now()) {
+		missing, err := r.isSecretMissing(ctx, certificate)
+		if err != nil {
 			return ctrl.Result{}, err
 		}
 
-		if err := r.forceExpirationUpdate(ctx, certClient, certificate, certificateConfig.Spec.ForceExpirationUpdate); err != nil {
+		drifted := false
+		if !missing {
+			drifted, err = r.hasSubjectDrifted(ctx, certificate)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		switch {
+		case missing && certificate.Status.Guid != "":
+			r.logger(ctx).Info("secret of the valid certificate is missing, downloading the certificate of its guid again", "guid", certificate.Status.Guid)
+			redownload = true
+		case missing:
+			r.logger(ctx).Info("secret of the valid certificate is missing and it has no guid, reissuing")
+		case drifted:
+			r.logger(ctx).Info("certificate in the secret does not match the requested subject or SANs, reissuing")
+		default:
+			meta.SetStatusCondition(&certificate.Status.Conditions, r.syncedCondition(reasonCertificateValid, nil))
+			markReconciled(ctx, certificate)
+			if err := r.removeErrorConditions(ctx, certificate); err != nil {
+				return ctrl.Result{}, err
+			}
+
+			if err := r.forceExpirationUpdate(ctx, certClient, certificate, certificateConfig); err != nil {
+				return ctrl.Result{}, err
+			}
+			metrics.SetCertificateExpiry(certificate.Namespace, certificate.Name, certificate.Status.ValidTo.Time)
+
+			return ctrl.Result{}, nil
+		}
+	}
+
+	if condition, err := validateSANCount(certificate, certificateConfig); err != nil {
+		r.logger(ctx).Error(err, "invalid SAN entries")
+		return ctrl.Result{}, r.updateCertificateConditions(ctx, certificate, condition)
+	}
+
+	if condition, err := r.validateTemplate(ctx, certificate, certificateConfig); err != nil {
+		if condition.Type == "" {
 			return ctrl.Result{}, err
 		}
+		r.logger(ctx).Error(err, "template not allowed")
+		return ctrl.Result{}, r.updateCertificateConditions(ctx, certificate, condition)
+	}
 
-		return ctrl.Result{}, nil
+	version := terminalErrorVersion(certificate, certificateConfig, secret)
+	if r.terminalErrors.blocked(req.NamespacedName, version) {
+		r.logger(ctx).Info("Certificate failed with a terminal error, waiting for it or its CertificateConfig to change")
+		return ctrl.Result{RequeueAfter: r.terminalErrorRequeueAfter()}, nil
 	}
 
-	condition, err := r.issueCertificate(ctx, certClient, certificate)
-	if err != nil {
-		if updateErr := r.updateCertificateConditions(ctx, certificate, condition); updateErr != nil {
-			return ctrl.Result{}, updateErr
+	if allowed, retryAfter := r.allowCertAPIRequests(certificateConfig.Name); !allowed {
+		return r.waitForCircuit(ctx, certificate, &circuitOpenError{RetryAfter: retryAfter})
+	}
+
+	renewal := certificate.Status.Guid != ""
+	if renewal && !redownload && certificate.Status.ValidTo.After(time.Now()) {
+		ctx = withRenewalOfValidCertificate(ctx)
+	}
+	// The certificate of the guid is downloaded again without creating another one if only its secret is missing.
+	if !redownload {
+		condition, err := r.issueCertificate(ctx, certClient, certificate, certificateConfig)
+		if err != nil {
+			meta.SetStatusCondition(&certificate.Status.Conditions, r.syncedCondition(reasonPostFailed, err))
+			return r.handleCertAPIError(ctx, certificate, certificateConfig, version, condition, err)
+		}
+
+		condition, err = r.updateCertValidity(ctx, certClient, certificate, certificateConfig)
+		if err != nil {
+			meta.SetStatusCondition(&certificate.Status.Conditions, r.syncedCondition(reasonPollFailed, err))
+			switch {
+			case condition.Type == ConditionCertificatePending:
+				return r.waitForPendingCertificate(ctx, certificate, condition)
+			case condition.Reason == ConditionRevoked:
+				return r.handleRevokedCertificate(ctx, certificate, certificateConfig, version, condition, err)
+			case condition.Reason == ConditionExpiredAtCA:
+				return ctrl.Result{Requeue: true}, r.expireCertificate(ctx, certificate, condition)
+			}
+			if reset, resetErr := r.resetStuckGUID(ctx, certificate, err); reset || resetErr != nil {
+				return ctrl.Result{Requeue: true}, resetErr
+			}
+			if isNotFoundError(err) {
+				meta.SetStatusCondition(&certificate.Status.Conditions, certNotFoundAtCACondition(certificate))
+				if updateErr := r.failIssuance(ctx, certificate, certificateConfig, condition); updateErr != nil {
+					return ctrl.Result{}, updateErr
+				}
+				return ctrl.Result{RequeueAfter: requeueAfterNotFoundError}, err
+			}
+
+			return r.handleCertAPIError(ctx, certificate, certificateConfig, version, condition, err)
 		}
-		return ctrl.Result{}, err
 	}
 
-	condition, err = r.updateCertValidity(ctx, certClient, certificate)
+	tlsData, condition, err := r.downloadCert(ctx, certClient, certificate, certificateConfig)
 	if err != nil {
-		if updateErr := r.updateCertificateConditions(ctx, certificate, condition); updateErr != nil {
-			return ctrl.Result{}, updateErr
+		meta.SetStatusCondition(&certificate.Status.Conditions, r.syncedCondition(reasonDownloadFailed, err))
+		if reset, resetErr := r.resetStuckGUID(ctx, certificate, err); reset || resetErr != nil {
+			return ctrl.Result{Requeue: true}, resetErr
 		}
-
-		if strings.Contains(err.Error(), http.StatusText(http.StatusNotFound)) {
-			return ctrl.Result{RequeueAfter: requeueAfterNotFoundError}, err
+		if isNotFoundError(err) {
+			meta.SetStatusCondition(&certificate.Status.Conditions, certNotFoundAtCACondition(certificate))
 		}
+		return r.handleCertAPIError(ctx, certificate, certificateConfig, version, condition, err)
+	}
+	certificate.Status.DownloadFailures = 0
+	certificate.Status.GUIDIssuedTime = metav1.Time{}
+	certificate.Status.GUIDResets = 0
 
+	condition, err = r.createOrUpdateTlsSecret(ctx, certificate, tlsData, req.Namespace)
+	if err != nil {
+		meta.SetStatusCondition(&certificate.Status.Conditions, r.syncedCondition(reasonSecretUpdateFailed, err))
+		if updateErr := r.failIssuance(ctx, certificate, certificateConfig, condition); updateErr != nil {
+			return ctrl.Result{}, updateErr
+		}
 		return ctrl.Result{}, err
 	}
 
-	tlsData, condition, err := r.downloadCert(ctx, certClient, certificate)
+	condition, err = r.createOrUpdateAdditionalSecrets(ctx, certificate, tlsData, req.Namespace)
 	if err != nil {
-		if updateErr := r.updateCertificateConditions(ctx, certificate, condition); updateErr != nil {
+		meta.SetStatusCondition(&certificate.Status.Conditions, r.syncedCondition(reasonSecretUpdateFailed, err))
+		if updateErr := r.failIssuance(ctx, certificate, certificateConfig, condition); updateErr != nil {
 			return ctrl.Result{}, updateErr
 		}
 		return ctrl.Result{}, err
 	}
 
-	condition, err = r.createOrUpdateTlsSecret(ctx, certificate, tlsData, req.Namespace)
+	condition, err = r.createOrUpdateConfigMap(ctx, certificate, tlsData, req.Namespace)
 	if err != nil {
-		if updateErr := r.updateCertificateConditions(ctx, certificate, condition); updateErr != nil {
+		meta.SetStatusCondition(&certificate.Status.Conditions, r.syncedCondition(reasonSecretUpdateFailed, err))
+		if updateErr := r.failIssuance(ctx, certificate, certificateConfig, condition); updateErr != nil {
 			return ctrl.Result{}, updateErr
 		}
 		return ctrl.Result{}, err
 	}
 
+	meta.SetStatusCondition(&certificate.Status.Conditions, r.syncedCondition(reasonSecretUpdated, nil))
+	certificate.Status.LastReconcileTime = metav1.Now()
 	err = r.removeErrorConditions(ctx, certificate)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
+	r.terminalErrors.forget(req.NamespacedName)
+	metrics.SetCertificateExpiry(certificate.Namespace, certificate.Name, certificate.Status.ValidTo.Time)
+
+	if redownload {
+		return reconcile.Result{}, nil
+	}
+
+	eventType := notification.EventIssued
+	if renewal {
+		eventType = notification.EventRenewed
+	}
+	r.notify(ctx, certificateConfig, certificate, eventType, nil)
+
 	return reconcile.Result{}, nil
 }
 
-// updateCertificateConditions updates the conditions of the Certificate resource
+// logger returns the logger of the reconcile in the context, or the logger of the reconciler outside of a reconcile.
+func (r *CertificateReconciler) logger(ctx context.Context) logr.Logger {
+	if log, err := logr.FromContext(ctx); err == nil {
+		return log
+	}
+
+	return r.Log
+}
+
+// waitForCircuit updates the conditions of the Certificate with the CircuitOpen condition, and requeues it once the
+// circuit breaker allows requests to the Cert API again, instead of retrying it with backoff.
+func (r *CertificateReconciler) waitForCircuit(ctx context.Context, certificate *v1alpha1.Certificate, err *circuitOpenError) (ctrl.Result, error) {
+	if updateErr := r.updateCertificateConditions(ctx, certificate, errorCondition(ConditionCircuitOpen, err)); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+
+	return ctrl.Result{RequeueAfter: err.RetryAfter}, nil
+}
+
+// handleCertAPIError updates the conditions of the Certificate with the condition of a failed request to the Cert API.
+// Failures to resolve the host of the Cert API are reported with a dedicated condition, instead of the noisy request error.
+// So are rate-limited requests, with the delay requested by their Retry-After header, so that throttling can be alerted on.
+// Responses requesting a delay with a Retry-After header are requeued after it, instead of being retried with backoff.
+// Requests which the circuit breaker did not allow are handled like reconciles of an open circuit, without failing the
+// issuance, since the Cert API was not requested.
+// Terminal errors are recorded at the given version, so that the Cert API is not requested again until the Certificate
+// or its CertificateConfig change, and are requeued after the terminal error interval instead of being retried with backoff.
+func (r *CertificateReconciler) handleCertAPIError(ctx context.Context, certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig, version string, condition metav1.Condition, err error) (ctrl.Result, error) {
+	if circuitOpenErr, ok := circuitOpen(err); ok {
+		return r.waitForCircuit(ctx, certificate, circuitOpenErr)
+	}
+
+	if dnsError, ok := httpClient.DNSError(err); ok {
+		condition = errorCondition(ConditionCAEndpointUnreachable, fmt.Errorf(errCAEndpointUnreachable, dnsError.Name))
+	}
+
+	if statusCode, ok := httpClient.StatusCode(err); ok && statusCode == http.StatusTooManyRequests {
+		condition = errorCondition(ConditionRateLimited, fmt.Errorf(errRateLimited, err))
+		if retryAfter, ok := httpClient.RetryAfter(err); ok {
+			condition = errorCondition(ConditionRateLimited, fmt.Errorf(errRateLimitedRetryAfter, retryAfter, err))
+		}
+	}
+
+	if updateErr := r.failIssuance(ctx, certificate, certificateConfig, condition); updateErr != nil {
+		return ctrl.Result{}, updateErr
+	}
+
+	if retryAfter, ok := httpClient.RetryAfter(err); ok {
+		r.logger(ctx).Info("Cert API requested to retry later", "retryAfter", retryAfter)
+		return ctrl.Result{RequeueAfter: retryAfter}, nil
+	}
+
+	if !isTerminalError(err) {
+		return ctrl.Result{}, err
+	}
+
+	r.terminalErrors.record(client.ObjectKeyFromObject(certificate), version)
+	r.logger(ctx).Error(err, "terminal error, not retrying until the Certificate or its CertificateConfig change")
+	return ctrl.Result{RequeueAfter: r.terminalErrorRequeueAfter()}, nil
+}
+
+// failIssuance updates the conditions of the Certificate with the condition of a failed issuance step, records the
+// failure if it renews a certificate which is still valid, and notifies the failure to the NotificationURL of the
+// CertificateConfig. The failure is only notified when the Certificate turns failed, and not again while it keeps
+// failing, so that the endpoint is not sent an event on every retry.
+func (r *CertificateReconciler) failIssuance(ctx context.Context, certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig, condition metav1.Condition) error {
+	failed := meta.IsStatusConditionTrue(certificate.Status.Conditions, errorConditionType(r.ConditionTypePrefix))
+	r.recordRenewalFailure(ctx, certificate)
+	if !failed {
+		r.notify(ctx, certificateConfig, certificate, notification.EventFailed, &condition)
+	}
+	return r.updateCertificateConditions(ctx, certificate, condition)
+}
+
+// notify sends an event about the Certificate to the NotificationURL of the CertificateConfig, if both it and the
+// Notifier are set. Failures are only logged, so that an unavailable endpoint does not block certificate issuance.
+func (r *CertificateReconciler) notify(ctx context.Context, certificateConfig *v1alpha1.CertificateConfig, certificate *v1alpha1.Certificate, eventType string, condition *metav1.Condition) {
+	if r.Notifier == nil || certificateConfig.Spec.NotificationURL == "" {
+		return
+	}
+
+	event := notification.NewEvent(eventType, certificate, condition, time.Now())
+	if err := r.Notifier.Notify(ctx, certificateConfig.Spec.NotificationURL, event); err != nil {
+		r.logger(ctx).Error(err, "failed to send notification", "event", eventType)
+	}
+}
+
+// updateCertificateConditions updates the conditions of the Certificate resource.
+// An Error condition is prefixed with the ConditionTypePrefix, and also recorded as the last error of the Certificate.
+// The time of the last error is only advanced when the error changes, so that repeated failures with the same error
+// do not write to the status.
 func (r *CertificateReconciler) updateCertificateConditions(ctx context.Context, certificate *v1alpha1.Certificate, condition metav1.Condition) error {
+	condition.Message = truncateMessage(condition.Message, maxConditionMessageLength(r.MaxConditionMessageLength))
+	if condition.Type == ConditionError {
+		condition.Type = errorConditionType(r.ConditionTypePrefix)
+	}
 	meta.SetStatusCondition(&certificate.Status.Conditions, condition)
-	err := r.Client.Status().Update(ctx, certificate)
+	if condition.Type == errorConditionType(r.ConditionTypePrefix) && certificate.Status.LastError != condition.Message {
+		certificate.Status.LastError = condition.Message
+		certificate.Status.LastErrorTime = metav1.Now()
+	}
+	err := r.patchStatus(ctx, certificate)
 	if err != nil {
 		return fmt.Errorf(errUpdateStatus, err)
 	}
 
+	if condition.Type == errorConditionType(r.ConditionTypePrefix) {
+		metrics.SetCertificateError(client.ObjectKeyFromObject(certificate).String(), true)
+	}
+
 	return nil
 }
 
-// removeErrorConditions removes the error conditions of the Certificate resource
+// removeErrorConditions removes the error conditions of the Certificate resource, and clears its last error and its
+// renewal failures.
 func (r *CertificateReconciler) removeErrorConditions(ctx context.Context, certificate *v1alpha1.Certificate) error {
-	meta.RemoveStatusCondition(&certificate.Status.Conditions, ConditionError)
-	err := r.Client.Status().Update(ctx, certificate)
+	removeErrorCondition(certificate)
+	meta.RemoveStatusCondition(&certificate.Status.Conditions, ConditionCertNotFoundAtCA)
+	meta.RemoveStatusCondition(&certificate.Status.Conditions, ConditionDegraded)
+	certificate.Status.RenewalFailures = 0
+	certificate.Status.LastRenewalFailureTime = metav1.Time{}
+	certificate.Status.LastError = ""
+	certificate.Status.LastErrorTime = metav1.Time{}
+	err := r.patchStatus(ctx, certificate)
 	if err != nil {
 		return fmt.Errorf(errUpdateStatus, err)
 	}
 
+	metrics.SetCertificateError(client.ObjectKeyFromObject(certificate).String(), false)
+
 	return nil
 }
 
-// isCertificateValid checks if the certificate is valid based on the renewal criteria specified in the CertificateConfig.
-// It calculates the renewal date by subtracting the specified number of days before renewal from the current time.
-// Returns true if the certificate is valid and false otherwise.
-func isCertificateValid(certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig) bool {
-	renewDate := time.Now().AddDate(0, 0, -certificateConfig.Spec.DaysBeforeRenewal)
-	return !certificate.Status.ValidTo.IsZero() && certificate.Status.ValidTo.Time.After(renewDate)
+// updatePausedCondition sets the Paused condition if the Certificate is paused and removes it otherwise.
+// It returns whether the Certificate is paused.
+func (r *CertificateReconciler) updatePausedCondition(ctx context.Context, certificate *v1alpha1.Certificate) (bool, error) {
+	paused := isPaused(certificate)
+
+	var changed bool
+	if paused {
+		changed = meta.SetStatusCondition(&certificate.Status.Conditions, metav1.Condition{
+			Type:    ConditionPaused,
+			Status:  metav1.ConditionTrue,
+			Reason:  reasonReconcilePaused,
+			Message: fmt.Sprintf("reconcile is paused by the %q annotation", AnnotationPaused),
+		})
+	} else {
+		changed = meta.RemoveStatusCondition(&certificate.Status.Conditions, ConditionPaused)
+	}
+
+	if !changed {
+		return paused, nil
+	}
+
+	if err := r.patchStatus(ctx, certificate); err != nil {
+		return paused, fmt.Errorf(errUpdateStatus, err)
+	}
+
+	return paused, nil
+}
+
+// isPaused checks if the Certificate has the paused annotation set to true.
+func isPaused(certificate *v1alpha1.Certificate) bool {
+	return certificate.GetAnnotations()[AnnotationPaused] == "true"
+}
+
+// isCertificateValid checks if the certificate is valid at the given time based on the renewal criteria specified in
+// the CertificateConfig. If RenewBeforePercent is set, the certificate is valid until that percentage of its validity
+// remains. Otherwise, it calculates the renewal date by subtracting the specified number of days before renewal from
+// the given time. Returns true if the certificate is valid and false otherwise.
+func isCertificateValid(certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig, now time.Time) bool {
+	if certificate.Status.ValidTo.IsZero() {
+		return false
+	}
+
+	if percent := certificateConfig.Spec.RenewBeforePercent; percent != nil {
+		validity := certificate.Status.ValidTo.Sub(certificate.Status.ValidFrom.Time)
+		renewalTime := certificate.Status.ValidTo.Add(-validity * time.Duration(*percent) / 100)
+		return now.Before(renewalTime)
+	}
+
+	renewDate := now.AddDate(0, 0, -certificateConfig.Spec.DaysBeforeRenewal)
+	return certificate.Status.ValidTo.Time.After(renewDate)
 }
 
 // forceExpirationUpdate updates the validity period of the certificate based on the certificate configuration.
 // If ForceExpirationUpdate is set to true in the CertificateConfig, it updates the certificate's validity period.
+// A certificate the CA reports as expired is expired, so that it is reissued, and one it reports as pending is ignored.
 // returns an error if any occurred during the update process.
-func (r *CertificateReconciler) forceExpirationUpdate(ctx context.Context, certClient cert.Client, certificate *v1alpha1.Certificate, force bool) error {
-	if !force {
+func (r *CertificateReconciler) forceExpirationUpdate(ctx context.Context, certClient cert.Client, certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig) error {
+	if !certificateConfig.Spec.ForceExpirationUpdate {
 		return nil
 	}
 
-	condition, err := r.updateCertValidity(ctx, certClient, certificate)
+	condition, err := r.updateCertValidity(ctx, certClient, certificate, certificateConfig)
 	if err != nil {
+		switch {
+		case condition.Type == ConditionCertificatePending:
+			return nil
+		case condition.Reason == ConditionExpiredAtCA:
+			return r.expireCertificate(ctx, certificate, condition)
+		}
 		err = r.updateCertificateConditions(ctx, certificate, condition)
 		return err
 	}
@@ -218,12 +788,58 @@ func (r *CertificateReconciler) forceExpirationUpdate(ctx context.Context, certC
 	return nil
 }
 
-// hasNotFoundErrorCondition checks if the Certificate resource has a condition indicating a NotFound error.
-func (r *CertificateReconciler) hasNotFoundErrorCondition(certificate *v1alpha1.Certificate) bool {
-	for _, condition := range certificate.Status.Conditions {
-		if condition.Type == ConditionError && strings.Contains(condition.Message, http.StatusText(http.StatusNotFound)) {
-			return true
-		}
+// annotationChangedPredicate returns a predicate accepting updates which change the annotations of the Certificate,
+// like predicate.AnnotationChangedPredicate, except for the pending guid annotations, which are only written by the
+// reconcile itself.
+func annotationChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			if e.ObjectOld == nil || e.ObjectNew == nil {
+				return false
+			}
+
+			oldAnnotations, newAnnotations := maps.Clone(e.ObjectOld.GetAnnotations()), maps.Clone(e.ObjectNew.GetAnnotations())
+			for _, annotation := range []string{AnnotationPendingGUID, AnnotationPendingGUIDGeneration} {
+				delete(oldAnnotations, annotation)
+				delete(newAnnotations, annotation)
+			}
+
+			return !equality.Semantic.DeepEqual(oldAnnotations, newAnnotations)
+		},
+	}
+}
+
+// allowCertAPIRequests reports whether requests to the Cert API of the given CertificateConfig are allowed by the circuit breaker.
+// If they are not, it also returns the time remaining until they are allowed again. The probe request of a half-open
+// circuit is not taken, since the reconcile may not request the Cert API: it is taken by the request itself.
+func (r *CertificateReconciler) allowCertAPIRequests(configName string) (bool, time.Duration) {
+	if r.CircuitBreaker == nil {
+		return true, 0
 	}
-	return false
+
+	return r.CircuitBreaker.Check(configName)
+}
+
+// isNotFoundError checks if the error is a NotFound response of the Cert API. It relies on the status code of the
+// response rather than on its message, which the Cert API may localize.
+func isNotFoundError(err error) bool {
+	code, ok := httpClient.StatusCode(err)
+	return ok && code == http.StatusNotFound
+}
+
+// certNotFoundAtCACondition returns the CertNotFoundAtCA condition of a Certificate whose certificate was not found
+// by the Cert API, which is polled again instead of another certificate being created.
+func certNotFoundAtCACondition(certificate *v1alpha1.Certificate) metav1.Condition {
+	return metav1.Condition{
+		Type:    ConditionCertNotFoundAtCA,
+		Status:  metav1.ConditionTrue,
+		Reason:  reasonCertificateNotFound,
+		Message: fmt.Sprintf(errCertNotFoundAtCA, certificate.Status.Guid),
+	}
+}
+
+// hasNotFoundErrorCondition checks if the Certificate resource has the CertNotFoundAtCA condition, set when the Cert
+// API did not find the certificate of its guid.
+func (r *CertificateReconciler) hasNotFoundErrorCondition(certificate *v1alpha1.Certificate) bool {
+	return meta.IsStatusConditionTrue(certificate.Status.Conditions, ConditionCertNotFoundAtCA)
 }
@@ -2,67 +2,308 @@ package controller
 
 import (
 	"context"
+	"crypto/x509"
+	goerrors "errors"
 	"fmt"
+	"maps"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
 	"github.com/dana-team/certificate-operator/internal/clients/cert"
 
 	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
 	certhandler "github.com/dana-team/certificate-operator/internal/certhandler"
+	"github.com/dana-team/certificate-operator/internal/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
 const (
 	errFailedParseValidTo           = "failed to parse validTo: %v"
 	errFailedParseValidFrom         = "failed to parse validFrom: %v"
-	errFailedDownloadingCertificate = "failed downloading certificate: %v"
+	errFailedDownloadingCertificate = "failed downloading certificate: %w"
 	errCreateOrUpdateTlsSecret      = "failed to create or update tls secret: %v"
+	errCreateOrUpdateFormatSecret   = "failed to create or update %s secret: %v"
+	errGetExistingSecret            = "failed to get existing secret %q: %v"
+	errCreateOrUpdateConfigMap      = "failed to create or update ConfigMap: %v"
+	errGetExistingConfigMap         = "failed to get existing ConfigMap %q: %v"
+	errConfigMapNotOwned            = "ConfigMap %q already exists and is not owned by the Certificate, set adoptExisting to adopt it"
+	errSecretNotOwned               = "secret %q already exists and is not owned by the Certificate, set adoptExisting to adopt it"
+	errParseSecretNameTemplate      = "failed to parse secretNameTemplate: %v"
+	errRenderSecretNameTemplate     = "failed to render secretNameTemplate: %v"
+	errInvalidSecretName            = "invalid secret name %q: %s"
+	errTooManySANEntries            = "certificate has %d SAN entries, which exceeds the maximum of %d"
+	errTemplateNotAllowed           = "template %q is not allowed in namespace %q by the template policies of CertificateConfig %q, allowed templates are %v"
+	errGetPKCS12Password            = "failed to get PKCS#12 password from secret %q: %v"
+	errMissingPKCS12PasswordKey     = "secret %q has no key %q holding the PKCS#12 password"
+	errChainMissing                 = "downloaded certificate bundle contains no CA certificates"
+	errOrphanObject                 = "failed to remove the owner reference of the Certificate from %q: %v"
+	errCleanupObject                = "failed to delete %q of the deleted Certificate: %v"
+	errUpdatePendingGUID            = "failed to patch the pending guid annotation: %w"
+	errGUIDNotPersisted             = "the guid %q of the created certificate was persisted neither in the pending guid annotation (%v) nor in the status: %w"
 )
 
+const (
+	// DefaultMaxConditionMessageLength is the default maximum length of condition messages.
+	DefaultMaxConditionMessageLength = 1024
+
+	ellipsis = "..."
+)
+
+// DefaultMaxSANEntries is the default maximum number of SAN entries of a certificate.
+const DefaultMaxSANEntries = 250
+
+// errorConditionType returns the type of the Error condition, prefixed with the given prefix.
+func errorConditionType(prefix string) string {
+	return prefix + ConditionError
+}
+
+// isErrorConditionType returns whether the condition type is the type of the Error condition under any prefix, or
+// under none.
+func isErrorConditionType(conditionType string) bool {
+	return conditionType == ConditionError || strings.HasSuffix(conditionType, "/"+ConditionError)
+}
+
+// removeErrorCondition removes the Error condition of the Certificate, along with the Error conditions set under a
+// previous ConditionTypePrefix or before one was set, so that changing the prefix does not leave stale errors behind.
+func removeErrorCondition(certificate *v1alpha1.Certificate) {
+	certificate.Status.Conditions = slices.DeleteFunc(certificate.Status.Conditions, func(condition metav1.Condition) bool {
+		return isErrorConditionType(condition.Type)
+	})
+}
+
+const (
+	reasonCertificateExpired    = "CertificateExpired"
+	reasonCertificateNotExpired = "CertificateNotExpired"
+)
+
+const (
+	reasonCAChainMissing = "CAChainMissing"
+	reasonCAChainPresent = "CAChainPresent"
+)
+
+const (
+	reasonRenewalWindowExceedsValidity = "RenewalWindowExceedsValidity"
+	reasonRenewalWindowWithinValidity  = "RenewalWindowWithinValidity"
+)
+
+// ConditionSynced is the condition summarizing the issuance steps of a Certificate: posting it to the Cert API,
+// polling its validity, downloading it and updating its secrets.
+const ConditionSynced = "Synced"
+
+const (
+	reasonPostFailed         = "PostFailed"
+	reasonPollFailed         = "PollFailed"
+	reasonDownloadFailed     = "DownloadFailed"
+	reasonSecretUpdateFailed = "SecretUpdateFailed"
+	reasonSecretUpdated      = "SecretUpdated"
+	reasonCertificateValid   = "CertificateValid"
+)
+
+const certificateKind = "Certificate"
+
 const (
 	ConditionParseValidToFailed            = "ParseValidToFailed"
 	ConditionParseValidFromFailed          = "ParseValidFromFailed"
 	ConditionSetOwnerRefFailed             = "SetOwnerRefFailed"
 	ConditionCreateOrUpdateTLSSecretFailed = "CreateOrUpdateTLSSecretFailed"
+	ConditionCreateOrUpdateConfigMapFailed = "CreateOrUpdateConfigMapFailed"
+	ConditionEncodeSecretFailed            = "EncodeSecretFailed"
+	ConditionSecretNotOwned                = "SecretNotOwned"
+	ConditionInvalidSecretName             = "InvalidSecretName"
+	ConditionTooManySANEntries             = "TooManySANEntries"
+	ConditionTemplateNotAllowed            = "TemplateNotAllowed"
+	ConditionGetPKCS12PasswordFailed       = "GetPKCS12PasswordFailed"
+	ConditionDecodePasswordFailed          = "DecodePasswordFailed"
 )
 
 // issueCertificate creates a certificate, obtains the certificate guid, and updates the Certificate status with the obtained guid.
+// The guid is first persisted in the AnnotationPendingGUID annotation, so that a failed status update does not cause
+// the certificate to be created again on the next reconcile; the pending guid is adopted into the status instead.
+// If the guid is persisted neither in the annotation nor in the status, an error holding the guid is returned.
+// No certificate is created while the certificate of the guid is pending at the CA.
 // It returns an error if the operation fails.
-func (r *CertificateReconciler) issueCertificate(ctx context.Context, certClient cert.Client, certificate *v1alpha1.Certificate) (condition metav1.Condition, err error) {
-	if r.hasNotFoundErrorCondition(certificate) {
+func (r *CertificateReconciler) issueCertificate(ctx context.Context, certClient cert.Client, certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig) (condition metav1.Condition, err error) {
+	if r.hasNotFoundErrorCondition(certificate) || meta.IsStatusConditionTrue(certificate.Status.Conditions, ConditionCertificatePending) {
 		return metav1.Condition{}, nil
 	}
 
-	guid, err := certClient.PostCertificate(ctx, certificate)
-	if err != nil {
-		return errorCondition(ConditionPostToCertAPIFailed, err), fmt.Errorf(errCreationFailed, err)
+	var pendingErr error
+	guid, pending := pendingGUID(certificate)
+	if !pending {
+		certificateRequest := r.recordCertificateRequest(ctx, certificate, certificateConfig)
+		var response cert.PostCertificateResponse
+		response, err = certClient.PostCertificate(ctx, certificate)
+		r.recordCertificateResponse(ctx, certificateRequest, response, err)
+		if err != nil {
+			return errorCondition(ConditionPostToCertAPIFailed, err), fmt.Errorf(errCreationFailed, err)
+		}
+
+		guid = response.Guid
+		if pendingErr = r.setPendingGUID(ctx, certificate, guid); pendingErr != nil {
+			r.logger(ctx).Error(pendingErr, "failed to persist the pending guid, persisting it in the status only", "guid", guid)
+		}
+	} else {
+		r.logger(ctx).Info("adopting the guid of a previously created certificate", "guid", guid)
 	}
 
+	previousGUID := certificate.Status.Guid
 	certificate.Status.Guid = guid
-	if err = r.Status().Update(ctx, certificate); err != nil {
+	if guid != previousGUID {
+		certificate.Status.DownloadFailures = 0
+		certificate.Status.GUIDIssuedTime = metav1.Now()
+	}
+	if err = r.patchStatus(ctx, certificate); err != nil {
+		certificate.Status.Guid = previousGUID
+		if pendingErr != nil {
+			err = fmt.Errorf(errGUIDNotPersisted, guid, pendingErr, err)
+		}
 		return errorCondition(ConditionUpdateStatusFailed, err), fmt.Errorf(errCreationFailed, err)
 	}
 
+	if err := r.setPendingGUID(ctx, certificate, ""); err != nil {
+		r.logger(ctx).Error(err, "failed to remove the pending guid persisted in the status", "guid", guid)
+	}
 	return metav1.Condition{}, nil
 }
 
+// pendingGUID returns the guid of a created certificate which was not yet persisted in the status of the Certificate.
+// A pending guid equal to the guid in the status is stale, and is not returned. Neither is a pending guid created for
+// another generation of the Certificate, since its certificate does not match the current spec.
+func pendingGUID(certificate *v1alpha1.Certificate) (string, bool) {
+	annotations := certificate.GetAnnotations()
+	guid := annotations[AnnotationPendingGUID]
+	if guid == "" || guid == certificate.Status.Guid {
+		return "", false
+	}
+	if annotations[AnnotationPendingGUIDGeneration] != strconv.FormatInt(certificate.Generation, 10) {
+		return "", false
+	}
+
+	return guid, true
+}
+
+// resetStuckGUID counts the failure err to poll or download the certificate of the guid in the status of the
+// Certificate. Requests which the circuit breaker did not allow are not counted, since the Cert API was not requested.
+// If it still fails stuckGUIDTimeout after the certificate was created, e.g. when the operator stopped after creating
+// the certificate and it expired at the CA before it was downloaded, the guid and the Error and CertNotFoundAtCA
+// conditions are cleared and the status is updated, so that a new certificate is created on the next reconcile. The
+// guid is cleared at most maxGUIDResets times until a certificate is downloaded. A guid whose creation time is unknown
+// is timed from its first failure. It returns whether the guid was cleared.
+func (r *CertificateReconciler) resetStuckGUID(ctx context.Context, certificate *v1alpha1.Certificate, err error) (bool, error) {
+	if _, ok := circuitOpen(err); ok {
+		return false, nil
+	}
+
+	certificate.Status.DownloadFailures++
+	if certificate.Status.GUIDIssuedTime.IsZero() {
+		certificate.Status.GUIDIssuedTime = metav1.Now()
+	}
+	if time.Since(certificate.Status.GUIDIssuedTime.Time) < stuckGUIDTimeout {
+		return false, nil
+	}
+
+	if certificate.Status.GUIDResets >= maxGUIDResets {
+		r.logger(ctx).Info("certificate of the guid keeps failing to download, not creating another one", "guid", certificate.Status.Guid, "guidResets", certificate.Status.GUIDResets)
+		return false, nil
+	}
+
+	r.logger(ctx).Info("certificate of the guid keeps failing to download, creating another one", "guid", certificate.Status.Guid, "downloadFailures", certificate.Status.DownloadFailures)
+	certificate.Status.Guid = ""
+	certificate.Status.DownloadFailures = 0
+	certificate.Status.GUIDIssuedTime = metav1.Time{}
+	certificate.Status.GUIDResets++
+	removeErrorCondition(certificate)
+	meta.RemoveStatusCondition(&certificate.Status.Conditions, ConditionCertNotFoundAtCA)
+
+	if err := r.patchStatus(ctx, certificate); err != nil {
+		return false, fmt.Errorf(errUpdateStatus, err)
+	}
+
+	return true, nil
+}
+
+// setPendingGUID sets the AnnotationPendingGUID annotation of the Certificate to the guid, and the
+// AnnotationPendingGUIDGeneration annotation to the generation of the Certificate, or removes them if the guid is
+// empty. Only the two annotations are patched, with a merge patch, so that the patch does not conflict with or overwrite
+// concurrent writes of the Certificate. The patch does not enqueue the Certificate again, since updates changing only
+// these annotations are filtered out by annotationChangedPredicate. The annotations of the Certificate are left
+// unchanged if the patch fails.
+func (r *CertificateReconciler) setPendingGUID(ctx context.Context, certificate *v1alpha1.Certificate, guid string) error {
+	previousAnnotations := certificate.GetAnnotations()
+	generation := strconv.FormatInt(certificate.Generation, 10)
+	if guid == "" {
+		generation = ""
+	}
+	if previousAnnotations[AnnotationPendingGUID] == guid && previousAnnotations[AnnotationPendingGUIDGeneration] == generation {
+		return nil
+	}
+
+	original := certificate.DeepCopy()
+	annotations := maps.Clone(previousAnnotations)
+	if guid == "" {
+		delete(annotations, AnnotationPendingGUID)
+		delete(annotations, AnnotationPendingGUIDGeneration)
+	} else {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[AnnotationPendingGUID] = guid
+		annotations[AnnotationPendingGUIDGeneration] = generation
+	}
+	certificate.SetAnnotations(annotations)
+
+	status := certificate.Status.DeepCopy()
+	err := r.Patch(ctx, certificate, client.MergeFrom(original))
+	certificate.Status = *status
+	if err != nil {
+		certificate.SetAnnotations(previousAnnotations)
+		return fmt.Errorf(errUpdatePendingGUID, err)
+	}
+
+	return nil
+}
+
 // obtainCertificateData obtains certificate data, updates the Certificate status with the obtained data,
 // and returns the validity information.
-// It returns the validity information (validTo, validFrom, signatureHashAlgorithm), or an error if the operation fails.
+// It returns the validity information (validTo, validFrom, signatureHashAlgorithm), or an error if the operation fails
+// or the CA reports the certificate as pending, revoked or expired.
 func (r *CertificateReconciler) obtainCertificateData(ctx context.Context, certClient cert.Client, certificate *v1alpha1.Certificate) (validTo, validFrom, signatureHashAlgorithm string, condition metav1.Condition, err error) {
 	getResponse, err := certClient.GetCertificate(ctx, certificate)
 	if err != nil {
 		return "", "", "", errorCondition(ConditionGetCertDataFromCertAPIFailed, err), err
 	}
 
+	r.setDebugRawResponse(ctx, certificate, getResponse.Raw)
+	mergeCAMetadata(certificate, getResponse.Metadata)
+
+	if condition, err := caStatusCondition(certificate, getResponse.Status); err != nil {
+		return "", "", "", condition, err
+	}
+
 	return getResponse.ValidTo, getResponse.ValidFrom, getResponse.SignatureHashAlgorithm, metav1.Condition{}, nil
 }
 
 // updateCertValidity updates the certificate status with the validity information.
+// The RenewalMisconfigured condition is set if the DaysBeforeRenewal of the CertificateConfig exceed the validity of
+// the certificate, without failing the update. It is removed if the CertificateConfig sets a RenewBeforePercent instead.
+// The CertificatePending condition is removed once the CA reports the certificate as no longer pending.
 // It returns an error if the status update operation fails.
-func (r *CertificateReconciler) updateCertValidity(ctx context.Context, certClient cert.Client, certificate *v1alpha1.Certificate) (metav1.Condition, error) {
+func (r *CertificateReconciler) updateCertValidity(ctx context.Context, certClient cert.Client, certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig) (metav1.Condition, error) {
 	validTo, validFrom, signatureHashAlgorithm, condition, err := r.obtainCertificateData(ctx, certClient, certificate)
+	if condition.Type != ConditionCertificatePending && condition.Reason != ConditionGetCertDataFromCertAPIFailed {
+		meta.RemoveStatusCondition(&certificate.Status.Conditions, ConditionCertificatePending)
+	}
 	if err != nil {
 		return condition, err
 	}
@@ -80,38 +321,183 @@ func (r *CertificateReconciler) updateCertValidity(ctx context.Context, certClie
 	certificate.Status.ValidTo = metav1.Time{Time: validToTime}
 	certificate.Status.ValidFrom = metav1.Time{Time: validFromTime}
 	certificate.Status.SignatureHashAlgorithm = signatureHashAlgorithm
+	meta.SetStatusCondition(&certificate.Status.Conditions, expiredCondition(certificate, time.Now()))
+
+	if certificateConfig.Spec.RenewBeforePercent != nil {
+		meta.RemoveStatusCondition(&certificate.Status.Conditions, ConditionRenewalMisconfigured)
+	} else {
+		renewalCondition := renewalMisconfiguredCondition(certificate, certificateConfig.Spec.DaysBeforeRenewal)
+		if renewalCondition.Status == metav1.ConditionTrue {
+			r.logger(ctx).Info("daysBeforeRenewal exceeds the validity of the certificate", "daysBeforeRenewal", certificateConfig.Spec.DaysBeforeRenewal, "certificateConfig", certificateConfig.Name)
+		}
+		meta.SetStatusCondition(&certificate.Status.Conditions, renewalCondition)
+	}
 
-	if err = r.Status().Update(ctx, certificate); err != nil {
+	if err = r.patchStatus(ctx, certificate); err != nil {
 		return errorCondition(ConditionUpdateStatusFailed, err), fmt.Errorf(errUpdateStatus, err)
 	}
 
 	return metav1.Condition{}, nil
 }
 
+// renewalMisconfiguredCondition returns the RenewalMisconfigured condition of the Certificate, which is true when the
+// days before renewal are at least the validity of its certificate, so that it is always due for renewal.
+func renewalMisconfiguredCondition(certificate *v1alpha1.Certificate, daysBeforeRenewal int) metav1.Condition {
+	validity := certificate.Status.ValidTo.Sub(certificate.Status.ValidFrom.Time)
+	renewalWindow := time.Duration(daysBeforeRenewal) * 24 * time.Hour
+	if renewalWindow >= validity {
+		return metav1.Condition{
+			Type:    ConditionRenewalMisconfigured,
+			Status:  metav1.ConditionTrue,
+			Reason:  reasonRenewalWindowExceedsValidity,
+			Message: fmt.Sprintf("daysBeforeRenewal of %d days is not shorter than the validity of the certificate of %g days, so the certificate is renewed continuously", daysBeforeRenewal, validity.Hours()/24),
+		}
+	}
+
+	return metav1.Condition{
+		Type:    ConditionRenewalMisconfigured,
+		Status:  metav1.ConditionFalse,
+		Reason:  reasonRenewalWindowWithinValidity,
+		Message: "daysBeforeRenewal is shorter than the validity of the certificate",
+	}
+}
+
 // downloadCert downloads the certificate from the Cert API and decodes it into TLS data.
+// The PKCS#12 data is decoded with the password referenced by the CertificateConfig, or with the password returned by the Cert API if none is referenced.
+// The password returned by the Cert API is decoded with the PasswordEncoding of the CertificateConfig.
+// If the Cert API did not provide the signature hash algorithm, it is derived from the downloaded certificate.
+// The fingerprint of the downloaded certificate is set in the status, and so is the ChainMissing condition. The
+// download fails instead if the CertificateConfig requires a chain and the PKCS#12 data holds no CA certificates, or
+// if it verifies the chain and the certificate does not chain to the CA certificates, so that its secret is not written.
 // It returns the TLS data containing the certificate and private key, or an error if the download or decoding fails.
-func (r *CertificateReconciler) downloadCert(ctx context.Context, certClient cert.Client, certificate *v1alpha1.Certificate) (certhandler.TLSData, metav1.Condition, error) {
+func (r *CertificateReconciler) downloadCert(ctx context.Context, certClient cert.Client, certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig) (certhandler.TLSData, metav1.Condition, error) {
 	downloadResponse, err := certClient.DownloadCertificate(ctx, certificate)
 	if err != nil {
 		return certhandler.TLSData{}, errorCondition(ConditionDownloadCertFromCertAPIFailed, err), fmt.Errorf(errFailedDownloadingCertificate, err)
 	}
+	r.setDebugRawResponse(ctx, certificate, downloadResponse.Raw)
 
-	tlsData, err := certhandler.Decoder(downloadResponse.Data, downloadResponse.Password)
+	var password string
+	if certificateConfig.Spec.PasswordSecretRef != nil {
+		password, err = r.pkcs12Password(ctx, certificateConfig.Spec.PasswordSecretRef)
+		if err != nil {
+			return certhandler.TLSData{}, errorCondition(ConditionGetPKCS12PasswordFailed, err), fmt.Errorf(errFailedDownloadingCertificate, err)
+		}
+	} else {
+		password, err = certhandler.DecodePassword(downloadResponse.Password, certificateConfig.Spec.PasswordEncoding)
+		if err != nil {
+			return certhandler.TLSData{}, errorCondition(ConditionDecodePasswordFailed, err), fmt.Errorf(errFailedDownloadingCertificate, err)
+		}
+	}
+
+	mergeCAMetadata(certificate, downloadResponse.Metadata)
+
+	tlsData, err := decodeDownloadedData(downloadResponse, password)
 	if err != nil {
 		return certhandler.TLSData{}, errorCondition(ConditionDecodeCertFailed, err), fmt.Errorf(errFailedDownloadingCertificate, err)
 	}
 
+	if tlsData.CertificateOnly {
+		r.logger(ctx).Info("downloaded certificate bundle contains no private key, storing the certificate only")
+	}
+
+	if len(tlsData.CACertificateBytes) == 0 && certificateConfig.Spec.RequireChain {
+		err := goerrors.New(errChainMissing)
+		return certhandler.TLSData{}, errorCondition(ConditionChainMissing, err), fmt.Errorf(errFailedDownloadingCertificate, err)
+	}
+	meta.SetStatusCondition(&certificate.Status.Conditions, chainMissingCondition(tlsData))
+
+	if certificateConfig.Spec.VerifyChain {
+		if err := certhandler.VerifyChain(tlsData); err != nil {
+			return certhandler.TLSData{}, errorCondition(ConditionChainVerificationFailed, err), fmt.Errorf(errFailedDownloadingCertificate, err)
+		}
+	}
+
+	if certificate.Status.SignatureHashAlgorithm == "" {
+		certificate.Status.SignatureHashAlgorithm = certhandler.SignatureHashAlgorithm(tlsData.Certificate)
+	}
+	certificate.Status.Fingerprint = certhandler.Fingerprint(tlsData.Certificate)
+	certificate.Status.KeyUsages = certhandler.KeyUsages(tlsData.Certificate)
+	certificate.Status.ExtendedKeyUsages = certhandler.ExtendedKeyUsages(tlsData.Certificate)
+
 	return tlsData, metav1.Condition{}, nil
 }
 
+// decodeDownloadedData decodes the PKCS#12 data of the download response with the password. The data was base64-decoded
+// as it was streamed from the response, unless the response holds it base64-encoded.
+func decodeDownloadedData(downloadResponse cert.DownloadCertificateResponse, password string) (certhandler.TLSData, error) {
+	if downloadResponse.DataErr != nil {
+		return certhandler.TLSData{}, downloadResponse.DataErr
+	}
+
+	if downloadResponse.DecodedData != nil {
+		return certhandler.DecodeDER(downloadResponse.DecodedData, password)
+	}
+
+	return certhandler.Decoder(downloadResponse.Data, password)
+}
+
+// chainMissingCondition returns the ChainMissing condition of the downloaded TLS data, which is true when the
+// PKCS#12 data held no CA certificates.
+func chainMissingCondition(tlsData certhandler.TLSData) metav1.Condition {
+	if len(tlsData.CACertificateBytes) == 0 {
+		return metav1.Condition{
+			Type:    ConditionChainMissing,
+			Status:  metav1.ConditionTrue,
+			Reason:  reasonCAChainMissing,
+			Message: errChainMissing,
+		}
+	}
+
+	return metav1.Condition{
+		Type:    ConditionChainMissing,
+		Status:  metav1.ConditionFalse,
+		Reason:  reasonCAChainPresent,
+		Message: "downloaded certificate bundle contains CA certificates",
+	}
+}
+
+// mergeCAMetadata copies the metadata fields of a Cert API response to the CAMetadata of the Certificate status.
+func mergeCAMetadata(certificate *v1alpha1.Certificate, metadata map[string]string) {
+	if len(metadata) == 0 {
+		return
+	}
+
+	if certificate.Status.CAMetadata == nil {
+		certificate.Status.CAMetadata = map[string]string{}
+	}
+	for field, value := range metadata {
+		certificate.Status.CAMetadata[field] = value
+	}
+}
+
+// pkcs12Password returns the PKCS#12 password held in the referenced Secret key.
+func (r *CertificateReconciler) pkcs12Password(ctx context.Context, ref *v1alpha1.SecretKeyRef) (string, error) {
+	secret, err := common.GetSecret(r.Client, ctx, ref.Name, ref.Namespace)
+	if err != nil {
+		return "", fmt.Errorf(errGetPKCS12Password, ref.Name, err)
+	}
+
+	password, ok := secret.Data[ref.Key]
+	if !ok {
+		return "", fmt.Errorf(errMissingPKCS12PasswordKey, ref.Name, ref.Key)
+	}
+
+	return string(password), nil
+}
+
 // createOrUpdateTlsSecret creates or updates a TLS secret with the provided TLS data and associates it with the certificate.
 // It returns an error if the creation or update operation fails.
 func (r *CertificateReconciler) createOrUpdateTlsSecret(ctx context.Context, certificate *v1alpha1.Certificate, tlsData certhandler.TLSData, namespace string) (metav1.Condition, error) {
 	tlsSecret := certhandler.TlsSecret(tlsData, certificate, namespace)
-	if err := controllerutil.SetOwnerReference(certificate, tlsSecret, r.Scheme); err != nil {
+	if err := r.setOwner(certificate, tlsSecret); err != nil {
 		return errorCondition(ConditionSetOwnerRefFailed, err), fmt.Errorf(fmt.Sprintf(errFailedToSetOwnerRefForSecret, tlsSecret.Name), err)
 	}
 
+	if condition, err := r.checkSecretOwnership(ctx, certificate, tlsSecret); err != nil {
+		return condition, fmt.Errorf(errCreateOrUpdateTlsSecret, err)
+	}
+
 	err := certhandler.CreateOrUpdateTLSSecret(ctx, r.Client, tlsSecret)
 	if err != nil {
 		return errorCondition(ConditionCreateOrUpdateTLSSecretFailed, err), fmt.Errorf(errCreateOrUpdateTlsSecret, err)
@@ -120,6 +506,495 @@ func (r *CertificateReconciler) createOrUpdateTlsSecret(ctx context.Context, cer
 	return metav1.Condition{}, nil
 }
 
+// createOrUpdateAdditionalSecrets creates or updates a secret for every additional format requested by the certificate,
+// and associates it with the certificate.
+// It returns an error if encoding the TLS data or the creation or update operation fails.
+func (r *CertificateReconciler) createOrUpdateAdditionalSecrets(ctx context.Context, certificate *v1alpha1.Certificate, tlsData certhandler.TLSData, namespace string) (metav1.Condition, error) {
+	for _, secretFormat := range certificate.Spec.AdditionalFormats {
+		secret, err := certhandler.FormatSecret(tlsData, secretFormat.Format, secretFormat.SecretName, namespace)
+		if err != nil {
+			return errorCondition(ConditionEncodeSecretFailed, err), fmt.Errorf(errCreateOrUpdateFormatSecret, secretFormat.Format, err)
+		}
+
+		if err := r.setOwner(certificate, secret); err != nil {
+			return errorCondition(ConditionSetOwnerRefFailed, err), fmt.Errorf(errFailedToSetOwnerRefForSecret+": %v", secret.Name, err)
+		}
+
+		if condition, err := r.checkSecretOwnership(ctx, certificate, secret); err != nil {
+			return condition, fmt.Errorf(errCreateOrUpdateFormatSecret, secretFormat.Format, err)
+		}
+
+		if err := certhandler.CreateOrUpdateTLSSecret(ctx, r.Client, secret); err != nil {
+			return errorCondition(ConditionCreateOrUpdateTLSSecretFailed, err), fmt.Errorf(errCreateOrUpdateFormatSecret, secretFormat.Format, err)
+		}
+	}
+
+	return metav1.Condition{}, nil
+}
+
+// setOwner marks the object, a secret or ConfigMap, as belonging to the certificate. The certificate is set as the
+// owner of the object, unless SetOwnerReference is disabled, in which case the object is labeled with the name of the
+// certificate instead.
+func (r *CertificateReconciler) setOwner(certificate *v1alpha1.Certificate, object client.Object) error {
+	if !setOwnerReference(certificate) {
+		labels := object.GetLabels()
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labels[LabelCertificate] = certificate.Name
+		object.SetLabels(labels)
+		return nil
+	}
+
+	return controllerutil.SetOwnerReference(certificate, object, r.Scheme)
+}
+
+// setOwnerReference checks if the certificate should own its secrets, which it does unless explicitly disabled.
+func setOwnerReference(certificate *v1alpha1.Certificate) bool {
+	return certificate.Spec.SetOwnerReference == nil || *certificate.Spec.SetOwnerReference
+}
+
+// createOrUpdateConfigMap creates or updates the ConfigMap publishing the certificate and CA certificates, if
+// PublishToConfigMap is set, and associates it with the certificate.
+// It returns an error if the creation or update operation fails.
+func (r *CertificateReconciler) createOrUpdateConfigMap(ctx context.Context, certificate *v1alpha1.Certificate, tlsData certhandler.TLSData, namespace string) (metav1.Condition, error) {
+	if certificate.Spec.PublishToConfigMap == "" {
+		return metav1.Condition{}, nil
+	}
+
+	configMap := certhandler.CertConfigMap(tlsData, certificate.Spec.PublishToConfigMap, namespace)
+	if err := r.setOwner(certificate, configMap); err != nil {
+		return errorCondition(ConditionSetOwnerRefFailed, err), fmt.Errorf(errCreateOrUpdateConfigMap, err)
+	}
+
+	if condition, err := r.checkConfigMapOwnership(ctx, certificate, configMap); err != nil {
+		return condition, fmt.Errorf(errCreateOrUpdateConfigMap, err)
+	}
+
+	if err := certhandler.CreateOrUpdateConfigMap(ctx, r.Client, configMap); err != nil {
+		return errorCondition(ConditionCreateOrUpdateConfigMapFailed, err), fmt.Errorf(errCreateOrUpdateConfigMap, err)
+	}
+
+	return metav1.Condition{}, nil
+}
+
+// resolveSecretName resolves the name of the TLS secret of the Certificate into its status.
+// The name is rendered from SecretNameTemplate if it is set, and is taken from SecretName otherwise.
+// It returns an error if the template cannot be rendered or the resulting name is not a valid Secret name.
+func resolveSecretName(certificate *v1alpha1.Certificate) (metav1.Condition, error) {
+	name, err := renderSecretName(certificate)
+	if err != nil {
+		return errorCondition(ConditionInvalidSecretName, err), err
+	}
+
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		err := fmt.Errorf(errInvalidSecretName, name, strings.Join(errs, ", "))
+		return errorCondition(ConditionInvalidSecretName, err), err
+	}
+
+	certificate.Status.SecretName = name
+	return metav1.Condition{}, nil
+}
+
+// validateSANCount returns an error if the Certificate has more SAN entries than allowed by the CertificateConfig.
+func validateSANCount(certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig) (metav1.Condition, error) {
+	san := certificate.Spec.CertificateData.San
+	count := len(san.DNS) + len(san.IPs)
+
+	maxEntries := DefaultMaxSANEntries
+	if certificateConfig.Spec.MaxSANEntries != nil {
+		maxEntries = int(*certificateConfig.Spec.MaxSANEntries)
+	}
+
+	if count > maxEntries {
+		err := fmt.Errorf(errTooManySANEntries, count, maxEntries)
+		return errorCondition(ConditionTooManySANEntries, err), err
+	}
+
+	return metav1.Condition{}, nil
+}
+
+// validateTemplate returns an error if the template requested by the Certificate is not allowed in its namespace by
+// the template policies of the CertificateConfig. The policies are enforced by the validating webhook as well, but it
+// may be disabled, or may have admitted the Certificate before the policies changed.
+// The returned condition is empty if the policies could not be evaluated.
+func (r *CertificateReconciler) validateTemplate(ctx context.Context, certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig) (metav1.Condition, error) {
+	allowedTemplates, restricted, err := common.AllowedTemplates(ctx, r.Client, certificateConfig, certificate.Namespace)
+	if err != nil {
+		return metav1.Condition{}, err
+	}
+
+	requested := certificate.Spec.CertificateData.Template
+	if restricted && !slices.Contains(allowedTemplates, requested) {
+		err := fmt.Errorf(errTemplateNotAllowed, requested, certificate.Namespace, certificateConfig.Name, allowedTemplates)
+		return errorCondition(ConditionTemplateNotAllowed, err), err
+	}
+
+	return metav1.Condition{}, nil
+}
+
+// renderSecretName renders the SecretNameTemplate of the Certificate against the Certificate itself,
+// or returns the literal SecretName if no template is set.
+func renderSecretName(certificate *v1alpha1.Certificate) (string, error) {
+	if certificate.Spec.SecretNameTemplate == "" {
+		return certificate.Spec.SecretName, nil
+	}
+
+	tmpl, err := template.New("secretName").Option("missingkey=error").Parse(certificate.Spec.SecretNameTemplate)
+	if err != nil {
+		return "", fmt.Errorf(errParseSecretNameTemplate, err)
+	}
+
+	var name strings.Builder
+	if err := tmpl.Execute(&name, certificate); err != nil {
+		return "", fmt.Errorf(errRenderSecretNameTemplate, err)
+	}
+
+	return name.String(), nil
+}
+
+// checkSecretOwnership checks whether an existing secret may be overwritten by the certificate.
+// An existing secret which is not owned by the certificate is adopted if AdoptExisting is set, and refused otherwise.
+func (r *CertificateReconciler) checkSecretOwnership(ctx context.Context, certificate *v1alpha1.Certificate, secret *corev1.Secret) (metav1.Condition, error) {
+	existingSecret := &corev1.Secret{}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(secret), existingSecret); err != nil {
+		if errors.IsNotFound(err) {
+			return metav1.Condition{}, nil
+		}
+		return errorCondition(ConditionCreateOrUpdateTLSSecretFailed, err), fmt.Errorf(errGetExistingSecret, secret.Name, err)
+	}
+
+	return r.checkOwnership(ctx, certificate, existingSecret, fmt.Errorf(errSecretNotOwned, secret.Name))
+}
+
+// checkConfigMapOwnership checks whether an existing ConfigMap may be overwritten by the certificate, like secrets.
+func (r *CertificateReconciler) checkConfigMapOwnership(ctx context.Context, certificate *v1alpha1.Certificate, configMap *corev1.ConfigMap) (metav1.Condition, error) {
+	existingConfigMap := &corev1.ConfigMap{}
+	if err := r.Client.Get(ctx, client.ObjectKeyFromObject(configMap), existingConfigMap); err != nil {
+		if errors.IsNotFound(err) {
+			return metav1.Condition{}, nil
+		}
+		return errorCondition(ConditionCreateOrUpdateConfigMapFailed, err), fmt.Errorf(errGetExistingConfigMap, configMap.Name, err)
+	}
+
+	return r.checkOwnership(ctx, certificate, existingConfigMap, fmt.Errorf(errConfigMapNotOwned, configMap.Name))
+}
+
+// checkOwnership checks whether the existing object may be overwritten by the certificate. It may if it is owned by
+// the certificate, or if AdoptExisting is set, and the notOwnedErr is returned otherwise.
+func (r *CertificateReconciler) checkOwnership(ctx context.Context, certificate *v1alpha1.Certificate, existing client.Object, notOwnedErr error) (metav1.Condition, error) {
+	if isOwnedByCertificate(existing, certificate) {
+		return metav1.Condition{}, nil
+	}
+
+	if !certificate.Spec.AdoptExisting {
+		return errorCondition(ConditionSecretNotOwned, notOwnedErr), notOwnedErr
+	}
+
+	r.logger(ctx).Info("adopting existing object", "name", existing.GetName())
+	return metav1.Condition{}, nil
+}
+
+// hasSubjectDrifted checks whether the certificate in the TLS secret of the Certificate no longer matches the
+// CommonName or SANs requested in its spec, e.g. because the spec was edited after the certificate was issued.
+// A missing secret, or one without a parsable certificate, is not considered drifted.
+func (r *CertificateReconciler) hasSubjectDrifted(ctx context.Context, certificate *v1alpha1.Certificate) (bool, error) {
+	secret := &corev1.Secret{}
+	key := client.ObjectKey{Name: certificate.Status.SecretName, Namespace: certificate.Namespace}
+	if err := r.Client.Get(ctx, key, secret); err != nil {
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf(errGetExistingSecret, key.Name, err)
+	}
+
+	liveCertificate, err := certhandler.ParseSecretCertificate(secret)
+	if err != nil {
+		r.logger(ctx).Info("cannot parse the certificate of the secret, skipping drift detection", "secret", key.Name, "error", err.Error())
+		return false, nil
+	}
+
+	return subjectDrifted(liveCertificate, certificate.Spec.CertificateData), nil
+}
+
+// isSecretMissing checks whether the TLS secret of the Certificate does not exist, e.g. because it was deleted while
+// the certificate is still valid. It returns an error if the get operation fails for another reason.
+func (r *CertificateReconciler) isSecretMissing(ctx context.Context, certificate *v1alpha1.Certificate) (bool, error) {
+	key := client.ObjectKey{Name: certificate.Status.SecretName, Namespace: certificate.Namespace}
+	if err := r.Client.Get(ctx, key, &corev1.Secret{}); err != nil {
+		if errors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, fmt.Errorf(errGetExistingSecret, key.Name, err)
+	}
+
+	return false, nil
+}
+
+// subjectDrifted checks whether the CommonName, DNS names or IPs of the certificate differ from the requested ones.
+// DNS names are compared case-insensitively and IPs by value, regardless of their order. A DNS name equal to the
+// CommonName is allowed in the certificate without being requested, since CAs commonly add it.
+func subjectDrifted(liveCertificate *x509.Certificate, certificateData v1alpha1.CertificateData) bool {
+	commonName := certificateData.Subject.CommonName
+	if liveCertificate.Subject.CommonName != commonName {
+		return true
+	}
+
+	requestedDNSNames := map[string]bool{}
+	for _, name := range certificateData.San.DNS {
+		requestedDNSNames[strings.ToLower(name)] = true
+	}
+
+	liveDNSNames := map[string]bool{}
+	for _, name := range liveCertificate.DNSNames {
+		name = strings.ToLower(name)
+		if !requestedDNSNames[name] && name != strings.ToLower(commonName) {
+			return true
+		}
+		liveDNSNames[name] = true
+	}
+
+	for name := range requestedDNSNames {
+		if !liveDNSNames[name] {
+			return true
+		}
+	}
+
+	requestedIPs := map[string]bool{}
+	for _, ip := range certificateData.San.IPs {
+		if parsed := net.ParseIP(ip); parsed != nil {
+			requestedIPs[parsed.String()] = true
+		}
+	}
+
+	liveIPs := map[string]bool{}
+	for _, ip := range liveCertificate.IPAddresses {
+		liveIPs[ip.String()] = true
+	}
+
+	return !maps.Equal(requestedIPs, liveIPs)
+}
+
+// handleDeletionPolicy applies the DeletionPolicy of the Certificate to its Secrets and ConfigMap through the finalizer
+// returned by deletionFinalizer. With the Orphan policy, the finalizer is removed on deletion once the owner references
+// of the Certificate are removed from them, so that they are not garbage collected. With the Delete policy, Secrets
+// and a ConfigMap which are labeled with the name of the Certificate rather than owned by it, since SetOwnerReference
+// is disabled, are deleted before the finalizer is removed, as the garbage collector does not delete them.
+// It returns whether the Certificate is being deleted.
+func (r *CertificateReconciler) handleDeletionPolicy(ctx context.Context, certificate *v1alpha1.Certificate) (bool, error) {
+	finalizer := deletionFinalizer(certificate)
+
+	if certificate.DeletionTimestamp.IsZero() {
+		changed := false
+		for _, other := range []string{FinalizerOrphanSecrets, FinalizerCleanupSecrets} {
+			if other != finalizer && controllerutil.RemoveFinalizer(certificate, other) {
+				changed = true
+			}
+		}
+		if finalizer != "" && controllerutil.AddFinalizer(certificate, finalizer) {
+			changed = true
+		}
+		if !changed {
+			return false, nil
+		}
+
+		if err := r.Update(ctx, certificate); err != nil {
+			return false, fmt.Errorf(errUpdateFinalizers, err)
+		}
+
+		return false, nil
+	}
+
+	if !controllerutil.ContainsFinalizer(certificate, FinalizerOrphanSecrets) && !controllerutil.ContainsFinalizer(certificate, FinalizerCleanupSecrets) {
+		return true, nil
+	}
+
+	switch {
+	case finalizer == FinalizerOrphanSecrets && controllerutil.ContainsFinalizer(certificate, FinalizerOrphanSecrets):
+		if err := r.orphanOwnedObjects(ctx, certificate); err != nil {
+			return true, err
+		}
+		r.logger(ctx).Info("removed the owner references of the deleted Certificate from its secrets")
+	case finalizer == FinalizerCleanupSecrets && controllerutil.ContainsFinalizer(certificate, FinalizerCleanupSecrets):
+		if err := r.deleteLabeledObjects(ctx, certificate); err != nil {
+			return true, err
+		}
+		r.logger(ctx).Info("deleted the secrets labeled with the name of the deleted Certificate")
+	}
+
+	controllerutil.RemoveFinalizer(certificate, FinalizerOrphanSecrets)
+	controllerutil.RemoveFinalizer(certificate, FinalizerCleanupSecrets)
+	if err := r.Update(ctx, certificate); err != nil {
+		return true, fmt.Errorf(errUpdateFinalizers, err)
+	}
+
+	return true, nil
+}
+
+// deletionFinalizer returns the finalizer the Certificate holds to apply its DeletionPolicy, if any. It is the
+// FinalizerOrphanSecrets with the Orphan policy, and the FinalizerCleanupSecrets with the Delete policy if
+// SetOwnerReference is disabled. No finalizer is needed to garbage collect owned objects with the Delete policy.
+func deletionFinalizer(certificate *v1alpha1.Certificate) string {
+	switch {
+	case certificate.Spec.DeletionPolicy == v1alpha1.DeletionPolicyOrphan:
+		return FinalizerOrphanSecrets
+	case !setOwnerReference(certificate):
+		return FinalizerCleanupSecrets
+	}
+
+	return ""
+}
+
+// certificateObjects returns the TLS secret, the secrets of the additional formats and the ConfigMap of the
+// Certificate, identified by their names only.
+func certificateObjects(certificate *v1alpha1.Certificate) []client.Object {
+	secretName := certificate.Status.SecretName
+	if secretName == "" {
+		secretName = certificate.Spec.SecretName
+	}
+
+	var objects []client.Object
+	if secretName != "" {
+		objects = append(objects, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: certificate.Namespace}})
+	}
+	for _, secretFormat := range certificate.Spec.AdditionalFormats {
+		objects = append(objects, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretFormat.SecretName, Namespace: certificate.Namespace}})
+	}
+	if certificate.Spec.PublishToConfigMap != "" {
+		objects = append(objects, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: certificate.Spec.PublishToConfigMap, Namespace: certificate.Namespace}})
+	}
+
+	return objects
+}
+
+// orphanOwnedObjects removes the owner references of the Certificate from its TLS secret, the secrets of its
+// additional formats and its ConfigMap, so that they are not garbage collected along with it.
+// Objects which do not exist are skipped. It returns an error if any get or update operation fails.
+func (r *CertificateReconciler) orphanOwnedObjects(ctx context.Context, certificate *v1alpha1.Certificate) error {
+	for _, object := range certificateObjects(certificate) {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(object), object); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf(errOrphanObject, object.GetName(), err)
+		}
+
+		var refs []metav1.OwnerReference
+		for _, ref := range object.GetOwnerReferences() {
+			if ref.UID != certificate.UID {
+				refs = append(refs, ref)
+			}
+		}
+		if len(refs) == len(object.GetOwnerReferences()) {
+			continue
+		}
+
+		object.SetOwnerReferences(refs)
+		if err := r.Update(ctx, object); err != nil {
+			return fmt.Errorf(errOrphanObject, object.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// deleteLabeledObjects deletes the TLS secret, the secrets of the additional formats and the ConfigMap of the
+// Certificate which are labeled with its name. Objects which do not exist or are not labeled with the name of the
+// Certificate are skipped. It returns an error if any get or delete operation fails.
+func (r *CertificateReconciler) deleteLabeledObjects(ctx context.Context, certificate *v1alpha1.Certificate) error {
+	for _, object := range certificateObjects(certificate) {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(object), object); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return fmt.Errorf(errCleanupObject, object.GetName(), err)
+		}
+
+		if object.GetLabels()[LabelCertificate] != certificate.Name {
+			continue
+		}
+
+		if err := r.Delete(ctx, object); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf(errCleanupObject, object.GetName(), err)
+		}
+	}
+
+	return nil
+}
+
+// isOwnedByCertificate checks if the object has an owner reference to the certificate, or, if the certificate does not
+// set owner references, whether the object is labeled with its name.
+func isOwnedByCertificate(object client.Object, certificate *v1alpha1.Certificate) bool {
+	if !setOwnerReference(certificate) && object.GetLabels()[LabelCertificate] == certificate.Name {
+		return true
+	}
+
+	for _, ref := range object.GetOwnerReferences() {
+		if ref.Kind == certificateKind && ref.Name == certificate.Name && ref.UID == certificate.UID {
+			return true
+		}
+	}
+
+	return false
+}
+
+// updateExpiredCondition sets the Expired condition of the Certificate according to its ValidTo time,
+// independently of the Error condition, and updates the status if the condition changed.
+// It returns an error if the status update operation fails.
+func (r *CertificateReconciler) updateExpiredCondition(ctx context.Context, certificate *v1alpha1.Certificate) error {
+	if !meta.SetStatusCondition(&certificate.Status.Conditions, expiredCondition(certificate, time.Now())) {
+		return nil
+	}
+
+	if err := r.patchStatus(ctx, certificate); err != nil {
+		return fmt.Errorf(errUpdateStatus, err)
+	}
+
+	return nil
+}
+
+// expiredCondition returns the Expired condition of the Certificate at the given time.
+func expiredCondition(certificate *v1alpha1.Certificate, now time.Time) metav1.Condition {
+	validTo := certificate.Status.ValidTo
+	if !validTo.IsZero() && now.After(validTo.Time) {
+		return metav1.Condition{
+			Type:    ConditionExpired,
+			Status:  metav1.ConditionTrue,
+			Reason:  reasonCertificateExpired,
+			Message: fmt.Sprintf("certificate expired at %s", validTo.Format(time.RFC3339)),
+		}
+	}
+
+	return metav1.Condition{
+		Type:    ConditionExpired,
+		Status:  metav1.ConditionFalse,
+		Reason:  reasonCertificateNotExpired,
+		Message: "certificate has not expired",
+	}
+}
+
+// syncedCondition returns the Synced condition of a Certificate. The reason is that of the first failed step if err is set,
+// and that of the furthest successful step otherwise. The message of a failed step is truncated to the configured length.
+func (r *CertificateReconciler) syncedCondition(reason string, err error) metav1.Condition {
+	if err != nil {
+		return metav1.Condition{
+			Type:    ConditionSynced,
+			Status:  metav1.ConditionFalse,
+			Reason:  reason,
+			Message: truncateMessage(err.Error(), maxConditionMessageLength(r.MaxConditionMessageLength)),
+		}
+	}
+
+	return metav1.Condition{
+		Type:    ConditionSynced,
+		Status:  metav1.ConditionTrue,
+		Reason:  reason,
+		Message: "certificate is synced with the Cert API",
+	}
+}
+
+// errorCondition returns the Error condition with the reason and the message of the error. Its type is the bare
+// ConditionError: updateCertificateConditions prefixes it with the ConditionTypePrefix of the reconciler.
 func errorCondition(reason string, err error) metav1.Condition {
 	return metav1.Condition{
 		Type:    ConditionError,
@@ -128,3 +1003,39 @@ func errorCondition(reason string, err error) metav1.Condition {
 		Message: err.Error(),
 	}
 }
+
+// truncateMessage truncates the message to at most maxLength bytes, keeping its head and appending an ellipsis.
+// The message is returned unchanged if maxLength is not positive.
+func truncateMessage(message string, maxLength int) string {
+	if maxLength <= 0 || len(message) <= maxLength {
+		return message
+	}
+
+	if maxLength <= len(ellipsis) {
+		return ellipsis[:maxLength]
+	}
+
+	cut := maxLength - len(ellipsis)
+	for cut > 0 && !utf8.RuneStart(message[cut]) {
+		cut--
+	}
+
+	return message[:cut] + ellipsis
+}
+
+// truncateConditionMessages truncates the messages of the conditions to at most maxLength bytes.
+func truncateConditionMessages(conditions []metav1.Condition, maxLength int) {
+	for i := range conditions {
+		conditions[i].Message = truncateMessage(conditions[i].Message, maxLength)
+	}
+}
+
+// maxConditionMessageLength returns the maximum length of condition messages configured for a reconciler, which
+// defaults to DefaultMaxConditionMessageLength. Messages are not truncated if it is negative.
+func maxConditionMessageLength(configured int) int {
+	if configured == 0 {
+		return DefaultMaxConditionMessageLength
+	}
+
+	return configured
+}
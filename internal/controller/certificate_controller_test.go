@@ -0,0 +1,2539 @@
+package controller
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+	"github.com/dana-team/certificate-operator/internal/clients/notification"
+	"github.com/dana-team/certificate-operator/internal/common"
+	"github.com/dana-team/certificate-operator/internal/metrics"
+	"github.com/dana-team/certificate-operator/internal/tracing"
+	"github.com/dana-team/certificate-operator/internal/tracing/tracingtest"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func Test_ReconcilePaused(t *testing.T) {
+	paused := certificate.DeepCopy()
+	paused.Annotations = map[string]string{AnnotationPaused: "true"}
+
+	resumed := certificate.DeepCopy()
+	resumed.Status.ValidTo = metav1.NewTime(time.Now().AddDate(1, 0, 0))
+	resumed.Status.Conditions = []metav1.Condition{{Type: ConditionPaused, Status: metav1.ConditionTrue, Reason: reasonReconcilePaused}}
+
+	type args struct {
+		certificate *v1alpha1.Certificate
+	}
+	type want struct {
+		paused        bool
+		clientBuilt   bool
+		statusUpdated bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldSkipPausedCertificate": {
+			args: args{
+				certificate: paused,
+			},
+			want: want{
+				paused:        true,
+				clientBuilt:   false,
+				statusUpdated: true,
+			},
+		},
+		"ShouldReconcileResumedCertificate": {
+			args: args{
+				certificate: resumed,
+			},
+			want: want{
+				paused:        false,
+				clientBuilt:   true,
+				statusUpdated: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.Certificate
+			var clientBuilt, statusUpdated bool
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							tc.args.certificate.DeepCopyInto(o)
+							got = o
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockStatusPatch: func(context.Context, client.Object, client.Patch, ...client.SubResourcePatchOption) error {
+						statusUpdated = true
+						return nil
+					},
+				},
+				Scheme: runtime.NewScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					clientBuilt = true
+					return &MockCertClient{}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("Reconcile(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.paused, meta.IsStatusConditionTrue(got.Status.Conditions, ConditionPaused)); diff != "" {
+				t.Fatalf("Reconcile(...): -want paused condition, +got paused condition: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.clientBuilt, clientBuilt); diff != "" {
+				t.Fatalf("Reconcile(...): -want client built, +got client built: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.statusUpdated, statusUpdated); diff != "" {
+				t.Fatalf("Reconcile(...): -want status updated, +got status updated: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_certificatesInErrorGauge(t *testing.T) {
+	r := &CertificateReconciler{
+		Client: &test.MockClient{
+			MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+		},
+		Scheme: runtime.NewScheme(),
+		Log:    logr.Logger{},
+	}
+
+	errored := certificate.DeepCopy()
+	errored.Name = "errored-cert"
+	before := metricValue(t, metrics.CertificatesInError)
+
+	if err := r.updateCertificateConditions(context.Background(), errored, errorCondition(ConditionPostToCertAPIFailed, errBoom)); err != nil {
+		t.Fatalf("updateCertificateConditions(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(before+1, metricValue(t, metrics.CertificatesInError)); diff != "" {
+		t.Fatalf("updateCertificateConditions(...): -want gauge, +got gauge: %v", diff)
+	}
+
+	if err := r.removeErrorConditions(context.Background(), errored); err != nil {
+		t.Fatalf("removeErrorConditions(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(before, metricValue(t, metrics.CertificatesInError)); diff != "" {
+		t.Fatalf("removeErrorConditions(...): -want gauge, +got gauge: %v", diff)
+	}
+}
+
+// metricValue returns the current value of a counter or gauge.
+func metricValue(t *testing.T, metric prometheus.Metric) float64 {
+	t.Helper()
+
+	m := &dto.Metric{}
+	if err := metric.Write(m); err != nil {
+		t.Fatalf("Write(...): unexpected error: %v", err)
+	}
+
+	if m.Counter != nil {
+		return m.GetCounter().GetValue()
+	}
+
+	return m.GetGauge().GetValue()
+}
+
+func Test_ReconcileInvalidCredentials(t *testing.T) {
+	type args struct {
+		credentials string
+	}
+	type want struct {
+		message string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldReportMissingAPIEndpoint": {
+			args: args{
+				credentials: `{"downloadEndpoint": "/down", "token": "jwt-token"}`,
+			},
+			want: want{
+				message: `failed to build Cert client: missing API Endpoint, expected the "apiEndpoint" field of the CertificateConfig or key in secret`,
+			},
+		},
+		"ShouldReportMissingDownloadEndpoint": {
+			args: args{
+				credentials: `{"apiEndpoint": "https://cert.com/", "token": "jwt-token"}`,
+			},
+			want: want{
+				message: `failed to build Cert client: missing Download API Endpoint, expected the "downloadEndpoint" field of the CertificateConfig or key in secret`,
+			},
+		},
+		"ShouldReportMissingToken": {
+			args: args{
+				credentials: `{"apiEndpoint": "https://cert.com/", "downloadEndpoint": "/down"}`,
+			},
+			want: want{
+				message: `failed to build Cert client: missing token in secret, expected the "token" or "tokenFile" key`,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.Certificate
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							certificate.DeepCopyInto(o)
+							got = o
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.Data = map[string][]byte{"credentials": []byte(tc.args.credentials)}
+						}
+						return nil
+					},
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme:            runtime.NewScheme(),
+				Log:               logr.Logger{},
+				CertClientBuilder: cert.NewClientFromCertificateConfigAndSecretData,
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			result, err := r.Reconcile(context.Background(), req)
+			if err != nil {
+				t.Fatalf("Reconcile(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(ctrl.Result{RequeueAfter: DefaultTerminalErrorRequeueAfter}, result); diff != "" {
+				t.Fatalf("Reconcile(...): -want result, +got result: %v", diff)
+			}
+
+			want := metav1.Condition{
+				Type:    ConditionError,
+				Status:  metav1.ConditionTrue,
+				Reason:  ConditionCredentialsInvalid,
+				Message: tc.want.message,
+			}
+			if diff := cmp.Diff(&want, meta.FindStatusCondition(got.Status.Conditions, ConditionError), cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime")); diff != "" {
+				t.Fatalf("Reconcile(...): -want condition, +got condition: %v", diff)
+			}
+		})
+	}
+}
+
+type MockNotifier struct {
+	MockNotify func(ctx context.Context, url string, event notification.Event) error
+}
+
+func (n *MockNotifier) Notify(ctx context.Context, url string, event notification.Event) error {
+	return n.MockNotify(ctx, url, event)
+}
+
+func Test_failIssuance(t *testing.T) {
+	const notificationURL = "https://notifications.example.com"
+
+	withURL := certificateConfig.DeepCopy()
+	withURL.Spec.NotificationURL = notificationURL
+
+	type args struct {
+		certificateConfig *v1alpha1.CertificateConfig
+		conditions        []metav1.Condition
+		notifyErr         error
+	}
+	type want struct {
+		notified bool
+		err      error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldNotifyFailure": {
+			args: args{
+				certificateConfig: withURL,
+			},
+			want: want{
+				notified: true,
+			},
+		},
+		"ShouldNotNotifyWithoutNotificationURL": {
+			args: args{
+				certificateConfig: &certificateConfig,
+			},
+			want: want{
+				notified: false,
+			},
+		},
+		"ShouldNotNotifyWhileStillFailed": {
+			args: args{
+				certificateConfig: withURL,
+				conditions:        []metav1.Condition{condition(ConditionDownloadCertFromCertAPIFailed, errBoom)},
+			},
+			want: want{
+				notified: false,
+			},
+		},
+		"ShouldNotifyFailureAfterRecovery": {
+			args: args{
+				certificateConfig: withURL,
+				conditions:        []metav1.Condition{(&CertificateReconciler{}).syncedCondition(reasonSecretUpdated, nil)},
+			},
+			want: want{
+				notified: true,
+			},
+		},
+		"ShouldIgnoreNotificationFailure": {
+			args: args{
+				certificateConfig: withURL,
+				notifyErr:         errBoom,
+			},
+			want: want{
+				notified: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var notified bool
+			var gotURL string
+			var gotEvent notification.Event
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Log: logr.Logger{},
+				Notifier: &MockNotifier{
+					MockNotify: func(_ context.Context, url string, event notification.Event) error {
+						notified = true
+						gotURL = url
+						gotEvent = event
+						return tc.args.notifyErr
+					},
+				},
+			}
+
+			current := certificate.DeepCopy()
+			current.Status.Conditions = tc.args.conditions
+
+			failed := condition(ConditionPostToCertAPIFailed, errBoom)
+			err := r.failIssuance(context.Background(), current, tc.args.certificateConfig, failed)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("failIssuance(...): -want error, +got error: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.notified, notified); diff != "" {
+				t.Fatalf("failIssuance(...): -want notified, +got notified: %v", diff)
+			}
+			if !notified {
+				return
+			}
+
+			if diff := cmp.Diff(notificationURL, gotURL); diff != "" {
+				t.Errorf("failIssuance(...): -want url, +got url: %v", diff)
+			}
+			if diff := cmp.Diff(notification.EventFailed, gotEvent.Type); diff != "" {
+				t.Errorf("failIssuance(...): -want event type, +got event type: %v", diff)
+			}
+			if diff := cmp.Diff(&failed, gotEvent.Condition); diff != "" {
+				t.Errorf("failIssuance(...): -want condition, +got condition: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileTerminalError(t *testing.T) {
+	type args struct {
+		postErr error
+	}
+	type want struct {
+		result              ctrl.Result
+		hasErr              bool
+		postsAfterRetry     int
+		postsAfterNewConfig int
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldNotRetryTerminalErrorUntilChanged": {
+			args: args{
+				postErr: &httpClient.APIError{StatusCode: http.StatusBadRequest},
+			},
+			want: want{
+				result:              ctrl.Result{RequeueAfter: time.Hour},
+				hasErr:              false,
+				postsAfterRetry:     1,
+				postsAfterNewConfig: 2,
+			},
+		},
+		"ShouldRetryTransientError": {
+			args: args{
+				postErr: errBoom,
+			},
+			want: want{
+				result:              ctrl.Result{},
+				hasErr:              true,
+				postsAfterRetry:     2,
+				postsAfterNewConfig: 3,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := certificateConfig.DeepCopy()
+			posts := 0
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							certificate.DeepCopyInto(o)
+						case *v1alpha1.CertificateConfig:
+							config.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: runtime.NewScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							posts++
+							return cert.PostCertificateResponse{}, tc.args.postErr
+						},
+					}, nil
+				},
+				TerminalErrorRequeueAfter: time.Hour,
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			result, err := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Fatalf("Reconcile(...): -want result, +got result: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.hasErr, err != nil); diff != "" {
+				t.Fatalf("Reconcile(...): -want error, +got error: %v", diff)
+			}
+
+			_, _ = r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.postsAfterRetry, posts); diff != "" {
+				t.Fatalf("Reconcile(...): -want posts after retry, +got posts after retry: %v", diff)
+			}
+
+			config.Generation++
+			_, _ = r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.postsAfterNewConfig, posts); diff != "" {
+				t.Fatalf("Reconcile(...): -want posts after config change, +got posts after config change: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileSyncedCondition(t *testing.T) {
+	valid := certificate.DeepCopy()
+	valid.Status.ValidTo = metav1.NewTime(time.Now().AddDate(1, 0, 0))
+
+	validTo := time.Now().AddDate(1, 0, 0).Format(timeFormat)
+	validFrom := time.Now().Format(timeFormat)
+
+	type args struct {
+		certificate  *v1alpha1.Certificate
+		secretExists bool
+		postErr      error
+		getErr       error
+		downloadErr  error
+		applyErr     error
+	}
+	type want struct {
+		condition metav1.Condition
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldReportSecretUpdated": {
+			args: args{
+				certificate: certificate.DeepCopy(),
+			},
+			want: want{
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionTrue, Reason: reasonSecretUpdated},
+			},
+		},
+		"ShouldReportCertificateValid": {
+			args: args{
+				certificate:  valid,
+				secretExists: true,
+			},
+			want: want{
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionTrue, Reason: reasonCertificateValid},
+			},
+		},
+		"ShouldReportPostFailed": {
+			args: args{
+				certificate: certificate.DeepCopy(),
+				postErr:     errBoom,
+			},
+			want: want{
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionFalse, Reason: reasonPostFailed},
+			},
+		},
+		"ShouldReportPollFailed": {
+			args: args{
+				certificate: certificate.DeepCopy(),
+				getErr:      errBoom,
+			},
+			want: want{
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionFalse, Reason: reasonPollFailed},
+			},
+		},
+		"ShouldReportDownloadFailed": {
+			args: args{
+				certificate: certificate.DeepCopy(),
+				downloadErr: errBoom,
+			},
+			want: want{
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionFalse, Reason: reasonDownloadFailed},
+			},
+		},
+		"ShouldReportSecretUpdateFailed": {
+			args: args{
+				certificate: certificate.DeepCopy(),
+				applyErr:    errBoom,
+			},
+			want: want{
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionFalse, Reason: reasonSecretUpdateFailed},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.Certificate
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							tc.args.certificate.DeepCopyInto(o)
+							got = o
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							if key.Name != certificateConfig.Spec.SecretRef.Name && !(tc.args.secretExists && key.Name == certificate.Spec.SecretName) {
+								return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+							}
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockPatch:       test.NewMockPatchFn(tc.args.applyErr),
+					MockUpdate:      test.NewMockUpdateFn(nil),
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: newScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							return cert.PostCertificateResponse{Guid: guid}, tc.args.postErr
+						},
+						MockGetCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.GetCertificateResponse, error) {
+							return cert.GetCertificateResponse{ValidTo: validTo, ValidFrom: validFrom}, tc.args.getErr
+						},
+						MockDownloadCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.DownloadCertificateResponse, error) {
+							return cert.DownloadCertificateResponse{Data: validPKCS12Data, Password: validPKCS12Password}, tc.args.downloadErr
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			_, _ = r.Reconcile(context.Background(), req)
+
+			synced := meta.FindStatusCondition(got.Status.Conditions, ConditionSynced)
+			if synced == nil {
+				t.Fatalf("Reconcile(...): missing %s condition", ConditionSynced)
+			}
+			if diff := cmp.Diff(tc.want.condition, *synced, cmpopts.IgnoreFields(metav1.Condition{}, "Message", "LastTransitionTime")); diff != "" {
+				t.Fatalf("Reconcile(...): -want condition, +got condition: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileLastReconcileTime(t *testing.T) {
+	previous := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	valid := certificate.DeepCopy()
+	valid.Status.ValidTo = metav1.NewTime(time.Now().AddDate(1, 0, 0))
+	valid.Status.LastReconcileTime = previous
+
+	issuing := certificate.DeepCopy()
+	issuing.Status.LastReconcileTime = previous
+
+	validTo := time.Now().AddDate(1, 0, 0).Format(timeFormat)
+	validFrom := time.Now().Format(timeFormat)
+
+	type args struct {
+		certificate *v1alpha1.Certificate
+		postErr     error
+	}
+	type want struct {
+		advanced bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldAdvanceWhenCertificateIsIssued": {
+			args: args{
+				certificate: issuing,
+			},
+			want: want{
+				advanced: true,
+			},
+		},
+		"ShouldAdvanceWhenCertificateIsValid": {
+			args: args{
+				certificate: valid,
+			},
+			want: want{
+				advanced: true,
+			},
+		},
+		"ShouldNotAdvanceOnFailure": {
+			args: args{
+				certificate: issuing,
+				postErr:     errBoom,
+			},
+			want: want{
+				advanced: false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.Certificate
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							tc.args.certificate.DeepCopyInto(o)
+							got = o
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							if key.Name != certificateConfig.Spec.SecretRef.Name {
+								return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+							}
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockPatch:       test.NewMockPatchFn(nil),
+					MockUpdate:      test.NewMockUpdateFn(nil),
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: newScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							return cert.PostCertificateResponse{Guid: guid}, tc.args.postErr
+						},
+						MockGetCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.GetCertificateResponse, error) {
+							return cert.GetCertificateResponse{ValidTo: validTo, ValidFrom: validFrom}, nil
+						},
+						MockDownloadCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.DownloadCertificateResponse, error) {
+							return cert.DownloadCertificateResponse{Data: validPKCS12Data, Password: validPKCS12Password}, nil
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			_, _ = r.Reconcile(context.Background(), req)
+
+			if diff := cmp.Diff(tc.want.advanced, got.Status.LastReconcileTime.After(previous.Time)); diff != "" {
+				t.Fatalf("Reconcile(...): -want advanced, +got advanced: %v", diff)
+			}
+			if !tc.want.advanced && !got.Status.LastReconcileTime.Equal(&previous) {
+				t.Fatalf("Reconcile(...): expected the last reconcile time to be kept, got %v", got.Status.LastReconcileTime)
+			}
+		})
+	}
+}
+
+func Test_ReconcileSteadyState(t *testing.T) {
+	current := certificate.DeepCopy()
+	current.Status.ValidTo = metav1.NewTime(time.Now().AddDate(1, 0, 0).Truncate(time.Second))
+	current.Status.Guid = guid
+
+	patches := 0
+	r := &CertificateReconciler{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+				switch o := obj.(type) {
+				case *v1alpha1.Certificate:
+					current.DeepCopyInto(o)
+				case *v1alpha1.CertificateConfig:
+					certificateConfig.DeepCopyInto(o)
+				case *corev1.Secret:
+					o.Data = map[string][]byte{}
+					if key.Name == current.Spec.SecretName {
+						o.Data[corev1.TLSCertKey] = selfSignedCertificatePEM(t, "example", []string{"www.example.com"}, []string{"192.168.1.1"})
+					}
+				}
+				return nil
+			},
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockStatusPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+				patches++
+				obj.(*v1alpha1.Certificate).DeepCopyInto(current)
+				return nil
+			},
+		},
+		Scheme: newScheme(),
+		Log:    logr.Discard(),
+		CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+			return &MockCertClient{}, nil
+		},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile(...): unexpected error: %v", err)
+	}
+	if patches == 0 {
+		t.Fatalf("Reconcile(...): expected the first reconcile to patch the status")
+	}
+	lastReconcileTime := current.Status.LastReconcileTime
+
+	patches = 0
+	for i := 0; i < 3; i++ {
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Reconcile(...): unexpected error: %v", err)
+		}
+	}
+	if diff := cmp.Diff(0, patches); diff != "" {
+		t.Fatalf("Reconcile(...): -want status patches, +got status patches: %v", diff)
+	}
+	if !current.Status.LastReconcileTime.Equal(&lastReconcileTime) {
+		t.Fatalf("Reconcile(...): expected the last reconcile time to be kept, got %v", current.Status.LastReconcileTime)
+	}
+
+	stale := metav1.NewTime(time.Now().Add(-2 * lastReconcileTimeInterval).Truncate(time.Second))
+	current.Status.LastReconcileTime = stale
+	patches = 0
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(1, patches); diff != "" {
+		t.Fatalf("Reconcile(...): -want status patches, +got status patches: %v", diff)
+	}
+	if !current.Status.LastReconcileTime.After(stale.Time) {
+		t.Fatalf("Reconcile(...): expected the stale last reconcile time to be advanced, got %v", current.Status.LastReconcileTime)
+	}
+}
+
+func Test_ReconcileCAEndpointUnreachable(t *testing.T) {
+	dnsError := &net.DNSError{Err: "no such host", Name: "cert.example.com", IsNotFound: true}
+	errTLS := fmt.Errorf("http request to %q failed: %v", "https://cert.example.com", "tls: failed to verify certificate")
+
+	type args struct {
+		postErr error
+	}
+	type want struct {
+		condition metav1.Condition
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldReportUnresolvableHost": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", fmt.Errorf("http request to %q failed: %w", "https://cert.example.com", dnsError)),
+			},
+			want: want{
+				condition: condition(ConditionCAEndpointUnreachable, fmt.Errorf(errCAEndpointUnreachable, "cert.example.com")),
+			},
+		},
+		"ShouldNotReportTLSError": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", errTLS),
+			},
+			want: want{
+				condition: condition(ConditionPostToCertAPIFailed, fmt.Errorf("POST to cert failed: %w", errTLS)),
+			},
+		},
+		"ShouldNotReportHTTPError": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusBadGateway}),
+			},
+			want: want{
+				condition: condition(ConditionPostToCertAPIFailed, fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusBadGateway})),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.Certificate
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							certificate.DeepCopyInto(o)
+							got = o
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: newScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							return cert.PostCertificateResponse{}, tc.args.postErr
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			_, _ = r.Reconcile(context.Background(), req)
+
+			errorCondition := meta.FindStatusCondition(got.Status.Conditions, ConditionError)
+			if errorCondition == nil {
+				t.Fatalf("Reconcile(...): missing %s condition", ConditionError)
+			}
+			if diff := cmp.Diff(tc.want.condition, *errorCondition, cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime")); diff != "" {
+				t.Fatalf("Reconcile(...): -want condition, +got condition: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileRateLimited(t *testing.T) {
+	type args struct {
+		postErr error
+	}
+	type want struct {
+		result    ctrl.Result
+		condition metav1.Condition
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldReportRateLimitWithRetryAfter": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second}),
+			},
+			want: want{
+				result:    ctrl.Result{RequeueAfter: 30 * time.Second},
+				condition: condition(ConditionRateLimited, fmt.Errorf(errRateLimitedRetryAfter, 30*time.Second, "failed to create Certificate: POST to cert failed: Too Many Requests")),
+			},
+		},
+		"ShouldReportRateLimitWithoutRetryAfter": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusTooManyRequests}),
+			},
+			want: want{
+				result:    ctrl.Result{},
+				condition: condition(ConditionRateLimited, fmt.Errorf(errRateLimited, "failed to create Certificate: POST to cert failed: Too Many Requests")),
+			},
+		},
+		"ShouldNotReportServiceUnavailable": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusServiceUnavailable, RetryAfter: 30 * time.Second}),
+			},
+			want: want{
+				result:    ctrl.Result{RequeueAfter: 30 * time.Second},
+				condition: condition(ConditionPostToCertAPIFailed, fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusServiceUnavailable})),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.Certificate
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							certificate.DeepCopyInto(o)
+							got = o
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: newScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							return cert.PostCertificateResponse{}, tc.args.postErr
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			result, _ := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Fatalf("Reconcile(...): -want result, +got result: %v", diff)
+			}
+
+			errorCondition := meta.FindStatusCondition(got.Status.Conditions, ConditionError)
+			if errorCondition == nil {
+				t.Fatalf("Reconcile(...): missing %s condition", ConditionError)
+			}
+			if diff := cmp.Diff(tc.want.condition, *errorCondition, cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime")); diff != "" {
+				t.Fatalf("Reconcile(...): -want condition, +got condition: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileStuckGUID(t *testing.T) {
+	errNotFound := fmt.Errorf("GET request to Cert API failed: %w", &httpClient.APIError{StatusCode: http.StatusNotFound})
+
+	issuedRecently := metav1.NewTime(time.Now().Add(-time.Minute))
+	issuedLongAgo := metav1.NewTime(time.Now().Add(-2 * stuckGUIDTimeout))
+
+	type args struct {
+		downloadFailures int32
+		guidIssuedTime   metav1.Time
+		guidResets       int32
+	}
+	type want struct {
+		result           ctrl.Result
+		guid             string
+		downloadFailures int32
+		guidResets       int32
+		hasError         bool
+		notFound         bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldCountDownloadFailure": {
+			args: args{
+				downloadFailures: 0,
+				guidIssuedTime:   issuedRecently,
+				guidResets:       0,
+			},
+			want: want{
+				result:           ctrl.Result{RequeueAfter: requeueAfterNotFoundError},
+				guid:             guid,
+				downloadFailures: 1,
+				guidResets:       0,
+				hasError:         true,
+				notFound:         true,
+			},
+		},
+		"ShouldNotClearGUIDOfRecentCertificateAfterManyFailures": {
+			args: args{
+				downloadFailures: 100,
+				guidIssuedTime:   issuedRecently,
+				guidResets:       0,
+			},
+			want: want{
+				result:           ctrl.Result{RequeueAfter: requeueAfterNotFoundError},
+				guid:             guid,
+				downloadFailures: 101,
+				guidResets:       0,
+				hasError:         true,
+				notFound:         true,
+			},
+		},
+		"ShouldNotClearGUIDWithUnknownIssuedTime": {
+			args: args{
+				downloadFailures: 100,
+				guidResets:       0,
+			},
+			want: want{
+				result:           ctrl.Result{RequeueAfter: requeueAfterNotFoundError},
+				guid:             guid,
+				downloadFailures: 101,
+				guidResets:       0,
+				hasError:         true,
+				notFound:         true,
+			},
+		},
+		"ShouldClearStuckGUID": {
+			args: args{
+				downloadFailures: 1,
+				guidIssuedTime:   issuedLongAgo,
+				guidResets:       0,
+			},
+			want: want{
+				result:           ctrl.Result{Requeue: true},
+				guid:             "",
+				downloadFailures: 0,
+				guidResets:       1,
+				hasError:         false,
+				notFound:         false,
+			},
+		},
+		"ShouldNotClearGUIDAfterMaxResets": {
+			args: args{
+				downloadFailures: 1,
+				guidIssuedTime:   issuedLongAgo,
+				guidResets:       maxGUIDResets,
+			},
+			want: want{
+				result:           ctrl.Result{RequeueAfter: requeueAfterNotFoundError},
+				guid:             guid,
+				downloadFailures: 2,
+				guidResets:       maxGUIDResets,
+				hasError:         true,
+				notFound:         true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.Certificate
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							certificate.DeepCopyInto(o)
+							o.Status = v1alpha1.CertificateStatus{
+								Guid:             guid,
+								DownloadFailures: tc.args.downloadFailures,
+								GUIDIssuedTime:   tc.args.guidIssuedTime,
+								GUIDResets:       tc.args.guidResets,
+								Conditions: []metav1.Condition{
+									condition(ConditionGetCertDataFromCertAPIFailed, errNotFound),
+									certNotFoundAtCACondition(&v1alpha1.Certificate{Status: v1alpha1.CertificateStatus{Guid: guid}}),
+								},
+							}
+							got = o
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: newScheme(),
+				Log:    logr.Discard(),
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockGetCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.GetCertificateResponse, error) {
+							return cert.GetCertificateResponse{}, errNotFound
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			result, _ := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Fatalf("Reconcile(...): -want result, +got result: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.guid, got.Status.Guid); diff != "" {
+				t.Fatalf("Reconcile(...): -want guid, +got guid: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.downloadFailures, got.Status.DownloadFailures); diff != "" {
+				t.Fatalf("Reconcile(...): -want downloadFailures, +got downloadFailures: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.guidResets, got.Status.GUIDResets); diff != "" {
+				t.Fatalf("Reconcile(...): -want guidResets, +got guidResets: %v", diff)
+			}
+
+			if hasError := meta.FindStatusCondition(got.Status.Conditions, ConditionError) != nil; hasError != tc.want.hasError {
+				t.Fatalf("Reconcile(...): want Error condition %v, got %v", tc.want.hasError, hasError)
+			}
+
+			if diff := cmp.Diff(tc.want.notFound, r.hasNotFoundErrorCondition(got)); diff != "" {
+				t.Fatalf("Reconcile(...): -want CertNotFoundAtCA, +got CertNotFoundAtCA: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileCertNotFoundAtCA(t *testing.T) {
+	errNotFound := fmt.Errorf("GET request to Cert API failed: %w", &httpClient.APIError{StatusCode: http.StatusNotFound})
+	validTo := time.Now().AddDate(1, 0, 0)
+
+	type args struct {
+		notFound    bool
+		getErr      error
+		downloadErr error
+	}
+	type want struct {
+		posted   bool
+		notFound bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldSetConditionWhenGetIsNotFound": {
+			args: args{
+				getErr: errNotFound,
+			},
+			want: want{
+				posted:   true,
+				notFound: true,
+			},
+		},
+		"ShouldSetConditionWhenDownloadIsNotFound": {
+			args: args{
+				downloadErr: errNotFound,
+			},
+			want: want{
+				posted:   true,
+				notFound: true,
+			},
+		},
+		"ShouldNotSetConditionOnOtherErrors": {
+			args: args{
+				getErr: errBoom,
+			},
+			want: want{
+				posted:   true,
+				notFound: false,
+			},
+		},
+		"ShouldPollWithoutPostingWhileNotFound": {
+			args: args{
+				notFound: true,
+				getErr:   errNotFound,
+			},
+			want: want{
+				posted:   false,
+				notFound: true,
+			},
+		},
+		"ShouldRemoveConditionOnceDownloaded": {
+			args: args{
+				notFound: true,
+			},
+			want: want{
+				posted:   false,
+				notFound: false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.Certificate
+			posted := false
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							certificate.DeepCopyInto(o)
+							o.Status.Guid = guid
+							if tc.args.notFound {
+								o.Status.Conditions = []metav1.Condition{certNotFoundAtCACondition(o)}
+							}
+							got = o
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							if key.Name != certificateConfig.Spec.SecretRef.Name {
+								return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+							}
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockUpdate:      test.NewMockUpdateFn(nil),
+					MockPatch:       test.NewMockPatchFn(nil),
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: newScheme(),
+				Log:    logr.Discard(),
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							posted = true
+							return cert.PostCertificateResponse{Guid: guid}, nil
+						},
+						MockGetCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.GetCertificateResponse, error) {
+							return cert.GetCertificateResponse{
+								ValidTo:   validTo.Format(timeFormat),
+								ValidFrom: time.Now().Format(timeFormat),
+							}, tc.args.getErr
+						},
+						MockDownloadCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.DownloadCertificateResponse, error) {
+							if tc.args.downloadErr != nil {
+								return cert.DownloadCertificateResponse{}, tc.args.downloadErr
+							}
+							return cert.DownloadCertificateResponse{Data: validPKCS12Data, Password: validPKCS12Password}, nil
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			_, _ = r.Reconcile(context.Background(), req)
+
+			if diff := cmp.Diff(tc.want.posted, posted); diff != "" {
+				t.Fatalf("Reconcile(...): -want posted, +got posted: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.notFound, meta.IsStatusConditionTrue(got.Status.Conditions, ConditionCertNotFoundAtCA)); diff != "" {
+				t.Fatalf("Reconcile(...): -want CertNotFoundAtCA, +got CertNotFoundAtCA: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileLastError(t *testing.T) {
+	previousErrorTime := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	type args struct {
+		validTo   time.Time
+		lastError string
+	}
+	type want struct {
+		lastError       string
+		hasErrorTime    bool
+		recentErrorTime bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldRecordLastErrorOnFailure": {
+			args: args{
+				validTo:   time.Time{},
+				lastError: "previous failure",
+			},
+			want: want{
+				lastError:       errBoom.Error(),
+				hasErrorTime:    true,
+				recentErrorTime: true,
+			},
+		},
+		"ShouldKeepLastErrorTimeOfRepeatedFailure": {
+			args: args{
+				validTo:   time.Time{},
+				lastError: errBoom.Error(),
+			},
+			want: want{
+				lastError:       errBoom.Error(),
+				hasErrorTime:    true,
+				recentErrorTime: false,
+			},
+		},
+		"ShouldClearLastErrorOnSuccess": {
+			args: args{
+				validTo:   time.Now().AddDate(1, 0, 0),
+				lastError: "previous failure",
+			},
+			want: want{
+				lastError:    "",
+				hasErrorTime: false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.Certificate
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							certificate.DeepCopyInto(o)
+							o.Status = v1alpha1.CertificateStatus{
+								ValidTo:       metav1.Time{Time: tc.args.validTo},
+								LastError:     tc.args.lastError,
+								LastErrorTime: previousErrorTime,
+							}
+							got = o
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: newScheme(),
+				Log:    logr.Discard(),
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							return cert.PostCertificateResponse{}, errBoom
+						},
+						MockGetCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.GetCertificateResponse, error) {
+							return cert.GetCertificateResponse{ValidTo: "2024-10-18T09:05:22", ValidFrom: "2024-04-18T09:05:22"}, nil
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			_, _ = r.Reconcile(context.Background(), req)
+
+			if diff := cmp.Diff(tc.want.lastError, got.Status.LastError); diff != "" {
+				t.Fatalf("Reconcile(...): -want lastError, +got lastError: %v", diff)
+			}
+
+			if hasErrorTime := !got.Status.LastErrorTime.IsZero(); hasErrorTime != tc.want.hasErrorTime {
+				t.Fatalf("Reconcile(...): want lastErrorTime set %v, got %v", tc.want.hasErrorTime, hasErrorTime)
+			}
+			if diff := cmp.Diff(tc.want.recentErrorTime, got.Status.LastErrorTime.After(previousErrorTime.Time)); tc.want.hasErrorTime && diff != "" {
+				t.Fatalf("Reconcile(...): -want recent lastErrorTime, +got recent lastErrorTime: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileRetryAfter(t *testing.T) {
+	type args struct {
+		postErr error
+	}
+	type want struct {
+		result ctrl.Result
+		err    error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldRequeueAfterRetryAfterOfTooManyRequests": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 2 * time.Minute}),
+			},
+			want: want{
+				result: ctrl.Result{RequeueAfter: 2 * time.Minute},
+				err:    nil,
+			},
+		},
+		"ShouldRequeueAfterRetryAfterOfServiceUnavailable": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusServiceUnavailable, RetryAfter: 30 * time.Second}),
+			},
+			want: want{
+				result: ctrl.Result{RequeueAfter: 30 * time.Second},
+				err:    nil,
+			},
+		},
+		"ShouldRequeueAfterRetryAfterOfOpenCircuit": {
+			args: args{
+				postErr: &circuitOpenError{RetryAfter: time.Minute},
+			},
+			want: want{
+				result: ctrl.Result{RequeueAfter: time.Minute},
+				err:    nil,
+			},
+		},
+		"ShouldRetryWithBackoffWithoutRetryAfter": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusServiceUnavailable}),
+			},
+			want: want{
+				result: ctrl.Result{},
+				err:    fmt.Errorf(errCreationFailed, fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusServiceUnavailable})),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							certificate.DeepCopyInto(o)
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: newScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							return cert.PostCertificateResponse{}, tc.args.postErr
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			result, err := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("Reconcile(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Fatalf("Reconcile(...): -want result, +got result: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileSpans(t *testing.T) {
+	recorder, reset := tracingtest.NewRecorder()
+	defer reset()
+
+	r := &CertificateReconciler{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+				switch o := obj.(type) {
+				case *v1alpha1.Certificate:
+					certificate.DeepCopyInto(o)
+				case *v1alpha1.CertificateConfig:
+					certificateConfig.DeepCopyInto(o)
+				case *corev1.Secret:
+					o.Data = map[string][]byte{}
+				}
+				return nil
+			},
+			MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+		},
+		Scheme: newScheme(),
+		Log:    logr.Logger{},
+		CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+			return &MockCertClient{
+				MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+					_, span := tracing.Start(ctx, "PostCertificate")
+					err := errBoom
+					tracing.End(span, &err)
+					return cert.PostCertificateResponse{}, err
+				},
+			}, nil
+		},
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+	_, err := r.Reconcile(context.Background(), req)
+
+	want := []tracingtest.RecordedSpan{
+		{
+			Name: "Reconcile",
+			Attributes: map[string]any{
+				tracing.AttributeCertificate: req.NamespacedName.String(),
+				tracing.AttributeConfig:      certificateConfig.Name,
+			},
+			Err:   err.Error(),
+			Ended: true,
+		},
+		{Name: "PostCertificate", Parent: "Reconcile", Attributes: map[string]any{}, Err: errBoom.Error(), Ended: true},
+	}
+	if diff := cmp.Diff(want, recorder.Spans()); diff != "" {
+		t.Fatalf("Reconcile(...): -want spans, +got spans: %v", diff)
+	}
+}
+
+func Test_certificatesForRecreatedConfig(t *testing.T) {
+	newCertificate := func(name, configName string, configUID types.UID) v1alpha1.Certificate {
+		return v1alpha1.Certificate{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Spec:       v1alpha1.CertificateSpec{ConfigRef: v1alpha1.ConfigReference{Name: configName}},
+			Status:     v1alpha1.CertificateStatus{ConfigUID: configUID},
+		}
+	}
+	certificates := []v1alpha1.Certificate{
+		newCertificate("reconciled", "config", "old-uid"),
+		newCertificate("unreconciled", "config", ""),
+		newCertificate("other-config", "other", "old-uid"),
+		newCertificate("waiting", "created", ""),
+	}
+	defaulted := newCertificate("defaulted", "", "old-uid")
+	defaulted.Status.ConfigName = "config"
+	certificates = append(certificates, defaulted)
+
+	type args struct {
+		certificateConfig *v1alpha1.CertificateConfig
+		listErr           error
+	}
+	type want struct {
+		requests []reconcile.Request
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldEnqueueDependentsOfRecreatedConfig": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{ObjectMeta: metav1.ObjectMeta{Name: "config", UID: "new-uid"}},
+			},
+			want: want{
+				requests: []reconcile.Request{
+					{NamespacedName: types.NamespacedName{Name: "reconciled", Namespace: "default"}},
+					{NamespacedName: types.NamespacedName{Name: "unreconciled", Namespace: "default"}},
+					{NamespacedName: types.NamespacedName{Name: "defaulted", Namespace: "default"}},
+				},
+			},
+		},
+		"ShouldNotEnqueueReconciledDependentsOfUpdatedConfig": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{ObjectMeta: metav1.ObjectMeta{Name: "config", UID: "old-uid", Generation: 2}},
+			},
+			want: want{
+				requests: []reconcile.Request{
+					{NamespacedName: types.NamespacedName{Name: "unreconciled", Namespace: "default"}},
+				},
+			},
+		},
+		"ShouldEnqueueCertificatesWaitingForCreatedConfig": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{ObjectMeta: metav1.ObjectMeta{Name: "created", UID: "created-uid"}},
+			},
+			want: want{
+				requests: []reconcile.Request{
+					{NamespacedName: types.NamespacedName{Name: "waiting", Namespace: "default"}},
+				},
+			},
+		},
+		"ShouldEnqueueNothingWhenListFails": {
+			args: args{
+				certificateConfig: &v1alpha1.CertificateConfig{ObjectMeta: metav1.ObjectMeta{Name: "config", UID: "new-uid"}},
+				listErr:           errBoom,
+			},
+			want: want{
+				requests: nil,
+			},
+		},
+	}
+	for name, tc := range cases {
+		r := &CertificateReconciler{
+			Client: &test.MockClient{
+				MockList: func(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+					if tc.args.listErr != nil {
+						return tc.args.listErr
+					}
+
+					listOpts := &client.ListOptions{}
+					listOpts.ApplyOptions(opts)
+
+					certificateList := list.(*v1alpha1.CertificateList)
+					for _, certificate := range certificates {
+						if listOpts.FieldSelector.Matches(fields.Set{configNameIndexField: configNameIndexValue(&certificate)}) {
+							certificateList.Items = append(certificateList.Items, certificate)
+						}
+					}
+					return nil
+				},
+			},
+			Log: logr.Logger{},
+		}
+
+		t.Run(name, func(t *testing.T) {
+			got := r.certificatesForRecreatedConfig(context.Background(), tc.args.certificateConfig)
+			if diff := cmp.Diff(tc.want.requests, got); diff != "" {
+				t.Fatalf("certificatesForRecreatedConfig(...): -want requests, +got requests: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_certificatesUsingNamespaceDefault(t *testing.T) {
+	certificates := []v1alpha1.Certificate{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "defaulted", Namespace: "default"},
+			Status:     v1alpha1.CertificateStatus{ConfigName: "namespace-default"},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "referencing", Namespace: "default"},
+			Spec:       v1alpha1.CertificateSpec{ConfigRef: v1alpha1.ConfigReference{Name: "config"}},
+		},
+	}
+
+	type args struct {
+		listErr error
+	}
+	type want struct {
+		requests []reconcile.Request
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldEnqueueCertificatesWithoutConfigRef": {
+			want: want{
+				requests: []reconcile.Request{
+					{NamespacedName: types.NamespacedName{Name: "defaulted", Namespace: "default"}},
+				},
+			},
+		},
+		"ShouldEnqueueNothingWhenListFails": {
+			args: args{
+				listErr: errBoom,
+			},
+			want: want{
+				requests: nil,
+			},
+		},
+	}
+	for name, tc := range cases {
+		r := &CertificateReconciler{
+			Client: &test.MockClient{
+				MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+					if tc.args.listErr != nil {
+						return tc.args.listErr
+					}
+
+					list.(*v1alpha1.CertificateList).Items = certificates
+					return nil
+				},
+			},
+			Log: logr.Logger{},
+		}
+
+		t.Run(name, func(t *testing.T) {
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}}
+			got := r.certificatesUsingNamespaceDefault(context.Background(), namespace)
+			if diff := cmp.Diff(tc.want.requests, got); diff != "" {
+				t.Fatalf("certificatesUsingNamespaceDefault(...): -want requests, +got requests: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_waitForConfigDelay(t *testing.T) {
+	now := time.Now()
+
+	cases := map[string]struct {
+		condition *metav1.Condition
+		want      time.Duration
+	}{
+		"ShouldWaitBaseDelayInitially": {
+			condition: nil,
+			want:      configBackoffBase,
+		},
+		"ShouldWaitBaseDelayShortlyAfterWaitingStarted": {
+			condition: &metav1.Condition{Status: metav1.ConditionTrue, LastTransitionTime: metav1.NewTime(now.Add(-time.Second))},
+			want:      configBackoffBase,
+		},
+		"ShouldWaitAsLongAsAlreadyWaited": {
+			condition: &metav1.Condition{Status: metav1.ConditionTrue, LastTransitionTime: metav1.NewTime(now.Add(-time.Minute))},
+			want:      time.Minute,
+		},
+		"ShouldWaitAtMostMaxDelay": {
+			condition: &metav1.Condition{Status: metav1.ConditionTrue, LastTransitionTime: metav1.NewTime(now.Add(-time.Hour))},
+			want:      configBackoffMax,
+		},
+		"ShouldWaitBaseDelayWhenNoLongerWaiting": {
+			condition: &metav1.Condition{Status: metav1.ConditionFalse, LastTransitionTime: metav1.NewTime(now.Add(-time.Hour))},
+			want:      configBackoffBase,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, waitForConfigDelay(tc.condition, now)); diff != "" {
+				t.Fatalf("waitForConfigDelay(...): -want delay, +got delay: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileWaitingForConfig(t *testing.T) {
+	waitingSince := metav1.NewTime(time.Now().Add(-time.Minute).Truncate(time.Second))
+
+	type args struct {
+		configExists bool
+		waiting      bool
+	}
+	type want struct {
+		result  ctrl.Result
+		err     bool
+		waiting bool
+		since   *metav1.Time
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldWaitForMissingConfig": {
+			args: args{
+				configExists: false,
+			},
+			want: want{
+				result:  ctrl.Result{RequeueAfter: configBackoffBase},
+				err:     false,
+				waiting: true,
+			},
+		},
+		"ShouldBackOffWhileConfigIsMissing": {
+			args: args{
+				configExists: false,
+				waiting:      true,
+			},
+			want: want{
+				err:     false,
+				waiting: true,
+				since:   &waitingSince,
+			},
+		},
+		"ShouldStopWaitingOnceConfigExists": {
+			args: args{
+				configExists: true,
+				waiting:      true,
+			},
+			want: want{
+				result:  ctrl.Result{},
+				err:     true,
+				waiting: false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			current := certificate.DeepCopy()
+			if tc.args.waiting {
+				current.Status.Conditions = []metav1.Condition{{
+					Type:               ConditionWaitingForConfig,
+					Status:             metav1.ConditionTrue,
+					Reason:             reasonConfigNotFound,
+					LastTransitionTime: waitingSince,
+				}}
+			}
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							current.DeepCopyInto(o)
+						case *v1alpha1.CertificateConfig:
+							if !tc.args.configExists {
+								return kerrors.NewNotFound(v1alpha1.GroupVersion.WithResource("certificateconfigs").GroupResource(), key.Name)
+							}
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockStatusPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+						obj.(*v1alpha1.Certificate).DeepCopyInto(current)
+						return nil
+					},
+				},
+				Scheme: runtime.NewScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							return cert.PostCertificateResponse{}, errBoom
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			result, err := r.Reconcile(context.Background(), req)
+			if (err != nil) != tc.want.err {
+				t.Fatalf("Reconcile(...): want error %v, got error %v", tc.want.err, err)
+			}
+
+			if tc.want.since != nil {
+				if result.RequeueAfter < time.Minute || result.RequeueAfter > configBackoffMax {
+					t.Fatalf("Reconcile(...): expected to requeue after the time already waited, got %v", result.RequeueAfter)
+				}
+			} else if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Fatalf("Reconcile(...): -want result, +got result: %v", diff)
+			}
+
+			waiting := meta.FindStatusCondition(current.Status.Conditions, ConditionWaitingForConfig)
+			if diff := cmp.Diff(tc.want.waiting, waiting != nil); diff != "" {
+				t.Fatalf("Reconcile(...): -want WaitingForConfig, +got WaitingForConfig: %v", diff)
+			}
+			if tc.want.since != nil && !waiting.LastTransitionTime.Equal(tc.want.since) {
+				t.Fatalf("Reconcile(...): expected the WaitingForConfig condition to be set since %v, got %v", tc.want.since, waiting.LastTransitionTime)
+			}
+		})
+	}
+}
+
+func Test_ReconcileDefaultConfig(t *testing.T) {
+	type args struct {
+		configRef        string
+		namespaceDefault string
+		clusterDefault   string
+	}
+	type want struct {
+		config string
+		reason string
+		err    error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldUseReferencedConfig": {
+			args: args{
+				configRef:        "explicit",
+				namespaceDefault: "namespace-default",
+				clusterDefault:   "cluster-default",
+			},
+			want: want{
+				config: "explicit",
+			},
+		},
+		"ShouldUseNamespaceDefaultConfig": {
+			args: args{
+				namespaceDefault: "namespace-default",
+				clusterDefault:   "cluster-default",
+			},
+			want: want{
+				config: "namespace-default",
+			},
+		},
+		"ShouldUseClusterDefaultConfig": {
+			args: args{
+				clusterDefault: "cluster-default",
+			},
+			want: want{
+				config: "cluster-default",
+			},
+		},
+		"ShouldFailWithoutDefaultConfig": {
+			args: args{},
+			want: want{
+				reason: ConditionDefaultConfigNotResolved,
+				err:    common.ErrNoDefaultConfig,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			current := certificate.DeepCopy()
+			current.Spec.ConfigRef.Name = tc.args.configRef
+
+			var config string
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							current.DeepCopyInto(o)
+						case *corev1.Namespace:
+							if tc.args.namespaceDefault != "" {
+								o.Annotations = map[string]string{common.AnnotationDefaultConfig: tc.args.namespaceDefault}
+							}
+						case *v1alpha1.CertificateConfig:
+							config = key.Name
+							return kerrors.NewNotFound(v1alpha1.GroupVersion.WithResource("certificateconfigs").GroupResource(), key.Name)
+						}
+						return nil
+					},
+					MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+						if tc.args.clusterDefault != "" {
+							list.(*v1alpha1.CertificateConfigList).Items = []v1alpha1.CertificateConfig{{ObjectMeta: metav1.ObjectMeta{Name: tc.args.clusterDefault}}}
+						}
+						return nil
+					},
+					MockStatusPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+						obj.(*v1alpha1.Certificate).DeepCopyInto(current)
+						return nil
+					},
+				},
+				Scheme: runtime.NewScheme(),
+				Log:    logr.Discard(),
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			_, err := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("Reconcile(...): -want error, +got error: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.config, config); diff != "" {
+				t.Fatalf("Reconcile(...): -want CertificateConfig, +got CertificateConfig: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.config, current.Status.ConfigName); diff != "" {
+				t.Fatalf("Reconcile(...): -want status config name, +got status config name: %v", diff)
+			}
+
+			var reason string
+			if errorCondition := meta.FindStatusCondition(current.Status.Conditions, ConditionError); errorCondition != nil {
+				reason = errorCondition.Reason
+			}
+			if diff := cmp.Diff(tc.want.reason, reason); diff != "" {
+				t.Fatalf("Reconcile(...): -want Error reason, +got Error reason: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileRecreatedConfig(t *testing.T) {
+	type args struct {
+		configUID types.UID
+	}
+	type want struct {
+		builds    int
+		configUID types.UID
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldRefreshWhenConfigIsRecreated": {
+			args: args{
+				configUID: "new-uid",
+			},
+			want: want{
+				builds:    2,
+				configUID: "new-uid",
+			},
+		},
+		"ShouldNotRefreshWhenConfigIsUpdatedInPlace": {
+			args: args{
+				configUID: "old-uid",
+			},
+			want: want{
+				builds:    1,
+				configUID: "old-uid",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			config := certificateConfig.DeepCopy()
+			config.UID = "old-uid"
+			current := certificate.DeepCopy()
+			builds := 0
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							current.DeepCopyInto(o)
+						case *v1alpha1.CertificateConfig:
+							config.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockStatusPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+						obj.(*v1alpha1.Certificate).DeepCopyInto(current)
+						return nil
+					},
+				},
+				Scheme: runtime.NewScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					builds++
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							return cert.PostCertificateResponse{}, errBoom
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			_, _ = r.Reconcile(context.Background(), req)
+
+			config.UID = tc.args.configUID
+			_, _ = r.Reconcile(context.Background(), req)
+
+			if diff := cmp.Diff(tc.want.builds, builds); diff != "" {
+				t.Fatalf("Reconcile(...): -want builds, +got builds: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.configUID, current.Status.ConfigUID); diff != "" {
+				t.Fatalf("Reconcile(...): -want config UID, +got config UID: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileStaleConfigSecret(t *testing.T) {
+	type args struct {
+		resourceVersions []string
+	}
+	type want struct {
+		result          ctrl.Result
+		posted          bool
+		resourceVersion string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldRequeueWhenSecretChangesMidReconcile": {
+			args: args{
+				resourceVersions: []string{"1", "2"},
+			},
+			want: want{
+				result:          ctrl.Result{Requeue: true},
+				posted:          false,
+				resourceVersion: "",
+			},
+		},
+		"ShouldRecordResourceVersionOfUnchangedSecret": {
+			args: args{
+				resourceVersions: []string{"1", "1"},
+			},
+			want: want{
+				result:          ctrl.Result{},
+				posted:          true,
+				resourceVersion: "1",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			current := certificate.DeepCopy()
+			reads := 0
+			posted := false
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							current.DeepCopyInto(o)
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.ResourceVersion = tc.args.resourceVersions[reads]
+							o.Data = map[string][]byte{}
+							reads++
+						}
+						return nil
+					},
+					MockStatusPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+						obj.(*v1alpha1.Certificate).DeepCopyInto(current)
+						return nil
+					},
+				},
+				Scheme: runtime.NewScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							posted = true
+							return cert.PostCertificateResponse{}, errBoom
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			result, _ := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Fatalf("Reconcile(...): -want result, +got result: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.posted, posted); diff != "" {
+				t.Fatalf("Reconcile(...): -want posted, +got posted: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.resourceVersion, current.Status.ConfigSecretResourceVersion); diff != "" {
+				t.Fatalf("Reconcile(...): -want secret resourceVersion, +got secret resourceVersion: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_isNotFoundError(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want bool
+	}{
+		"ShouldDetectNotFoundResponse": {
+			err:  fmt.Errorf("GET request to Cert API failed: %w", &httpClient.APIError{StatusCode: http.StatusNotFound}),
+			want: true,
+		},
+		"ShouldDetectNotFoundResponseWithLocalizedMessage": {
+			err:  fmt.Errorf("Zertifikat wurde nicht gefunden: %w", &httpClient.APIError{StatusCode: http.StatusNotFound}),
+			want: true,
+		},
+		"ShouldNotDetectNotFoundMessageWithoutStatusCode": {
+			err:  fmt.Errorf("GET request to Cert API failed: %v", http.StatusText(http.StatusNotFound)),
+			want: false,
+		},
+		"ShouldNotDetectOtherStatusCode": {
+			err:  &httpClient.APIError{StatusCode: http.StatusInternalServerError},
+			want: false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, isNotFoundError(tc.err)); diff != "" {
+				t.Errorf("isNotFoundError(...): -want, +got: %v", diff)
+			}
+		})
+	}
+}
+
+// selfSignedCertificatePEM returns a PEM-encoded self-signed certificate with the given CommonName, DNS names and IPs.
+func selfSignedCertificatePEM(t *testing.T, commonName string, dnsNames []string, ips []string) []byte {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey(...): unexpected error: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     dnsNames,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+	}
+	for _, ip := range ips {
+		template.IPAddresses = append(template.IPAddresses, net.ParseIP(ip))
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate(...): unexpected error: %v", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func Test_ReconcileSubjectDrift(t *testing.T) {
+	valid := certificate.DeepCopy()
+	valid.Status.ValidTo = metav1.NewTime(time.Now().AddDate(1, 0, 0))
+	valid.Status.Guid = guid
+
+	type args struct {
+		certificatePEM []byte
+	}
+	type want struct {
+		posts int
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldNotReissueMatchingCertificate": {
+			args: args{
+				certificatePEM: selfSignedCertificatePEM(t, "example", []string{"www.example.com"}, []string{"192.168.1.1"}),
+			},
+			want: want{
+				posts: 0,
+			},
+		},
+		"ShouldReissueDriftedCertificate": {
+			args: args{
+				certificatePEM: selfSignedCertificatePEM(t, "example", []string{"old.example.com"}, []string{"192.168.1.1"}),
+			},
+			want: want{
+				posts: 1,
+			},
+		},
+		"ShouldNotReissueWithoutParsableCertificate": {
+			args: args{
+				certificatePEM: []byte("not a certificate"),
+			},
+			want: want{
+				posts: 0,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			posts := 0
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							valid.DeepCopyInto(o)
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							o.Data = map[string][]byte{}
+							if key.Name == valid.Spec.SecretName {
+								o.Data[corev1.TLSCertKey] = tc.args.certificatePEM
+							}
+						}
+						return nil
+					},
+					MockUpdate:      test.NewMockUpdateFn(nil),
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: newScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							posts++
+							return cert.PostCertificateResponse{}, errBoom
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			_, _ = r.Reconcile(context.Background(), req)
+
+			if diff := cmp.Diff(tc.want.posts, posts); diff != "" {
+				t.Fatalf("Reconcile(...): -want posts, +got posts: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_isCertificateValid(t *testing.T) {
+	validFrom := time.Date(2024, 4, 18, 9, 5, 22, 0, time.UTC)
+	validTo := validFrom.Add(90 * 24 * time.Hour)
+	renewBeforePercent := 33
+
+	type args struct {
+		validTo time.Time
+		now     time.Time
+	}
+	type want struct {
+		dayBasedValid     bool
+		percentBasedValid bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldBeValidBeforeBothRenewalPoints": {
+			args: args{
+				validTo: validTo,
+				now:     validFrom.Add(50 * 24 * time.Hour),
+			},
+			want: want{
+				dayBasedValid:     true,
+				percentBasedValid: true,
+			},
+		},
+		"ShouldRenewOnlyPercentBasedAfterPercentageOfValidityElapsed": {
+			args: args{
+				validTo: validTo,
+				now:     validFrom.Add(61 * 24 * time.Hour),
+			},
+			want: want{
+				dayBasedValid:     true,
+				percentBasedValid: false,
+			},
+		},
+		"ShouldRenewBothAfterBothRenewalPoints": {
+			args: args{
+				validTo: validTo,
+				now:     validTo.Add(31 * 24 * time.Hour),
+			},
+			want: want{
+				dayBasedValid:     false,
+				percentBasedValid: false,
+			},
+		},
+		"ShouldRenewBothWithoutValidity": {
+			args: args{
+				now: validFrom,
+			},
+			want: want{
+				dayBasedValid:     false,
+				percentBasedValid: false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			testCertificate := certificate.DeepCopy()
+			testCertificate.Status.ValidFrom = metav1.NewTime(validFrom)
+			testCertificate.Status.ValidTo = metav1.Time{}
+			if !tc.args.validTo.IsZero() {
+				testCertificate.Status.ValidTo = metav1.NewTime(tc.args.validTo)
+			}
+
+			dayBasedConfig := certificateConfig.DeepCopy()
+			dayBasedConfig.Spec.DaysBeforeRenewal = 30
+			dayBasedConfig.Spec.RenewBeforePercent = nil
+
+			percentBasedConfig := dayBasedConfig.DeepCopy()
+			percentBasedConfig.Spec.RenewBeforePercent = &renewBeforePercent
+
+			if diff := cmp.Diff(tc.want.dayBasedValid, isCertificateValid(testCertificate, dayBasedConfig, tc.args.now)); diff != "" {
+				t.Fatalf("isCertificateValid(...): -want day-based validity, +got day-based validity: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.percentBasedValid, isCertificateValid(testCertificate, percentBasedConfig, tc.args.now)); diff != "" {
+				t.Fatalf("isCertificateValid(...): -want percent-based validity, +got percent-based validity: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileExpiryGauge(t *testing.T) {
+	validTo := metav1.NewTime(time.Now().AddDate(1, 0, 0).Truncate(time.Second))
+	valid := certificate.DeepCopy()
+	valid.Name = "expiring-cert"
+	valid.Status.ValidTo = validTo
+
+	deleted := false
+	r := &CertificateReconciler{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+				switch o := obj.(type) {
+				case *v1alpha1.Certificate:
+					if deleted {
+						return kerrors.NewNotFound(v1alpha1.GroupVersion.WithResource("certificates").GroupResource(), key.Name)
+					}
+					valid.DeepCopyInto(o)
+				case *v1alpha1.CertificateConfig:
+					certificateConfig.DeepCopyInto(o)
+				case *corev1.Secret:
+					if key.Name != certificateConfig.Spec.SecretRef.Name && key.Name != valid.Spec.SecretName {
+						return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+					}
+					o.Data = map[string][]byte{}
+				}
+				return nil
+			},
+			MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+		},
+		Scheme: newScheme(),
+		Log:    logr.Logger{},
+		CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+			return &MockCertClient{}, nil
+		},
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: valid.Name, Namespace: valid.Namespace}}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(float64(validTo.Unix()), metricValue(t, metrics.CertificateExpiry.WithLabelValues(valid.Namespace, valid.Name))); diff != "" {
+		t.Fatalf("Reconcile(...): -want expiry gauge, +got expiry gauge: %v", diff)
+	}
+
+	deleted = true
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile(...): unexpected error: %v", err)
+	}
+	if metrics.CertificateExpiry.DeleteLabelValues(valid.Namespace, valid.Name) {
+		t.Fatalf("Reconcile(...): expected the expiry gauge of the deleted Certificate to be removed")
+	}
+}
+
+func Test_ReconcileMissingSecret(t *testing.T) {
+	validTo := time.Now().AddDate(1, 0, 0)
+
+	type args struct {
+		guid         string
+		secretExists bool
+	}
+	type want struct {
+		posted     bool
+		polled     bool
+		downloaded bool
+		created    bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldDownloadAgainWithoutPostingWhenSecretIsMissing": {
+			args: args{
+				guid:         guid,
+				secretExists: false,
+			},
+			want: want{
+				posted:     false,
+				polled:     false,
+				downloaded: true,
+				created:    true,
+			},
+		},
+		"ShouldReissueWhenSecretIsMissingWithoutGUID": {
+			args: args{
+				guid:         "",
+				secretExists: false,
+			},
+			want: want{
+				posted:     true,
+				polled:     true,
+				downloaded: true,
+				created:    true,
+			},
+		},
+		"ShouldNotDownloadWhenSecretExists": {
+			args: args{
+				guid:         guid,
+				secretExists: true,
+			},
+			want: want{
+				posted:     false,
+				polled:     false,
+				downloaded: false,
+				created:    false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			valid := certificate.DeepCopy()
+			valid.Status.Guid = tc.args.guid
+			valid.Status.ValidTo = metav1.NewTime(validTo)
+
+			var got want
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							valid.DeepCopyInto(o)
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							if key.Name != certificateConfig.Spec.SecretRef.Name && !(tc.args.secretExists && key.Name == valid.Spec.SecretName) {
+								return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+							}
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.PatchOption) error {
+						if obj.GetName() == valid.Spec.SecretName {
+							got.created = true
+						}
+						return nil
+					},
+					MockUpdate:      test.NewMockUpdateFn(nil),
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme: newScheme(),
+				Log:    logr.Logger{},
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							got.posted = true
+							return cert.PostCertificateResponse{Guid: guid}, nil
+						},
+						MockGetCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.GetCertificateResponse, error) {
+							got.polled = true
+							return cert.GetCertificateResponse{ValidTo: validTo.Format(timeFormat), ValidFrom: time.Now().Format(timeFormat)}, nil
+						},
+						MockDownloadCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.DownloadCertificateResponse, error) {
+							got.downloaded = true
+							return cert.DownloadCertificateResponse{Data: validPKCS12Data, Password: validPKCS12Password}, nil
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: valid.Name, Namespace: valid.Namespace}}
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("Reconcile(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want, got, cmp.AllowUnexported(want{})); diff != "" {
+				t.Fatalf("Reconcile(...): -want requests, +got requests: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileLogger(t *testing.T) {
+	var lines []string
+	r := &CertificateReconciler{
+		Client: &test.MockClient{
+			MockGet: test.NewMockGetFn(kerrors.NewNotFound(v1alpha1.GroupVersion.WithResource("certificates").GroupResource(), "")),
+		},
+		Scheme: newScheme(),
+		Log: funcr.New(func(prefix, args string) {
+			lines = append(lines, args)
+		}, funcr.Options{}),
+	}
+
+	keys := []types.NamespacedName{
+		{Name: "first", Namespace: "default"},
+		{Name: "second", Namespace: "default"},
+	}
+	for _, key := range keys {
+		if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key}); err != nil {
+			t.Fatalf("Reconcile(...): unexpected error: %v", err)
+		}
+	}
+
+	want := []string{
+		`"level"=0 "msg"="Starting Reconcile" "certificate"={"name"="first" "namespace"="default"}`,
+		`"level"=0 "msg"="Starting Reconcile" "certificate"={"name"="second" "namespace"="default"}`,
+	}
+	if diff := cmp.Diff(want, lines); diff != "" {
+		t.Fatalf("Reconcile(...): -want log lines, +got log lines: %v", diff)
+	}
+}
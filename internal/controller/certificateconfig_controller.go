@@ -19,16 +19,29 @@ package controller
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/dana-team/certificate-operator/internal/common"
 
 	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
 	"github.com/go-logr/logr"
 	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 const (
@@ -38,10 +51,24 @@ const (
 	errSettingFinalizer             = "error occurred while setting the finalizers of the CertificateConfig resource: %v"
 	errDeletingFinalizer            = "error occurred while deleting the finalizers of the CertificateConfig resource"
 	errListingCertificates          = "failed to list Certificates: %v"
+	errUpdateConfigBackoff          = "failed to update the backoff status of the CertificateConfig: %v"
 )
 
 const (
-	dependenciesFinalizer = "cert.dana.io/check-dependencies"
+	secretRefIndexField  = "spec.secretRef"
+	configNameIndexField = "status.configName"
+)
+
+const (
+	// configBackoffBase is the delay before reconciling a CertificateConfig again after its first failed reconcile.
+	configBackoffBase = 5 * time.Second
+	// configBackoffMax is the maximal delay before reconciling a CertificateConfig again after a failed reconcile.
+	configBackoffMax = 10 * time.Minute
+)
+
+const (
+	// DefaultDependenciesFinalizer is the finalizer set on CertificateConfigs when no other name is configured.
+	DefaultDependenciesFinalizer = "cert.dana.io/check-dependencies"
 )
 
 // CertificateConfigReconciler reconciles a CertificateConfig object
@@ -49,6 +76,12 @@ type CertificateConfigReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+	// FinalizerName is the finalizer set on CertificateConfigs. It defaults to DefaultDependenciesFinalizer,
+	// and can be changed so that parallel installs of the operator do not manage the same finalizer.
+	FinalizerName string
+	// FinalizerRemovalBackoff is the backoff of the retries of the finalizer removal when the CertificateConfig
+	// changed meanwhile. It defaults to retry.DefaultBackoff.
+	FinalizerRemovalBackoff wait.Backoff
 }
 
 //+kubebuilder:rbac:groups=cert.dana.io,resources=certificateconfigs,verbs=get;list;watch;create;update;patch;delete
@@ -56,19 +89,82 @@ type CertificateConfigReconciler struct {
 //+kubebuilder:rbac:groups=cert.dana.io,resources=certificateconfigs/finalizers,verbs=update
 
 // SetupWithManager sets up the controller with the Manager.
+// Updates of CertificateConfigs only enqueue them when their spec, annotations, labels or finalizers change, so that
+// the backoff status written by a failed reconcile does not enqueue the CertificateConfig again immediately.
 func (r *CertificateConfigReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	if err := mgr.GetFieldIndexer().IndexField(context.TODO(), &v1alpha1.Certificate{}, "spec.configRef.Name", func(obj client.Object) []string {
-		return []string{obj.(*v1alpha1.Certificate).Spec.ConfigRef.Name}
+	if err := mgr.GetFieldIndexer().IndexField(context.TODO(), &v1alpha1.Certificate{}, configNameIndexField, func(obj client.Object) []string {
+		return []string{configNameIndexValue(obj.(*v1alpha1.Certificate))}
+	}); err != nil {
+		return err
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.TODO(), &v1alpha1.CertificateConfig{}, secretRefIndexField, func(obj client.Object) []string {
+		return []string{secretRefIndexValue(obj.(*v1alpha1.CertificateConfig).Spec.SecretRef)}
 	}); err != nil {
 		return err
 	}
 
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&v1alpha1.CertificateConfig{}).
+		For(&v1alpha1.CertificateConfig{}, builder.WithPredicates(predicate.Or(
+			predicate.GenerationChangedPredicate{},
+			predicate.AnnotationChangedPredicate{},
+			predicate.LabelChangedPredicate{},
+			finalizersChangedPredicate(),
+		))).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.certificateConfigsForSecret)).
 		Complete(r)
 }
 
+// certificateConfigsForSecret returns reconcile requests for the CertificateConfigs referencing the given Secret,
+// so that they are reconciled when their credentials change.
+func (r *CertificateConfigReconciler) certificateConfigsForSecret(ctx context.Context, secret client.Object) []reconcile.Request {
+	secretRef := v1alpha1.SecretRef{Name: secret.GetName(), Namespace: secret.GetNamespace()}
+
+	certificateConfigList := &v1alpha1.CertificateConfigList{}
+	if err := r.Client.List(ctx, certificateConfigList, client.MatchingFields{secretRefIndexField: secretRefIndexValue(secretRef)}); err != nil {
+		r.Log.Error(err, "failed to list CertificateConfigs referencing secret", "secret", client.ObjectKeyFromObject(secret))
+		return nil
+	}
+
+	requests := make([]reconcile.Request, 0, len(certificateConfigList.Items))
+	for _, certificateConfig := range certificateConfigList.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: certificateConfig.Name}})
+	}
+
+	return requests
+}
+
+// finalizersChangedPredicate returns a predicate accepting updates which change the finalizers of the object, so that
+// a CertificateConfig whose finalizer was removed by another client is reconciled to set it again.
+func finalizersChangedPredicate() predicate.Predicate {
+	return predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return !equality.Semantic.DeepEqual(e.ObjectOld.GetFinalizers(), e.ObjectNew.GetFinalizers())
+		},
+	}
+}
+
+// configNameIndexValue returns the name of the CertificateConfig under which a Certificate is indexed: the name
+// resolved in its status, or else the name it references, so that Certificates using a default CertificateConfig are
+// found as well as Certificates which were not reconciled yet.
+func configNameIndexValue(certificate *v1alpha1.Certificate) string {
+	if certificate.Status.ConfigName != "" {
+		return certificate.Status.ConfigName
+	}
+
+	return certificate.Spec.ConfigRef.Name
+}
+
+// secretRefIndexValue returns the value under which a SecretRef is indexed.
+func secretRefIndexValue(secretRef v1alpha1.SecretRef) string {
+	return types.NamespacedName{Name: secretRef.Name, Namespace: secretRef.Namespace}.String()
+}
+
 // Reconcile handles reconciliation of CertificateConfig objects.
+// Failed reconciles are retried with an exponential backoff persisted in the status of the CertificateConfig,
+// instead of the default backoff of the controller, which is reset at every event and loops frequently.
+// A CertificateConfig enqueued before its next retry time is requeued until then, without being reconciled, unless
+// it or its secret changed since the failed reconcile or it is being deleted, e.g. once its missing secret is created.
 func (r *CertificateConfigReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	r.Log = r.Log.WithValues("certificateConfig", req.Name)
 	r.Log.Info("Starting Reconcile")
@@ -78,28 +174,113 @@ func (r *CertificateConfigReconciler) Reconcile(ctx context.Context, req ctrl.Re
 		return ctrl.Result{}, fmt.Errorf(errFailedToGetCertificateConfig, req.Name, err)
 	}
 
+	secretResourceVersion := r.secretResourceVersion(ctx, certificateConfig)
+	if backingOff(certificateConfig, secretResourceVersion) {
+		retryAfter := time.Until(certificateConfig.Status.NextRetryTime.Time)
+		r.Log.Info("CertificateConfig is backing off after a failed reconcile", "retryAfter", retryAfter)
+		return ctrl.Result{RequeueAfter: retryAfter}, nil
+	}
+
+	if err := r.reconcileCertificateConfig(ctx, certificateConfig, req.Name); err != nil {
+		return r.backOff(ctx, certificateConfig, secretResourceVersion, err)
+	}
+
+	return ctrl.Result{}, r.resetBackoff(ctx, certificateConfig)
+}
+
+// secretResourceVersion returns the resourceVersion of the secret of the CertificateConfig, or an empty string if it
+// cannot be read.
+func (r *CertificateConfigReconciler) secretResourceVersion(ctx context.Context, certificateConfig *v1alpha1.CertificateConfig) string {
+	secret, err := common.GetSecret(r.Client, ctx, certificateConfig.Spec.SecretRef.Name, certificateConfig.Spec.SecretRef.Namespace)
+	if err != nil {
+		return ""
+	}
+
+	return secret.ResourceVersion
+}
+
+// backingOff checks if the CertificateConfig is enqueued before the next retry time of its last failed reconcile, while
+// neither it nor its secret, of the given resourceVersion, changed since, and it is not being deleted.
+func backingOff(certificateConfig *v1alpha1.CertificateConfig, secretResourceVersion string) bool {
+	status := certificateConfig.Status
+	if status.NextRetryTime == nil || !time.Now().Before(status.NextRetryTime.Time) {
+		return false
+	}
+
+	return certificateConfig.DeletionTimestamp.IsZero() &&
+		status.ObservedGeneration == certificateConfig.Generation &&
+		status.ObservedSecretResourceVersion == secretResourceVersion
+}
+
+// reconcileCertificateConfig checks that the secret of the CertificateConfig exists, sets its finalizers and
+// handles its deletion.
+func (r *CertificateConfigReconciler) reconcileCertificateConfig(ctx context.Context, certificateConfig *v1alpha1.CertificateConfig, name string) error {
 	_, err := common.GetSecret(r.Client, ctx, certificateConfig.Spec.SecretRef.Name, certificateConfig.Spec.SecretRef.Namespace)
 	if err != nil {
-		return ctrl.Result{}, fmt.Errorf(errFailedToGetSecret, err)
+		return fmt.Errorf(errFailedToGetSecret, err)
 	}
 
 	err = r.setFinalizers(ctx, certificateConfig)
 	if err != nil {
-		return ctrl.Result{}, fmt.Errorf(errSettingFinalizer, err)
+		return fmt.Errorf(errSettingFinalizer, err)
 	}
 
-	err = r.handleDelete(ctx, certificateConfig, req.Name)
-	if err != nil {
-		return ctrl.Result{}, err
+	return r.handleDelete(ctx, certificateConfig, name)
+}
+
+// backOff records the failed reconcile in the status of the CertificateConfig, along with the generation of the
+// CertificateConfig and the resourceVersion of its secret it observed, and requeues it after a delay growing
+// exponentially with the number of consecutive failed reconciles.
+func (r *CertificateConfigReconciler) backOff(ctx context.Context, certificateConfig *v1alpha1.CertificateConfig, secretResourceVersion string, reconcileErr error) (ctrl.Result, error) {
+	certificateConfig.Status.FailedAttempts++
+	delay := configBackoff(certificateConfig.Status.FailedAttempts)
+	nextRetryTime := metav1.NewTime(time.Now().Add(delay))
+	certificateConfig.Status.NextRetryTime = &nextRetryTime
+	certificateConfig.Status.ObservedGeneration = certificateConfig.Generation
+	certificateConfig.Status.ObservedSecretResourceVersion = secretResourceVersion
+
+	r.Log.Error(reconcileErr, "failed to reconcile CertificateConfig", "failedAttempts", certificateConfig.Status.FailedAttempts, "retryAfter", delay)
+
+	if err := r.Status().Update(ctx, certificateConfig); err != nil {
+		return ctrl.Result{}, fmt.Errorf(errUpdateConfigBackoff, err)
+	}
+
+	return ctrl.Result{RequeueAfter: delay}, nil
+}
+
+// resetBackoff clears the backoff state from the status of the CertificateConfig after a successful reconcile.
+// A CertificateConfig which was deleted once its finalizer was removed is ignored.
+func (r *CertificateConfigReconciler) resetBackoff(ctx context.Context, certificateConfig *v1alpha1.CertificateConfig) error {
+	if certificateConfig.Status == (v1alpha1.CertificateConfigStatus{}) {
+		return nil
+	}
+
+	certificateConfig.Status = v1alpha1.CertificateConfigStatus{}
+	if err := r.Status().Update(ctx, certificateConfig); client.IgnoreNotFound(err) != nil {
+		return fmt.Errorf(errUpdateConfigBackoff, err)
+	}
+
+	return nil
+}
+
+// configBackoff returns the delay before reconciling a CertificateConfig again after the number of consecutive
+// failed reconciles. It doubles with every failed reconcile, from configBackoffBase up to configBackoffMax.
+func configBackoff(failedAttempts int32) time.Duration {
+	delay := configBackoffBase
+	for i := int32(1); i < failedAttempts; i++ {
+		delay *= 2
+		if delay >= configBackoffMax {
+			return configBackoffMax
+		}
 	}
 
-	return ctrl.Result{}, nil
+	return delay
 }
 
 // setFinalizers sets the finalizers on the CertificateConfig if it has not been marked for deletion and the finalizers need updating.
 // It returns an error if the update operation fails.
 func (r *CertificateConfigReconciler) setFinalizers(ctx context.Context, certificateConfig *v1alpha1.CertificateConfig) error {
-	controllerutil.AddFinalizer(certificateConfig, dependenciesFinalizer)
+	controllerutil.AddFinalizer(certificateConfig, r.finalizerName())
 	if err := r.Update(ctx, certificateConfig); err != nil {
 		r.Log.Error(err, errSettingFinalizer)
 		return err
@@ -129,22 +310,57 @@ func (r *CertificateConfigReconciler) handleDelete(ctx context.Context, certific
 }
 
 // removeFinalizer removes the finalizer, and updates the CertificateConfig accordingly.
+// If the update conflicts because the CertificateConfig changed meanwhile, it is fetched again and the removal is
+// retried with the finalizer removal backoff. A CertificateConfig which is gone once fetched again needs no removal.
 // It returns an error if any operation fails.
 func (r *CertificateConfigReconciler) removeFinalizer(ctx context.Context, certificateConfig *v1alpha1.CertificateConfig) error {
-	controllerutil.RemoveFinalizer(certificateConfig, dependenciesFinalizer)
-	if err := r.Update(ctx, certificateConfig); err != nil {
+	conflicted := false
+	err := retry.RetryOnConflict(r.finalizerRemovalBackoff(), func() error {
+		if conflicted {
+			if err := r.Get(ctx, client.ObjectKeyFromObject(certificateConfig), certificateConfig); err != nil {
+				return err
+			}
+		}
+
+		controllerutil.RemoveFinalizer(certificateConfig, r.finalizerName())
+		err := r.Update(ctx, certificateConfig)
+		conflicted = kerrors.IsConflict(err)
+		return err
+	})
+	if kerrors.IsNotFound(err) && conflicted {
+		return nil
+	}
+	if err != nil {
 		return errors.New(errDeletingFinalizer)
 	}
 
-	r.Log.Info("cleaned up the '" + dependenciesFinalizer + "' finalizer successfully")
+	r.Log.Info("cleaned up the '" + r.finalizerName() + "' finalizer successfully")
 	return nil
 }
 
+// finalizerRemovalBackoff returns the backoff of the retries of the finalizer removal.
+func (r *CertificateConfigReconciler) finalizerRemovalBackoff() wait.Backoff {
+	if r.FinalizerRemovalBackoff.Steps > 0 {
+		return r.FinalizerRemovalBackoff
+	}
+
+	return retry.DefaultBackoff
+}
+
+// finalizerName returns the finalizer managed by the reconciler, or DefaultDependenciesFinalizer if none is configured.
+func (r *CertificateConfigReconciler) finalizerName() string {
+	if r.FinalizerName == "" {
+		return DefaultDependenciesFinalizer
+	}
+
+	return r.FinalizerName
+}
+
 // shouldRemoveFinalizer checks if there are associated Certificates with the CertificateConfig, if there are, returns false, otherwise returns true
 // It returns an error if any operation fails.
 func (r *CertificateConfigReconciler) shouldRemoveFinalizer(ctx context.Context, name string) error {
 	certificateList := &v1alpha1.CertificateList{}
-	if err := r.Client.List(ctx, certificateList, client.MatchingFields{"spec.configRef.Name": name}); err != nil {
+	if err := r.Client.List(ctx, certificateList, client.MatchingFields{configNameIndexField: name}); err != nil {
 		return fmt.Errorf(errListingCertificates, err)
 	}
 
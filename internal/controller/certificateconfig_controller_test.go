@@ -11,14 +11,22 @@ import (
 	"github.com/dana-team/certificate-operator/api/v1alpha1"
 	"github.com/go-logr/logr"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	errorspkg "github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 var (
-	finalizers = []string{dependenciesFinalizer}
+	finalizers = []string{DefaultDependenciesFinalizer}
 )
 
 func Test_setFinalizers(t *testing.T) {
@@ -287,3 +295,560 @@ func Test_shouldRemoveFinalizer(t *testing.T) {
 		})
 	}
 }
+
+func Test_configNameIndexValue(t *testing.T) {
+	type args struct {
+		configRef  string
+		configName string
+	}
+	type want struct {
+		value string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldIndexResolvedConfig": {
+			args: args{
+				configName: "namespace-default",
+			},
+			want: want{
+				value: "namespace-default",
+			},
+		},
+		"ShouldIndexReferencedConfigOfUnreconciledCertificate": {
+			args: args{
+				configRef: "config",
+			},
+			want: want{
+				value: "config",
+			},
+		},
+		"ShouldPreferResolvedConfig": {
+			args: args{
+				configRef:  "config",
+				configName: "previous",
+			},
+			want: want{
+				value: "previous",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			certificate := &v1alpha1.Certificate{
+				Spec:   v1alpha1.CertificateSpec{ConfigRef: v1alpha1.ConfigReference{Name: tc.args.configRef}},
+				Status: v1alpha1.CertificateStatus{ConfigName: tc.args.configName},
+			}
+			if diff := cmp.Diff(tc.want.value, configNameIndexValue(certificate)); diff != "" {
+				t.Fatalf("configNameIndexValue(...): -want value, +got value: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_removeFinalizerOnConflict(t *testing.T) {
+	conflict := kerrors.NewConflict(v1alpha1.GroupVersion.WithResource("certificateconfigs").GroupResource(), "test-conf", errBoom)
+	notFound := kerrors.NewNotFound(v1alpha1.GroupVersion.WithResource("certificateconfigs").GroupResource(), "test-conf")
+
+	type args struct {
+		conflicts int
+		getErr    error
+	}
+	type want struct {
+		err        error
+		updates    int
+		gets       int
+		finalizers []string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldRemoveFinalizerWithoutConflict": {
+			args: args{
+				conflicts: 0,
+			},
+			want: want{
+				err:        nil,
+				updates:    1,
+				gets:       0,
+				finalizers: []string{"other.dana.io/finalizer"},
+			},
+		},
+		"ShouldRemoveFinalizerAfterConflict": {
+			args: args{
+				conflicts: 1,
+			},
+			want: want{
+				err:        nil,
+				updates:    2,
+				gets:       1,
+				finalizers: []string{"other.dana.io/finalizer", "added.dana.io/finalizer"},
+			},
+		},
+		"ShouldFailAfterRepeatedConflicts": {
+			args: args{
+				conflicts: 10,
+			},
+			want: want{
+				err:     errorspkg.New(errDeletingFinalizer),
+				updates: 3,
+				gets:    2,
+			},
+		},
+		"ShouldNotFailIfConfigIsGone": {
+			args: args{
+				conflicts: 1,
+				getErr:    notFound,
+			},
+			want: want{
+				err:     nil,
+				updates: 1,
+				gets:    1,
+			},
+		},
+		"ShouldFailGettingConfig": {
+			args: args{
+				conflicts: 1,
+				getErr:    errBoom,
+			},
+			want: want{
+				err:     errorspkg.New(errDeletingFinalizer),
+				updates: 1,
+				gets:    1,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var updates, gets int
+			r := &CertificateConfigReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						gets++
+						if tc.args.getErr != nil {
+							return tc.args.getErr
+						}
+						obj.SetFinalizers([]string{DefaultDependenciesFinalizer, "other.dana.io/finalizer", "added.dana.io/finalizer"})
+						return nil
+					},
+					MockUpdate: func(_ context.Context, _ client.Object, _ ...client.UpdateOption) error {
+						updates++
+						if updates <= tc.args.conflicts {
+							return conflict
+						}
+						return nil
+					},
+				},
+				Scheme:                  runtime.NewScheme(),
+				Log:                     logr.Discard(),
+				FinalizerRemovalBackoff: wait.Backoff{Duration: time.Millisecond, Factor: 2, Steps: 3},
+			}
+
+			config := &v1alpha1.CertificateConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test-conf",
+					Finalizers: []string{DefaultDependenciesFinalizer, "other.dana.io/finalizer"},
+				},
+			}
+
+			err := r.removeFinalizer(context.Background(), config)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("removeFinalizer(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.updates, updates); diff != "" {
+				t.Fatalf("removeFinalizer(...): -want updates, +got updates: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.gets, gets); diff != "" {
+				t.Fatalf("removeFinalizer(...): -want gets, +got gets: %v", diff)
+			}
+			if tc.want.err == nil && tc.args.getErr == nil {
+				if diff := cmp.Diff(tc.want.finalizers, config.Finalizers); diff != "" {
+					t.Fatalf("removeFinalizer(...): -want finalizers, +got finalizers: %v", diff)
+				}
+			}
+		})
+	}
+}
+
+func Test_configuredFinalizerName(t *testing.T) {
+	const customFinalizer = "custom.dana.io/check-dependencies"
+
+	type args struct {
+		finalizerName string
+		finalizers    []string
+	}
+	type want struct {
+		afterSet    []string
+		afterRemove []string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldUseDefaultFinalizer": {
+			args: args{
+				finalizerName: "",
+				finalizers:    nil,
+			},
+			want: want{
+				afterSet:    []string{DefaultDependenciesFinalizer},
+				afterRemove: nil,
+			},
+		},
+		"ShouldUseConfiguredFinalizer": {
+			args: args{
+				finalizerName: customFinalizer,
+				finalizers:    nil,
+			},
+			want: want{
+				afterSet:    []string{customFinalizer},
+				afterRemove: nil,
+			},
+		},
+		"ShouldNotTouchOtherInstanceFinalizer": {
+			args: args{
+				finalizerName: customFinalizer,
+				finalizers:    []string{DefaultDependenciesFinalizer},
+			},
+			want: want{
+				afterSet:    []string{DefaultDependenciesFinalizer, customFinalizer},
+				afterRemove: []string{DefaultDependenciesFinalizer},
+			},
+		},
+	}
+	for name, tc := range cases {
+		r := &CertificateConfigReconciler{
+			Client: &test.MockClient{
+				MockUpdate: test.NewMockUpdateFn(nil),
+			},
+			Scheme:        runtime.NewScheme(),
+			Log:           logr.Logger{},
+			FinalizerName: tc.args.finalizerName,
+		}
+
+		t.Run(name, func(t *testing.T) {
+			config := &v1alpha1.CertificateConfig{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:       "test-conf",
+					Finalizers: tc.args.finalizers,
+				},
+			}
+
+			if err := r.setFinalizers(context.Background(), config); err != nil {
+				t.Fatalf("setFinalizers(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.afterSet, config.Finalizers); diff != "" {
+				t.Fatalf("setFinalizers(...): -want finalizers, +got finalizers: %v", diff)
+			}
+
+			if err := r.removeFinalizer(context.Background(), config); err != nil {
+				t.Fatalf("removeFinalizer(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.afterRemove, config.Finalizers, cmpopts.EquateEmpty()); diff != "" {
+				t.Fatalf("removeFinalizer(...): -want finalizers, +got finalizers: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_certificateConfigsForSecret(t *testing.T) {
+	newConfig := func(name, secretName, secretNamespace string) v1alpha1.CertificateConfig {
+		return v1alpha1.CertificateConfig{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.CertificateConfigSpec{
+				SecretRef: v1alpha1.SecretRef{Name: secretName, Namespace: secretNamespace},
+			},
+		}
+	}
+	configs := []v1alpha1.CertificateConfig{
+		newConfig("first", "credentials", "default"),
+		newConfig("second", "credentials", "default"),
+		newConfig("other-namespace", "credentials", "other"),
+		newConfig("other-secret", "other-credentials", "default"),
+	}
+
+	type args struct {
+		secret  *corev1.Secret
+		listErr error
+	}
+	type want struct {
+		requests []reconcile.Request
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldEnqueueReferencingConfigs": {
+			args: args{
+				secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"}},
+			},
+			want: want{
+				requests: []reconcile.Request{
+					{NamespacedName: types.NamespacedName{Name: "first"}},
+					{NamespacedName: types.NamespacedName{Name: "second"}},
+				},
+			},
+		},
+		"ShouldEnqueueNothingForUnreferencedSecret": {
+			args: args{
+				secret: &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "unrelated", Namespace: "default"}},
+			},
+			want: want{
+				requests: []reconcile.Request{},
+			},
+		},
+		"ShouldEnqueueNothingWhenListFails": {
+			args: args{
+				secret:  &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"}},
+				listErr: errBoom,
+			},
+			want: want{
+				requests: nil,
+			},
+		},
+	}
+	for name, tc := range cases {
+		r := &CertificateConfigReconciler{
+			Client: &test.MockClient{
+				MockList: func(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+					if tc.args.listErr != nil {
+						return tc.args.listErr
+					}
+
+					listOpts := &client.ListOptions{}
+					listOpts.ApplyOptions(opts)
+
+					configList := list.(*v1alpha1.CertificateConfigList)
+					for _, config := range configs {
+						if listOpts.FieldSelector.Matches(fields.Set{secretRefIndexField: secretRefIndexValue(config.Spec.SecretRef)}) {
+							configList.Items = append(configList.Items, config)
+						}
+					}
+					return nil
+				},
+			},
+			Log: logr.Logger{},
+		}
+
+		t.Run(name, func(t *testing.T) {
+			got := r.certificateConfigsForSecret(context.Background(), tc.args.secret)
+			if diff := cmp.Diff(tc.want.requests, got); diff != "" {
+				t.Fatalf("certificateConfigsForSecret(...): -want requests, +got requests: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileConfigBackoff(t *testing.T) {
+	type args struct {
+		failedAttempts int32
+		secretErr      error
+	}
+	type want struct {
+		result         ctrl.Result
+		failedAttempts int32
+		nextRetryTime  bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldBackOffAfterFirstFailure": {
+			args: args{
+				failedAttempts: 0,
+				secretErr:      kerrors.NewNotFound(corev1.Resource("secrets"), "secret"),
+			},
+			want: want{
+				result:         ctrl.Result{RequeueAfter: configBackoffBase},
+				failedAttempts: 1,
+				nextRetryTime:  true,
+			},
+		},
+		"ShouldGrowBackoffWithFailedAttempts": {
+			args: args{
+				failedAttempts: 3,
+				secretErr:      kerrors.NewNotFound(corev1.Resource("secrets"), "secret"),
+			},
+			want: want{
+				result:         ctrl.Result{RequeueAfter: 8 * configBackoffBase},
+				failedAttempts: 4,
+				nextRetryTime:  true,
+			},
+		},
+		"ShouldCapBackoff": {
+			args: args{
+				failedAttempts: 30,
+				secretErr:      kerrors.NewNotFound(corev1.Resource("secrets"), "secret"),
+			},
+			want: want{
+				result:         ctrl.Result{RequeueAfter: configBackoffMax},
+				failedAttempts: 31,
+				nextRetryTime:  true,
+			},
+		},
+		"ShouldResetBackoffOnSuccess": {
+			args: args{
+				failedAttempts: 3,
+			},
+			want: want{
+				result:         ctrl.Result{},
+				failedAttempts: 0,
+				nextRetryTime:  false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.CertificateConfig
+
+			r := &CertificateConfigReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+							o.DeletionTimestamp = nil
+							o.Status.FailedAttempts = tc.args.failedAttempts
+							o.Status.NextRetryTime = &metav1.Time{Time: time.Now()}
+						case *corev1.Secret:
+							return tc.args.secretErr
+						}
+						return nil
+					},
+					MockUpdate: test.NewMockUpdateFn(nil),
+					MockStatusUpdate: func(_ context.Context, obj client.Object, _ ...client.SubResourceUpdateOption) error {
+						got = obj.(*v1alpha1.CertificateConfig).DeepCopy()
+						return nil
+					},
+				},
+				Log: logr.Discard(),
+			}
+
+			before := time.Now()
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: certificateConfig.Name}})
+			if err != nil {
+				t.Fatalf("Reconcile(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Fatalf("Reconcile(...): -want result, +got result: %v", diff)
+			}
+			if got == nil {
+				t.Fatalf("Reconcile(...): expected the status of the CertificateConfig to be updated")
+			}
+			if diff := cmp.Diff(tc.want.failedAttempts, got.Status.FailedAttempts); diff != "" {
+				t.Fatalf("Reconcile(...): -want failed attempts, +got failed attempts: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.nextRetryTime, got.Status.NextRetryTime != nil); diff != "" {
+				t.Fatalf("Reconcile(...): -want next retry time, +got next retry time: %v", diff)
+			}
+			if tc.want.nextRetryTime && got.Status.NextRetryTime.Time.Before(before.Add(tc.want.result.RequeueAfter)) {
+				t.Fatalf("Reconcile(...): next retry time %v is earlier than the requeue delay", got.Status.NextRetryTime)
+			}
+		})
+	}
+}
+
+func Test_ReconcileConfigBeforeNextRetryTime(t *testing.T) {
+	nextRetryTime := metav1.NewTime(time.Now().Add(time.Minute))
+	deletionTimestamp := metav1.Now()
+
+	type args struct {
+		generation        int64
+		secretVersion     string
+		deletionTimestamp *metav1.Time
+	}
+	type want struct {
+		reconciled bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldBackOffWhileUnchanged": {
+			args: args{
+				generation: 1,
+			},
+			want: want{
+				reconciled: false,
+			},
+		},
+		"ShouldReconcileOnceSecretIsCreated": {
+			args: args{
+				generation:    1,
+				secretVersion: "2",
+			},
+			want: want{
+				reconciled: true,
+			},
+		},
+		"ShouldReconcileOnceSpecChanged": {
+			args: args{
+				generation: 2,
+			},
+			want: want{
+				reconciled: true,
+			},
+		},
+		"ShouldReconcileOnceDeleted": {
+			args: args{
+				generation:        1,
+				deletionTimestamp: &deletionTimestamp,
+			},
+			want: want{
+				reconciled: true,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var updates, statusUpdates int
+			r := &CertificateConfigReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+							o.Generation = tc.args.generation
+							o.DeletionTimestamp = tc.args.deletionTimestamp
+							o.Finalizers = finalizers
+							o.Status.FailedAttempts = 2
+							o.Status.NextRetryTime = nextRetryTime.DeepCopy()
+							o.Status.ObservedGeneration = 1
+						case *corev1.Secret:
+							if tc.args.secretVersion == "" {
+								return kerrors.NewNotFound(corev1.Resource("secrets"), "secret")
+							}
+							o.ResourceVersion = tc.args.secretVersion
+						}
+						return nil
+					},
+					MockList: test.NewMockListFn(nil),
+					MockUpdate: func(_ context.Context, _ client.Object, _ ...client.UpdateOption) error {
+						updates++
+						return nil
+					},
+					MockStatusUpdate: func(_ context.Context, _ client.Object, _ ...client.SubResourceUpdateOption) error {
+						statusUpdates++
+						return nil
+					},
+				},
+				Log: logr.Discard(),
+			}
+
+			result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: certificateConfig.Name}})
+			if err != nil {
+				t.Fatalf("Reconcile(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want.reconciled, updates > 0 || statusUpdates > 0); diff != "" {
+				t.Fatalf("Reconcile(...): -want reconciled, +got reconciled: %v", diff)
+			}
+			if !tc.want.reconciled && (result.RequeueAfter <= 0 || result.RequeueAfter > time.Minute) {
+				t.Fatalf("Reconcile(...): expected to be requeued until the next retry time, got %v", result.RequeueAfter)
+			}
+		})
+	}
+}
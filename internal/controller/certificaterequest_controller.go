@@ -0,0 +1,236 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	errGetCertificateRequest          = "failed to get CertificateRequest: %v"
+	errUpdateCertificateRequestStatus = "failed to update CertificateRequest status: %v"
+)
+
+const (
+	// ConditionRecorded is the condition of a CertificateRequest indicating whether the response of the Cert API was recorded.
+	ConditionRecorded = "Recorded"
+
+	reasonResponseRecorded = "ResponseRecorded"
+	reasonRequestFailed    = "RequestFailed"
+	reasonAwaitingResponse = "AwaitingResponse"
+)
+
+// LabelCertificate is the label holding the name of the Certificate for which a CertificateRequest was sent,
+// or to which a Secret belongs when the Certificate does not set owner references.
+const LabelCertificate = "cert.dana.io/certificate"
+
+// DefaultRequestHistoryLimit is the default number of CertificateRequests kept per Certificate.
+const DefaultRequestHistoryLimit = 10
+
+// CertificateRequestReconciler reconciles a CertificateRequest object
+type CertificateRequestReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// MaxConditionMessageLength is the maximum length of the condition messages of CertificateRequests, longer messages
+	// being truncated. It defaults to DefaultMaxConditionMessageLength, and messages are not truncated if it is negative.
+	MaxConditionMessageLength int
+}
+
+//+kubebuilder:rbac:groups=cert.dana.io,resources=certificaterequests,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=cert.dana.io,resources=certificaterequests/status,verbs=get;update;patch
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CertificateRequestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.CertificateRequest{}).
+		Complete(r)
+}
+
+// Reconcile handles reconciliation of CertificateRequest objects. CertificateRequests only record the requests sent
+// to the Cert API, so reconciling them only reflects the recorded response in the Recorded condition. The condition is
+// written with a merge patch, so that it does not conflict with the response recorded while the request is in flight.
+func (r *CertificateRequestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	certificateRequest := &v1alpha1.CertificateRequest{}
+	if err := r.Client.Get(ctx, req.NamespacedName, certificateRequest); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf(errGetCertificateRequest, err)
+	}
+
+	original := certificateRequest.DeepCopy()
+	condition := recordedCondition(certificateRequest, maxConditionMessageLength(r.MaxConditionMessageLength))
+	if !meta.SetStatusCondition(&certificateRequest.Status.Conditions, condition) {
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.Client.Status().Patch(ctx, certificateRequest, client.MergeFrom(original)); err != nil {
+		return ctrl.Result{}, fmt.Errorf(errUpdateCertificateRequestStatus, err)
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// recordedCondition returns the Recorded condition of the CertificateRequest according to its recorded response.
+// The message of a failed request is truncated to at most maxLength bytes.
+func recordedCondition(certificateRequest *v1alpha1.CertificateRequest, maxLength int) metav1.Condition {
+	condition := metav1.Condition{
+		Type:               ConditionRecorded,
+		ObservedGeneration: certificateRequest.Generation,
+	}
+
+	switch {
+	case certificateRequest.Status.Guid != "":
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = reasonResponseRecorded
+		condition.Message = fmt.Sprintf("the Cert API created the certificate %q", certificateRequest.Status.Guid)
+	case certificateRequest.Status.Failure != "":
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonRequestFailed
+		condition.Message = truncateMessage(certificateRequest.Status.Failure, maxLength)
+	default:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonAwaitingResponse
+		condition.Message = "the response of the Cert API was not recorded yet"
+	}
+
+	return condition
+}
+
+// requestHistoryLimit returns the number of CertificateRequests kept per Certificate.
+func (r *CertificateReconciler) requestHistoryLimit() int {
+	if r.RequestHistoryLimit > 0 {
+		return r.RequestHistoryLimit
+	}
+
+	return DefaultRequestHistoryLimit
+}
+
+// recordCertificateRequest creates a CertificateRequest recording the request sent to the Cert API to create the
+// certificate, if recording requests is enabled. The oldest CertificateRequests of the Certificate are deleted first,
+// so that it keeps at most the request history limit. Failures are only logged, and nil is returned.
+func (r *CertificateReconciler) recordCertificateRequest(ctx context.Context, certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig) *v1alpha1.CertificateRequest {
+	if !r.RecordRequests {
+		return nil
+	}
+
+	r.pruneCertificateRequests(ctx, certificate, r.requestHistoryLimit()-1)
+
+	request, err := cert.RequestBody(certificate, certificateConfig.Spec.DefaultSubject)
+	if err != nil {
+		r.logger(ctx).Error(err, "failed to render the request recorded in the CertificateRequest")
+		return nil
+	}
+
+	certificateRequest := &v1alpha1.CertificateRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: certificate.Name + "-",
+			Namespace:    certificate.Namespace,
+			Labels:       map[string]string{LabelCertificate: certificate.Name},
+		},
+		Spec: v1alpha1.CertificateRequestSpec{
+			CertificateRef: v1alpha1.CertificateReference{Name: certificate.Name},
+			ConfigRef:      v1alpha1.ConfigReference{Name: certificateConfig.Name},
+			Request:        request,
+		},
+	}
+
+	if err := controllerutil.SetOwnerReference(certificate, certificateRequest, r.Scheme); err != nil {
+		r.logger(ctx).Error(err, "failed to set owner reference for CertificateRequest")
+		return nil
+	}
+
+	if err := r.Client.Create(ctx, certificateRequest); err != nil {
+		r.logger(ctx).Error(err, "failed to record CertificateRequest")
+		return nil
+	}
+
+	return certificateRequest
+}
+
+// pruneCertificateRequests deletes the oldest CertificateRequests of the Certificate, so that at most keep of them
+// remain. Failures are only logged.
+func (r *CertificateReconciler) pruneCertificateRequests(ctx context.Context, certificate *v1alpha1.Certificate, keep int) {
+	certificateRequestList := &v1alpha1.CertificateRequestList{}
+	if err := r.Client.List(ctx, certificateRequestList, client.InNamespace(certificate.Namespace), client.MatchingLabels{LabelCertificate: certificate.Name}); err != nil {
+		r.logger(ctx).Error(err, "failed to list CertificateRequests to prune")
+		return
+	}
+
+	certificateRequests := certificateRequestList.Items
+	if len(certificateRequests) <= keep {
+		return
+	}
+
+	sort.Slice(certificateRequests, func(i, j int) bool {
+		if !certificateRequests[i].CreationTimestamp.Equal(&certificateRequests[j].CreationTimestamp) {
+			return certificateRequests[i].CreationTimestamp.Before(&certificateRequests[j].CreationTimestamp)
+		}
+		return certificateRequests[i].Name < certificateRequests[j].Name
+	})
+
+	for i := range certificateRequests[:len(certificateRequests)-keep] {
+		if err := r.Client.Delete(ctx, &certificateRequests[i]); client.IgnoreNotFound(err) != nil {
+			r.logger(ctx).Error(err, "failed to prune CertificateRequest", "certificateRequest", certificateRequests[i].Name)
+		}
+	}
+}
+
+// recordCertificateResponse records the response of the Cert API in the status of the CertificateRequest, with the
+// raw body truncated and its password and data fields redacted. It does nothing if the CertificateRequest is nil.
+// The response is written with a merge patch of the fields it sets, so that it does not conflict with the Recorded
+// condition written by the CertificateRequest reconciler since the CertificateRequest was created. Failures are only
+// logged, so that they do not fail the issuance of the certificate.
+func (r *CertificateReconciler) recordCertificateResponse(ctx context.Context, certificateRequest *v1alpha1.CertificateRequest, response cert.PostCertificateResponse, requestErr error) {
+	if certificateRequest == nil {
+		return
+	}
+
+	original := certificateRequest.DeepCopy()
+	certificateRequest.Status.Guid = response.Guid
+	if response.Raw != "" {
+		rawResponse, err := redactSecrets(response.Raw)
+		if err != nil {
+			r.logger(ctx).Error(err, "failed to redact the raw response, not recording it", "certificateRequest", certificateRequest.Name)
+		} else {
+			certificateRequest.Status.RawResponse = truncateMessage(rawResponse, maxDebugRawResponseLength)
+		}
+	}
+	if requestErr != nil {
+		certificateRequest.Status.Failure = requestErr.Error()
+	}
+	now := metav1.Now()
+	certificateRequest.Status.RespondedAt = &now
+
+	if err := r.Client.Status().Patch(ctx, certificateRequest, client.MergeFrom(original)); err != nil {
+		r.logger(ctx).Error(err, "failed to record CertificateRequest response", "certificateRequest", certificateRequest.Name)
+	}
+}
@@ -0,0 +1,483 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func Test_issueCertificateRecordsRequest(t *testing.T) {
+	owned := certificate.DeepCopy()
+	owned.UID = "uid"
+
+	request, err := cert.RequestBody(owned, v1alpha1.Subject{})
+	if err != nil {
+		t.Fatalf("RequestBody(...): unexpected error: %v", err)
+	}
+
+	longResponse := `{"taskId":"` + strings.Repeat("a", maxDebugRawResponseLength) + `"}`
+
+	type args struct {
+		recordRequests bool
+		response       cert.PostCertificateResponse
+		postErr        error
+	}
+	type want struct {
+		created bool
+		status  v1alpha1.CertificateRequestStatus
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldRecordSuccessfulRequest": {
+			args: args{
+				recordRequests: true,
+				response:       cert.PostCertificateResponse{Guid: guid, Raw: `{"taskId":"` + guid + `"}`},
+			},
+			want: want{
+				created: true,
+				status: v1alpha1.CertificateRequestStatus{
+					Guid:        guid,
+					RawResponse: `{"taskId":"` + guid + `"}`,
+				},
+			},
+		},
+		"ShouldRecordFailedRequest": {
+			args: args{
+				recordRequests: true,
+				postErr:        errBoom,
+			},
+			want: want{
+				created: true,
+				status:  v1alpha1.CertificateRequestStatus{Failure: errBoom.Error()},
+			},
+		},
+		"ShouldRecordRawResponseOfFailedRequest": {
+			args: args{
+				recordRequests: true,
+				response:       cert.PostCertificateResponse{Raw: `{"error":"template not allowed","success":false}`},
+				postErr:        errBoom,
+			},
+			want: want{
+				created: true,
+				status: v1alpha1.CertificateRequestStatus{
+					Failure:     errBoom.Error(),
+					RawResponse: `{"error":"template not allowed","success":false}`,
+				},
+			},
+		},
+		"ShouldRedactPasswordInRawResponse": {
+			args: args{
+				recordRequests: true,
+				response:       cert.PostCertificateResponse{Guid: guid, Raw: `{"taskId":"` + guid + `","Password":"secret"}`},
+			},
+			want: want{
+				created: true,
+				status: v1alpha1.CertificateRequestStatus{
+					Guid:        guid,
+					RawResponse: `{"Password":"` + redactedValue + `","taskId":"` + guid + `"}`,
+				},
+			},
+		},
+		"ShouldTruncateRawResponse": {
+			args: args{
+				recordRequests: true,
+				response:       cert.PostCertificateResponse{Guid: guid, Raw: longResponse},
+			},
+			want: want{
+				created: true,
+				status: v1alpha1.CertificateRequestStatus{
+					Guid:        guid,
+					RawResponse: truncateMessage(longResponse, maxDebugRawResponseLength),
+				},
+			},
+		},
+		"ShouldNotRecordRawResponseThatCannotBeRedacted": {
+			args: args{
+				recordRequests: true,
+				response:       cert.PostCertificateResponse{Guid: guid, Raw: `not json`},
+			},
+			want: want{
+				created: true,
+				status:  v1alpha1.CertificateRequestStatus{Guid: guid},
+			},
+		},
+		"ShouldNotRecordWhenDisabled": {
+			args: args{
+				recordRequests: false,
+			},
+			want: want{
+				created: false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var created *v1alpha1.CertificateRequest
+			var recorded *v1alpha1.CertificateRequest
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+						created = obj.(*v1alpha1.CertificateRequest).DeepCopy()
+						return nil
+					},
+					MockPatch: test.NewMockPatchFn(nil),
+					MockStatusPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+						if certificateRequest, ok := obj.(*v1alpha1.CertificateRequest); ok {
+							recorded = certificateRequest.DeepCopy()
+						}
+						return nil
+					},
+					MockList: test.NewMockListFn(nil),
+				},
+				Scheme:         newScheme(),
+				Log:            logr.Logger{},
+				RecordRequests: tc.args.recordRequests,
+			}
+
+			certClient := &MockCertClient{
+				MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+					return tc.args.response, tc.args.postErr
+				},
+			}
+
+			_, _ = r.issueCertificate(context.Background(), certClient, owned.DeepCopy(), &certificateConfig)
+
+			if diff := cmp.Diff(tc.want.created, created != nil); diff != "" {
+				t.Fatalf("issueCertificate(...): -want created, +got created: %v", diff)
+			}
+			if !tc.want.created {
+				return
+			}
+
+			wantSpec := v1alpha1.CertificateRequestSpec{
+				CertificateRef: v1alpha1.CertificateReference{Name: owned.Name},
+				ConfigRef:      v1alpha1.ConfigReference{Name: certificateConfig.Name},
+				Request:        request,
+			}
+			if diff := cmp.Diff(wantSpec, created.Spec); diff != "" {
+				t.Errorf("issueCertificate(...): -want spec, +got spec: %v", diff)
+			}
+			if diff := cmp.Diff(owned.Name+"-", created.GenerateName); diff != "" {
+				t.Errorf("issueCertificate(...): -want generate name, +got generate name: %v", diff)
+			}
+			if len(created.OwnerReferences) != 1 || created.OwnerReferences[0].UID != owned.UID {
+				t.Errorf("issueCertificate(...): expected the CertificateRequest to be owned by the Certificate, got %v", created.OwnerReferences)
+			}
+
+			if recorded == nil {
+				t.Fatalf("issueCertificate(...): expected the response to be recorded")
+			}
+			if recorded.Status.RespondedAt == nil {
+				t.Errorf("issueCertificate(...): expected respondedAt to be set")
+			}
+			if diff := cmp.Diff(tc.want.status, recorded.Status, cmpopts.IgnoreFields(v1alpha1.CertificateRequestStatus{}, "RespondedAt")); diff != "" {
+				t.Errorf("issueCertificate(...): -want status, +got status: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_pruneCertificateRequests(t *testing.T) {
+	now := time.Now()
+	newCertificateRequest := func(name string, age time.Duration) v1alpha1.CertificateRequest {
+		return v1alpha1.CertificateRequest{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         certificate.Namespace,
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+		}
+	}
+	certificateRequests := []v1alpha1.CertificateRequest{
+		newCertificateRequest("second", 2*time.Hour),
+		newCertificateRequest("newest", time.Hour),
+		newCertificateRequest("oldest", 3*time.Hour),
+	}
+
+	type args struct {
+		keep      int
+		listErr   error
+		deleteErr error
+	}
+	type want struct {
+		deleted []string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldDeleteOldestRequests": {
+			args: args{
+				keep: 1,
+			},
+			want: want{
+				deleted: []string{"oldest", "second"},
+			},
+		},
+		"ShouldKeepRequestsWithinLimit": {
+			args: args{
+				keep: 3,
+			},
+			want: want{
+				deleted: nil,
+			},
+		},
+		"ShouldDeleteAllRequestsWhenKeepingNone": {
+			args: args{
+				keep: 0,
+			},
+			want: want{
+				deleted: []string{"oldest", "second", "newest"},
+			},
+		},
+		"ShouldDeleteNothingWhenListFails": {
+			args: args{
+				keep:    1,
+				listErr: errBoom,
+			},
+			want: want{
+				deleted: nil,
+			},
+		},
+		"ShouldContinuePruningWhenDeleteFails": {
+			args: args{
+				keep:      1,
+				deleteErr: errBoom,
+			},
+			want: want{
+				deleted: []string{"oldest", "second"},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var deleted []string
+			listOptions := &client.ListOptions{}
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockList: func(_ context.Context, list client.ObjectList, opts ...client.ListOption) error {
+						if tc.args.listErr != nil {
+							return tc.args.listErr
+						}
+
+						listOptions.ApplyOptions(opts)
+						list.(*v1alpha1.CertificateRequestList).Items = append([]v1alpha1.CertificateRequest{}, certificateRequests...)
+						return nil
+					},
+					MockDelete: func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+						deleted = append(deleted, obj.GetName())
+						return tc.args.deleteErr
+					},
+				},
+				Log: logr.Discard(),
+			}
+
+			r.pruneCertificateRequests(context.Background(), &certificate, tc.args.keep)
+
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Fatalf("pruneCertificateRequests(...): -want deleted, +got deleted: %v", diff)
+			}
+			if tc.args.listErr == nil {
+				if listOptions.Namespace != certificate.Namespace || !listOptions.LabelSelector.Matches(labels.Set{LabelCertificate: certificate.Name}) {
+					t.Fatalf("pruneCertificateRequests(...): expected the CertificateRequests of the Certificate to be listed, got %v", listOptions)
+				}
+			}
+		})
+	}
+}
+
+func Test_CertificateRequestReconcile(t *testing.T) {
+	type args struct {
+		status v1alpha1.CertificateRequestStatus
+	}
+	type want struct {
+		statusUpdated bool
+		condition     metav1.Condition
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldMarkResponseRecorded": {
+			args: args{
+				status: v1alpha1.CertificateRequestStatus{Guid: guid},
+			},
+			want: want{
+				statusUpdated: true,
+				condition: metav1.Condition{
+					Type:    ConditionRecorded,
+					Status:  metav1.ConditionTrue,
+					Reason:  reasonResponseRecorded,
+					Message: `the Cert API created the certificate "guid"`,
+				},
+			},
+		},
+		"ShouldMarkRequestFailed": {
+			args: args{
+				status: v1alpha1.CertificateRequestStatus{Failure: errBoom.Error()},
+			},
+			want: want{
+				statusUpdated: true,
+				condition: metav1.Condition{
+					Type:    ConditionRecorded,
+					Status:  metav1.ConditionFalse,
+					Reason:  reasonRequestFailed,
+					Message: errBoom.Error(),
+				},
+			},
+		},
+		"ShouldMarkAwaitingResponse": {
+			args: args{
+				status: v1alpha1.CertificateRequestStatus{},
+			},
+			want: want{
+				statusUpdated: true,
+				condition: metav1.Condition{
+					Type:    ConditionRecorded,
+					Status:  metav1.ConditionFalse,
+					Reason:  reasonAwaitingResponse,
+					Message: "the response of the Cert API was not recorded yet",
+				},
+			},
+		},
+		"ShouldNotUpdateUnchangedCondition": {
+			args: args{
+				status: v1alpha1.CertificateRequestStatus{
+					Guid: guid,
+					Conditions: []metav1.Condition{{
+						Type:    ConditionRecorded,
+						Status:  metav1.ConditionTrue,
+						Reason:  reasonResponseRecorded,
+						Message: `the Cert API created the certificate "guid"`,
+					}},
+				},
+			},
+			want: want{
+				statusUpdated: false,
+				condition: metav1.Condition{
+					Type:    ConditionRecorded,
+					Status:  metav1.ConditionTrue,
+					Reason:  reasonResponseRecorded,
+					Message: `the Cert API created the certificate "guid"`,
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var got *v1alpha1.CertificateRequest
+			var statusUpdated bool
+
+			r := &CertificateRequestReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+						certificateRequest := obj.(*v1alpha1.CertificateRequest)
+						certificateRequest.Name = "certificate-request"
+						certificateRequest.Namespace = "default"
+						certificateRequest.Status = *tc.args.status.DeepCopy()
+						got = certificateRequest
+						return nil
+					},
+					MockStatusPatch: func(context.Context, client.Object, client.Patch, ...client.SubResourcePatchOption) error {
+						statusUpdated = true
+						return nil
+					},
+				},
+				Scheme: newScheme(),
+				Log:    logr.Logger{},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "certificate-request", Namespace: "default"}}
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("Reconcile(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.statusUpdated, statusUpdated); diff != "" {
+				t.Errorf("Reconcile(...): -want status updated, +got status updated: %v", diff)
+			}
+
+			gotCondition := meta.FindStatusCondition(got.Status.Conditions, ConditionRecorded)
+			if gotCondition == nil {
+				t.Fatalf("Reconcile(...): expected a %q condition", ConditionRecorded)
+			}
+			if diff := cmp.Diff(tc.want.condition, *gotCondition, cmpopts.IgnoreFields(metav1.Condition{}, "LastTransitionTime")); diff != "" {
+				t.Errorf("Reconcile(...): -want condition, +got condition: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_recordCertificateResponseAfterReconcile(t *testing.T) {
+	owned := certificate.DeepCopy()
+	owned.UID = "uid"
+
+	kubeClient := fake.NewClientBuilder().
+		WithScheme(newScheme()).
+		WithStatusSubresource(&v1alpha1.CertificateRequest{}).
+		Build()
+
+	r := &CertificateReconciler{
+		Client:         kubeClient,
+		Scheme:         newScheme(),
+		Log:            logr.Discard(),
+		RecordRequests: true,
+	}
+	requestReconciler := &CertificateRequestReconciler{
+		Client: kubeClient,
+		Scheme: newScheme(),
+		Log:    logr.Discard(),
+	}
+
+	certificateRequest := r.recordCertificateRequest(context.Background(), owned, &certificateConfig)
+	if certificateRequest == nil {
+		t.Fatalf("recordCertificateRequest(...): expected a CertificateRequest to be created")
+	}
+
+	// The CertificateRequest reconciler handles the create event while the request is in flight.
+	req := ctrl.Request{NamespacedName: client.ObjectKeyFromObject(certificateRequest)}
+	if _, err := requestReconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile(...): unexpected error: %v", err)
+	}
+
+	r.recordCertificateResponse(context.Background(), certificateRequest, cert.PostCertificateResponse{Guid: guid, Raw: `{"taskId":"` + guid + `"}`}, nil)
+
+	if _, err := requestReconciler.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile(...): unexpected error: %v", err)
+	}
+
+	got := &v1alpha1.CertificateRequest{}
+	if err := kubeClient.Get(context.Background(), req.NamespacedName, got); err != nil {
+		t.Fatalf("Get(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(guid, got.Status.Guid); diff != "" {
+		t.Errorf("recordCertificateResponse(...): -want guid, +got guid: %v", diff)
+	}
+	if diff := cmp.Diff(`{"taskId":"`+guid+`"}`, got.Status.RawResponse); diff != "" {
+		t.Errorf("recordCertificateResponse(...): -want raw response, +got raw response: %v", diff)
+	}
+	if got.Status.RespondedAt == nil {
+		t.Errorf("recordCertificateResponse(...): expected respondedAt to be set")
+	}
+	if !meta.IsStatusConditionTrue(got.Status.Conditions, ConditionRecorded) {
+		t.Errorf("Reconcile(...): expected the %q condition to be true, got %v", ConditionRecorded, got.Status.Conditions)
+	}
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+
+	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+const (
+	errGetCertificateSet          = "failed to get CertificateSet: %v"
+	errUpdateCertificateSetStatus = "failed to update CertificateSet status: %v"
+	errCreateOrUpdateSetMember    = "failed to create or update Certificate %q of the CertificateSet: %v"
+	errListSetMembers             = "failed to list Certificates of the CertificateSet: %v"
+	errDeleteSetMember            = "failed to delete Certificate %q of the CertificateSet: %v"
+	errSetMemberNotControlled     = "it already exists and is not controlled by the CertificateSet"
+)
+
+const (
+	reasonCertificatesSynced     = "CertificatesSynced"
+	reasonCertificatesSyncFailed = "CertificatesSyncFailed"
+)
+
+// LabelCertificateSet is the label holding the name of the CertificateSet which created a Certificate.
+const LabelCertificateSet = "cert.dana.io/certificate-set"
+
+// CertificateSetReconciler reconciles a CertificateSet object
+type CertificateSetReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+	// MaxConditionMessageLength is the maximum length of the condition messages of CertificateSets, longer messages
+	// being truncated. It defaults to DefaultMaxConditionMessageLength, and messages are not truncated if it is negative.
+	MaxConditionMessageLength int
+}
+
+//+kubebuilder:rbac:groups=cert.dana.io,resources=certificatesets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=cert.dana.io,resources=certificatesets/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=cert.dana.io,resources=certificates,verbs=get;list;watch;create;update;delete
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *CertificateSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&v1alpha1.CertificateSet{}).
+		Owns(&v1alpha1.Certificate{}).
+		Complete(r)
+}
+
+// Reconcile handles reconciliation of CertificateSet objects. It creates or updates a Certificate for every entry
+// of the CertificateSet, and deletes the Certificates of entries which were removed.
+func (r *CertificateSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx = logr.NewContext(ctx, r.Log.WithValues("certificateSet", req.NamespacedName))
+
+	certificateSet := &v1alpha1.CertificateSet{}
+	if err := r.Client.Get(ctx, req.NamespacedName, certificateSet); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, fmt.Errorf(errGetCertificateSet, err)
+	}
+
+	if !certificateSet.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	original := certificateSet.DeepCopy()
+	syncErr := r.syncCertificates(ctx, certificateSet)
+	if err := r.updateCertificateSetStatus(ctx, original, certificateSet, syncErr); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, syncErr
+}
+
+// logger returns the logger of the reconcile in the context, or the logger of the reconciler outside of a reconcile.
+func (r *CertificateSetReconciler) logger(ctx context.Context) logr.Logger {
+	if log, err := logr.FromContext(ctx); err == nil {
+		return log
+	}
+
+	return r.Log
+}
+
+// syncCertificates creates or updates the Certificates of the entries of the CertificateSet, and prunes the
+// Certificates it created for entries which no longer exist. A Certificate of an entry which already exists without
+// being controlled by the CertificateSet, e.g. one created by a user, is not taken over, and fails the sync.
+func (r *CertificateSetReconciler) syncCertificates(ctx context.Context, certificateSet *v1alpha1.CertificateSet) error {
+	desired := make(map[string]bool, len(certificateSet.Spec.Entries))
+	certificateSet.Status.Certificates = nil
+
+	for _, entry := range certificateSet.Spec.Entries {
+		certificate := &v1alpha1.Certificate{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      setMemberName(certificateSet, entry),
+				Namespace: certificateSet.Namespace,
+			},
+		}
+
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, certificate, func() error {
+			if certificate.ResourceVersion != "" && !metav1.IsControlledBy(certificate, certificateSet) {
+				return goerrors.New(errSetMemberNotControlled)
+			}
+			metav1.SetMetaDataLabel(&certificate.ObjectMeta, LabelCertificateSet, certificateSet.Name)
+			certificate.Spec = setMemberSpec(certificateSet, entry, certificate.Name)
+			return controllerutil.SetControllerReference(certificateSet, certificate, r.Scheme)
+		}); err != nil {
+			return fmt.Errorf(errCreateOrUpdateSetMember, certificate.Name, err)
+		}
+
+		desired[certificate.Name] = true
+		certificateSet.Status.Certificates = append(certificateSet.Status.Certificates, certificate.Name)
+	}
+
+	certificateList := &v1alpha1.CertificateList{}
+	if err := r.Client.List(ctx, certificateList, client.InNamespace(certificateSet.Namespace), client.MatchingLabels{LabelCertificateSet: certificateSet.Name}); err != nil {
+		return fmt.Errorf(errListSetMembers, err)
+	}
+
+	for i := range certificateList.Items {
+		certificate := &certificateList.Items[i]
+		if desired[certificate.Name] || !metav1.IsControlledBy(certificate, certificateSet) {
+			continue
+		}
+
+		if err := r.Client.Delete(ctx, certificate); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf(errDeleteSetMember, certificate.Name, err)
+		}
+		r.logger(ctx).Info("deleted Certificate of removed CertificateSet entry", "certificate", certificate.Name)
+	}
+
+	return nil
+}
+
+// updateCertificateSetStatus sets the Synced condition of the CertificateSet according to the result of the sync,
+// and patches its status with a merge patch of the fields changed since the original was read, so that it does not
+// conflict with concurrent writes. The status is not patched if it did not change.
+func (r *CertificateSetReconciler) updateCertificateSetStatus(ctx context.Context, original, certificateSet *v1alpha1.CertificateSet, syncErr error) error {
+	condition := metav1.Condition{
+		Type:               ConditionSynced,
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: certificateSet.Generation,
+		Reason:             reasonCertificatesSynced,
+		Message:            fmt.Sprintf("%d Certificates are synced", len(certificateSet.Spec.Entries)),
+	}
+	if syncErr != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = reasonCertificatesSyncFailed
+		condition.Message = truncateMessage(syncErr.Error(), maxConditionMessageLength(r.MaxConditionMessageLength))
+	}
+	meta.SetStatusCondition(&certificateSet.Status.Conditions, condition)
+
+	if equality.Semantic.DeepEqual(original.Status, certificateSet.Status) {
+		return nil
+	}
+
+	if err := r.Client.Status().Patch(ctx, certificateSet, client.MergeFrom(original)); err != nil {
+		return fmt.Errorf(errUpdateCertificateSetStatus, err)
+	}
+
+	return nil
+}
+
+// setMemberName returns the name of the Certificate of the entry of the CertificateSet.
+func setMemberName(certificateSet *v1alpha1.CertificateSet, entry v1alpha1.CertificateSetEntry) string {
+	return certificateSet.Name + "-" + entry.Name
+}
+
+// setMemberSpec returns the spec of the Certificate of the entry, made of the template of the CertificateSet with
+// the subject, SANs and secret name of the entry. The secret name defaults to the name of the Certificate, unless
+// the template sets a secret name template, so that the Certificates of the set do not share a Secret.
+func setMemberSpec(certificateSet *v1alpha1.CertificateSet, entry v1alpha1.CertificateSetEntry, name string) v1alpha1.CertificateSpec {
+	spec := *certificateSet.Spec.Template.DeepCopy()
+	spec.CertificateData.Subject = entry.Subject
+	spec.CertificateData.San = *entry.San.DeepCopy()
+
+	switch {
+	case entry.SecretName != "":
+		spec.SecretName = entry.SecretName
+		spec.SecretNameTemplate = ""
+	case spec.SecretNameTemplate == "":
+		spec.SecretName = name
+	}
+
+	return spec
+}
@@ -0,0 +1,241 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var certificateSet = v1alpha1.CertificateSet{
+	ObjectMeta: metav1.ObjectMeta{
+		Name:      "set",
+		Namespace: "default",
+		UID:       "set-uid",
+	},
+	Spec: v1alpha1.CertificateSetSpec{
+		Template: v1alpha1.CertificateSpec{
+			CertificateData: v1alpha1.CertificateData{Template: "default", Form: "pfx"},
+			ConfigRef:       v1alpha1.ConfigReference{Name: "certificateconfig-sample"},
+		},
+		Entries: []v1alpha1.CertificateSetEntry{
+			{Name: "frontend", Subject: v1alpha1.Subject{CommonName: "frontend.example.com"}},
+			{Name: "backend", Subject: v1alpha1.Subject{CommonName: "backend.example.com"}, SecretName: "backend-tls"},
+		},
+	},
+}
+
+// setMember returns an existing Certificate of the CertificateSet with the name, controlled by the owner if set.
+func setMember(name string, owner *v1alpha1.CertificateSet) v1alpha1.Certificate {
+	certificate := v1alpha1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       "default",
+			Labels:          map[string]string{LabelCertificateSet: certificateSet.Name},
+			ResourceVersion: "1",
+		},
+	}
+	if owner != nil {
+		certificate.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: v1alpha1.GroupVersion.String(),
+			Kind:       "CertificateSet",
+			Name:       owner.Name,
+			UID:        owner.UID,
+			Controller: ptr.To(true),
+		}}
+	}
+
+	return certificate
+}
+
+func Test_CertificateSetReconcile(t *testing.T) {
+	withoutBackend := certificateSet.DeepCopy()
+	withoutBackend.Spec.Entries = withoutBackend.Spec.Entries[:1]
+
+	type args struct {
+		certificateSet *v1alpha1.CertificateSet
+		existing       []v1alpha1.Certificate
+		createErr      error
+	}
+	type want struct {
+		created   map[string]v1alpha1.CertificateSpec
+		deleted   []string
+		condition metav1.Condition
+		status    []string
+		err       error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldCreateCertificatesOfEntries": {
+			args: args{
+				certificateSet: certificateSet.DeepCopy(),
+			},
+			want: want{
+				created: map[string]v1alpha1.CertificateSpec{
+					"set-frontend": {
+						CertificateData: v1alpha1.CertificateData{
+							Subject:  v1alpha1.Subject{CommonName: "frontend.example.com"},
+							Template: "default",
+							Form:     "pfx",
+						},
+						SecretName: "set-frontend",
+						ConfigRef:  v1alpha1.ConfigReference{Name: "certificateconfig-sample"},
+					},
+					"set-backend": {
+						CertificateData: v1alpha1.CertificateData{
+							Subject:  v1alpha1.Subject{CommonName: "backend.example.com"},
+							Template: "default",
+							Form:     "pfx",
+						},
+						SecretName: "backend-tls",
+						ConfigRef:  v1alpha1.ConfigReference{Name: "certificateconfig-sample"},
+					},
+				},
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionTrue, Reason: reasonCertificatesSynced},
+				status:    []string{"set-frontend", "set-backend"},
+			},
+		},
+		"ShouldPruneCertificatesOfRemovedEntries": {
+			args: args{
+				certificateSet: withoutBackend,
+				existing: []v1alpha1.Certificate{
+					setMember("set-frontend", &certificateSet),
+					setMember("set-backend", &certificateSet),
+					setMember("set-unowned", nil),
+				},
+			},
+			want: want{
+				created:   map[string]v1alpha1.CertificateSpec{},
+				deleted:   []string{"set-backend"},
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionTrue, Reason: reasonCertificatesSynced},
+				status:    []string{"set-frontend"},
+			},
+		},
+		"ShouldNotTakeOverCertificateNotControlledBySet": {
+			args: args{
+				certificateSet: withoutBackend,
+				existing: []v1alpha1.Certificate{
+					setMember("set-frontend", nil),
+				},
+			},
+			want: want{
+				created:   map[string]v1alpha1.CertificateSpec{},
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionFalse, Reason: reasonCertificatesSyncFailed},
+				err:       fmt.Errorf(errCreateOrUpdateSetMember, "set-frontend", errors.New(errSetMemberNotControlled)),
+			},
+		},
+		"ShouldReportFailedCreation": {
+			args: args{
+				certificateSet: withoutBackend,
+				createErr:      errBoom,
+			},
+			want: want{
+				created:   map[string]v1alpha1.CertificateSpec{},
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionFalse, Reason: reasonCertificatesSyncFailed},
+				err:       fmt.Errorf(errCreateOrUpdateSetMember, "set-frontend", errBoom),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			existing := map[string]v1alpha1.Certificate{}
+			for _, certificate := range tc.args.existing {
+				existing[certificate.Name] = certificate
+			}
+
+			created := map[string]v1alpha1.CertificateSpec{}
+			var deleted []string
+			var got *v1alpha1.CertificateSet
+
+			r := &CertificateSetReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.CertificateSet:
+							tc.args.certificateSet.DeepCopyInto(o)
+						case *v1alpha1.Certificate:
+							certificate, ok := existing[key.Name]
+							if !ok {
+								return kerrors.NewNotFound(v1alpha1.GroupVersion.WithResource("certificates").GroupResource(), key.Name)
+							}
+							certificate.DeepCopyInto(o)
+						}
+						return nil
+					},
+					MockCreate: func(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+						if tc.args.createErr != nil {
+							return tc.args.createErr
+						}
+						certificate := obj.(*v1alpha1.Certificate)
+						if !metav1.IsControlledBy(certificate, tc.args.certificateSet) {
+							return fmt.Errorf("certificate %q is not controlled by the CertificateSet", certificate.Name)
+						}
+						created[certificate.Name] = certificate.Spec
+						return nil
+					},
+					MockUpdate: func(_ context.Context, obj client.Object, _ ...client.UpdateOption) error {
+						certificate := obj.(*v1alpha1.Certificate)
+						if !metav1.IsControlledBy(certificate, tc.args.certificateSet) {
+							return fmt.Errorf("certificate %q is not controlled by the CertificateSet", certificate.Name)
+						}
+						return nil
+					},
+					MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+						for _, certificate := range tc.args.existing {
+							list.(*v1alpha1.CertificateList).Items = append(list.(*v1alpha1.CertificateList).Items, *certificate.DeepCopy())
+						}
+						return nil
+					},
+					MockDelete: func(_ context.Context, obj client.Object, _ ...client.DeleteOption) error {
+						deleted = append(deleted, obj.GetName())
+						return nil
+					},
+					MockStatusPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+						got = obj.(*v1alpha1.CertificateSet).DeepCopy()
+						return nil
+					},
+				},
+				Scheme: newScheme(),
+				Log:    logr.Discard(),
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificateSet.Name, Namespace: certificateSet.Namespace}}
+			_, err := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("Reconcile(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.created, created); diff != "" {
+				t.Fatalf("Reconcile(...): -want created Certificates, +got created Certificates: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.deleted, deleted); diff != "" {
+				t.Fatalf("Reconcile(...): -want deleted Certificates, +got deleted Certificates: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.status, got.Status.Certificates); diff != "" {
+				t.Fatalf("Reconcile(...): -want status Certificates, +got status Certificates: %v", diff)
+			}
+
+			synced := meta.FindStatusCondition(got.Status.Conditions, ConditionSynced)
+			if synced == nil {
+				t.Fatalf("Reconcile(...): missing %s condition", ConditionSynced)
+			}
+			if diff := cmp.Diff(tc.want.condition, *synced, cmpopts.IgnoreFields(metav1.Condition{}, "Message", "LastTransitionTime")); diff != "" {
+				t.Fatalf("Reconcile(...): -want condition, +got condition: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/circuitbreaker"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+)
+
+// breakerCertClient is a cert.Client which records the result of every request to the Cert API in a circuit breaker.
+// Only failures showing that the Cert API is unavailable are recorded as failures, so that a Certificate rejected by
+// the Cert API, or failing before its request is sent, does not pause the requests of other Certificates.
+// Every request is allowed by the circuit breaker right before it is sent, so that the probe request of a half-open
+// circuit is only taken by a reconcile which requests the Cert API.
+type breakerCertClient struct {
+	cert.Client
+	breaker *circuitbreaker.Breaker
+	key     string
+}
+
+// circuitOpenError is the error of a request to the Cert API which the circuit breaker does not allow, e.g. of a
+// concurrent reconcile while another one holds the probe request of a half-open circuit.
+type circuitOpenError struct {
+	// RetryAfter is the time remaining until the circuit breaker allows requests again.
+	RetryAfter time.Duration
+}
+
+// Error returns the message of the error, with the remaining time rounded to the second.
+func (e *circuitOpenError) Error() string {
+	return fmt.Sprintf(errCircuitOpen, e.RetryAfter.Round(time.Second))
+}
+
+// circuitOpen returns the circuitOpenError wrapped in err, if any.
+func circuitOpen(err error) (*circuitOpenError, bool) {
+	var circuitOpenErr *circuitOpenError
+	if errors.As(err, &circuitOpenErr) {
+		return circuitOpenErr, true
+	}
+
+	return nil, false
+}
+
+// newBreakerCertClient returns a cert.Client which records the results of certClient's requests under the given key.
+func newBreakerCertClient(certClient cert.Client, breaker *circuitbreaker.Breaker, key string) cert.Client {
+	return &breakerCertClient{
+		Client:  certClient,
+		breaker: breaker,
+		key:     key,
+	}
+}
+
+// PostCertificate sends a POST request to the Cert API, if the circuit breaker allows it, and records its result.
+func (c *breakerCertClient) PostCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+	if err := c.allow(); err != nil {
+		return cert.PostCertificateResponse{}, err
+	}
+
+	response, err := c.Client.PostCertificate(ctx, certificate)
+	c.record(err)
+	return response, err
+}
+
+// DownloadCertificate downloads a certificate from the Cert API, if the circuit breaker allows it, and records the
+// result.
+func (c *breakerCertClient) DownloadCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (cert.DownloadCertificateResponse, error) {
+	if err := c.allow(); err != nil {
+		return cert.DownloadCertificateResponse{}, err
+	}
+
+	response, err := c.Client.DownloadCertificate(ctx, certificate)
+	c.record(err)
+	return response, err
+}
+
+// GetCertificate gets certificate data from the Cert API, if the circuit breaker allows it, and records the result.
+func (c *breakerCertClient) GetCertificate(ctx context.Context, certificate *v1alpha1.Certificate) (cert.GetCertificateResponse, error) {
+	if err := c.allow(); err != nil {
+		return cert.GetCertificateResponse{}, err
+	}
+
+	response, err := c.Client.GetCertificate(ctx, certificate)
+	c.record(err)
+	return response, err
+}
+
+// allow returns a circuitOpenError if the circuit breaker does not allow a request to the Cert API.
+func (c *breakerCertClient) allow() error {
+	if allowed, retryAfter := c.breaker.Allow(c.key); !allowed {
+		return &circuitOpenError{RetryAfter: retryAfter}
+	}
+
+	return nil
+}
+
+// record records a request result in the circuit breaker. Responses of the Cert API which do not show that it is
+// unavailable are recorded as successes, and other errors, e.g. of requests which were not sent, are not recorded.
+func (c *breakerCertClient) record(err error) {
+	switch {
+	case err == nil:
+		c.breaker.RecordSuccess(c.key)
+	case isCertAPIUnavailable(err):
+		c.breaker.RecordFailure(c.key)
+	default:
+		if _, ok := httpClient.StatusCode(err); ok {
+			c.breaker.RecordSuccess(c.key)
+		}
+	}
+}
+
+// isCertAPIUnavailable checks if err shows that the Cert API is unavailable: the request failed in transport, or the
+// Cert API responded with a 5xx or 429 status code.
+func isCertAPIUnavailable(err error) bool {
+	if statusCode, ok := httpClient.StatusCode(err); ok {
+		return statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests
+	}
+
+	return httpClient.IsTransportError(err)
+}
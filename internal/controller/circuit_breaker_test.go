@@ -0,0 +1,212 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/circuitbreaker"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+	"github.com/dana-team/certificate-operator/internal/clients/notification"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/api/meta"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func Test_breakerCertClient(t *testing.T) {
+	type args struct {
+		postErr error
+	}
+	type want struct {
+		state circuitbreaker.State
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldOpenCircuitOnServerError": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusServiceUnavailable}),
+			},
+			want: want{
+				state: circuitbreaker.StateOpen,
+			},
+		},
+		"ShouldOpenCircuitOnTooManyRequests": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusTooManyRequests}),
+			},
+			want: want{
+				state: circuitbreaker.StateOpen,
+			},
+		},
+		"ShouldOpenCircuitOnTransportError": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", &httpClient.TransportError{URL: "https://cert.example.com", Err: errBoom}),
+			},
+			want: want{
+				state: circuitbreaker.StateOpen,
+			},
+		},
+		"ShouldKeepCircuitClosedOnClientError": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusNotFound}),
+			},
+			want: want{
+				state: circuitbreaker.StateClosed,
+			},
+		},
+		"ShouldKeepCircuitClosedOnLocalError": {
+			args: args{
+				postErr: errBoom,
+			},
+			want: want{
+				state: circuitbreaker.StateClosed,
+			},
+		},
+		"ShouldKeepCircuitClosedOnCancelledRequest": {
+			args: args{
+				postErr: fmt.Errorf("POST to cert failed: %w", context.DeadlineExceeded),
+			},
+			want: want{
+				state: circuitbreaker.StateClosed,
+			},
+		},
+		"ShouldKeepCircuitClosedOnSuccess": {
+			args: args{
+				postErr: nil,
+			},
+			want: want{
+				state: circuitbreaker.StateClosed,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			breaker := circuitbreaker.New(1, time.Minute)
+			certClient := newBreakerCertClient(&MockCertClient{
+				MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+					return cert.PostCertificateResponse{Guid: guid}, tc.args.postErr
+				},
+			}, breaker, certificateConfig.Name)
+
+			_, _ = certClient.PostCertificate(context.Background(), &certificate)
+			if diff := cmp.Diff(tc.want.state, breaker.State(certificateConfig.Name)); diff != "" {
+				t.Fatalf("PostCertificate(...): -want state, +got state: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_breakerCertClientClosesHalfOpenCircuitOnClientError(t *testing.T) {
+	breaker := circuitbreaker.New(1, 0)
+	breaker.RecordFailure(certificateConfig.Name)
+
+	certClient := newBreakerCertClient(&MockCertClient{
+		MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+			return cert.PostCertificateResponse{}, fmt.Errorf("POST to cert failed: %w", &httpClient.APIError{StatusCode: http.StatusBadRequest})
+		},
+	}, breaker, certificateConfig.Name)
+
+	_, _ = certClient.PostCertificate(context.Background(), &certificate)
+	if diff := cmp.Diff(circuitbreaker.StateClosed, breaker.State(certificateConfig.Name)); diff != "" {
+		t.Fatalf("PostCertificate(...): -want state, +got state: %v", diff)
+	}
+}
+
+func Test_breakerCertClientTakesProbeOnlyForRequests(t *testing.T) {
+	const cooldown = 200 * time.Millisecond
+
+	breaker := circuitbreaker.New(1, cooldown)
+	breaker.RecordFailure(certificateConfig.Name)
+	time.Sleep(cooldown)
+
+	r := &CertificateReconciler{CircuitBreaker: breaker}
+	for i := 0; i < 2; i++ {
+		if allowed, _ := r.allowCertAPIRequests(certificateConfig.Name); !allowed {
+			t.Fatalf("allowCertAPIRequests(...): expected requests to be allowed once the circuit half-opens")
+		}
+	}
+
+	var posts int
+	certClient := newBreakerCertClient(&MockCertClient{
+		MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+			posts++
+			return cert.PostCertificateResponse{}, errBoom
+		},
+	}, breaker, certificateConfig.Name)
+
+	if _, err := certClient.PostCertificate(context.Background(), &certificate); err == nil {
+		t.Fatalf("PostCertificate(...): expected the error of the probe request")
+	}
+	if _, err := certClient.PostCertificate(context.Background(), &certificate); err == nil {
+		t.Fatalf("PostCertificate(...): expected the request to be rejected while the probe is pending")
+	}
+	if diff := cmp.Diff(1, posts); diff != "" {
+		t.Fatalf("PostCertificate(...): -want requests, +got requests: %v", diff)
+	}
+}
+
+func Test_breakerCertClientReturnsCircuitOpenError(t *testing.T) {
+	breaker := circuitbreaker.New(1, time.Minute)
+	breaker.RecordFailure(certificateConfig.Name)
+
+	certClient := newBreakerCertClient(&MockCertClient{}, breaker, certificateConfig.Name)
+
+	_, err := certClient.GetCertificate(context.Background(), &certificate)
+	circuitOpenErr, ok := circuitOpen(fmt.Errorf("failed to get certificate: %w", err))
+	if !ok {
+		t.Fatalf("GetCertificate(...): expected a circuitOpenError, got %v", err)
+	}
+	if circuitOpenErr.RetryAfter <= 0 || circuitOpenErr.RetryAfter > time.Minute {
+		t.Fatalf("GetCertificate(...): unexpected retry after %v", circuitOpenErr.RetryAfter)
+	}
+}
+
+func Test_handleCertAPIErrorCircuitOpen(t *testing.T) {
+	var notified bool
+	current := certificate.DeepCopy()
+
+	withURL := certificateConfig.DeepCopy()
+	withURL.Spec.NotificationURL = "https://notifications.example.com"
+
+	r := &CertificateReconciler{
+		Client: &test.MockClient{
+			MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+		},
+		Log: logr.Logger{},
+		Notifier: &MockNotifier{
+			MockNotify: func(context.Context, string, notification.Event) error {
+				notified = true
+				return nil
+			},
+		},
+	}
+
+	ctx := withRenewalOfValidCertificate(context.Background())
+	apiErr := fmt.Errorf(errFailedDownloadingCertificate, &circuitOpenError{RetryAfter: time.Minute})
+	result, err := r.handleCertAPIError(ctx, current, withURL, "", condition(ConditionDownloadCertFromCertAPIFailed, apiErr), apiErr)
+	if err != nil {
+		t.Fatalf("handleCertAPIError(...): unexpected error: %v", err)
+	}
+	if diff := cmp.Diff(ctrl.Result{RequeueAfter: time.Minute}, result); diff != "" {
+		t.Fatalf("handleCertAPIError(...): -want result, +got result: %v", diff)
+	}
+
+	errCondition := meta.FindStatusCondition(current.Status.Conditions, ConditionError)
+	if errCondition == nil || errCondition.Reason != ConditionCircuitOpen {
+		t.Fatalf("handleCertAPIError(...): expected the CircuitOpen condition, got %v", current.Status.Conditions)
+	}
+	if notified {
+		t.Errorf("handleCertAPIError(...): expected no notification")
+	}
+	if current.Status.RenewalFailures != 0 {
+		t.Errorf("handleCertAPIError(...): expected no renewal failure, got %d", current.Status.RenewalFailures)
+	}
+}
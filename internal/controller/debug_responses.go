@@ -0,0 +1,100 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+)
+
+const (
+	// maxDebugRawResponseLength is the maximum length of the raw Cert API responses stored in Certificate statuses.
+	// Longer responses are truncated.
+	maxDebugRawResponseLength = 4096
+
+	// passwordField is the name of the fields holding the PKCS#12 password in the raw Cert API responses.
+	passwordField = "password"
+
+	// dataField is the name of the fields holding the PKCS#12 bundle, with its private key, in the raw Cert API
+	// responses.
+	dataField = "data"
+
+	// redactedValue replaces the values of the redacted fields.
+	redactedValue = "REDACTED"
+)
+
+// setDebugRawResponse stores the raw Cert API response in the Certificate status, with its password and certificate
+// data redacted and truncated, if the reconciler stores responses. Responses which cannot be redacted are not stored.
+func (r *CertificateReconciler) setDebugRawResponse(ctx context.Context, certificate *v1alpha1.Certificate, raw string) {
+	if !r.DebugStoreResponses {
+		return
+	}
+
+	redacted, err := redactSecrets(raw)
+	if err != nil {
+		r.logger(ctx).Info("failed to redact the Cert API response, not storing it", "error", err.Error())
+		return
+	}
+
+	certificate.Status.DebugRawResponse = truncateMessage(redacted, maxDebugRawResponseLength)
+}
+
+// redactSecrets returns the JSON body with the values of its password and data fields replaced, at any depth, so that
+// neither the PKCS#12 password nor the PKCS#12 bundle holding the private key is stored.
+func redactSecrets(body string) (string, error) {
+	decoder := json.NewDecoder(strings.NewReader(body))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return "", err
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(redactValue(value)); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(buffer.String(), "\n"), nil
+}
+
+// redactValue replaces the values of the password and data fields of the decoded JSON value, at any depth. Fields are
+// matched case-insensitively. Objects and arrays held by these fields are not replaced but redacted in turn, so that a
+// response path named like them, e.g. "data", still shows the fields of the response it wraps.
+func redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if isSecretField(key) && !isContainer(field) {
+				v[key] = redactedValue
+				continue
+			}
+			v[key] = redactValue(field)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactValue(item)
+		}
+	}
+
+	return value
+}
+
+// isSecretField returns whether the field of a Cert API response holds a secret, and is redacted.
+func isSecretField(key string) bool {
+	return strings.EqualFold(key, passwordField) || strings.EqualFold(key, dataField)
+}
+
+// isContainer returns whether the decoded JSON value is an object or an array.
+func isContainer(value interface{}) bool {
+	switch value.(type) {
+	case map[string]interface{}, []interface{}:
+		return true
+	}
+
+	return false
+}
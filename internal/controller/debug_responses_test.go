@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+)
+
+func Test_redactSecrets(t *testing.T) {
+	type args struct {
+		body string
+	}
+	type want struct {
+		body string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldRedactPassword": {
+			args: args{
+				body: `{"form":"pfx","data":"pkcs12-data","password":"pkcs12-password"}`,
+			},
+			want: want{
+				body: `{"data":"REDACTED","form":"pfx","password":"REDACTED"}`,
+			},
+		},
+		"ShouldRedactEnvelopedPasswordAndData": {
+			args: args{
+				body: `{"result":{"data":"pkcs12-data","password":"pkcs12-password"}}`,
+			},
+			want: want{
+				body: `{"result":{"data":"REDACTED","password":"REDACTED"}}`,
+			},
+		},
+		"ShouldRedactDataEnvelopeFields": {
+			args: args{
+				body: `{"data":{"form":"pfx","data":"pkcs12-data","password":"pkcs12-password"}}`,
+			},
+			want: want{
+				body: `{"data":{"data":"REDACTED","form":"pfx","password":"REDACTED"}}`,
+			},
+		},
+		"ShouldRedactCaseInsensitively": {
+			args: args{
+				body: `{"Password":"pkcs12-password","PASSWORD":"pkcs12-password","Data":"pkcs12-data"}`,
+			},
+			want: want{
+				body: `{"Data":"REDACTED","PASSWORD":"REDACTED","Password":"REDACTED"}`,
+			},
+		},
+		"ShouldRedactPasswordInArrays": {
+			args: args{
+				body: `{"items":[{"password":"pkcs12-password"},"password"]}`,
+			},
+			want: want{
+				body: `{"items":[{"password":"REDACTED"},"password"]}`,
+			},
+		},
+		"ShouldKeepResponseWithoutSecrets": {
+			args: args{
+				body: `{"validTo":"2025-01-01T00:00:00","serial":1234567890123456789,"url":"https://ca.example.com/?a=1&b=<2>"}`,
+			},
+			want: want{
+				body: `{"serial":1234567890123456789,"url":"https://ca.example.com/?a=1&b=<2>","validTo":"2025-01-01T00:00:00"}`,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			got, err := redactSecrets(tc.args.body)
+			if err != nil {
+				t.Fatalf("redactSecrets(...): unexpected error: %v", err)
+			}
+			if diff := cmp.Diff(tc.want.body, got); diff != "" {
+				t.Fatalf("redactSecrets(...): -want body, +got body: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_setDebugRawResponse(t *testing.T) {
+	longSerial := strings.Repeat("1", 2*maxDebugRawResponseLength)
+
+	type args struct {
+		storeResponses bool
+		raw            string
+	}
+	type want struct {
+		response string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldNotStoreResponseIfDisabled": {
+			args: args{
+				storeResponses: false,
+				raw:            `{"data":"pkcs12-data","password":"pkcs12-password"}`,
+			},
+			want: want{
+				response: "",
+			},
+		},
+		"ShouldStoreRedactedResponse": {
+			args: args{
+				storeResponses: true,
+				raw:            `{"data":"pkcs12-data","password":"pkcs12-password"}`,
+			},
+			want: want{
+				response: `{"data":"REDACTED","password":"REDACTED"}`,
+			},
+		},
+		"ShouldStoreRedactedEnvelopedResponse": {
+			args: args{
+				storeResponses: true,
+				raw:            `{"data":{"form":"pfx","data":"pkcs12-data","password":"pkcs12-password"}}`,
+			},
+			want: want{
+				response: `{"data":{"data":"REDACTED","form":"pfx","password":"REDACTED"}}`,
+			},
+		},
+		"ShouldCapResponseSize": {
+			args: args{
+				storeResponses: true,
+				raw:            `{"serial":"` + longSerial + `","data":"pkcs12-data","password":"pkcs12-password"}`,
+			},
+			want: want{
+				response: truncateMessage(`{"data":"REDACTED","password":"REDACTED","serial":"`+longSerial+`"}`, maxDebugRawResponseLength),
+			},
+		},
+		"ShouldNotStoreResponseWhichCannotBeRedacted": {
+			args: args{
+				storeResponses: true,
+				raw:            `password: pkcs12-password, data: pkcs12-data`,
+			},
+			want: want{
+				response: "",
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &CertificateReconciler{Log: logr.Discard(), DebugStoreResponses: tc.args.storeResponses}
+			certificate := &v1alpha1.Certificate{}
+
+			r.setDebugRawResponse(context.Background(), certificate, tc.args.raw)
+			if diff := cmp.Diff(tc.want.response, certificate.Status.DebugRawResponse); diff != "" {
+				t.Fatalf("setDebugRawResponse(...): -want response, +got response: %v", diff)
+			}
+			if len(certificate.Status.DebugRawResponse) > maxDebugRawResponseLength {
+				t.Fatalf("setDebugRawResponse(...): response of length %d exceeds %d", len(certificate.Status.DebugRawResponse), maxDebugRawResponseLength)
+			}
+			if strings.Contains(certificate.Status.DebugRawResponse, "pkcs12-password") {
+				t.Fatalf("setDebugRawResponse(...): response holds the password: %v", certificate.Status.DebugRawResponse)
+			}
+			if strings.Contains(certificate.Status.DebugRawResponse, "pkcs12-data") {
+				t.Fatalf("setDebugRawResponse(...): response holds the PKCS#12 data: %v", certificate.Status.DebugRawResponse)
+			}
+		})
+	}
+}
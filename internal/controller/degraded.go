@@ -0,0 +1,73 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultDegradedAfterRenewalFailures is the default number of consecutive failures to renew a certificate which is
+// still valid after which the Certificate is marked Degraded.
+const DefaultDegradedAfterRenewalFailures = 3
+
+// renewalAttemptInterval is the minimal time between two counted renewal failures. Failures of the retries of a
+// renewal within it are part of the same attempt, so that a single transient error retried by the workqueue does
+// not mark the Certificate Degraded.
+const renewalAttemptInterval = 5 * time.Minute
+
+// renewalKey is the context key marking reconciles which renew a certificate that is still valid.
+type renewalKey struct{}
+
+// withRenewalOfValidCertificate returns a context marking the reconcile as renewing a certificate which is still valid.
+func withRenewalOfValidCertificate(ctx context.Context) context.Context {
+	return context.WithValue(ctx, renewalKey{}, true)
+}
+
+// isRenewalOfValidCertificate checks if the reconcile renews a certificate which is still valid.
+func isRenewalOfValidCertificate(ctx context.Context) bool {
+	renewal, _ := ctx.Value(renewalKey{}).(bool)
+	return renewal
+}
+
+// degradedAfterRenewalFailures returns the number of consecutive renewal failures after which a Certificate is
+// marked Degraded.
+func (r *CertificateReconciler) degradedAfterRenewalFailures() int32 {
+	if r.DegradedAfterRenewalFailures > 0 {
+		return r.DegradedAfterRenewalFailures
+	}
+
+	return DefaultDegradedAfterRenewalFailures
+}
+
+// recordRenewalFailure counts a failure of the reconcile if it renews a certificate which is still valid and no
+// failure was counted within the renewal attempt interval, and sets the Degraded condition once the consecutive
+// failures reach the threshold, so that the Certificate is noticed before it expires. The failures are reset, and
+// the condition removed, by removeErrorConditions.
+func (r *CertificateReconciler) recordRenewalFailure(ctx context.Context, certificate *v1alpha1.Certificate) {
+	if !isRenewalOfValidCertificate(ctx) {
+		return
+	}
+
+	now := time.Now()
+	if lastFailure := certificate.Status.LastRenewalFailureTime; !lastFailure.IsZero() && now.Sub(lastFailure.Time) < renewalAttemptInterval {
+		return
+	}
+
+	certificate.Status.RenewalFailures++
+	certificate.Status.LastRenewalFailureTime = metav1.NewTime(now)
+	if certificate.Status.RenewalFailures < r.degradedAfterRenewalFailures() {
+		return
+	}
+
+	r.logger(ctx).Info("renewal keeps failing while the certificate is still valid, marking it degraded", "renewalFailures", certificate.Status.RenewalFailures)
+	meta.SetStatusCondition(&certificate.Status.Conditions, metav1.Condition{
+		Type:    ConditionDegraded,
+		Status:  metav1.ConditionTrue,
+		Reason:  reasonRenewalFailing,
+		Message: fmt.Sprintf(errRenewalFailing, certificate.Status.RenewalFailures, certificate.Status.ValidTo.Format(time.RFC3339)),
+	})
+}
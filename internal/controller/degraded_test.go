@@ -0,0 +1,279 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_recordRenewalFailure(t *testing.T) {
+	validTo := metav1.NewTime(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	type args struct {
+		renewal                bool
+		renewalFailures        int32
+		lastRenewalFailureTime metav1.Time
+	}
+	type want struct {
+		renewalFailures int32
+		degraded        *metav1.Condition
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldNotCountFailureOutsideRenewal": {
+			args: args{
+				renewal:         false,
+				renewalFailures: 0,
+			},
+			want: want{
+				renewalFailures: 0,
+				degraded:        nil,
+			},
+		},
+		"ShouldCountFailureBelowThreshold": {
+			args: args{
+				renewal:         true,
+				renewalFailures: DefaultDegradedAfterRenewalFailures - 2,
+			},
+			want: want{
+				renewalFailures: DefaultDegradedAfterRenewalFailures - 1,
+				degraded:        nil,
+			},
+		},
+		"ShouldNotCountRetryWithinAttemptInterval": {
+			args: args{
+				renewal:                true,
+				renewalFailures:        DefaultDegradedAfterRenewalFailures - 1,
+				lastRenewalFailureTime: metav1.NewTime(time.Now().Add(-time.Minute)),
+			},
+			want: want{
+				renewalFailures: DefaultDegradedAfterRenewalFailures - 1,
+				degraded:        nil,
+			},
+		},
+		"ShouldCountFailureAfterAttemptInterval": {
+			args: args{
+				renewal:                true,
+				renewalFailures:        DefaultDegradedAfterRenewalFailures - 2,
+				lastRenewalFailureTime: metav1.NewTime(time.Now().Add(-renewalAttemptInterval)),
+			},
+			want: want{
+				renewalFailures: DefaultDegradedAfterRenewalFailures - 1,
+				degraded:        nil,
+			},
+		},
+		"ShouldSetDegradedAtThreshold": {
+			args: args{
+				renewal:         true,
+				renewalFailures: DefaultDegradedAfterRenewalFailures - 1,
+			},
+			want: want{
+				renewalFailures: DefaultDegradedAfterRenewalFailures,
+				degraded: &metav1.Condition{
+					Type:    ConditionDegraded,
+					Status:  metav1.ConditionTrue,
+					Reason:  reasonRenewalFailing,
+					Message: fmt.Sprintf(errRenewalFailing, DefaultDegradedAfterRenewalFailures, "2030-01-01T00:00:00Z"),
+				},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			r := &CertificateReconciler{Log: logr.Discard()}
+			certificate := &v1alpha1.Certificate{Status: v1alpha1.CertificateStatus{
+				ValidTo:                validTo,
+				RenewalFailures:        tc.args.renewalFailures,
+				LastRenewalFailureTime: tc.args.lastRenewalFailureTime,
+			}}
+
+			ctx := context.Background()
+			if tc.args.renewal {
+				ctx = withRenewalOfValidCertificate(ctx)
+			}
+
+			r.recordRenewalFailure(ctx, certificate)
+			if diff := cmp.Diff(tc.want.renewalFailures, certificate.Status.RenewalFailures); diff != "" {
+				t.Fatalf("recordRenewalFailure(...): -want renewal failures, +got renewal failures: %v", diff)
+			}
+
+			degraded := meta.FindStatusCondition(certificate.Status.Conditions, ConditionDegraded)
+			if degraded != nil {
+				degraded.LastTransitionTime = metav1.Time{}
+			}
+			if diff := cmp.Diff(tc.want.degraded, degraded); diff != "" {
+				t.Fatalf("recordRenewalFailure(...): -want Degraded, +got Degraded: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileRenewalFailures(t *testing.T) {
+	renewBeforePercent := 50
+	config := certificateConfig.DeepCopy()
+	config.Spec.RenewBeforePercent = &renewBeforePercent
+
+	current := certificate.DeepCopy()
+	current.Status.Guid = guid
+	current.Status.ValidFrom = metav1.NewTime(time.Now().AddDate(0, 0, -30).Truncate(time.Second))
+	current.Status.ValidTo = metav1.NewTime(time.Now().AddDate(0, 0, 2).Truncate(time.Second))
+
+	var postErr error
+	r := &CertificateReconciler{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+				switch o := obj.(type) {
+				case *v1alpha1.Certificate:
+					current.DeepCopyInto(o)
+				case *v1alpha1.CertificateConfig:
+					config.DeepCopyInto(o)
+				case *corev1.Secret:
+					if key.Name != certificateConfig.Spec.SecretRef.Name {
+						return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+					}
+					o.Data = map[string][]byte{}
+				}
+				return nil
+			},
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockPatch:  test.NewMockPatchFn(nil),
+			MockStatusPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+				obj.(*v1alpha1.Certificate).DeepCopyInto(current)
+				return nil
+			},
+		},
+		Scheme: newScheme(),
+		Log:    logr.Discard(),
+		CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+			return &MockCertClient{
+				MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+					if postErr != nil {
+						return cert.PostCertificateResponse{}, postErr
+					}
+					return cert.PostCertificateResponse{Guid: guid}, nil
+				},
+				MockGetCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.GetCertificateResponse, error) {
+					return cert.GetCertificateResponse{
+						ValidTo:   time.Now().AddDate(1, 0, 0).Format(timeFormat),
+						ValidFrom: time.Now().Format(timeFormat),
+					}, nil
+				},
+				MockDownloadCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.DownloadCertificateResponse, error) {
+					return cert.DownloadCertificateResponse{Data: validPKCS12Data, Password: validPKCS12Password}, nil
+				},
+			}, nil
+		},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+
+	postErr = errBoom
+	for failures := int32(1); failures <= DefaultDegradedAfterRenewalFailures; failures++ {
+		// The previous attempt is moved before the renewal attempt interval, as if the workqueue retried it later.
+		if !current.Status.LastRenewalFailureTime.IsZero() {
+			current.Status.LastRenewalFailureTime = metav1.NewTime(current.Status.LastRenewalFailureTime.Add(-renewalAttemptInterval))
+		}
+
+		if _, err := r.Reconcile(context.Background(), req); err == nil {
+			t.Fatalf("Reconcile(...): expected the renewal to fail")
+		}
+
+		if diff := cmp.Diff(failures, current.Status.RenewalFailures); diff != "" {
+			t.Fatalf("Reconcile(...): -want renewal failures, +got renewal failures: %v", diff)
+		}
+
+		degraded := meta.IsStatusConditionTrue(current.Status.Conditions, ConditionDegraded)
+		if diff := cmp.Diff(failures == DefaultDegradedAfterRenewalFailures, degraded); diff != "" {
+			t.Fatalf("Reconcile(...): -want Degraded, +got Degraded: %v", diff)
+		}
+	}
+
+	postErr = nil
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile(...): unexpected error: %v", err)
+	}
+
+	if diff := cmp.Diff(int32(0), current.Status.RenewalFailures); diff != "" {
+		t.Fatalf("Reconcile(...): -want renewal failures, +got renewal failures: %v", diff)
+	}
+	if meta.FindStatusCondition(current.Status.Conditions, ConditionDegraded) != nil {
+		t.Fatalf("Reconcile(...): expected the Degraded condition to be removed once the renewal succeeds")
+	}
+	if !current.Status.LastRenewalFailureTime.IsZero() {
+		t.Fatalf("Reconcile(...): expected the last renewal failure time to be cleared once the renewal succeeds")
+	}
+}
+
+func Test_ReconcileRetriedRenewalFailure(t *testing.T) {
+	renewBeforePercent := 50
+	config := certificateConfig.DeepCopy()
+	config.Spec.RenewBeforePercent = &renewBeforePercent
+
+	current := certificate.DeepCopy()
+	current.Status.Guid = guid
+	current.Status.ValidFrom = metav1.NewTime(time.Now().AddDate(0, 0, -30).Truncate(time.Second))
+	current.Status.ValidTo = metav1.NewTime(time.Now().AddDate(0, 0, 2).Truncate(time.Second))
+
+	r := &CertificateReconciler{
+		Client: &test.MockClient{
+			MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+				switch o := obj.(type) {
+				case *v1alpha1.Certificate:
+					current.DeepCopyInto(o)
+				case *v1alpha1.CertificateConfig:
+					config.DeepCopyInto(o)
+				case *corev1.Secret:
+					if key.Name != certificateConfig.Spec.SecretRef.Name {
+						return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+					}
+					o.Data = map[string][]byte{}
+				}
+				return nil
+			},
+			MockUpdate: test.NewMockUpdateFn(nil),
+			MockPatch:  test.NewMockPatchFn(nil),
+			MockStatusPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+				obj.(*v1alpha1.Certificate).DeepCopyInto(current)
+				return nil
+			},
+		},
+		Scheme: newScheme(),
+		Log:    logr.Discard(),
+		CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+			return &MockCertClient{
+				MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+					return cert.PostCertificateResponse{}, errBoom
+				},
+			}, nil
+		},
+	}
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+
+	for i := int32(0); i < 2*DefaultDegradedAfterRenewalFailures; i++ {
+		if _, err := r.Reconcile(context.Background(), req); err == nil {
+			t.Fatalf("Reconcile(...): expected the renewal to fail")
+		}
+	}
+
+	if diff := cmp.Diff(int32(1), current.Status.RenewalFailures); diff != "" {
+		t.Fatalf("Reconcile(...): -want renewal failures, +got renewal failures: %v", diff)
+	}
+	if meta.FindStatusCondition(current.Status.Conditions, ConditionDegraded) != nil {
+		t.Fatalf("Reconcile(...): expected retries of a single renewal failure not to mark the Certificate Degraded")
+	}
+}
@@ -0,0 +1,185 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	"github.com/dana-team/certificate-operator/internal/clients/cert/certtest"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+const integrationToken = "integration-token"
+
+// Test_ReconcileAgainstFakeCertAPI reconciles Certificates in an envtest API server with the real Cert and HTTP clients,
+// against a fake Cert API. It is skipped unless KUBEBUILDER_ASSETS points to the envtest binaries, as set by "make test".
+func Test_ReconcileAgainstFakeCertAPI(t *testing.T) {
+	if os.Getenv("KUBEBUILDER_ASSETS") == "" {
+		t.Skip("KUBEBUILDER_ASSETS is not set, skipping envtest integration test")
+	}
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths:     []string{filepath.Join("..", "..", "config", "crd", "bases")},
+		ErrorIfCRDPathMissing: true,
+	}
+	cfg, err := testEnv.Start()
+	if err != nil {
+		t.Fatalf("Start(...): unexpected error: %v", err)
+	}
+	defer func() {
+		_ = testEnv.Stop()
+	}()
+
+	scheme := newScheme()
+	kubeClient, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		t.Fatalf("New(...): unexpected error: %v", err)
+	}
+
+	type args struct {
+		operation  certtest.Operation
+		statusCode int
+		latency    time.Duration
+	}
+	type want struct {
+		condition metav1.Condition
+		secret    bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldIssueCertificate": {
+			args: args{},
+			want: want{
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionTrue, Reason: reasonSecretUpdated},
+				secret:    true,
+			},
+		},
+		"ShouldReportFailedPost": {
+			args: args{
+				operation:  certtest.OperationPost,
+				statusCode: http.StatusInternalServerError,
+			},
+			want: want{
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionFalse, Reason: reasonPostFailed},
+			},
+		},
+		"ShouldReportFailedDownload": {
+			args: args{
+				operation:  certtest.OperationDownload,
+				statusCode: http.StatusBadGateway,
+			},
+			want: want{
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionFalse, Reason: reasonDownloadFailed},
+			},
+		},
+		"ShouldReportTimedOutPost": {
+			args: args{
+				latency: time.Second,
+			},
+			want: want{
+				condition: metav1.Condition{Type: ConditionSynced, Status: metav1.ConditionFalse, Reason: reasonPostFailed},
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			suffix := fakeCertAPIName(name)
+
+			server := certtest.NewServer(integrationToken, validPKCS12Data, validPKCS12Password)
+			defer server.Close()
+
+			server.SetLatency(tc.args.latency)
+			if tc.args.operation != "" {
+				server.FailWith(tc.args.operation, tc.args.statusCode)
+			}
+
+			credentials := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "credentials-" + suffix, Namespace: "default"},
+				Data:       server.Credentials(),
+			}
+			config := &v1alpha1.CertificateConfig{
+				ObjectMeta: metav1.ObjectMeta{Name: "config-" + suffix},
+				Spec: v1alpha1.CertificateConfigSpec{
+					SecretRef:         v1alpha1.SecretRef{Name: credentials.Name, Namespace: credentials.Namespace},
+					DaysBeforeRenewal: 7,
+					WaitTimeout:       &metav1.Duration{Duration: 500 * time.Millisecond},
+				},
+			}
+			certificate := certificate.DeepCopy()
+			certificate.ObjectMeta = metav1.ObjectMeta{Name: "cert-" + suffix, Namespace: "default"}
+			certificate.Spec.ConfigRef.Name = config.Name
+			certificate.Spec.SecretName = "tls-" + suffix
+
+			for _, obj := range []client.Object{credentials, config, certificate} {
+				if err := kubeClient.Create(ctx, obj); err != nil {
+					t.Fatalf("Create(...): unexpected error: %v", err)
+				}
+			}
+
+			r := &CertificateReconciler{
+				Client:            kubeClient,
+				Scheme:            scheme,
+				Log:               logr.Discard(),
+				CertClientBuilder: cert.NewClientFromCertificateConfigAndSecretData,
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			_, _ = r.Reconcile(ctx, req)
+
+			got := &v1alpha1.Certificate{}
+			if err := kubeClient.Get(ctx, req.NamespacedName, got); err != nil {
+				t.Fatalf("Get(...): unexpected error: %v", err)
+			}
+
+			synced := meta.FindStatusCondition(got.Status.Conditions, ConditionSynced)
+			if synced == nil {
+				t.Fatalf("Reconcile(...): missing %s condition", ConditionSynced)
+			}
+			if diff := cmp.Diff(tc.want.condition, *synced, cmpopts.IgnoreFields(metav1.Condition{}, "Message", "LastTransitionTime", "ObservedGeneration")); diff != "" {
+				t.Fatalf("Reconcile(...): -want condition, +got condition: %v", diff)
+			}
+
+			secret := &corev1.Secret{}
+			err := kubeClient.Get(ctx, types.NamespacedName{Name: certificate.Spec.SecretName, Namespace: certificate.Namespace}, secret)
+			if diff := cmp.Diff(tc.want.secret, err == nil); diff != "" {
+				t.Fatalf("Reconcile(...): -want secret, +got secret: %v", diff)
+			}
+			if tc.want.secret && len(secret.Data[corev1.TLSCertKey]) == 0 {
+				t.Fatalf("Reconcile(...): expected the secret to hold the certificate")
+			}
+		})
+	}
+}
+
+// fakeCertAPIName returns a lowercase name for the resources of a test case.
+func fakeCertAPIName(name string) string {
+	var lower []rune
+	for _, r := range name {
+		if r >= 'A' && r <= 'Z' {
+			if len(lower) > 0 {
+				lower = append(lower, '-')
+			}
+			r += 'a' - 'A'
+		}
+		lower = append(lower, r)
+	}
+
+	return string(lower)
+}
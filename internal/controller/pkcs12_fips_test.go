@@ -0,0 +1,32 @@
+//go:build fips
+
+package controller
+
+import (
+	"crypto/x509"
+
+	"github.com/dana-team/certificate-operator/pkg/pkcs12provider"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// FIPS builds have no default PKCS#12 implementation, so the tests register one decoding and encoding the PKCS#12
+// fixtures, as a FIPS build of the operator registers a FIPS-approved implementation.
+func init() {
+	pkcs12provider.SetDecoder(testPKCS12{})
+	pkcs12provider.SetEncoder(testPKCS12{})
+}
+
+// testPKCS12 is the PKCS#12 implementation of the tests in FIPS builds.
+type testPKCS12 struct{}
+
+func (testPKCS12) DecodeChain(pfxData []byte, password string) (interface{}, *x509.Certificate, []*x509.Certificate, error) {
+	return pkcs12.DecodeChain(pfxData, password)
+}
+
+func (testPKCS12) Encode(privateKey interface{}, certificate *x509.Certificate, caCerts []*x509.Certificate, password string) ([]byte, error) {
+	return pkcs12.Modern2023.Encode(privateKey, certificate, caCerts, password)
+}
+
+func (testPKCS12) EncodeTrustStore(certs []*x509.Certificate, password string) ([]byte, error) {
+	return pkcs12.Modern2023.EncodeTrustStore(certs, password)
+}
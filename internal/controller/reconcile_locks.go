@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// reconcileLocks serializes the reconciles of the same Certificate, so that they do not race on the writes of its
+// Secrets, while reconciles of different Certificates run concurrently. The zero value is ready to use.
+type reconcileLocks struct {
+	mu    sync.Mutex
+	locks map[types.NamespacedName]*reconcileLock
+}
+
+// reconcileLock is the lock of a Certificate, with the number of reconciles holding or waiting for it.
+type reconcileLock struct {
+	sync.Mutex
+	refs int
+}
+
+// lock locks the Certificate, waiting for the reconciles holding its lock, and returns the function unlocking it.
+// The lock of a Certificate is forgotten once no reconcile holds or waits for it.
+func (l *reconcileLocks) lock(key types.NamespacedName) func() {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = map[types.NamespacedName]*reconcileLock{}
+	}
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &reconcileLock{}
+		l.locks[key] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	lock.Lock()
+
+	return func() {
+		lock.Unlock()
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+
+		lock.refs--
+		if lock.refs == 0 {
+			delete(l.locks, key)
+		}
+	}
+}
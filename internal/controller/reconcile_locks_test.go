@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_reconcileLocksSameKey(t *testing.T) {
+	var locks reconcileLocks
+	key := types.NamespacedName{Namespace: "default", Name: "certificate"}
+
+	var holders, maxHolders int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			unlock := locks.lock(key)
+			defer unlock()
+
+			current := atomic.AddInt32(&holders, 1)
+			for {
+				observed := atomic.LoadInt32(&maxHolders)
+				if current <= observed || atomic.CompareAndSwapInt32(&maxHolders, observed, current) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&holders, -1)
+		}()
+	}
+	wg.Wait()
+
+	if diff := cmp.Diff(int32(1), maxHolders); diff != "" {
+		t.Fatalf("lock(...): -want concurrent holders, +got concurrent holders: %v", diff)
+	}
+
+	if diff := cmp.Diff(0, len(locks.locks)); diff != "" {
+		t.Fatalf("lock(...): -want remaining locks, +got remaining locks: %v", diff)
+	}
+}
+
+func Test_reconcileLocksDifferentKeys(t *testing.T) {
+	var locks reconcileLocks
+
+	unlock := locks.lock(types.NamespacedName{Namespace: "default", Name: "certificate-a"})
+	defer unlock()
+
+	locked := make(chan struct{})
+	go func() {
+		unlockOther := locks.lock(types.NamespacedName{Namespace: "default", Name: "certificate-b"})
+		defer unlockOther()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("lock(...): the lock of another Certificate was not acquired while the first one was held")
+	}
+}
+
+func Test_reconcileLocksWaitsForUnlock(t *testing.T) {
+	var locks reconcileLocks
+	key := types.NamespacedName{Namespace: "default", Name: "certificate"}
+
+	unlock := locks.lock(key)
+
+	locked := make(chan struct{})
+	go func() {
+		unlockOther := locks.lock(key)
+		defer unlockOther()
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		t.Fatalf("lock(...): the lock of the Certificate was acquired while it was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("lock(...): the lock of the Certificate was not acquired after it was released")
+	}
+}
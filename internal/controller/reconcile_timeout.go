@@ -0,0 +1,54 @@
+package controller
+
+import (
+	"context"
+	goerrors "errors"
+	"fmt"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// DefaultReconcileTimeout is the default maximum duration of the reconcile of a Certificate. It is larger than
+// cert.DefaultMaxWaitTimeout, so that a reconcile is not abandoned while waiting for a single response of the Cert API.
+const DefaultReconcileTimeout = 15 * time.Minute
+
+const (
+	errReconcileTimedOut           = "reconcile did not complete within %v and was abandoned: %w"
+	errReconcileTimeoutNotLarger   = "reconcile timeout %v must be larger than the max wait timeout %v"
+	errReconcileTimeoutNotPositive = "reconcile timeout %v must be positive"
+)
+
+// ValidateReconcileTimeout checks that the reconcile timeout is larger than the maximum waitTimeout of
+// CertificateConfigs, so that a reconcile is not abandoned while it waits for the Cert API.
+func ValidateReconcileTimeout(reconcileTimeout, maxWaitTimeout time.Duration) error {
+	if reconcileTimeout <= 0 {
+		return fmt.Errorf(errReconcileTimeoutNotPositive, reconcileTimeout)
+	}
+	if reconcileTimeout <= maxWaitTimeout {
+		return fmt.Errorf(errReconcileTimeoutNotLarger, reconcileTimeout, maxWaitTimeout)
+	}
+
+	return nil
+}
+
+// reconcileTimeout returns the maximum duration of the reconcile of a Certificate.
+func (r *CertificateReconciler) reconcileTimeout() time.Duration {
+	if r.ReconcileTimeout > 0 {
+		return r.ReconcileTimeout
+	}
+
+	return DefaultReconcileTimeout
+}
+
+// abandonTimedOutReconcile replaces the result of a reconcile whose context reached its deadline with an error,
+// so that the Certificate is requeued with backoff rather than blocking a worker.
+func (r *CertificateReconciler) abandonTimedOutReconcile(ctx context.Context, result *ctrl.Result, err *error) {
+	if !goerrors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return
+	}
+
+	r.logger(ctx).Info("reconcile timed out, abandoning it", "timeout", r.reconcileTimeout())
+	*result = ctrl.Result{}
+	*err = fmt.Errorf(errReconcileTimedOut, r.reconcileTimeout(), ctx.Err())
+}
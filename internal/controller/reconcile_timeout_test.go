@@ -0,0 +1,177 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/clients/cert"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_ValidateReconcileTimeout(t *testing.T) {
+	type args struct {
+		reconcileTimeout time.Duration
+		maxWaitTimeout   time.Duration
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldAcceptDefaults": {
+			args: args{
+				reconcileTimeout: DefaultReconcileTimeout,
+				maxWaitTimeout:   cert.DefaultMaxWaitTimeout,
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldFailTimeoutEqualToMaxWaitTimeout": {
+			args: args{
+				reconcileTimeout: time.Minute,
+				maxWaitTimeout:   time.Minute,
+			},
+			want: want{
+				err: fmt.Errorf(errReconcileTimeoutNotLarger, time.Minute, time.Minute),
+			},
+		},
+		"ShouldFailTimeoutSmallerThanMaxWaitTimeout": {
+			args: args{
+				reconcileTimeout: time.Minute,
+				maxWaitTimeout:   cert.DefaultMaxWaitTimeout,
+			},
+			want: want{
+				err: fmt.Errorf(errReconcileTimeoutNotLarger, time.Minute, cert.DefaultMaxWaitTimeout),
+			},
+		},
+		"ShouldFailNonPositiveTimeout": {
+			args: args{
+				reconcileTimeout: 0,
+				maxWaitTimeout:   cert.DefaultMaxWaitTimeout,
+			},
+			want: want{
+				err: fmt.Errorf(errReconcileTimeoutNotPositive, time.Duration(0)),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := ValidateReconcileTimeout(tc.args.reconcileTimeout, tc.args.maxWaitTimeout)
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("ValidateReconcileTimeout(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ReconcileTimeout(t *testing.T) {
+	const reconcileTimeout = 50 * time.Millisecond
+
+	type args struct {
+		stuck bool
+	}
+	type want struct {
+		result    ctrl.Result
+		err       error
+		cancelled bool
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldAbandonStuckReconcile": {
+			args: args{
+				stuck: true,
+			},
+			want: want{
+				result:    ctrl.Result{},
+				err:       fmt.Errorf(errReconcileTimedOut, reconcileTimeout, context.DeadlineExceeded),
+				cancelled: true,
+			},
+		},
+		"ShouldNotAbandonReconcileCompletingInTime": {
+			args: args{
+				stuck: false,
+			},
+			want: want{
+				result:    ctrl.Result{RequeueAfter: requeueAfterPendingCertificate},
+				err:       nil,
+				cancelled: false,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var cancelled bool
+
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockGet: func(_ context.Context, key client.ObjectKey, obj client.Object) error {
+						switch o := obj.(type) {
+						case *v1alpha1.Certificate:
+							certificate.DeepCopyInto(o)
+						case *v1alpha1.CertificateConfig:
+							certificateConfig.DeepCopyInto(o)
+						case *corev1.Secret:
+							if key.Name != certificateConfig.Spec.SecretRef.Name {
+								return kerrors.NewNotFound(corev1.Resource("secrets"), key.Name)
+							}
+							o.Data = map[string][]byte{}
+						}
+						return nil
+					},
+					MockUpdate:      test.NewMockUpdateFn(nil),
+					MockPatch:       test.NewMockPatchFn(nil),
+					MockStatusPatch: test.NewMockSubResourcePatchFn(nil),
+				},
+				Scheme:           newScheme(),
+				Log:              logr.Discard(),
+				ReconcileTimeout: reconcileTimeout,
+				CertClientBuilder: func(logr.Logger, *v1alpha1.CertificateConfig, map[string][]byte) (cert.Client, error) {
+					return &MockCertClient{
+						MockPostCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.PostCertificateResponse, error) {
+							if !tc.args.stuck {
+								return cert.PostCertificateResponse{Guid: guid}, nil
+							}
+							select {
+							case <-ctx.Done():
+								cancelled = true
+								return cert.PostCertificateResponse{}, ctx.Err()
+							case <-time.After(5 * time.Second):
+								return cert.PostCertificateResponse{Guid: guid}, nil
+							}
+						},
+						MockGetCertificate: func(ctx context.Context, certificate *v1alpha1.Certificate) (cert.GetCertificateResponse, error) {
+							return cert.GetCertificateResponse{Status: cert.CAStatusPending}, nil
+						},
+					}, nil
+				},
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: certificate.Name, Namespace: certificate.Namespace}}
+			result, err := r.Reconcile(context.Background(), req)
+			if diff := cmp.Diff(tc.want.result, result); diff != "" {
+				t.Fatalf("Reconcile(...): -want result, +got result: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("Reconcile(...): -want error, +got error: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.cancelled, cancelled); diff != "" {
+				t.Fatalf("Reconcile(...): -want cancelled, +got cancelled: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+const (
+	errResyncCacheNotSynced = "cache did not sync before resyncing Certificates"
+	errResyncListFailed     = "failed to list Certificates to resync: %w"
+)
+
+// cacheSyncWaiter waits for the informers of a cache to sync.
+type cacheSyncWaiter interface {
+	WaitForCacheSync(ctx context.Context) bool
+}
+
+// certificateResync is a runnable enqueuing all Certificates once, when the operator starts or becomes the leader,
+// so that a new leader re-evaluates them promptly rather than waiting for their next event.
+type certificateResync struct {
+	client client.Reader
+	cache  cacheSyncWaiter
+	events chan<- event.GenericEvent
+	log    logr.Logger
+}
+
+// Start enqueues all Certificates once the cache has synced, by sending a generic event for each of them.
+// It returns once all Certificates are enqueued, or the context is cancelled.
+func (c *certificateResync) Start(ctx context.Context) error {
+	if !c.cache.WaitForCacheSync(ctx) {
+		return errors.New(errResyncCacheNotSynced)
+	}
+
+	certificateList := &v1alpha1.CertificateList{}
+	if err := c.client.List(ctx, certificateList); err != nil {
+		return fmt.Errorf(errResyncListFailed, err)
+	}
+
+	c.log.Info("resyncing Certificates", "count", len(certificateList.Items))
+	for i := range certificateList.Items {
+		select {
+		case c.events <- event.GenericEvent{Object: &certificateList.Items[i]}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// NeedLeaderElection makes the resync start once the operator becomes the leader.
+func (c *certificateResync) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,153 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+// mockCacheSyncWaiter is a cacheSyncWaiter reporting whether the cache synced.
+type mockCacheSyncWaiter bool
+
+func (m mockCacheSyncWaiter) WaitForCacheSync(context.Context) bool {
+	return bool(m)
+}
+
+func Test_certificateResyncStart(t *testing.T) {
+	certificates := []v1alpha1.Certificate{
+		{ObjectMeta: metav1.ObjectMeta{Name: "certificate-a", Namespace: "default"}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "certificate-b", Namespace: "other"}},
+	}
+
+	type args struct {
+		synced  bool
+		listErr error
+	}
+	type want struct {
+		enqueued []string
+		err      error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldEnqueueAllCertificates": {
+			args: args{
+				synced: true,
+			},
+			want: want{
+				enqueued: []string{"default/certificate-a", "other/certificate-b"},
+				err:      nil,
+			},
+		},
+		"ShouldFailIfCacheDidNotSync": {
+			args: args{
+				synced: false,
+			},
+			want: want{
+				enqueued: nil,
+				err:      errors.New(errResyncCacheNotSynced),
+			},
+		},
+		"ShouldFailListingCertificates": {
+			args: args{
+				synced:  true,
+				listErr: errBoom,
+			},
+			want: want{
+				enqueued: nil,
+				err:      fmt.Errorf(errResyncListFailed, errBoom),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			events := make(chan event.GenericEvent)
+			resync := &certificateResync{
+				client: &test.MockClient{
+					MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+						if tc.args.listErr != nil {
+							return tc.args.listErr
+						}
+						list.(*v1alpha1.CertificateList).Items = append([]v1alpha1.Certificate{}, certificates...)
+						return nil
+					},
+				},
+				cache:  mockCacheSyncWaiter(tc.args.synced),
+				events: events,
+				log:    logr.Discard(),
+			}
+
+			errs := make(chan error, 1)
+			go func() {
+				errs <- resync.Start(context.Background())
+			}()
+
+			var enqueued []string
+			var err error
+			for done := false; !done; {
+				select {
+				case e := <-events:
+					enqueued = append(enqueued, client.ObjectKeyFromObject(e.Object).String())
+				case err = <-errs:
+					done = true
+				case <-time.After(5 * time.Second):
+					t.Fatalf("Start(...): did not return")
+				}
+			}
+
+			if diff := cmp.Diff(tc.want.enqueued, enqueued); diff != "" {
+				t.Fatalf("Start(...): -want enqueued, +got enqueued: %v", diff)
+			}
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("Start(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_certificateResyncStopsOnCancel(t *testing.T) {
+	resync := &certificateResync{
+		client: &test.MockClient{
+			MockList: func(_ context.Context, list client.ObjectList, _ ...client.ListOption) error {
+				list.(*v1alpha1.CertificateList).Items = []v1alpha1.Certificate{{}}
+				return nil
+			},
+		},
+		cache:  mockCacheSyncWaiter(true),
+		events: make(chan event.GenericEvent),
+		log:    logr.Discard(),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		errs <- resync.Start(ctx)
+	}()
+	cancel()
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			t.Fatalf("Start(...): unexpected error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Start(...): did not return once the context was cancelled")
+	}
+}
+
+func Test_certificateResyncNeedLeaderElection(t *testing.T) {
+	if !(&certificateResync{}).NeedLeaderElection() {
+		t.Fatalf("NeedLeaderElection(...): the resync must start once the operator becomes the leader")
+	}
+}
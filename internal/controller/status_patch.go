@@ -0,0 +1,72 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// lastReconcileTimeInterval is the age after which the last reconcile time of a Certificate is advanced by a successful
+// reconcile which changes no other field of its status, so that it tells stuck Certificates apart from healthy ones
+// without writing the status on every reconcile.
+const lastReconcileTimeInterval = 5 * time.Minute
+
+// statusBaseKey is the context key of the status of the Certificate as last read from or written to the API server.
+type statusBaseKey struct{}
+
+// withStatusBase returns a context holding the status of the Certificate as read from the API server, against which
+// the status patches of the reconcile are computed.
+func withStatusBase(ctx context.Context, certificate *v1alpha1.Certificate) context.Context {
+	return context.WithValue(ctx, statusBaseKey{}, certificate.Status.DeepCopy())
+}
+
+// patchStatus patches the status of the Certificate with a merge patch of the fields changed since it was last read
+// or patched in the reconcile, instead of updating the whole status, so that it does not conflict with or overwrite
+// concurrent writes of other fields. Without a status in the context, every set field of the status is patched.
+// The status is not patched if it did not change, so that a steady-state reconcile does not write to the API server.
+// The messages of the conditions are truncated to the MaxConditionMessageLength of the reconciler first.
+func (r *CertificateReconciler) patchStatus(ctx context.Context, certificate *v1alpha1.Certificate) error {
+	truncateConditionMessages(certificate.Status.Conditions, maxConditionMessageLength(r.MaxConditionMessageLength))
+
+	base, _ := ctx.Value(statusBaseKey{}).(*v1alpha1.CertificateStatus)
+	if base != nil && equality.Semantic.DeepEqual(*base, certificate.Status) {
+		return nil
+	}
+
+	original := certificate.DeepCopy()
+	original.Status = v1alpha1.CertificateStatus{}
+	if base != nil {
+		base.DeepCopyInto(&original.Status)
+	}
+
+	if err := r.Client.Status().Patch(ctx, certificate, client.MergeFrom(original)); err != nil {
+		return err
+	}
+
+	if base != nil {
+		certificate.Status.DeepCopyInto(base)
+	}
+
+	return nil
+}
+
+// markReconciled advances the last reconcile time of the Certificate at the end of a successful reconcile. It is
+// throttled if the reconcile changed no other field of its status since it was last read or patched: the time is then
+// only advanced once it is older than the lastReconcileTimeInterval, so that reconciling a Certificate in its steady
+// state does not write to its status every time.
+func markReconciled(ctx context.Context, certificate *v1alpha1.Certificate) {
+	base, _ := ctx.Value(statusBaseKey{}).(*v1alpha1.CertificateStatus)
+	if base != nil && time.Since(base.LastReconcileTime.Time) < lastReconcileTimeInterval {
+		status := certificate.Status.DeepCopy()
+		status.LastReconcileTime = base.LastReconcileTime
+		if equality.Semantic.DeepEqual(*base, *status) {
+			return
+		}
+	}
+
+	certificate.Status.LastReconcileTime = metav1.Now()
+}
@@ -0,0 +1,222 @@
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	"github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/google/go-cmp/cmp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+func Test_patchStatus(t *testing.T) {
+	type args struct {
+		base   bool
+		status v1alpha1.CertificateStatus
+		mutate []func(status *v1alpha1.CertificateStatus)
+	}
+	type want struct {
+		patches []string
+		err     error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldPatchOnlyChangedFields": {
+			args: args{
+				base:   true,
+				status: v1alpha1.CertificateStatus{Guid: guid, DownloadFailures: 2},
+				mutate: []func(status *v1alpha1.CertificateStatus){
+					func(status *v1alpha1.CertificateStatus) { status.LastError = errBoom.Error() },
+				},
+			},
+			want: want{
+				patches: []string{`{"status":{"lastError":"boom"}}`},
+				err:     nil,
+			},
+		},
+		"ShouldPatchAgainstPreviousPatch": {
+			args: args{
+				base:   true,
+				status: v1alpha1.CertificateStatus{Guid: guid},
+				mutate: []func(status *v1alpha1.CertificateStatus){
+					func(status *v1alpha1.CertificateStatus) { status.LastError = errBoom.Error() },
+					func(status *v1alpha1.CertificateStatus) { status.Guid = "other-guid" },
+				},
+			},
+			want: want{
+				patches: []string{`{"status":{"lastError":"boom"}}`, `{"status":{"guid":"other-guid"}}`},
+				err:     nil,
+			},
+		},
+		"ShouldRemoveClearedFields": {
+			args: args{
+				base:   true,
+				status: v1alpha1.CertificateStatus{Guid: guid, LastError: errBoom.Error()},
+				mutate: []func(status *v1alpha1.CertificateStatus){
+					func(status *v1alpha1.CertificateStatus) { status.LastError = "" },
+				},
+			},
+			want: want{
+				patches: []string{`{"status":{"lastError":null}}`},
+				err:     nil,
+			},
+		},
+		"ShouldPatchSetFieldsWithoutBase": {
+			args: args{
+				base:   false,
+				status: v1alpha1.CertificateStatus{Guid: guid},
+				mutate: []func(status *v1alpha1.CertificateStatus){
+					func(status *v1alpha1.CertificateStatus) { status.LastError = errBoom.Error() },
+				},
+			},
+			want: want{
+				patches: []string{`{"status":{"guid":"guid","lastError":"boom"}}`},
+				err:     nil,
+			},
+		},
+		"ShouldNotPatchUnchangedStatus": {
+			args: args{
+				base:   true,
+				status: v1alpha1.CertificateStatus{Guid: guid},
+				mutate: []func(status *v1alpha1.CertificateStatus){
+					func(status *v1alpha1.CertificateStatus) { status.Guid = guid },
+				},
+			},
+			want: want{
+				patches: nil,
+				err:     nil,
+			},
+		},
+		"ShouldFailWhenPatchFails": {
+			args: args{
+				base:   true,
+				status: v1alpha1.CertificateStatus{Guid: guid},
+				mutate: []func(status *v1alpha1.CertificateStatus){
+					func(status *v1alpha1.CertificateStatus) { status.LastError = errBoom.Error() },
+				},
+			},
+			want: want{
+				patches: []string{`{"status":{"lastError":"boom"}}`},
+				err:     errBoom,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var patches []string
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockStatusPatch: func(_ context.Context, obj client.Object, patch client.Patch, _ ...client.SubResourcePatchOption) error {
+						if diff := cmp.Diff(types.MergePatchType, patch.Type()); diff != "" {
+							t.Fatalf("patchStatus(...): -want patch type, +got patch type: %v", diff)
+						}
+						data, err := patch.Data(obj)
+						if err != nil {
+							t.Fatalf("Data(...): unexpected error: %v", err)
+						}
+						patches = append(patches, string(data))
+						return tc.want.err
+					},
+				},
+				Log: logr.Logger{},
+			}
+
+			testCertificate := certificate.DeepCopy()
+			testCertificate.Status = tc.args.status
+
+			ctx := context.Background()
+			if tc.args.base {
+				ctx = withStatusBase(ctx, testCertificate)
+			}
+
+			var err error
+			for _, mutate := range tc.args.mutate {
+				mutate(&testCertificate.Status)
+				if err = r.patchStatus(ctx, testCertificate); err != nil {
+					break
+				}
+			}
+
+			if diff := cmp.Diff(tc.want.err, err, test.EquateErrors()); diff != "" {
+				t.Fatalf("patchStatus(...): -want error, +got error: %v", diff)
+			}
+
+			if diff := cmp.Diff(tc.want.patches, patches); diff != "" {
+				t.Fatalf("patchStatus(...): -want patches, +got patches: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_patchStatusTruncatesConditionMessages(t *testing.T) {
+	message := strings.Repeat("a", 2*DefaultMaxConditionMessageLength)
+
+	type args struct {
+		maxConditionMessageLength int
+	}
+	type want struct {
+		message string
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldTruncateToDefaultLength": {
+			args: args{
+				maxConditionMessageLength: 0,
+			},
+			want: want{
+				message: message[:DefaultMaxConditionMessageLength-len(ellipsis)] + ellipsis,
+			},
+		},
+		"ShouldTruncateToConfiguredLength": {
+			args: args{
+				maxConditionMessageLength: 8,
+			},
+			want: want{
+				message: "aaaaa" + ellipsis,
+			},
+		},
+		"ShouldNotTruncateWhenDisabled": {
+			args: args{
+				maxConditionMessageLength: -1,
+			},
+			want: want{
+				message: message,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			var patched *v1alpha1.Certificate
+			r := &CertificateReconciler{
+				Client: &test.MockClient{
+					MockStatusPatch: func(_ context.Context, obj client.Object, _ client.Patch, _ ...client.SubResourcePatchOption) error {
+						patched = obj.(*v1alpha1.Certificate).DeepCopy()
+						return nil
+					},
+				},
+				Log:                       logr.Logger{},
+				MaxConditionMessageLength: tc.args.maxConditionMessageLength,
+			}
+
+			testCertificate := certificate.DeepCopy()
+			testCertificate.Status.Conditions = []metav1.Condition{{Type: ConditionSynced, Status: metav1.ConditionFalse, Reason: reasonDownloadFailed, Message: message}}
+
+			if err := r.patchStatus(context.Background(), testCertificate); err != nil {
+				t.Fatalf("patchStatus(...): unexpected error: %v", err)
+			}
+
+			if diff := cmp.Diff(tc.want.message, patched.Status.Conditions[0].Message); diff != "" {
+				t.Fatalf("patchStatus(...): -want message, +got message: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DefaultTerminalErrorRequeueAfter is the default interval at which Certificates failing with terminal errors are requeued.
+const DefaultTerminalErrorRequeueAfter = 5 * time.Minute
+
+// terminalStatusCodes are the status codes of Cert API responses which fail the same way until the request changes,
+// so retrying them only wastes requests to the Cert API.
+var terminalStatusCodes = []int{
+	http.StatusBadRequest,
+	http.StatusUnauthorized,
+	http.StatusForbidden,
+	http.StatusUnprocessableEntity,
+}
+
+// isTerminalError checks if the error is a response of the Cert API which fails the same way until the
+// Certificate or its CertificateConfig change.
+func isTerminalError(err error) bool {
+	code, ok := httpClient.StatusCode(err)
+	return ok && slices.Contains(terminalStatusCodes, code)
+}
+
+// terminalErrorVersion returns the version of the Certificate and CertificateConfig a terminal error occurred with.
+// A change of the Certificate spec, the CertificateConfig spec or the referenced Secret changes the version.
+func terminalErrorVersion(certificate *v1alpha1.Certificate, certificateConfig *v1alpha1.CertificateConfig, secret *corev1.Secret) string {
+	return fmt.Sprintf("%d/%s", certificate.Generation, certClientVersion(certificateConfig, secret))
+}
+
+// terminalErrors records the Certificates which failed with terminal errors, so that the Cert API is not requested
+// for them again until their version changes. The zero value is ready to use.
+type terminalErrors struct {
+	mu      sync.Mutex
+	entries map[types.NamespacedName]string
+}
+
+// record records that the Certificate failed with a terminal error at the given version.
+func (t *terminalErrors) record(key types.NamespacedName, version string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.entries == nil {
+		t.entries = map[types.NamespacedName]string{}
+	}
+	t.entries[key] = version
+}
+
+// blocked checks if the Certificate failed with a terminal error at the given version.
+// A terminal error recorded at another version is forgotten.
+func (t *terminalErrors) blocked(key types.NamespacedName, version string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	recorded, ok := t.entries[key]
+	if !ok {
+		return false
+	}
+	if recorded != version {
+		delete(t.entries, key)
+		return false
+	}
+
+	return true
+}
+
+// forget forgets the terminal error of the Certificate.
+func (t *terminalErrors) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.entries, key)
+}
+
+// terminalErrorRequeueAfter returns the interval at which Certificates failing with terminal errors are requeued.
+func (r *CertificateReconciler) terminalErrorRequeueAfter() time.Duration {
+	if r.TerminalErrorRequeueAfter > 0 {
+		return r.TerminalErrorRequeueAfter
+	}
+
+	return DefaultTerminalErrorRequeueAfter
+}
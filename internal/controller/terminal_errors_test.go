@@ -0,0 +1,86 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	httpClient "github.com/dana-team/certificate-operator/internal/clients/http"
+	"github.com/google/go-cmp/cmp"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func Test_isTerminalError(t *testing.T) {
+	cases := map[string]struct {
+		err  error
+		want bool
+	}{
+		"ShouldClassifyBadRequestAsTerminal": {
+			err:  fmt.Errorf(errCreationFailed, &httpClient.APIError{StatusCode: http.StatusBadRequest}),
+			want: true,
+		},
+		"ShouldClassifyUnauthorizedAsTerminal": {
+			err:  &httpClient.APIError{StatusCode: http.StatusUnauthorized},
+			want: true,
+		},
+		"ShouldClassifyForbiddenAsTerminal": {
+			err:  &httpClient.APIError{StatusCode: http.StatusForbidden},
+			want: true,
+		},
+		"ShouldClassifyUnprocessableEntityAsTerminal": {
+			err:  &httpClient.APIError{StatusCode: http.StatusUnprocessableEntity},
+			want: true,
+		},
+		"ShouldClassifyServerErrorAsTransient": {
+			err:  &httpClient.APIError{StatusCode: http.StatusInternalServerError},
+			want: false,
+		},
+		"ShouldClassifyNotFoundAsTransient": {
+			err:  &httpClient.APIError{StatusCode: http.StatusNotFound},
+			want: false,
+		},
+		"ShouldNotClassifyMessageWithoutStatusCode": {
+			err:  errors.New(http.StatusText(http.StatusBadRequest)),
+			want: false,
+		},
+		"ShouldClassifyOtherErrorAsTransient": {
+			err:  errBoom,
+			want: false,
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if diff := cmp.Diff(tc.want, isTerminalError(tc.err)); diff != "" {
+				t.Errorf("isTerminalError(...): -want, +got: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_terminalErrors(t *testing.T) {
+	key := types.NamespacedName{Name: "certificate", Namespace: "default"}
+
+	var errs terminalErrors
+	if errs.blocked(key, "1/1/1") {
+		t.Fatalf("blocked(...): expected an unrecorded Certificate not to be blocked")
+	}
+
+	errs.record(key, "1/1/1")
+	if !errs.blocked(key, "1/1/1") {
+		t.Fatalf("blocked(...): expected the Certificate to be blocked at the recorded version")
+	}
+
+	if errs.blocked(key, "2/1/1") {
+		t.Fatalf("blocked(...): expected the Certificate not to be blocked at a new version")
+	}
+	if errs.blocked(key, "1/1/1") {
+		t.Fatalf("blocked(...): expected the terminal error to be forgotten after a version change")
+	}
+
+	errs.record(key, "1/1/1")
+	errs.forget(key)
+	if errs.blocked(key, "1/1/1") {
+		t.Fatalf("blocked(...): expected a forgotten Certificate not to be blocked")
+	}
+}
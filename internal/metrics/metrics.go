@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// OperationCreate is the operation label value of a Secret creation.
+	OperationCreate = "create"
+	// OperationUpdate is the operation label value of a Secret update.
+	OperationUpdate = "update"
+	// OperationRecreate is the operation label value of an immutable Secret deleted and created again.
+	OperationRecreate = "recreate"
+)
+
+var (
+	// SecretOperations counts the operations performed on Secrets, by operation.
+	SecretOperations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "certificate_operator_secret_operations_total",
+			Help: "Total number of operations performed on Secrets, by operation.",
+		},
+		[]string{"operation"},
+	)
+
+	// CertificatesInError is the number of Certificates currently in an error state.
+	CertificatesInError = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "certificate_operator_certificates_in_error",
+			Help: "Number of Certificates currently in an error state.",
+		},
+	)
+
+	// CertificateExpiry is the expiry time of the certificate of every Certificate, as a Unix timestamp in seconds.
+	CertificateExpiry = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "certificate_operator_expiry_timestamp_seconds",
+			Help: "Expiry time of the certificate of the Certificate, as a Unix timestamp in seconds.",
+		},
+		[]string{"namespace", "name"},
+	)
+
+	erroredMu           sync.Mutex
+	erroredCertificates = map[string]struct{}{}
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(SecretOperations, CertificatesInError, CertificateExpiry)
+}
+
+// RegisterBuildInfo registers the certificate_operator_build_info gauge with the registerer. Its value is 1, and it is
+// labeled with the version and git commit of the operator and the Go version it was built with, to correlate the
+// behavior of a cluster with the version of the operator.
+func RegisterBuildInfo(registerer prometheus.Registerer, version, gitCommit string) error {
+	buildInfo := prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "certificate_operator_build_info",
+			Help: "Build information of the operator, with a value of 1.",
+			ConstLabels: prometheus.Labels{
+				"version":    version,
+				"git_commit": gitCommit,
+				"go_version": runtime.Version(),
+			},
+		},
+	)
+	buildInfo.Set(1)
+
+	return registerer.Register(buildInfo)
+}
+
+// RecordSecretOperation increments the counter of the given Secret operation.
+func RecordSecretOperation(operation string) {
+	SecretOperations.WithLabelValues(operation).Inc()
+}
+
+// SetCertificateError marks the Certificate with the given key as being in an error state or not,
+// and updates the CertificatesInError gauge accordingly.
+func SetCertificateError(key string, inError bool) {
+	erroredMu.Lock()
+	defer erroredMu.Unlock()
+
+	if inError {
+		erroredCertificates[key] = struct{}{}
+	} else {
+		delete(erroredCertificates, key)
+	}
+
+	CertificatesInError.Set(float64(len(erroredCertificates)))
+}
+
+// SetCertificateExpiry sets the expiry time of the certificate of the Certificate with the given namespace and name.
+func SetCertificateExpiry(namespace, name string, validTo time.Time) {
+	CertificateExpiry.WithLabelValues(namespace, name).Set(float64(validTo.Unix()))
+}
+
+// DeleteCertificateExpiry removes the expiry time of the Certificate with the given namespace and name, once it is
+// deleted.
+func DeleteCertificateExpiry(namespace, name string) {
+	CertificateExpiry.DeleteLabelValues(namespace, name)
+}
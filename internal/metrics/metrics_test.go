@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func Test_RecordSecretOperation(t *testing.T) {
+	before := metricValue(t, SecretOperations.WithLabelValues(OperationCreate))
+
+	RecordSecretOperation(OperationCreate)
+	RecordSecretOperation(OperationCreate)
+
+	if diff := cmp.Diff(before+2, metricValue(t, SecretOperations.WithLabelValues(OperationCreate))); diff != "" {
+		t.Fatalf("RecordSecretOperation(...): -want count, +got count: %v", diff)
+	}
+}
+
+func Test_SetCertificateError(t *testing.T) {
+	type args struct {
+		key     string
+		inError bool
+	}
+	type want struct {
+		gauge float64
+	}
+	steps := []struct {
+		name string
+		args args
+		want want
+	}{
+		{name: "ShouldCountErroredCertificate", args: args{key: "default/first", inError: true}, want: want{gauge: 1}},
+		{name: "ShouldNotCountCertificateTwice", args: args{key: "default/first", inError: true}, want: want{gauge: 1}},
+		{name: "ShouldCountAnotherCertificate", args: args{key: "default/second", inError: true}, want: want{gauge: 2}},
+		{name: "ShouldUncountRecoveredCertificate", args: args{key: "default/first", inError: false}, want: want{gauge: 1}},
+		{name: "ShouldIgnoreUnknownCertificate", args: args{key: "default/third", inError: false}, want: want{gauge: 1}},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			SetCertificateError(step.args.key, step.args.inError)
+			if diff := cmp.Diff(step.want.gauge, metricValue(t, CertificatesInError)); diff != "" {
+				t.Fatalf("SetCertificateError(...): -want gauge, +got gauge: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_CertificateExpiry(t *testing.T) {
+	validTo := time.Date(2025, 4, 18, 9, 5, 22, 0, time.UTC)
+
+	SetCertificateExpiry("default", "expiring", validTo)
+	if diff := cmp.Diff(float64(validTo.Unix()), metricValue(t, CertificateExpiry.WithLabelValues("default", "expiring"))); diff != "" {
+		t.Fatalf("SetCertificateExpiry(...): -want gauge, +got gauge: %v", diff)
+	}
+
+	DeleteCertificateExpiry("default", "expiring")
+	if CertificateExpiry.DeleteLabelValues("default", "expiring") {
+		t.Fatalf("DeleteCertificateExpiry(...): expected the gauge of the Certificate to be removed")
+	}
+}
+
+// metricValue returns the current value of a counter or gauge.
+func metricValue(t *testing.T, metric prometheus.Metric) float64 {
+	t.Helper()
+
+	m := &dto.Metric{}
+	if err := metric.Write(m); err != nil {
+		t.Fatalf("Write(...): unexpected error: %v", err)
+	}
+
+	if m.Counter != nil {
+		return m.GetCounter().GetValue()
+	}
+
+	return m.GetGauge().GetValue()
+}
+
+func Test_RegisterBuildInfo(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	if err := RegisterBuildInfo(registry, "v1.2.3", "abc123"); err != nil {
+		t.Fatalf("RegisterBuildInfo(...): unexpected error: %v", err)
+	}
+
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather(): unexpected error: %v", err)
+	}
+	if len(families) != 1 || families[0].GetName() != "certificate_operator_build_info" || len(families[0].GetMetric()) != 1 {
+		t.Fatalf("RegisterBuildInfo(...): expected a single certificate_operator_build_info metric, got %v", families)
+	}
+
+	metric := families[0].GetMetric()[0]
+	labels := map[string]string{}
+	for _, label := range metric.GetLabel() {
+		labels[label.GetName()] = label.GetValue()
+	}
+	want := map[string]string{"version": "v1.2.3", "git_commit": "abc123", "go_version": runtime.Version()}
+	if diff := cmp.Diff(want, labels); diff != "" {
+		t.Fatalf("RegisterBuildInfo(...): -want labels, +got labels: %v", diff)
+	}
+
+	if diff := cmp.Diff(float64(1), metric.GetGauge().GetValue()); diff != "" {
+		t.Fatalf("RegisterBuildInfo(...): -want value, +got value: %v", diff)
+	}
+
+	if err := RegisterBuildInfo(registry, "v1.2.3", "abc123"); err == nil {
+		t.Fatalf("RegisterBuildInfo(...): expected registering the build info twice to fail")
+	}
+}
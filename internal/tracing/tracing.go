@@ -0,0 +1,83 @@
+// Package tracing instruments the reconciles of the operator and its requests to the Cert API with OpenTelemetry
+// spans. Spans are started with the global tracer provider, and are not recorded unless one is set, for instance
+// with SetupTracerProvider.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// AttributeCertificate is the attribute holding the namespaced name of the Certificate of a span.
+	AttributeCertificate = "certificate"
+	// AttributeConfig is the attribute holding the name of the CertificateConfig of a span.
+	AttributeConfig = "certificate.config"
+	// AttributeStatusCode is the attribute holding the status code of the response to an HTTP request.
+	AttributeStatusCode = "http.status_code"
+	// AttributeMethod is the attribute holding the method of an HTTP request.
+	AttributeMethod = "http.method"
+)
+
+const (
+	// tracerName is the name of the tracer starting the spans of the operator.
+	tracerName = "github.com/dana-team/certificate-operator"
+	// serviceName is the name of the service the spans of the operator are exported under.
+	serviceName = "certificate-operator"
+)
+
+const errCreatingExporter = "failed to create the OTLP trace exporter: %w"
+
+// String returns an attribute with a string value.
+func String(key, value string) attribute.KeyValue {
+	return attribute.String(key, value)
+}
+
+// Int returns an attribute with an int value.
+func Int(key string, value int) attribute.KeyValue {
+	return attribute.Int(key, value)
+}
+
+// Start starts a span with the name and attributes, as a child of the span in the context if there is one, and
+// returns a context holding the span.
+func Start(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// End records the error pointed to by err on the span, if any, and ends the span. It is meant to be deferred with
+// the named error result of the traced function.
+func End(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// SetupTracerProvider sets the global tracer provider to one batching the spans of the operator to the OTLP/HTTP
+// collector at the endpoint URL, and returns the function shutting it down, which flushes the remaining spans.
+func SetupTracerProvider(ctx context.Context, endpoint string) (func(context.Context) error, error) {
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint))
+	if err != nil {
+		return nil, fmt.Errorf(errCreatingExporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(serviceName))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	return provider.Shutdown, nil
+}
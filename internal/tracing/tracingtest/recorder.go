@@ -0,0 +1,80 @@
+// Package tracingtest provides an in-memory tracer provider recording the spans of the operator, for tests.
+package tracingtest
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// RecordedSpan is a span recorded by a Recorder.
+type RecordedSpan struct {
+	// Name is the name of the span.
+	Name string
+	// Parent is the name of the parent span, or empty for a root span.
+	Parent string
+	// Attributes are the attributes set on the span.
+	Attributes map[string]any
+	// Err is the description of the error status of the span, or empty if the span did not fail.
+	Err string
+	// Ended is whether the span was ended.
+	Ended bool
+}
+
+// Recorder records the spans started with the global tracer provider in memory.
+type Recorder struct {
+	spans *tracetest.SpanRecorder
+}
+
+// NewRecorder returns a Recorder of the spans started with the global tracer provider, which it sets. The returned
+// function resets the global tracer provider, so that spans are no longer recorded.
+func NewRecorder() (*Recorder, func()) {
+	recorder := &Recorder{spans: tracetest.NewSpanRecorder()}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder.spans)))
+
+	return recorder, func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+	}
+}
+
+// Spans returns the spans recorded so far, in the order in which they were started.
+func (r *Recorder) Spans() []RecordedSpan {
+	started := r.spans.Started()
+
+	names := make(map[trace.SpanID]string, len(started))
+	for _, span := range started {
+		names[span.SpanContext().SpanID()] = span.Name()
+	}
+
+	spans := make([]RecordedSpan, 0, len(started))
+	for _, span := range started {
+		recorded := RecordedSpan{
+			Name:       span.Name(),
+			Attributes: attributes(span.Attributes()),
+			Ended:      !span.EndTime().IsZero(),
+		}
+		if span.Parent().IsValid() {
+			recorded.Parent = names[span.Parent().SpanID()]
+		}
+		if span.Status().Code == codes.Error {
+			recorded.Err = span.Status().Description
+		}
+		spans = append(spans, recorded)
+	}
+
+	return spans
+}
+
+// attributes returns the values of the attributes by their keys.
+func attributes(keyValues []attribute.KeyValue) map[string]any {
+	values := make(map[string]any, len(keyValues))
+	for _, keyValue := range keyValues {
+		values[string(keyValue.Key)] = keyValue.Value.AsInterface()
+	}
+
+	return values
+}
@@ -0,0 +1,184 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"slices"
+
+	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/certhandler"
+	"github.com/dana-team/certificate-operator/internal/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	errUnexpectedObject     = "expected a Certificate but got %T"
+	errGetCertificateConfig = "failed to get CertificateConfig %q: %v"
+	errTemplateNotAllowed   = "template %q is not allowed in namespace %q, allowed templates are %v"
+	errInvalidKeySize       = "keySize %d is not allowed for RSA keys, allowed sizes are %v"
+	errInvalidKeyCurve      = "keyCurve %q is not allowed for ECDSA keys, allowed curves are %v"
+	errKeySizeNotAllowed    = "keySize %d can only be set for RSA keys, got keyAlgorithm %q"
+	errKeyCurveNotAllowed   = "keyCurve %q can only be set for ECDSA keys, got keyAlgorithm %q"
+	errInvalidEmail         = "subject email %q is not a valid email address"
+	errReservedCAKey        = "caKey %q collides with the certificate, private key or combined PEM key of the secret"
+)
+
+var (
+	reservedSecretKeys    = []string{corev1.TLSCertKey, corev1.TLSPrivateKeyKey, certhandler.KeyCombinedPEM}
+	allowedRSAKeySizes    = []int{2048, 3072, 4096}
+	allowedECDSAKeyCurves = []string{"P-256", "P-384"}
+)
+
+//+kubebuilder:webhook:path=/validate-cert-dana-io-v1alpha1-certificate,mutating=false,failurePolicy=fail,sideEffects=None,groups=cert.dana.io,resources=certificates,verbs=create;update,versions=v1alpha1,name=vcertificate.kb.io,admissionReviewVersions=v1
+//+kubebuilder:rbac:groups="",resources=namespaces,verbs=get;list;watch
+
+// CertificateValidator validates Certificates against the template policies of their CertificateConfig.
+type CertificateValidator struct {
+	Client client.Client
+}
+
+// SetupWebhookWithManager sets up the Certificate validating webhook with the Manager.
+func (v *CertificateValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&v1alpha1.Certificate{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate validates the key, subject email, CA key and template of a created Certificate.
+func (v *CertificateValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, v.validate(ctx, obj)
+}
+
+// ValidateUpdate validates the key, subject email, CA key and template of an updated Certificate.
+// Updates of Certificates being deleted, or which do not change the spec, are allowed without validation, so that the
+// operator can still update the metadata of Certificates whose CertificateConfig was deleted or whose template is no
+// longer allowed, such as when removing their finalizers.
+func (v *CertificateValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldCertificate, ok := oldObj.(*v1alpha1.Certificate)
+	if !ok {
+		return nil, fmt.Errorf(errUnexpectedObject, oldObj)
+	}
+
+	newCertificate, ok := newObj.(*v1alpha1.Certificate)
+	if !ok {
+		return nil, fmt.Errorf(errUnexpectedObject, newObj)
+	}
+
+	if !newCertificate.DeletionTimestamp.IsZero() || equality.Semantic.DeepEqual(oldCertificate.Spec, newCertificate.Spec) {
+		return nil, nil
+	}
+
+	return nil, v.validate(ctx, newCertificate)
+}
+
+// ValidateDelete allows every Certificate deletion.
+func (v *CertificateValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate validates the key, subject email, CA key and template of the Certificate.
+func (v *CertificateValidator) validate(ctx context.Context, obj runtime.Object) error {
+	certificate, ok := obj.(*v1alpha1.Certificate)
+	if !ok {
+		return fmt.Errorf(errUnexpectedObject, obj)
+	}
+
+	if err := validateKey(certificate.Spec.CertificateData); err != nil {
+		return err
+	}
+
+	if err := validateEmail(certificate.Spec.CertificateData.Subject.Email); err != nil {
+		return err
+	}
+
+	if err := validateCAKey(certificate.Spec.CAKey); err != nil {
+		return err
+	}
+
+	return v.validateTemplate(ctx, certificate)
+}
+
+// validateKey checks that the key size or curve requested by the Certificate is allowed for its key algorithm.
+// A size or curve without a key algorithm is rejected, since the key algorithm chosen by the CA is not known.
+func validateKey(certificateData v1alpha1.CertificateData) error {
+	if certificateData.KeySize != 0 && certificateData.KeyAlgorithm != v1alpha1.KeyAlgorithmRSA {
+		return fmt.Errorf(errKeySizeNotAllowed, certificateData.KeySize, certificateData.KeyAlgorithm)
+	}
+
+	if certificateData.KeyCurve != "" && certificateData.KeyAlgorithm != v1alpha1.KeyAlgorithmECDSA {
+		return fmt.Errorf(errKeyCurveNotAllowed, certificateData.KeyCurve, certificateData.KeyAlgorithm)
+	}
+
+	if certificateData.KeySize != 0 && !slices.Contains(allowedRSAKeySizes, certificateData.KeySize) {
+		return fmt.Errorf(errInvalidKeySize, certificateData.KeySize, allowedRSAKeySizes)
+	}
+
+	if certificateData.KeyCurve != "" && !slices.Contains(allowedECDSAKeyCurves, certificateData.KeyCurve) {
+		return fmt.Errorf(errInvalidKeyCurve, certificateData.KeyCurve, allowedECDSAKeyCurves)
+	}
+
+	return nil
+}
+
+// validateEmail checks that the subject email, if set, is a bare email address, without a display name.
+func validateEmail(email string) error {
+	if email == "" {
+		return nil
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Address != email {
+		return fmt.Errorf(errInvalidEmail, email)
+	}
+
+	return nil
+}
+
+// validateCAKey checks that the CA key does not collide with the keys of the certificate, private key or combined PEM
+// in the secret.
+func validateCAKey(caKey string) error {
+	if slices.Contains(reservedSecretKeys, caKey) {
+		return fmt.Errorf(errReservedCAKey, caKey)
+	}
+
+	return nil
+}
+
+// validateTemplate checks that the template requested by the Certificate is allowed in its namespace.
+// Certificates which reference no CertificateConfig while no default is set are rejected. Certificates whose
+// CertificateConfig does not exist yet are allowed, since the controller waits for it to be created and checks the
+// template policies again before requesting a certificate.
+func (v *CertificateValidator) validateTemplate(ctx context.Context, certificate *v1alpha1.Certificate) error {
+	configName, err := common.ConfigName(ctx, v.Client, certificate)
+	if err != nil {
+		return err
+	}
+
+	certificateConfig := &v1alpha1.CertificateConfig{}
+	if err := v.Client.Get(ctx, client.ObjectKey{Name: configName}, certificateConfig); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf(errGetCertificateConfig, configName, err)
+	}
+
+	allowedTemplates, restricted, err := common.AllowedTemplates(ctx, v.Client, certificateConfig, certificate.Namespace)
+	if err != nil {
+		return err
+	}
+
+	template := certificate.Spec.CertificateData.Template
+	if restricted && !slices.Contains(allowedTemplates, template) {
+		return fmt.Errorf(errTemplateNotAllowed, template, certificate.Namespace, allowedTemplates)
+	}
+
+	return nil
+}
@@ -0,0 +1,454 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/crossplane/crossplane-runtime/pkg/test"
+	v1alpha1 "github.com/dana-team/certificate-operator/api/v1alpha1"
+	"github.com/dana-team/certificate-operator/internal/common"
+	"github.com/google/go-cmp/cmp"
+	corev1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	configName = "certificateconfig-sample"
+	namespace  = "team-a"
+)
+
+var errBoom = errors.New("boom")
+
+func newCertificate(template string) *v1alpha1.Certificate {
+	return &v1alpha1.Certificate{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-cert",
+			Namespace: namespace,
+		},
+		Spec: v1alpha1.CertificateSpec{
+			CertificateData: v1alpha1.CertificateData{
+				Template: template,
+			},
+			ConfigRef: v1alpha1.ConfigReference{
+				Name: configName,
+			},
+		},
+	}
+}
+
+func newMockClient(policies []v1alpha1.TemplatePolicy, namespaceLabels map[string]string) client.Client {
+	return &test.MockClient{
+		MockGet: func(_ context.Context, _ client.ObjectKey, obj client.Object) error {
+			switch o := obj.(type) {
+			case *v1alpha1.CertificateConfig:
+				o.Name = configName
+				o.Spec.TemplatePolicies = policies
+			case *corev1.Namespace:
+				o.Name = namespace
+				o.Labels = namespaceLabels
+			default:
+				return errors.New("unexpected object")
+			}
+			return nil
+		},
+	}
+}
+
+func Test_ValidateCreate(t *testing.T) {
+	type args struct {
+		kube        client.Client
+		certificate *v1alpha1.Certificate
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldAllowTemplateListedForNamespace": {
+			args: args{
+				kube: newMockClient([]v1alpha1.TemplatePolicy{
+					{Namespaces: []string{namespace}, AllowedTemplates: []string{"default", "web"}},
+				}, nil),
+				certificate: newCertificate("web"),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldAllowTemplateListedForSelectedNamespace": {
+			args: args{
+				kube: newMockClient([]v1alpha1.TemplatePolicy{
+					{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "a"}}, AllowedTemplates: []string{"web"}},
+				}, map[string]string{"team": "a"}),
+				certificate: newCertificate("web"),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldRejectDisallowedTemplate": {
+			args: args{
+				kube: newMockClient([]v1alpha1.TemplatePolicy{
+					{Namespaces: []string{namespace}, AllowedTemplates: []string{"default"}},
+				}, nil),
+				certificate: newCertificate("admin"),
+			},
+			want: want{
+				err: fmt.Errorf(errTemplateNotAllowed, "admin", namespace, []string{"default"}),
+			},
+		},
+		"ShouldAllowAnyTemplateInUnrestrictedNamespace": {
+			args: args{
+				kube: newMockClient([]v1alpha1.TemplatePolicy{
+					{Namespaces: []string{"team-b"}, AllowedTemplates: []string{"default"}},
+					{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"team": "b"}}, AllowedTemplates: []string{"default"}},
+				}, map[string]string{"team": "a"}),
+				certificate: newCertificate("admin"),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldAllowWhenCertificateConfigIsMissing": {
+			args: args{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(kerrors.NewNotFound(v1alpha1.GroupVersion.WithResource("certificateconfigs").GroupResource(), configName)),
+				},
+				certificate: newCertificate("admin"),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldRejectWhenNoDefaultCertificateConfigIsSet": {
+			args: args{
+				kube: &test.MockClient{
+					MockGet:  test.NewMockGetFn(nil),
+					MockList: test.NewMockListFn(nil),
+				},
+				certificate: func() *v1alpha1.Certificate {
+					c := newCertificate("admin")
+					c.Spec.ConfigRef = v1alpha1.ConfigReference{}
+					return c
+				}(),
+			},
+			want: want{
+				err: common.ErrNoDefaultConfig,
+			},
+		},
+		"ShouldRejectInvalidKeyBeforeTemplate": {
+			args: args{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(errBoom),
+				},
+				certificate: func() *v1alpha1.Certificate {
+					c := newCertificate("admin")
+					c.Spec.CertificateData.KeyAlgorithm = v1alpha1.KeyAlgorithmRSA
+					c.Spec.CertificateData.KeySize = 1024
+					return c
+				}(),
+			},
+			want: want{
+				err: fmt.Errorf(errInvalidKeySize, 1024, allowedRSAKeySizes),
+			},
+		},
+		"ShouldFailGettingCertificateConfig": {
+			args: args{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(errBoom),
+				},
+				certificate: newCertificate("admin"),
+			},
+			want: want{
+				err: fmt.Errorf(errGetCertificateConfig, configName, errBoom),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := &CertificateValidator{Client: tc.args.kube}
+			_, gotErr := v.ValidateCreate(context.Background(), tc.args.certificate)
+			if diff := cmp.Diff(tc.want.err, gotErr, test.EquateErrors()); diff != "" {
+				t.Fatalf("ValidateCreate(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_ValidateUpdate(t *testing.T) {
+	type args struct {
+		kube           client.Client
+		oldCertificate *v1alpha1.Certificate
+		newCertificate *v1alpha1.Certificate
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldRejectDisallowedTemplateWhenSpecChanges": {
+			args: args{
+				kube: newMockClient([]v1alpha1.TemplatePolicy{
+					{Namespaces: []string{namespace}, AllowedTemplates: []string{"default"}},
+				}, nil),
+				oldCertificate: newCertificate("default"),
+				newCertificate: newCertificate("admin"),
+			},
+			want: want{
+				err: fmt.Errorf(errTemplateNotAllowed, "admin", namespace, []string{"default"}),
+			},
+		},
+		"ShouldAllowMetadataUpdateWithUnchangedSpec": {
+			args: args{
+				kube: newMockClient([]v1alpha1.TemplatePolicy{
+					{Namespaces: []string{namespace}, AllowedTemplates: []string{"default"}},
+				}, nil),
+				oldCertificate: newCertificate("admin"),
+				newCertificate: func() *v1alpha1.Certificate {
+					c := newCertificate("admin")
+					c.Annotations = map[string]string{"example.com/key": "value"}
+					return c
+				}(),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldAllowUpdateOfDeletedCertificate": {
+			args: args{
+				kube: &test.MockClient{
+					MockGet: test.NewMockGetFn(errBoom),
+				},
+				oldCertificate: newCertificate("admin"),
+				newCertificate: func() *v1alpha1.Certificate {
+					c := newCertificate("web")
+					now := metav1.Now()
+					c.DeletionTimestamp = &now
+					return c
+				}(),
+			},
+			want: want{
+				err: nil,
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			v := &CertificateValidator{Client: tc.args.kube}
+			_, gotErr := v.ValidateUpdate(context.Background(), tc.args.oldCertificate, tc.args.newCertificate)
+			if diff := cmp.Diff(tc.want.err, gotErr, test.EquateErrors()); diff != "" {
+				t.Fatalf("ValidateUpdate(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_validateKey(t *testing.T) {
+	type args struct {
+		certificateData v1alpha1.CertificateData
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldAllowCADefault": {
+			args: args{
+				certificateData: v1alpha1.CertificateData{},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldAllowRSAWithoutSize": {
+			args: args{
+				certificateData: v1alpha1.CertificateData{KeyAlgorithm: v1alpha1.KeyAlgorithmRSA},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldAllowRSAWithAllowedSize": {
+			args: args{
+				certificateData: v1alpha1.CertificateData{KeyAlgorithm: v1alpha1.KeyAlgorithmRSA, KeySize: 3072},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldRejectRSAWithDisallowedSize": {
+			args: args{
+				certificateData: v1alpha1.CertificateData{KeyAlgorithm: v1alpha1.KeyAlgorithmRSA, KeySize: 1024},
+			},
+			want: want{
+				err: fmt.Errorf(errInvalidKeySize, 1024, allowedRSAKeySizes),
+			},
+		},
+		"ShouldRejectRSAWithCurve": {
+			args: args{
+				certificateData: v1alpha1.CertificateData{KeyAlgorithm: v1alpha1.KeyAlgorithmRSA, KeyCurve: "P-256"},
+			},
+			want: want{
+				err: fmt.Errorf(errKeyCurveNotAllowed, "P-256", v1alpha1.KeyAlgorithmRSA),
+			},
+		},
+		"ShouldAllowECDSAWithAllowedCurve": {
+			args: args{
+				certificateData: v1alpha1.CertificateData{KeyAlgorithm: v1alpha1.KeyAlgorithmECDSA, KeyCurve: "P-384"},
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldRejectECDSAWithDisallowedCurve": {
+			args: args{
+				certificateData: v1alpha1.CertificateData{KeyAlgorithm: v1alpha1.KeyAlgorithmECDSA, KeyCurve: "P-521"},
+			},
+			want: want{
+				err: fmt.Errorf(errInvalidKeyCurve, "P-521", allowedECDSAKeyCurves),
+			},
+		},
+		"ShouldRejectECDSAWithSize": {
+			args: args{
+				certificateData: v1alpha1.CertificateData{KeyAlgorithm: v1alpha1.KeyAlgorithmECDSA, KeySize: 2048},
+			},
+			want: want{
+				err: fmt.Errorf(errKeySizeNotAllowed, 2048, v1alpha1.KeyAlgorithmECDSA),
+			},
+		},
+		"ShouldRejectSizeWithoutAlgorithm": {
+			args: args{
+				certificateData: v1alpha1.CertificateData{KeySize: 2048},
+			},
+			want: want{
+				err: fmt.Errorf(errKeySizeNotAllowed, 2048, ""),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gotErr := validateKey(tc.args.certificateData)
+			if diff := cmp.Diff(tc.want.err, gotErr, test.EquateErrors()); diff != "" {
+				t.Fatalf("validateKey(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_validateEmail(t *testing.T) {
+	type args struct {
+		email string
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldAllowEmptyEmail": {
+			args: args{
+				email: "",
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldAllowValidEmail": {
+			args: args{
+				email: "admin@example.com",
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldRejectEmailWithoutDomain": {
+			args: args{
+				email: "admin",
+			},
+			want: want{
+				err: fmt.Errorf(errInvalidEmail, "admin"),
+			},
+		},
+		"ShouldRejectEmailWithDisplayName": {
+			args: args{
+				email: "Admin <admin@example.com>",
+			},
+			want: want{
+				err: fmt.Errorf(errInvalidEmail, "Admin <admin@example.com>"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gotErr := validateEmail(tc.args.email)
+			if diff := cmp.Diff(tc.want.err, gotErr, test.EquateErrors()); diff != "" {
+				t.Fatalf("validateEmail(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
+
+func Test_validateCAKey(t *testing.T) {
+	type args struct {
+		caKey string
+	}
+	type want struct {
+		err error
+	}
+	cases := map[string]struct {
+		args args
+		want want
+	}{
+		"ShouldAllowDefaultCAKey": {
+			args: args{
+				caKey: "",
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldAllowCustomCAKey": {
+			args: args{
+				caKey: "chain.pem",
+			},
+			want: want{
+				err: nil,
+			},
+		},
+		"ShouldRejectCertificateKey": {
+			args: args{
+				caKey: "tls.crt",
+			},
+			want: want{
+				err: fmt.Errorf(errReservedCAKey, "tls.crt"),
+			},
+		},
+		"ShouldRejectPrivateKeyKey": {
+			args: args{
+				caKey: "tls.key",
+			},
+			want: want{
+				err: fmt.Errorf(errReservedCAKey, "tls.key"),
+			},
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			gotErr := validateCAKey(tc.args.caKey)
+			if diff := cmp.Diff(tc.want.err, gotErr, test.EquateErrors()); diff != "" {
+				t.Fatalf("validateCAKey(...): -want error, +got error: %v", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+// Package pkcs12provider registers the PKCS#12 implementation used by the operator to decode the certificates
+// downloaded from the Cert API and to encode the PKCS#12 and trust store secrets. Builds with the fips tag have no
+// default implementation, and must register a FIPS-approved one before the manager starts, for example from the
+// init function of a file of the operator command built with the fips tag.
+package pkcs12provider
+
+import (
+	"github.com/dana-team/certificate-operator/internal/certhandler"
+)
+
+// Decoder decodes PKCS#12 data into a private key, a leaf certificate and the CA certificates.
+type Decoder = certhandler.PKCS12Decoder
+
+// Encoder encodes certificates and private keys to PKCS#12 data.
+type Encoder = certhandler.PKCS12Encoder
+
+// SetDecoder replaces the PKCS#12 implementation decoding the certificates downloaded from the Cert API.
+func SetDecoder(decoder Decoder) {
+	certhandler.SetPKCS12Decoder(decoder)
+}
+
+// SetEncoder replaces the PKCS#12 implementation encoding the PKCS#12 and trust store secrets.
+func SetEncoder(encoder Encoder) {
+	certhandler.SetPKCS12Encoder(encoder)
+}